@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/Roll-Play/togglelabs/pkg/api"
+	grpcserver "github.com/Roll-Play/togglelabs/pkg/api/grpc_server"
 	"github.com/Roll-Play/togglelabs/pkg/config"
 	"github.com/Roll-Play/togglelabs/pkg/logger"
 	"github.com/Roll-Play/togglelabs/pkg/storage"
@@ -17,6 +18,27 @@ func main() {
 	}
 
 	config.StartEnvironment()
+	if err := config.StartJWT(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartCache(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartCORS(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartTrustedProxies(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartPasswordPolicy(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartPurge(); err != nil {
+		log.Panic(err)
+	}
+	if err := config.StartWebhooks(); err != nil {
+		log.Panic(err)
+	}
 
 	storage, err := storage.GetInstance()
 	if err != nil {
@@ -34,5 +56,12 @@ func main() {
 
 	app := api.NewApp(os.Getenv("PORT"), storage, logger)
 
+	// The gRPC evaluation service only runs when given a port of its own
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		go func() {
+			log.Panic(grpcserver.Listen(grpcPort, grpcserver.NewServer(storage.DB(), logger)))
+		}()
+	}
+
 	log.Panic(app.Listen())
 }
@@ -8,6 +8,8 @@ type AuthResponse struct {
 	FirstName string             `json:"first_name,omitempty" `
 	LastName  string             `json:"last_name,omitempty" `
 	Token     string             `json:"token"`
+	// RefreshToken is exchanged at /auth/refresh for a new token
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type Tuple[T comparable, U comparable] struct {
@@ -21,3 +23,74 @@ func NewTuple[T comparable, U comparable](first T, second U) Tuple[T, U] {
 		Second: second,
 	}
 }
+
+// PaginatedResponse is the envelope of every paginated list. TotalPages and
+// HasNext are derived from Total so clients don't have to, and PageSize is
+// the size actually served, which can be smaller than the one requested.
+type PaginatedResponse[T any] struct {
+	Page       int  `json:"page"`
+	PageSize   int  `json:"page_size"`
+	Total      int  `json:"total"`
+	TotalPages int  `json:"total_pages"`
+	HasNext    bool `json:"has_next"`
+	Data       []T  `json:"data"`
+}
+
+func NewPaginatedResponse[T any](data []T, page, pageSize, total int) PaginatedResponse[T] {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = (total + pageSize - 1) / pageSize
+	}
+
+	if data == nil {
+		data = []T{}
+	}
+
+	return PaginatedResponse[T]{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		Data:       data,
+	}
+}
+
+// CursorPaginatedResponse is the envelope of the lists paged through with a
+// cursor rather than a page number. NextCursor is sent back as the cursor to
+// get the following page, it's empty on the last one.
+type CursorPaginatedResponse[T any] struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	Data       []T    `json:"data"`
+}
+
+func NewCursorPaginatedResponse[T any](data []T, pageSize int, nextCursor string) CursorPaginatedResponse[T] {
+	if data == nil {
+		data = []T{}
+	}
+
+	return CursorPaginatedResponse[T]{
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+		HasNext:    nextCursor != "",
+		Data:       data,
+	}
+}
+
+// Paginate serves a page of items that are already all in memory
+func Paginate[T any](items []T, page, pageSize int) PaginatedResponse[T] {
+	total := len(items)
+
+	start := (page - 1) * pageSize
+	if start < 0 || start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end < start || end > total {
+		end = total
+	}
+
+	return NewPaginatedResponse(items[start:end], page, pageSize, total)
+}
@@ -0,0 +1,47 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginateLastPage(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	response := common.Paginate(items, 2, 2)
+	assert.Equal(t, []int{3, 4}, response.Data)
+	assert.Equal(t, 5, response.Total)
+	assert.Equal(t, 3, response.TotalPages)
+	assert.True(t, response.HasNext)
+
+	response = common.Paginate(items, 3, 2)
+	assert.Equal(t, []int{5}, response.Data)
+	assert.False(t, response.HasNext)
+
+	response = common.Paginate(items, 4, 2)
+	assert.Equal(t, []int{}, response.Data)
+	assert.False(t, response.HasNext)
+}
+
+func TestNewPaginatedResponseExactPages(t *testing.T) {
+	response := common.NewPaginatedResponse([]string{"c", "d"}, 2, 2, 4)
+	assert.Equal(t, 2, response.TotalPages)
+	assert.False(t, response.HasNext)
+
+	response = common.NewPaginatedResponse[string](nil, 1, 10, 0)
+	assert.Equal(t, []string{}, response.Data)
+	assert.Equal(t, 0, response.TotalPages)
+	assert.False(t, response.HasNext)
+}
+
+func TestNewCursorPaginatedResponse(t *testing.T) {
+	response := common.NewCursorPaginatedResponse([]string{"a", "b"}, 2, "next")
+	assert.Equal(t, "next", response.NextCursor)
+	assert.True(t, response.HasNext)
+
+	response = common.NewCursorPaginatedResponse[string](nil, 2, "")
+	assert.Equal(t, []string{}, response.Data)
+	assert.False(t, response.HasNext)
+}
@@ -9,12 +9,65 @@ import (
 type ErrorMessage = string
 
 const (
-	NotFoundError       ErrorMessage = "record not found"
-	InternalServerError ErrorMessage = "internal server error"
-	EmailConflictError  ErrorMessage = "email already in use"
-	UnauthorizedError   ErrorMessage = "user lacks valid authentication credentials"
-	BadRequestError     ErrorMessage = "malformed request"
-	ForbiddenError      ErrorMessage = "forbidden action"
+	NotFoundError             ErrorMessage = "record not found"
+	InternalServerError       ErrorMessage = "internal server error"
+	EmailConflictError        ErrorMessage = "email already in use"
+	UnauthorizedError         ErrorMessage = "user lacks valid authentication credentials"
+	BadRequestError           ErrorMessage = "malformed request"
+	ForbiddenError            ErrorMessage = "forbidden action"
+	NameConflictError         ErrorMessage = "name already in use"
+	RevisionNotDraftError     ErrorMessage = "revision is not a draft"
+	AlreadyApprovedError      ErrorMessage = "revision already approved by user"
+	SelfApprovalError         ErrorMessage = "revision author cannot approve it"
+	DuplicateFeatureFlagError ErrorMessage = "a change set takes one revision per feature flag"
+	NothingToRollbackError    ErrorMessage = "nothing to roll back to"
+	EnvironmentNotFoundError  ErrorMessage = "environment not found"
+	EnvironmentConflictError  ErrorMessage = "environment already exists"
+	LastEnvironmentError      ErrorMessage = "cannot delete the last environment of a feature flag"
+	UndefinedEnvironmentError ErrorMessage = "environment not defined on organization"
+	MissingEnvironmentError   ErrorMessage = "environment is required without a default environment"
+	MemberConflictError       ErrorMessage = "user is already a member"
+	LastAdminError            ErrorMessage = "organization must keep at least one admin"
+	OwnerChangeError          ErrorMessage = "the owner only changes through an ownership transfer"
+	WeakPasswordError         ErrorMessage = "password too weak"
+	TooManyRequestsError      ErrorMessage = "too many requests"
+	InvalidCursorError        ErrorMessage = "cursor is not valid"
+	InvalidValueError         ErrorMessage = "value does not match the flag type"
+	InvalidFlagTypeError      ErrorMessage = "flag type is not supported"
+	// InvalidRuleValueError is formatted with the index of the offending rule
+	InvalidRuleValueError     ErrorMessage = "rule %d value does not match the flag type"
+	PrerequisiteNotFoundError ErrorMessage = "prerequisite feature flag not found"
+	PrerequisiteCycleError    ErrorMessage = "prerequisites would form a cycle"
+	// InvalidOverrideValueError is formatted with the key of the offending
+	// override and why its value is invalid
+	InvalidOverrideValueError ErrorMessage = "override %s %s"
+	ConcurrentUpdateError     ErrorMessage = "feature flag changed concurrently, try again"
+	VersionMismatchError      ErrorMessage = "feature flag version does not match If-Match"
+	IdempotencyKeyReusedError ErrorMessage = "idempotency key already used by a different request"
+	IdempotencyKeyInUseError  ErrorMessage = "idempotency key is in use, try again"
+	BoundsNotNumberError      ErrorMessage = "only number flags can have a min or max"
+	InvalidBoundsError        ErrorMessage = "min cannot be greater than max"
+	// DefaultOutOfBoundsError is formatted with the bound the default value
+	// crosses, RuleOutOfBoundsError with the index of the offending rule too
+	DefaultOutOfBoundsError ErrorMessage = "default %s"
+	RuleOutOfBoundsError    ErrorMessage = "rule %d %s"
+	SchemaNotJSONError      ErrorMessage = "only json flags can have a schema"
+	InvalidSchemaError      ErrorMessage = "schema is not a valid JSON schema"
+	// DefaultSchemaViolationError is formatted with the schema violation,
+	// RuleSchemaViolationError with the index of the offending rule too
+	DefaultSchemaViolationError ErrorMessage = "default %s"
+	RuleSchemaViolationError    ErrorMessage = "rule %d %s"
+	BulkFeatureFlagsError       ErrorMessage = "some feature flags can't be created, none were"
+	// TooManyFeatureFlagsError is formatted with how many flags a bulk
+	// creation takes
+	TooManyFeatureFlagsError ErrorMessage = "a bulk creation takes at most %d feature flags"
+	// QuotaExceededError is formatted with the flag quota of the organization
+	QuotaExceededError ErrorMessage = "organization is limited to %d feature flags"
+	InvalidEmailError  ErrorMessage = "email is not valid"
+	// PasswordTooShortError is formatted with the minimum password length,
+	// PasswordTooFewClassesError with how many character classes it needs
+	PasswordTooShortError      ErrorMessage = "password must be at least %d characters long"
+	PasswordTooFewClassesError ErrorMessage = "password must mix at least %d of lowercase letters, uppercase letters, digits and symbols"
 )
 
 type Error struct {
@@ -0,0 +1,67 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizationMetadataKey carries the API key, with the same scheme as the
+// Authorization header of the REST API
+const AuthorizationMetadataKey = "authorization"
+
+type organizationContextKey struct{}
+
+// APIKeyInterceptor authenticates every call with an organization API key,
+// the organization then comes from the key itself
+func APIKeyInterceptor(db *mongo.Database, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		request interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorization := md.Get(AuthorizationMetadataKey)
+		if len(authorization) == 0 || !strings.HasPrefix(authorization[0], middlewares.APIKeyScheme) {
+			logger.Debug("Client error",
+				zap.Error(errors.New("missing API key")))
+			return nil, status.Error(codes.Unauthenticated, apierrors.UnauthorizedError)
+		}
+
+		key := strings.TrimSpace(strings.TrimPrefix(authorization[0], middlewares.APIKeyScheme))
+		model := organizationmodel.New(db)
+		organization, err := model.FindByAPIKeyHash(ctx, organizationmodel.HashAPIKey(key))
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				logger.Debug("Client error",
+					zap.Error(err))
+				return nil, status.Error(codes.Unauthenticated, apierrors.UnauthorizedError)
+			}
+			logger.Debug("Server error",
+				zap.Error(err))
+			return nil, status.Error(codes.Internal, apierrors.InternalServerError)
+		}
+
+		return handler(context.WithValue(ctx, organizationContextKey{}, organization), request)
+	}
+}
+
+func organizationFromContext(ctx context.Context) (*organizationmodel.OrganizationRecord, error) {
+	organization, ok := ctx.Value(organizationContextKey{}).(*organizationmodel.OrganizationRecord)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, apierrors.UnauthorizedError)
+	}
+
+	return organization, nil
+}
@@ -0,0 +1,152 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: evaluation/v1/evaluation.proto
+
+package evaluationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	EvaluationService_Evaluate_FullMethodName    = "/togglelabs.evaluation.v1.EvaluationService/Evaluate"
+	EvaluationService_EvaluateAll_FullMethodName = "/togglelabs.evaluation.v1.EvaluationService/EvaluateAll"
+)
+
+// EvaluationServiceClient is the client API for EvaluationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EvaluationServiceClient interface {
+	// Evaluate resolves the value a single flag serves to the context
+	Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error)
+	// EvaluateAll resolves every flag of the organization that can be
+	// evaluated in the environment
+	EvaluateAll(ctx context.Context, in *EvaluateAllRequest, opts ...grpc.CallOption) (*EvaluateAllResponse, error)
+}
+
+type evaluationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEvaluationServiceClient(cc grpc.ClientConnInterface) EvaluationServiceClient {
+	return &evaluationServiceClient{cc}
+}
+
+func (c *evaluationServiceClient) Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error) {
+	out := new(EvaluateResponse)
+	err := c.cc.Invoke(ctx, EvaluationService_Evaluate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *evaluationServiceClient) EvaluateAll(ctx context.Context, in *EvaluateAllRequest, opts ...grpc.CallOption) (*EvaluateAllResponse, error) {
+	out := new(EvaluateAllResponse)
+	err := c.cc.Invoke(ctx, EvaluationService_EvaluateAll_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EvaluationServiceServer is the server API for EvaluationService service.
+// All implementations must embed UnimplementedEvaluationServiceServer
+// for forward compatibility
+type EvaluationServiceServer interface {
+	// Evaluate resolves the value a single flag serves to the context
+	Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error)
+	// EvaluateAll resolves every flag of the organization that can be
+	// evaluated in the environment
+	EvaluateAll(context.Context, *EvaluateAllRequest) (*EvaluateAllResponse, error)
+	mustEmbedUnimplementedEvaluationServiceServer()
+}
+
+// UnimplementedEvaluationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedEvaluationServiceServer struct {
+}
+
+func (UnimplementedEvaluationServiceServer) Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Evaluate not implemented")
+}
+func (UnimplementedEvaluationServiceServer) EvaluateAll(context.Context, *EvaluateAllRequest) (*EvaluateAllResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvaluateAll not implemented")
+}
+func (UnimplementedEvaluationServiceServer) mustEmbedUnimplementedEvaluationServiceServer() {}
+
+// UnsafeEvaluationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EvaluationServiceServer will
+// result in compilation errors.
+type UnsafeEvaluationServiceServer interface {
+	mustEmbedUnimplementedEvaluationServiceServer()
+}
+
+func RegisterEvaluationServiceServer(s grpc.ServiceRegistrar, srv EvaluationServiceServer) {
+	s.RegisterService(&EvaluationService_ServiceDesc, srv)
+}
+
+func _EvaluationService_Evaluate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvaluationServiceServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EvaluationService_Evaluate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvaluationServiceServer).Evaluate(ctx, req.(*EvaluateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EvaluationService_EvaluateAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvaluationServiceServer).EvaluateAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EvaluationService_EvaluateAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvaluationServiceServer).EvaluateAll(ctx, req.(*EvaluateAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EvaluationService_ServiceDesc is the grpc.ServiceDesc for EvaluationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EvaluationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "togglelabs.evaluation.v1.EvaluationService",
+	HandlerType: (*EvaluationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Evaluate",
+			Handler:    _EvaluationService_Evaluate_Handler,
+		},
+		{
+			MethodName: "EvaluateAll",
+			Handler:    _EvaluationService_EvaluateAll_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "evaluation/v1/evaluation.proto",
+}
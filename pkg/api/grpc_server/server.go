@@ -0,0 +1,224 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/api/grpc_server/evaluationpb"
+	"github.com/Roll-Play/togglelabs/pkg/evaluator"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// EvaluationServer evaluates flags like the REST evaluation endpoint, for
+// services calling it often enough for JSON over HTTP to show
+type EvaluationServer struct {
+	evaluationpb.UnimplementedEvaluationServiceServer
+	db     *mongo.Database
+	logger *zap.Logger
+}
+
+func NewEvaluationServer(db *mongo.Database, logger *zap.Logger) *EvaluationServer {
+	return &EvaluationServer{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// NewServer returns a gRPC server exposing the evaluation service to API
+// keys
+func NewServer(db *mongo.Database, logger *zap.Logger) *grpc.Server {
+	server := grpc.NewServer(grpc.UnaryInterceptor(APIKeyInterceptor(db, logger)))
+	evaluationpb.RegisterEvaluationServiceServer(server, NewEvaluationServer(db, logger))
+
+	return server
+}
+
+// Listen serves the gRPC server on the port until it stops
+func Listen(port string, server *grpc.Server) error {
+	listener, err := net.Listen("tcp", ":"+strings.TrimPrefix(port, ":"))
+	if err != nil {
+		return err
+	}
+
+	return server.Serve(listener)
+}
+
+func (es *EvaluationServer) Evaluate(
+	ctx context.Context,
+	request *evaluationpb.EvaluateRequest,
+) (*evaluationpb.EvaluateResponse, error) {
+	organization, err := organizationFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(request.GetFeatureFlagId())
+	if err != nil {
+		es.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.InvalidArgument, apierrors.BadRequestError)
+	}
+
+	environment, err := es.resolveEnvironment(organization, request.GetEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	model := featureflagmodel.New(es.db)
+	featureFlagRecord, err := model.FindActiveByID(ctx, organization.ID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			es.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return nil, status.Error(codes.NotFound, apierrors.NotFoundError)
+		}
+		es.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.Internal, apierrors.InternalServerError)
+	}
+
+	value, err := es.evaluate(ctx, model, organization, featureFlagRecord, environment, request.GetContext())
+	if err != nil {
+		if errors.Is(err, evaluator.ErrEnvironmentNotFound) || errors.Is(err, evaluator.ErrNoLiveRevision) {
+			es.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return nil, status.Error(codes.NotFound, apierrors.NotFoundError)
+		}
+		es.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.Internal, apierrors.InternalServerError)
+	}
+
+	return &evaluationpb.EvaluateResponse{Value: value}, nil
+}
+
+// EvaluateAll skips the flags that aren't in the environment or never went
+// live, they have nothing to serve there
+func (es *EvaluationServer) EvaluateAll(
+	ctx context.Context,
+	request *evaluationpb.EvaluateAllRequest,
+) (*evaluationpb.EvaluateAllResponse, error) {
+	organization, err := organizationFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	environment, err := es.resolveEnvironment(organization, request.GetEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	model := featureflagmodel.New(es.db)
+	featureFlagRecords, err := model.FindAll(ctx, organization.ID)
+	if err != nil {
+		es.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.Internal, apierrors.InternalServerError)
+	}
+
+	flags := make([]*evaluationpb.FlagValue, 0, len(featureFlagRecords))
+	for index := range featureFlagRecords {
+		featureFlagRecord := &featureFlagRecords[index]
+		value, err := es.evaluate(ctx, model, organization, featureFlagRecord, environment, request.GetContext())
+		if errors.Is(err, evaluator.ErrEnvironmentNotFound) || errors.Is(err, evaluator.ErrNoLiveRevision) {
+			continue
+		}
+		if err != nil {
+			es.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return nil, status.Error(codes.Internal, apierrors.InternalServerError)
+		}
+
+		flags = append(flags, &evaluationpb.FlagValue{
+			FeatureFlagId: featureFlagRecord.ID.Hex(),
+			Name:          featureFlagRecord.Name,
+			Value:         value,
+		})
+	}
+
+	return &evaluationpb.EvaluateAllResponse{Flags: flags}, nil
+}
+
+func (es *EvaluationServer) resolveEnvironment(
+	organization *organizationmodel.OrganizationRecord,
+	environment string,
+) (string, error) {
+	environment = organization.ResolveEnvironment(environment)
+	if environment == "" {
+		es.logger.Debug("Client error",
+			zap.Error(errors.New(apierrors.MissingEnvironmentError)),
+		)
+		return "", status.Error(codes.InvalidArgument, apierrors.MissingEnvironmentError)
+	}
+
+	return environment, nil
+}
+
+// evaluate serves the value typed after the flag, like the REST endpoint.
+// Organizations in maintenance mode get the default value of every flag.
+func (es *EvaluationServer) evaluate(
+	ctx context.Context,
+	model *featureflagmodel.FeatureFlagModel,
+	organization *organizationmodel.OrganizationRecord,
+	featureFlagRecord *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context *structpb.Struct,
+) (*structpb.Value, error) {
+	var value string
+	var err error
+	if organization.MaintenanceMode {
+		var detail evaluator.Detail
+		detail, err = evaluator.EvaluateMaintenance(featureFlagRecord, environment)
+		value = detail.Value
+	} else {
+		value, err = evaluator.EvaluateWithPrerequisites(
+			featureFlagRecord,
+			environment,
+			evaluator.Context(context.AsMap()),
+			time.Now().UTC(),
+			func(id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+				return model.FindOne(ctx, bson.D{
+					{Key: "_id", Value: id},
+					{Key: "organization_id", Value: featureFlagRecord.OrganizationID},
+					{Key: "deleted_at", Value: bson.M{"$exists": false}},
+				})
+			},
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	typedValue, err := featureFlagRecord.TypedValue(value)
+	if err != nil {
+		// Values stored before they were checked against the flag type are
+		// served as they are
+		es.logger.Warn("Untyped feature flag value",
+			zap.String("_id", featureFlagRecord.ID.Hex()),
+			zap.Error(err),
+		)
+		typedValue = value
+	}
+
+	return structpb.NewValue(typedValue)
+}
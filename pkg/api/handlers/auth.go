@@ -0,0 +1,575 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	emailchangemodel "github.com/Roll-Play/togglelabs/pkg/models/email_change"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	passwordresetmodel "github.com/Roll-Play/togglelabs/pkg/models/password_reset"
+	refreshtokenmodel "github.com/Roll-Play/togglelabs/pkg/models/refresh_token"
+	tokendenylistmodel "github.com/Roll-Play/togglelabs/pkg/models/token_denylist"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+type AuthHandler struct {
+	db     *mongo.Database
+	logger *zap.Logger
+	tokens TokenSender
+}
+
+func NewAuthHandler(db *mongo.Database, logger *zap.Logger) *AuthHandler {
+	return &AuthHandler{
+		db:     db,
+		logger: logger,
+		tokens: DiscardTokenSender{},
+	}
+}
+
+// WithTokenSender sets where password reset tokens are delivered
+func (ah *AuthHandler) WithTokenSender(sender TokenSender) *AuthHandler {
+	ah.tokens = sender
+	return ah
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest optionally carries the refresh token of the session so it
+// can't be used to mint new access tokens
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// issueRefreshToken stores a new refresh token for the user and returns the
+// plain token to hand over to the client
+func issueRefreshToken(db *mongo.Database, userID primitive.ObjectID) (string, error) {
+	record, token, err := refreshtokenmodel.NewRefreshTokenRecord(
+		userID,
+		config.RefreshTokenExpireTime*time.Millisecond,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	model := refreshtokenmodel.New(db)
+	if _, err := model.InsertOne(context.Background(), record); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// PostRefresh exchanges a refresh token for a new access token. Refresh
+// tokens are rotated, the one used is invalidated and a new one returned.
+// Presenting a token that was already used revokes every token of its user
+// since it means the token leaked.
+func (ah *AuthHandler) PostRefresh(c echo.Context) error {
+	request := new(RefreshRequest)
+	if err := c.Bind(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	model := refreshtokenmodel.New(ah.db)
+	hash := refreshtokenmodel.HashToken(request.RefreshToken)
+	record, err := model.Consume(context.Background(), hash)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		usedRecord, err := model.FindByHash(context.Background(), hash)
+		if err == nil {
+			ah.logger.Info("Refresh token reused",
+				zap.String("user_id", usedRecord.UserID.Hex()),
+			)
+			if err := model.RevokeAllForUser(context.Background(), usedRecord.UserID); err != nil {
+				ah.logger.Debug("Server error",
+					zap.Error(err),
+				)
+				return apierrors.CustomError(c,
+					http.StatusInternalServerError,
+					apierrors.InternalServerError,
+				)
+			}
+		}
+
+		ah.logger.Debug("Client error",
+			zap.String("cause", apierrors.UnauthorizedError),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	if record.IsExpired(time.Now().UTC()) {
+		ah.logger.Debug("Client error",
+			zap.String("cause", "refresh token expired"),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	userModel := usermodel.New(ah.db)
+	user, err := userModel.FindByID(context.Background(), record.UserID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	token, err := apiutils.CreateJWT(user.ID, config.AccessTokenExpireTime)
+	if err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	refreshToken, err := issueRefreshToken(ah.db, user.ID)
+	if err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ah.logger.Debug("Token refreshed",
+		zap.String("_id", user.ID.Hex()),
+	)
+	return c.JSON(http.StatusOK, common.AuthResponse{
+		ID:           user.ID,
+		Email:        user.Email,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Token:        token,
+		RefreshToken: refreshToken,
+	})
+}
+
+// PostForgotPassword starts a password reset. The response is the same
+// whether the email belongs to a user or not so it can't be used to find
+// out who has an account.
+func (ah *AuthHandler) PostForgotPassword(c echo.Context) error {
+	request := new(ForgotPasswordRequest)
+	if err := c.Bind(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userModel := usermodel.New(ah.db)
+	user, err := userModel.FindByEmail(context.Background(), request.Email)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return c.NoContent(http.StatusAccepted)
+		}
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	record, token, err := passwordresetmodel.NewPasswordResetRecord(
+		user.ID,
+		config.PasswordResetExpireTime*time.Millisecond,
+	)
+	if err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	model := passwordresetmodel.New(ah.db)
+	if _, err := model.InsertOne(context.Background(), record); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// A failed delivery still answers like any other request, telling it
+	// apart would reveal the account exists
+	if err := ah.tokens.SendToken(context.Background(), TokenMessage{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Purpose: PasswordResetPurpose,
+		Token:   token,
+	}); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// PostResetPassword sets a new password using a reset token. Once the
+// password changes every other reset and refresh token of the user is
+// revoked.
+func (ah *AuthHandler) PostResetPassword(c echo.Context) error {
+	request := new(ResetPasswordRequest)
+	if err := c.Bind(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	// Checked before the token is consumed so a rejected password doesn't
+	// use it up
+	if err := usermodel.CheckPassword(request.Password); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			passwordPolicyMessage(err),
+		)
+	}
+
+	model := passwordresetmodel.New(ah.db)
+	record, err := model.Consume(context.Background(), passwordresetmodel.HashToken(request.Token))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if record.IsExpired(time.Now().UTC()) {
+		ah.logger.Debug("Client error",
+			zap.String("cause", "password reset token expired"),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	userModel := usermodel.New(ah.db)
+	if err := userModel.UpdatePassword(context.Background(), record.UserID, request.Password); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if err := model.RevokeAllForUser(context.Background(), record.UserID); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	refreshTokenModel := refreshtokenmodel.New(ah.db)
+	if err := refreshTokenModel.RevokeAllForUser(context.Background(), record.UserID); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ah.logger.Info("Password reset",
+		zap.String("_id", record.UserID.Hex()),
+	)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PostLogout revokes the token the request was made with until it expires
+func (ah *AuthHandler) PostLogout(c echo.Context) error {
+	request := new(LogoutRequest)
+	if err := c.Bind(request); err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	tokenID, expiresAt, err := apiutils.GetTokenFromContext(c)
+	if err != nil {
+		ah.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	model := tokendenylistmodel.New(ah.db)
+	record := tokendenylistmodel.NewTokenDenylistRecord(tokenID, expiresAt)
+	if _, err := model.InsertOne(context.Background(), record); err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if request.RefreshToken != "" {
+		refreshTokenModel := refreshtokenmodel.New(ah.db)
+		_, err := refreshTokenModel.Consume(context.Background(), refreshtokenmodel.HashToken(request.RefreshToken))
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetVerifyEmailChange confirms the pending email of a user with the token
+// sent to it
+func (ah *AuthHandler) GetVerifyEmailChange(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		ah.logger.Debug("Client error",
+			zap.String("cause", "missing token"),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	model := emailchangemodel.New(ah.db)
+	record, err := model.Consume(context.Background(), emailchangemodel.HashToken(token))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if record.IsExpired(time.Now().UTC()) {
+		ah.logger.Debug("Client error",
+			zap.String("cause", "email change token expired"),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	userModel := usermodel.New(ah.db)
+	err = userModel.ConfirmEmail(context.Background(), record.UserID, record.Payload.Email)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+		// Someone else signed up with the email since the change was requested
+		if mongo.IsDuplicateKeyError(err) {
+			ah.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.EmailConflictError,
+			)
+		}
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// Members embed the user, invites are matched against their email
+	organizationModel := organizationmodel.New(ah.db)
+	organizations, err := organizationModel.FindByMember(context.Background(), record.UserID)
+	if err != nil {
+		ah.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	for _, organization := range organizations {
+		err := organizationModel.UpdateOne(
+			context.Background(),
+			bson.D{
+				{Key: "_id", Value: organization.ID},
+				{Key: "members.user._id", Value: record.UserID},
+			},
+			bson.D{{Key: "$set", Value: bson.M{"members.$.user.email": record.Payload.Email}}},
+		)
+		if err != nil {
+			ah.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+	}
+
+	ah.logger.Info("Email changed",
+		zap.String("_id", record.UserID.Hex()),
+	)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// DeletedFlagPurger deletes for good the flags soft deleted longer than the
+// retention ago, along with their timelines
+type DeletedFlagPurger struct {
+	db        *mongo.Database
+	logger    *zap.Logger
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewDeletedFlagPurger(
+	db *mongo.Database,
+	logger *zap.Logger,
+	retention,
+	interval time.Duration,
+) *DeletedFlagPurger {
+	return &DeletedFlagPurger{
+		db:        db,
+		logger:    logger,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start purges expired flags every interval until the context is done
+func (dfp *DeletedFlagPurger) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dfp.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := dfp.Purge(ctx, now); err != nil {
+					dfp.logger.Error("Failed to purge deleted feature flags",
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Purge deletes the flags soft deleted before now minus the retention,
+// returning how many were. Flags restored while the purge runs are spared.
+func (dfp *DeletedFlagPurger) Purge(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-dfp.retention)
+
+	model := featureflagmodel.New(dfp.db)
+	featureFlagIDs, err := model.FindDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	timelineModel := timelinemodel.New(dfp.db)
+	purged, timelines := 0, 0
+	for _, featureFlagID := range featureFlagIDs {
+		deleted, err := model.Purge(ctx, featureFlagID, cutoff)
+		if err != nil {
+			return purged, err
+		}
+		if !deleted {
+			continue
+		}
+		purged++
+
+		count, err := timelineModel.DeleteByFeatureFlagID(ctx, featureFlagID)
+		if err != nil {
+			return purged, err
+		}
+		timelines += count
+	}
+
+	dfp.logger.Info("Purged deleted feature flags",
+		zap.Int("feature_flags", purged),
+		zap.Int("timelines", timelines),
+	)
+
+	return purged, nil
+}
@@ -1,16 +1,30 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
 	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	"github.com/Roll-Play/togglelabs/pkg/evaluator"
 	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	idempotencykeymodel "github.com/Roll-Play/togglelabs/pkg/models/idempotency_key"
 	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
 	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
 	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -18,55 +32,5404 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 type FeatureFlagHandler struct {
-	db     *mongo.Database
-	logger *zap.Logger
+	featureFlags  FeatureFlagRepository
+	organizations OrganizationRepository
+	timelines     TimelineRepository
+	users         UserRepository
+	idempotency   IdempotencyKeyRepository
+	transact      Transactor
+	logger        *zap.Logger
+	webhooks      *WebhookDispatcher
+	events        *FlagEventBroker
+	flags         *cache.FlagCache
 }
 
 func NewFeatureFlagHandler(db *mongo.Database, logger *zap.Logger) *FeatureFlagHandler {
-	return &FeatureFlagHandler{
-		db:     db,
-		logger: logger,
+	return NewFeatureFlagHandlerWithRepositories(NewMongoRepositories(db), logger)
+}
+
+// NewFeatureFlagHandlerWithRepositories lets the handler run against other
+// stores than Mongo, such as the fakes of unit tests
+func NewFeatureFlagHandlerWithRepositories(repositories Repositories, logger *zap.Logger) *FeatureFlagHandler {
+	ffh := &FeatureFlagHandler{
+		featureFlags:  repositories.FeatureFlags,
+		organizations: repositories.Organizations,
+		timelines:     repositories.Timelines,
+		users:         repositories.Users,
+		idempotency:   repositories.IdempotencyKeys,
+		transact:      repositories.Transact,
+		logger:        logger,
+		webhooks:      newWebhookDispatcher(repositories.Webhooks, logger),
+		events:        NewFlagEventBroker(config.StreamBufferSize),
+		flags:         cache.NewFlagCache(repositories.EvaluationCache, config.EvaluationCacheTTL*time.Second),
+	}
+	ffh.events.Listen(ffh.invalidateFlags)
+
+	return ffh
+}
+
+// requestLogger tags the handler's log lines with the ID of the request
+// being served, so they can be told apart from those of other requests
+func (ffh *FeatureFlagHandler) requestLogger(c echo.Context) *zap.Logger {
+	requestID := apiutils.GetRequestIDFromContext(c)
+	if requestID == "" {
+		return ffh.logger
+	}
+
+	return ffh.logger.With(zap.String(apiutils.RequestIDLogField, requestID))
+}
+
+// Events is where the handler publishes flag changes. Writes made outside
+// of it publish there too, so the cache and streams see them.
+func (ffh *FeatureFlagHandler) Events() *FlagEventBroker {
+	return ffh.events
+}
+
+// invalidateFlags drops the cached flags an event changed, a failure only
+// leaves them stale until their ttl runs out
+func (ffh *FeatureFlagHandler) invalidateFlags(event FlagEvent) {
+	if err := ffh.flags.Invalidate(context.Background(), event.OrganizationID, event.Environment); err != nil {
+		ffh.logger.Warn("Evaluation cache invalidation failed",
+			zap.String("organization_id", event.OrganizationID.Hex()),
+			zap.Error(err),
+		)
+	}
+}
+
+// GetEvaluationCacheStats reports how often evaluation was served from the
+// cache since the process started. The stats cover every organization, so
+// only admins get them.
+func (ffh *FeatureFlagHandler) GetEvaluationCacheStats(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
 	}
+
+	return c.JSON(http.StatusOK, ffh.flags.Stats())
 }
 
 type PostFeatureFlagRequest struct {
-	Name         string                     `json:"name" validate:"required"`
-	DefaultValue string                     `json:"default_value" validate:"required"`
-	Environment  string                     `json:"environment" validate:"required"`
-	Type         featureflagmodel.FlagType  `json:"type" validate:"required,oneof=boolean json string number"`
-	Tags         []string                   `json:"tags"`
-	Project      *organizationmodel.Project `json:"project"`
-	Rules        []featureflagmodel.Rule    `json:"rules" validate:"dive,required"`
+	Name         string `json:"name" validate:"required"`
+	Description  string `json:"description" validate:"max=1000"`
+	DefaultValue string `json:"default_value" validate:"required"`
+	// Environment is only required from organizations that don't define
+	// their environments yet, otherwise the flag gets every defined one
+	Environment string                     `json:"environment"`
+	Type        featureflagmodel.FlagType  `json:"type" validate:"required"`
+	Tags        []string                   `json:"tags"`
+	Project     *organizationmodel.Project `json:"project"`
+	Rules       []featureflagmodel.Rule    `json:"rules" validate:"dive,required"`
+	// Min and max are only taken by number flags
+	featureflagmodel.NumberBounds
+	// Schema is only taken by json flags
+	Schema string `json:"schema"`
+}
+
+// BulkFeatureFlagError points at an entry of a bulk creation by its index
+// in the request and tells why it can't be created
+type BulkFeatureFlagError struct {
+	Index   int                    `json:"index"`
+	Name    string                 `json:"name"`
+	Message apierrors.ErrorMessage `json:"message"`
+}
+
+// BulkFeatureFlagsErrorResponse rejects a bulk creation along with every
+// entry at fault
+type BulkFeatureFlagsErrorResponse struct {
+	Error   string                 `json:"error"`
+	Message apierrors.ErrorMessage `json:"message"`
+	Errors  []BulkFeatureFlagError `json:"errors"`
+}
+
+type PatchFeatureFlagRequest struct {
+	DefaultValue string                  `json:"default_value"`
+	Rules        []featureflagmodel.Rule `json:"rules" validate:"dive,required"`
+}
+
+// PatchFeatureFlagResponse is the proposed revision, led by its ID so the
+// approval that usually follows doesn't need to dig it out
+type PatchFeatureFlagResponse struct {
+	RevisionID primitive.ObjectID `json:"revision_id"`
+	featureflagmodel.Revision
+}
+
+type PatchFeatureFlagTagsRequest struct {
+	Tags []string `json:"tags" validate:"required"`
+}
+
+type RevisionApproval struct {
+	FeatureFlagID primitive.ObjectID `json:"feature_flag_id" validate:"required"`
+	RevisionID    primitive.ObjectID `json:"revision_id" validate:"required"`
+}
+
+type ApproveRevisionRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+type BulkApproveRevisionsRequest struct {
+	ChangeSetID string             `json:"change_set_id"`
+	ReleaseNote string             `json:"release_note"`
+	Revisions   []RevisionApproval `json:"revisions" validate:"required,min=1,dive"`
+}
+
+type ChangeSetResponse struct {
+	ChangeSetID string                               `json:"change_set_id"`
+	Data        []featureflagmodel.FeatureFlagRecord `json:"data"`
+}
+
+// EvaluateFeatureFlagRequest falls back to the default environment of the
+// organization when Environment is left out
+type EvaluateFeatureFlagRequest struct {
+	Environment string            `json:"environment"`
+	Context     evaluator.Context `json:"context"`
+}
+
+// EvaluateFeatureFlagResponse carries the value typed after the flag, a
+// boolean flag serves true rather than "true", and why it was served
+type EvaluateFeatureFlagResponse struct {
+	Value  interface{}      `json:"value"`
+	Reason evaluator.Reason `json:"reason"`
+}
+
+// RevisionImpactRequest falls back to the default environment of the
+// organization when Environment is left out
+type RevisionImpactRequest struct {
+	Environment string              `json:"environment"`
+	Contexts    []evaluator.Context `json:"contexts" validate:"required,min=1,max=1000"`
+}
+
+// RevisionImpact compares the value a context is served by the live
+// revision with the value it would be served by the revision, both typed
+// after the flag
+type RevisionImpact struct {
+	Context  evaluator.Context `json:"context"`
+	OldValue interface{}       `json:"old_value"`
+	NewValue interface{}       `json:"new_value"`
+	Changed  bool              `json:"changed"`
+}
+
+// RevisionImpactResponse lists the impact on each context in the order they
+// were sent, Changed counts the contexts whose value changes
+type RevisionImpactResponse struct {
+	BaseRevisionID *primitive.ObjectID `json:"base_revision_id,omitempty"`
+	RevisionID     primitive.ObjectID  `json:"revision_id"`
+	Changed        int                 `json:"changed"`
+	Data           []RevisionImpact    `json:"data"`
+}
+
+// SetPrerequisitesRequest replaces every prerequisite of the flag, an empty
+// list clears them
+type SetPrerequisitesRequest struct {
+	Prerequisites []featureflagmodel.Prerequisite `json:"prerequisites" validate:"dive"`
+}
+
+// SetOverridesRequest replaces every override of the flag, mapping context
+// keys to the value they're served, an empty map clears them
+type SetOverridesRequest struct {
+	Overrides map[string]string `json:"overrides" validate:"dive,keys,required,endkeys"`
+}
+
+type SetExpectedConfigRequest struct {
+	ConfigHash string `json:"config_hash"`
+}
+
+type DriftAlert struct {
+	FeatureFlagID      primitive.ObjectID `json:"feature_flag_id"`
+	Name               string             `json:"name"`
+	ExpectedConfigHash string             `json:"expected_config_hash"`
+	LiveConfigHash     string             `json:"live_config_hash"`
+}
+
+type ListDriftResponse struct {
+	Data []DriftAlert `json:"data"`
+}
+
+type PostEnvironmentRequest struct {
+	Name      string `json:"name" validate:"required"`
+	IsEnabled bool   `json:"is_enabled"`
+}
+
+type ToggleFeatureFlagResponse struct {
+	Environment string `json:"environment"`
+	IsEnabled   bool   `json:"is_enabled"`
+}
+
+// BatchToggleRequest takes the flag IDs as plain strings so a malformed one
+// fails on its own rather than the whole batch
+type BatchToggleRequest struct {
+	FeatureFlagIDs []string `json:"feature_flag_ids" validate:"required,min=1"`
+	IsEnabled      *bool    `json:"is_enabled" validate:"required"`
+}
+
+type BatchToggleResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchToggleResponse maps every requested flag ID to whether it was toggled
+type BatchToggleResponse struct {
+	Environment string                       `json:"environment"`
+	IsEnabled   bool                         `json:"is_enabled"`
+	Results     map[string]BatchToggleResult `json:"results"`
+}
+
+type RenameFeatureFlagRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// DescriptionRequest replaces the description of a flag, an empty one
+// clears it
+type DescriptionRequest struct {
+	Description string `json:"description" validate:"max=1000"`
+}
+
+type CloneFeatureFlagRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// VersionMismatchResponse is the error answering a write conditioned on a
+// version the flag is no longer at
+type VersionMismatchResponse struct {
+	apierrors.Error
+	Version int `json:"version"`
+}
+
+type ListFeatureFlagResponse = common.PaginatedResponse[FeatureFlagResponse]
+
+// ListFeatureFlagCursorResponse is what ListFeatureFlags serves when paging
+// with a cursor
+type ListFeatureFlagCursorResponse = common.CursorPaginatedResponse[FeatureFlagResponse]
+
+type ListRevisionsResponse = common.PaginatedResponse[featureflagmodel.Revision]
+
+// FlagAuthorResponse is who created, or last updated, a flag resolved from
+// its user_id or updated_by_id
+type FlagAuthorResponse struct {
+	UserID    primitive.ObjectID `json:"user_id"`
+	Email     string             `json:"email"`
+	FirstName string             `json:"first_name,omitempty"`
+	LastName  string             `json:"last_name,omitempty"`
+}
+
+func newFlagAuthorResponse(user *usermodel.UserRecord) *FlagAuthorResponse {
+	if user == nil {
+		return nil
+	}
+
+	return &FlagAuthorResponse{
+		UserID:    user.ID,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+}
+
+// FeatureFlagResponse is a flag along with its creator and the user who
+// last updated it, either is left out when the user has since been deleted
+type FeatureFlagResponse struct {
+	featureflagmodel.FeatureFlagRecord
+	CreatedBy *FlagAuthorResponse `json:"created_by,omitempty"`
+	UpdatedBy *FlagAuthorResponse `json:"updated_by,omitempty"`
+}
+
+// LiveConfigResponse is what the flag currently serves. RevisionID is only
+// set when a revision is live, otherwise the flag falls back to the default
+// value it was created with.
+type LiveConfigResponse struct {
+	RevisionID   *primitive.ObjectID                       `json:"revision_id,omitempty"`
+	DefaultValue string                                    `json:"default_value"`
+	Rules        []featureflagmodel.Rule                   `json:"rules"`
+	Environments []featureflagmodel.FeatureFlagEnvironment `json:"environments"`
+}
+
+const (
+	ExportFormatJSON    = "json"
+	ExportFormatYAML    = "yaml"
+	MIMEApplicationYAML = "application/yaml"
+)
+
+// ImportFlagsResponse lists the flags by name under what the import did to
+// them, or would do to them on a dry run
+type ImportFlagsResponse struct {
+	Mode      featureflagmodel.ImportMode `json:"mode"`
+	DryRun    bool                        `json:"dry_run"`
+	Created   []string                    `json:"created"`
+	Updated   []string                    `json:"updated"`
+	Unchanged []string                    `json:"unchanged"`
+	Deleted   []string                    `json:"deleted"`
+}
+
+type ListTimelineResponse = common.PaginatedResponse[timelinemodel.TimelineEntry]
+
+func (ffh *FeatureFlagHandler) ListFeatureFlags(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organization, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organization, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	filter := featureflagmodel.ListFilter{
+		Tags:   featureflagmodel.NormalizeTags(c.QueryParams()["tag"]),
+		Value:  c.QueryParam("value"),
+		Search: strings.TrimSpace(c.QueryParam("q")),
+		// Archived flags only show up when asked for
+		IncludeArchived: c.QueryParam("include_archived") == "true",
+	}
+
+	// Sending a cursor, an empty one for the first page, opts into cursor
+	// pagination, page is ignored then
+	if _, ok := c.QueryParams()["cursor"]; ok {
+		return ffh.listFeatureFlagsAfter(c, organizationID, filter, limit)
+	}
+
+	featureFlags, total, err := ffh.featureFlags.FindMany(context.Background(), organizationID, filter, page, limit, bson.D{{
+		Key:   "timestamps.created_at",
+		Value: -1,
+	}})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.JSON(http.StatusOK, common.NewPaginatedResponse(
+				[]FeatureFlagResponse{},
+				page,
+				limit,
+				0,
+			))
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	responses, err := ffh.flagResponses(context.Background(), featureFlags)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	return c.JSON(http.StatusOK, common.NewPaginatedResponse(responses, page, limit, total))
+}
+
+// listFeatureFlagsAfter serves the page of flags following the cursor, the
+// ID of the last flag of the previous page. Unlike offset pages these don't
+// skip nor repeat flags when others are created or deleted meanwhile.
+func (ffh *FeatureFlagHandler) listFeatureFlagsAfter(
+	c echo.Context,
+	organizationID primitive.ObjectID,
+	filter featureflagmodel.ListFilter,
+	limit int,
+) error {
+	var after *primitive.ObjectID
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		featureFlagID, err := primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusBadRequest,
+				apierrors.InvalidCursorError,
+			)
+		}
+		after = &featureFlagID
+	}
+
+	featureFlags, next, err := ffh.featureFlags.FindAfter(context.Background(), organizationID, filter, after, limit)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	responses, err := ffh.flagResponses(context.Background(), featureFlags)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Hex()
+	}
+
+	return c.JSON(http.StatusOK, common.NewCursorPaginatedResponse(responses, limit, nextCursor))
+}
+
+// flagResponses resolves the users who created and last updated the flags,
+// all of them at once
+func (ffh *FeatureFlagHandler) flagResponses(
+	ctx context.Context,
+	featureFlags []featureflagmodel.FeatureFlagRecord,
+) ([]FeatureFlagResponse, error) {
+	userIDs := make([]primitive.ObjectID, 0, len(featureFlags))
+	for _, featureFlag := range featureFlags {
+		userIDs = append(userIDs, featureFlag.UserID)
+		if featureFlag.UpdatedByID != nil {
+			userIDs = append(userIDs, *featureFlag.UpdatedByID)
+		}
+	}
+
+	users, err := ffh.users.FindActiveByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	usersByID := make(map[primitive.ObjectID]*usermodel.UserRecord, len(users))
+	for index := range users {
+		usersByID[users[index].ID] = &users[index]
+	}
+
+	responses := make([]FeatureFlagResponse, 0, len(featureFlags))
+	for _, featureFlag := range featureFlags {
+		response := FeatureFlagResponse{
+			FeatureFlagRecord: featureFlag,
+			CreatedBy:         newFlagAuthorResponse(usersByID[featureFlag.UserID]),
+		}
+		if featureFlag.UpdatedByID != nil {
+			response.UpdatedBy = newFlagAuthorResponse(usersByID[*featureFlag.UpdatedByID])
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+// invalidRuleValue answers a rule value validation failure with the index
+// of the offending rule
+func (ffh *FeatureFlagHandler) invalidRuleValue(c echo.Context, err error) error {
+	ffh.requestLogger(c).Debug("Client error",
+		zap.Error(err),
+	)
+
+	return apierrors.CustomError(c,
+		http.StatusBadRequest,
+		ruleValueMessage(err),
+	)
 }
 
-type PatchFeatureFlagRequest struct {
-	DefaultValue string                  `json:"default_value"`
-	Rules        []featureflagmodel.Rule `json:"rules" validate:"dive,required"`
-}
+// schemaViolation answers a value violating the flag schema with where in
+// the value it does, and the offending rule when it isn't the default value
+func (ffh *FeatureFlagHandler) schemaViolation(c echo.Context, err error) error {
+	ffh.requestLogger(c).Debug("Client error",
+		zap.Error(err),
+	)
+
+	return apierrors.CustomError(c,
+		http.StatusBadRequest,
+		schemaViolationMessage(err),
+	)
+}
+
+// outOfBounds answers a value out of the flag bounds with the bound it
+// crosses, and the offending rule when it isn't the default value
+func (ffh *FeatureFlagHandler) outOfBounds(c echo.Context, err error) error {
+	ffh.requestLogger(c).Debug("Client error",
+		zap.Error(err),
+	)
+
+	return apierrors.CustomError(c,
+		http.StatusBadRequest,
+		outOfBoundsMessage(err),
+	)
+}
+
+// flagQuotaAllows reports whether the organization can take adding more
+// flags. Deleted flags don't count against the quota, archived ones do.
+func (ffh *FeatureFlagHandler) flagQuotaAllows(
+	ctx context.Context,
+	organizationRecord *organizationmodel.OrganizationRecord,
+	adding int,
+) (bool, error) {
+	// Without a quota there's nothing to count
+	if organizationRecord.MaxFlags <= 0 {
+		return true, nil
+	}
+
+	flags, err := ffh.featureFlags.CountMany(ctx, organizationRecord.ID, featureflagmodel.ListFilter{
+		IncludeArchived: true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return organizationRecord.FlagQuotaAllows(flags + adding), nil
+}
+
+// errFlagQuotaExceeded aborts a transaction adding a flag the quota has no
+// room for, the caller answers it with quotaExceeded
+var errFlagQuotaExceeded = errors.New("flag quota exceeded")
+
+// checkFlagQuota is flagQuotaAllows for writes made in a transaction, the
+// flags are counted along with the write so concurrent ones can't both
+// take the last slot
+func (ffh *FeatureFlagHandler) checkFlagQuota(
+	ctx context.Context,
+	organizationRecord *organizationmodel.OrganizationRecord,
+	adding int,
+) error {
+	allowed, err := ffh.flagQuotaAllows(ctx, organizationRecord, adding)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errFlagQuotaExceeded
+	}
+
+	return nil
+}
+
+// quotaExceeded answers a creation taking the organization past its quota
+func (ffh *FeatureFlagHandler) quotaExceeded(
+	c echo.Context,
+	organizationRecord *organizationmodel.OrganizationRecord,
+) error {
+	ffh.requestLogger(c).Debug("Client error",
+		zap.Int("max_flags", organizationRecord.MaxFlags),
+	)
+
+	return apierrors.CustomError(c,
+		http.StatusForbidden,
+		fmt.Sprintf(apierrors.QuotaExceededError, organizationRecord.MaxFlags),
+	)
+}
+
+func ruleValueMessage(err error) apierrors.ErrorMessage {
+	var ruleValueError *featureflagmodel.RuleValueError
+	if !errors.As(err, &ruleValueError) {
+		return apierrors.InvalidValueError
+	}
+
+	return fmt.Sprintf(apierrors.InvalidRuleValueError, ruleValueError.Index)
+}
+
+func overrideValueMessage(err error) apierrors.ErrorMessage {
+	var overrideValueError *featureflagmodel.OverrideValueError
+	if !errors.As(err, &overrideValueError) {
+		return apierrors.InvalidValueError
+	}
+
+	return fmt.Sprintf(apierrors.InvalidOverrideValueError, overrideValueError.Key, overrideValueError.Err)
+}
+
+func schemaViolationMessage(err error) apierrors.ErrorMessage {
+	var ruleValueError *featureflagmodel.RuleValueError
+	if errors.As(err, &ruleValueError) {
+		return fmt.Sprintf(apierrors.RuleSchemaViolationError, ruleValueError.Index, ruleValueError.Err)
+	}
+
+	return fmt.Sprintf(apierrors.DefaultSchemaViolationError, err)
+}
+
+func outOfBoundsMessage(err error) apierrors.ErrorMessage {
+	var ruleValueError *featureflagmodel.RuleValueError
+	if errors.As(err, &ruleValueError) {
+		return fmt.Sprintf(apierrors.RuleOutOfBoundsError, ruleValueError.Index, ruleValueError.Err)
+	}
+
+	return fmt.Sprintf(apierrors.DefaultOutOfBoundsError, err)
+}
+
+// validatePostFeatureFlagRequest checks the request, the type of the flag
+// and every value it serves. The message tells the client what's wrong.
+func validatePostFeatureFlagRequest(request *PostFeatureFlagRequest) (apierrors.ErrorMessage, error) {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return apierrors.BadRequestError, err
+	}
+
+	if !request.Type.IsValid() {
+		return apierrors.InvalidFlagTypeError, fmt.Errorf("%w: %q", featureflagmodel.ErrInvalidFlagType, request.Type)
+	}
+
+	if err := featureflagmodel.ValidateRules(request.Rules); err != nil {
+		return apierrors.BadRequestError, err
+	}
+
+	if _, err := featureflagmodel.ParseValue(request.Type, request.DefaultValue); err != nil {
+		return apierrors.InvalidValueError, err
+	}
+
+	if err := featureflagmodel.ValidateRuleValues(request.Type, request.Rules); err != nil {
+		return ruleValueMessage(err), err
+	}
+
+	if err := request.NumberBounds.Validate(request.Type); err != nil {
+		if errors.Is(err, featureflagmodel.ErrBoundsNotNumber) {
+			return apierrors.BoundsNotNumberError, err
+		}
+		return apierrors.InvalidBoundsError, err
+	}
+
+	if err := request.NumberBounds.Check(request.DefaultValue); err != nil {
+		return outOfBoundsMessage(err), err
+	}
+
+	if err := request.NumberBounds.CheckRules(request.Rules); err != nil {
+		return outOfBoundsMessage(err), err
+	}
+
+	schema, err := featureflagmodel.CompileSchema(request.Type, request.Schema)
+	if err != nil {
+		if errors.Is(err, featureflagmodel.ErrSchemaNotJSON) {
+			return apierrors.SchemaNotJSONError, err
+		}
+		return apierrors.InvalidSchemaError, err
+	}
+
+	if err := featureflagmodel.CheckSchema(schema, request.DefaultValue); err != nil {
+		return schemaViolationMessage(err), err
+	}
+
+	if err := featureflagmodel.CheckSchemaRules(schema, request.Rules); err != nil {
+		return schemaViolationMessage(err), err
+	}
+
+	return "", nil
+}
+
+// newFlagEnvironmentNames picks the environments a new flag starts with,
+// every one the organization defines or, until it defines them, the one
+// requested
+func newFlagEnvironmentNames(
+	request *PostFeatureFlagRequest,
+	organizationRecord *organizationmodel.OrganizationRecord,
+) ([]string, apierrors.ErrorMessage, error) {
+	if len(organizationRecord.Environments) == 0 {
+		if request.Environment == "" {
+			return nil, apierrors.BadRequestError, errors.New("environment is required")
+		}
+		return []string{request.Environment}, "", nil
+	}
+
+	if request.Environment != "" && !organizationRecord.HasEnvironment(request.Environment) {
+		return nil, apierrors.UndefinedEnvironmentError, fmt.Errorf("%s: %s", apierrors.UndefinedEnvironmentError, request.Environment)
+	}
+
+	return organizationRecord.EnvironmentNames(), "", nil
+}
+
+func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(PostFeatureFlagRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if message, err := validatePostFeatureFlagRequest(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			message,
+		)
+	}
+
+	idempotencyKey := strings.TrimSpace(c.Request().Header.Get(IdempotencyKeyHeader))
+	requestHash := ""
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > config.MaxIdempotencyKeyLength {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.String("cause", "idempotency key too long"),
+			)
+			return apierrors.CustomError(c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
+
+		requestHash, err = hashRequest(request)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		if replayed, err := ffh.replayCreation(c, organizationID, idempotencyKey, requestHash); replayed {
+			return err
+		}
+	}
+
+	allowed, err := ffh.flagQuotaAllows(context.Background(), organizationRecord, 1)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+	if !allowed {
+		return ffh.quotaExceeded(c, organizationRecord)
+	}
+
+	request.Tags = featureflagmodel.NormalizeTags(request.Tags)
+	if len(request.Tags) > 0 {
+		err = ffh.organizations.UpdateOne(
+			context.Background(),
+			bson.D{{Key: "_id", Value: organizationID}},
+			bson.D{{Key: "$addToSet",
+				Value: bson.M{"tags": bson.M{"$each": request.Tags}},
+			}},
+		)
+	}
+
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	environmentNames, message, err := newFlagEnvironmentNames(request, organizationRecord)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			message,
+		)
+	}
+
+	nameInUse, err := ffh.featureFlags.NameInUse(
+		context.Background(),
+		organizationID,
+		request.Name,
+		environmentNames,
+		primitive.NilObjectID,
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if nameInUse {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NameConflictError,
+		)
+	}
+
+	featureFlagRecord := featureflagmodel.NewFeatureFlagRecord(
+		request.Name,
+		request.DefaultValue,
+		request.Type,
+		request.Rules,
+		organizationID,
+		userID,
+		environmentNames,
+		request.Project,
+		request.Tags,
+	)
+	featureFlagRecord.Description = strings.TrimSpace(request.Description)
+	featureFlagRecord.NumberBounds = request.NumberBounds
+	featureFlagRecord.Schema = request.Schema
+
+	// The flag is only created along with its timeline, a failure in
+	// between rolls the flag back rather than leaving it without one
+	err = ffh.transact(context.Background(), func(ctx context.Context) error {
+		featureFlagID, err := ffh.featureFlags.InsertOne(ctx, featureFlagRecord)
+		if err != nil {
+			return err
+		}
+
+		_, err = ffh.timelines.InsertOne(ctx,
+			&timelinemodel.TimelineRecord{
+				FeatureFlagID: featureFlagID,
+				Entries:       []timelinemodel.TimelineEntry{},
+			})
+		if err != nil {
+			return err
+		}
+
+		timelineEntry := timelinemodel.NewTimelineEntry(
+			userID,
+			timelinemodel.Created,
+			nil,
+		)
+		if err := ffh.timelines.UpdateOne(ctx, featureFlagID, timelineEntry); err != nil {
+			return err
+		}
+
+		if idempotencyKey == "" {
+			return nil
+		}
+
+		_, err = ffh.idempotency.InsertOne(ctx, idempotencykeymodel.NewIdempotencyKeyRecord(
+			organizationID,
+			idempotencyKey,
+			requestHash,
+			featureFlagID,
+			time.Now().UTC().Add(config.IdempotencyKeyWindow*time.Second),
+		))
+		if mongo.IsDuplicateKeyError(err) {
+			return errIdempotencyKeyInUse
+		}
+
+		return err
+	})
+	if err != nil {
+		// A concurrent retry may have created the flag first, either taking
+		// the key or the name, it's answered with that flag
+		if idempotencyKey != "" && (errors.Is(err, errIdempotencyKeyInUse) || mongo.IsDuplicateKeyError(err)) {
+			if replayed, err := ffh.replayCreation(c, organizationID, idempotencyKey, requestHash); replayed {
+				return err
+			}
+		}
+		if errors.Is(err, errIdempotencyKeyInUse) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.IdempotencyKeyInUseError,
+			)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.NameConflictError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagCreatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagRecord.ID,
+	})
+
+	return c.JSON(http.StatusCreated, featureFlagRecord)
+}
+
+// PostFeatureFlags creates every flag of the request or none of them. All
+// the entries are checked before anything is written, so a rejected batch
+// answers with every entry at fault: 409 when they only collide on names,
+// among themselves or with existing flags, and 400 otherwise.
+func (ffh *FeatureFlagHandler) PostFeatureFlags(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	var requests []PostFeatureFlagRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&requests); err != nil || len(requests) == 0 {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if len(requests) > config.MaxBulkFeatureFlags {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Int("count", len(requests)),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			fmt.Sprintf(apierrors.TooManyFeatureFlagsError, config.MaxBulkFeatureFlags),
+		)
+	}
+
+	records := make([]*featureflagmodel.FeatureFlagRecord, 0, len(requests))
+	itemErrors := []BulkFeatureFlagError{}
+	onlyConflicts := true
+	reject := func(index int, message apierrors.ErrorMessage, conflict bool) {
+		itemErrors = append(itemErrors, BulkFeatureFlagError{
+			Index:   index,
+			Name:    requests[index].Name,
+			Message: message,
+		})
+		onlyConflicts = onlyConflicts && conflict
+	}
+	// Entries of the batch collide like flags already stored do, on a name
+	// sharing an environment
+	batchNames := map[string]map[string]bool{}
+	tags := []string{}
+	for index := range requests {
+		request := &requests[index]
+		if message, err := validatePostFeatureFlagRequest(request); err != nil {
+			reject(index, message, false)
+			continue
+		}
+
+		environmentNames, message, err := newFlagEnvironmentNames(request, organizationRecord)
+		if err != nil {
+			reject(index, message, false)
+			continue
+		}
+
+		collides := false
+		if batchNames[request.Name] == nil {
+			batchNames[request.Name] = map[string]bool{}
+		}
+		for _, environmentName := range environmentNames {
+			collides = collides || batchNames[request.Name][environmentName]
+			batchNames[request.Name][environmentName] = true
+		}
+		if collides {
+			reject(index, apierrors.NameConflictError, true)
+			continue
+		}
+
+		nameInUse, err := ffh.featureFlags.NameInUse(
+			context.Background(),
+			organizationID,
+			request.Name,
+			environmentNames,
+			primitive.NilObjectID,
+		)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+		if nameInUse {
+			reject(index, apierrors.NameConflictError, true)
+			continue
+		}
+
+		request.Tags = featureflagmodel.NormalizeTags(request.Tags)
+		tags = append(tags, request.Tags...)
+
+		featureFlagRecord := featureflagmodel.NewFeatureFlagRecord(
+			request.Name,
+			request.DefaultValue,
+			request.Type,
+			request.Rules,
+			organizationID,
+			userID,
+			environmentNames,
+			request.Project,
+			request.Tags,
+		)
+		featureFlagRecord.Description = strings.TrimSpace(request.Description)
+		featureFlagRecord.NumberBounds = request.NumberBounds
+		featureFlagRecord.Schema = request.Schema
+		records = append(records, featureFlagRecord)
+	}
+
+	if len(itemErrors) > 0 {
+		status := http.StatusBadRequest
+		if onlyConflicts {
+			status = http.StatusConflict
+		}
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Int("rejected", len(itemErrors)),
+		)
+		return c.JSON(status, BulkFeatureFlagsErrorResponse{
+			Error:   http.StatusText(status),
+			Message: apierrors.BulkFeatureFlagsError,
+			Errors:  itemErrors,
+		})
+	}
+
+	// The batch is created as a whole or not at all, quota included
+	allowed, err := ffh.flagQuotaAllows(context.Background(), organizationRecord, len(records))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+	if !allowed {
+		return ffh.quotaExceeded(c, organizationRecord)
+	}
+
+	if tags = featureflagmodel.NormalizeTags(tags); len(tags) > 0 {
+		err = ffh.organizations.UpdateOne(
+			context.Background(),
+			bson.D{{Key: "_id", Value: organizationID}},
+			bson.D{{Key: "$addToSet",
+				Value: bson.M{"tags": bson.M{"$each": tags}},
+			}},
+		)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+	}
+
+	// Like a single creation, the flags are only created along with their
+	// timelines
+	err = ffh.transact(context.Background(), func(ctx context.Context) error {
+		featureFlagIDs, err := ffh.featureFlags.InsertMany(ctx, records)
+		if err != nil {
+			return err
+		}
+
+		timelineRecords := make([]*timelinemodel.TimelineRecord, 0, len(featureFlagIDs))
+		for _, featureFlagID := range featureFlagIDs {
+			timelineRecords = append(timelineRecords, &timelinemodel.TimelineRecord{
+				FeatureFlagID: featureFlagID,
+				Entries: []timelinemodel.TimelineEntry{
+					*timelinemodel.NewTimelineEntry(userID, timelinemodel.Created, nil),
+				},
+			})
+		}
+
+		return ffh.timelines.InsertMany(ctx, timelineRecords)
+	})
+	if err != nil {
+		// A flag created concurrently can still take one of the names
+		if mongo.IsDuplicateKeyError(err) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.NameConflictError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	for _, featureFlagRecord := range records {
+		ffh.events.Publish(FlagEvent{
+			Type:           FlagCreatedEvent,
+			OrganizationID: organizationID,
+			FeatureFlagID:  featureFlagRecord.ID,
+		})
+	}
+
+	return c.JSON(http.StatusCreated, records)
+}
+
+// IdempotencyKeyHeader lets clients retry a flag creation without risking a
+// second flag
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var errIdempotencyKeyInUse = errors.New("idempotency key already stored")
+
+// hashRequest fingerprints a request so a retry can be told apart from a
+// different request reusing its idempotency key
+func hashRequest(request interface{}) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayCreation answers a creation whose idempotency key was already used
+// in the organization, with the flag it created then. It reports false,
+// having written nothing, when the key is unused.
+func (ffh *FeatureFlagHandler) replayCreation(
+	c echo.Context,
+	organizationID primitive.ObjectID,
+	idempotencyKey,
+	requestHash string,
+) (bool, error) {
+	idempotencyKeyRecord, err := ffh.idempotency.FindByKey(context.Background(), organizationID, idempotencyKey)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return true, apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if idempotencyKeyRecord.RequestHash != requestHash {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.IdempotencyKeyReusedError)),
+		)
+		return true, apierrors.CustomError(c,
+			http.StatusUnprocessableEntity,
+			apierrors.IdempotencyKeyReusedError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: idempotencyKeyRecord.FeatureFlagID},
+		{Key: "organization_id", Value: organizationID},
+	})
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return true, apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.requestLogger(c).Debug("Replayed feature flag creation",
+		zap.String("_id", featureFlagRecord.ID.Hex()),
+	)
+	return true, c.JSON(http.StatusCreated, featureFlagRecord)
+}
+
+// ifMatchVersion reads the flag version a write is conditioned on from the
+// If-Match header, nil when the header isn't set. The version can be given
+// as is, quoted, or as the ETag GetFeatureFlag served.
+func ifMatchVersion(c echo.Context) (*int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" {
+		return nil, nil
+	}
+
+	tag, _, _ := strings.Cut(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), "-")
+	version, err := strconv.Atoi(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// versionMismatch answers a write whose If-Match version is stale with the
+// version the flag is at, so the client knows what to fetch
+func (ffh *FeatureFlagHandler) versionMismatch(c echo.Context, version int) error {
+	ffh.requestLogger(c).Debug("Client error",
+		zap.Error(errors.New(apierrors.VersionMismatchError)),
+		zap.Int("version", version),
+	)
+	return c.JSON(http.StatusPreconditionFailed, VersionMismatchResponse{
+		Error: apierrors.Error{
+			Error:   http.StatusText(http.StatusPreconditionFailed),
+			Message: apierrors.VersionMismatchError,
+		},
+		Version: version,
+	})
+}
+
+func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(PatchFeatureFlagRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if err := featureflagmodel.ValidateRules(request.Rules); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if expectedVersion != nil && *expectedVersion != featureFlagRecord.Version {
+		return ffh.versionMismatch(c, featureFlagRecord.Version)
+	}
+
+	if _, err := featureFlagRecord.TypedValue(request.DefaultValue); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+			zap.String("default_value", request.DefaultValue),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.InvalidValueError,
+		)
+	}
+
+	if err := featureflagmodel.ValidateRuleValues(featureFlagRecord.Type, request.Rules); err != nil {
+		return ffh.invalidRuleValue(c, err)
+	}
+
+	if err := featureFlagRecord.NumberBounds.Check(request.DefaultValue); err != nil {
+		return ffh.outOfBounds(c, err)
+	}
+
+	if err := featureFlagRecord.NumberBounds.CheckRules(request.Rules); err != nil {
+		return ffh.outOfBounds(c, err)
+	}
+
+	// The schema was checked when the flag got it, failing to compile it
+	// now is on the server
+	schema, err := featureflagmodel.CompileSchema(featureFlagRecord.Type, featureFlagRecord.Schema)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if err := featureflagmodel.CheckSchema(schema, request.DefaultValue); err != nil {
+		return ffh.schemaViolation(c, err)
+	}
+
+	if err := featureflagmodel.CheckSchemaRules(schema, request.Rules); err != nil {
+		return ffh.schemaViolation(c, err)
+	}
+
+	// The live revision is what the new draft would replace, falling back
+	// to the latest one for flags that never went live
+	previousRevision := featureFlagRecord.LiveRevision()
+	if previousRevision == nil && len(featureFlagRecord.Revisions) > 0 {
+		previousRevision = &featureFlagRecord.Revisions[len(featureFlagRecord.Revisions)-1]
+	}
+	if previousRevision == nil {
+		previousRevision = &featureflagmodel.Revision{Rules: []featureflagmodel.Rule{}}
+	}
+
+	revision := featureflagmodel.NewRevisionRecord(
+		request.DefaultValue,
+		request.Rules,
+		userID,
+	)
+	version, err := ffh.featureFlags.PushRevision(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		organizationID,
+		featureFlagID,
+		revision,
+		expectedVersion,
+	)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// The flag moved on, or was deleted, between reading and
+			// writing it. Nothing was pushed so nothing goes on the
+			// timeline either.
+			currentRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+			if err == nil {
+				return ffh.versionMismatch(c, currentRecord.Version)
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				ffh.requestLogger(c).Debug("Client error",
+					zap.Error(err),
+				)
+				return apierrors.CustomError(c,
+					http.StatusNotFound,
+					apierrors.NotFoundError,
+				)
+			}
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.RevisionCreated, map[string]interface{}{
+		timelinemodel.RevisionIDMetadataKey:      revision.ID.Hex(),
+		timelinemodel.OldDefaultValueMetadataKey: previousRevision.DefaultValue,
+		timelinemodel.NewDefaultValueMetadataKey: revision.DefaultValue,
+		timelinemodel.OldRulesMetadataKey:        previousRevision.Rules,
+		timelinemodel.NewRulesMetadataKey:        revision.Rules,
+	})
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagPatchedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+		Data: map[string]interface{}{
+			"revision_id": revision.ID.Hex(),
+		},
+	})
+
+	// The ETag carries the version the push moved the flag to, so the next
+	// write can be conditioned on it without fetching the flag again
+	body, err := json.Marshal(PatchFeatureFlagResponse{
+		RevisionID: revision.ID,
+		Revision:   *revision,
+	})
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	c.Response().Header().Set(
+		echo.HeaderLocation,
+		fmt.Sprintf("/features/%s/revisions/%s", featureFlagID.Hex(), revision.ID.Hex()),
+	)
+	c.Response().Header().Set(apiutils.HeaderETag, apiutils.VersionedETag(version, body))
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, body)
+}
+
+func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	request := new(ApproveRevisionRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if request.ScheduledAt != nil && !request.ScheduledAt.After(time.Now()) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("cause", "scheduled_at must be in the future"),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	revision := featureFlagRecord.FindRevision(revisionID)
+	if revision == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if revision.Status != featureflagmodel.Draft {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.RevisionNotDraftError)),
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.RevisionNotDraftError,
+		)
+	}
+
+	if revision.UserID == userID && !organizationRecord.AllowSelfApproval {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.SelfApprovalError)),
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusForbidden,
+			apierrors.SelfApprovalError,
+		)
+	}
+
+	if revision.ApprovedBy(userID) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.AlreadyApprovedError)),
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.AlreadyApprovedError,
+		)
+	}
+
+	// The approval and its timeline entry are written together. Concurrent
+	// approvals are serialized by the model, one that lost the race finds
+	// the revision in a state it can't be approved in anymore.
+	var action string
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		var err error
+		featureFlagRecord, err = ffh.featureFlags.AddApproval(ctx, organizationID, featureFlagID, revisionID, userID)
+		if err != nil {
+			return err
+		}
+
+		action = timelinemodel.RevisionApprovalAdded
+		if len(featureFlagRecord.FindRevision(revisionID).Approvals) >= organizationRecord.ApprovalThreshold() {
+			featureFlagRecord, action, err = completeApproval(ctx, ffh.featureFlags, featureFlagRecord, revisionID, request.ScheduledAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		timelineEntry := timelinemodel.NewTimelineEntry(userID, action, map[string]interface{}{
+			timelinemodel.RevisionIDMetadataKey:     revisionID.Hex(),
+			timelinemodel.RevisionStatusMetadataKey: featureFlagRecord.FindRevision(revisionID).Status,
+		})
+		return ffh.timelines.UpdateOne(ctx, featureFlagID, timelineEntry)
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.RevisionNotDraftError)),
+				zap.String("revision_id", revisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.RevisionNotDraftError,
+			)
+		}
+		if errors.Is(err, errApprovalContention) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+				zap.String("revision_id", revisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.ConcurrentUpdateError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if action == timelinemodel.RevisionApproved {
+		ffh.webhooks.Dispatch(webhookmodel.RevisionApproved, organizationID, featureFlagID, userID, map[string]interface{}{
+			"revision_id": revisionID.Hex(),
+			"version":     featureFlagRecord.Version,
+		})
+		ffh.events.Publish(FlagEvent{
+			Type:           FlagApprovedEvent,
+			OrganizationID: organizationID,
+			FeatureFlagID:  featureFlagID,
+			Data: map[string]interface{}{
+				"revision_id": revisionID.Hex(),
+				"version":     featureFlagRecord.Version,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// approvalAttempts bounds how many times completeApproval reads the flag
+// again when other changes keep landing between its read and its write
+const approvalAttempts = 3
+
+var errApprovalContention = errors.New("revision approval kept conflicting with other changes")
+
+// completeApproval promotes, or schedules, a revision that just reached the
+// approval threshold. When another approval got there first the revision
+// is left as that one made it and the action is only the approval added.
+func completeApproval(
+	ctx context.Context,
+	model FeatureFlagRepository,
+	featureFlagRecord *featureflagmodel.FeatureFlagRecord,
+	revisionID primitive.ObjectID,
+	scheduledAt *time.Time,
+) (*featureflagmodel.FeatureFlagRecord, string, error) {
+	for attempt := 0; attempt < approvalAttempts; attempt++ {
+		var completed *featureflagmodel.FeatureFlagRecord
+		var err error
+		action := timelinemodel.RevisionApproved
+		if scheduledAt != nil {
+			completed, err = model.ScheduleRevision(
+				ctx,
+				featureFlagRecord.OrganizationID,
+				featureFlagRecord.ID,
+				revisionID,
+				primitive.NewDateTimeFromTime(scheduledAt.UTC()),
+			)
+			action = fmt.Sprintf(timelinemodel.RevisionScheduled, scheduledAt.UTC().Format(time.RFC3339))
+		} else {
+			completed, err = model.PromoteRevision(ctx, featureFlagRecord, revisionID)
+		}
+		if err == nil {
+			return completed, action, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, "", err
+		}
+
+		featureFlagRecord, err = model.FindActiveByID(
+			ctx,
+			featureFlagRecord.OrganizationID,
+			featureFlagRecord.ID,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if featureFlagRecord.FindRevision(revisionID).Status != featureflagmodel.Draft {
+			return featureFlagRecord, timelinemodel.RevisionApprovalAdded, nil
+		}
+	}
+
+	return nil, "", errApprovalContention
+}
+
+func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// The first revision to go live points at a nil LastRevisionID, rolling
+	// it back would leave the flag without a live revision
+	liveRevision := featureFlagRecord.LiveRevision()
+	var previousRevision *featureflagmodel.Revision
+	if liveRevision != nil && liveRevision.LastRevisionID != nil && !liveRevision.LastRevisionID.IsZero() {
+		previousRevision = featureFlagRecord.FindRevision(*liveRevision.LastRevisionID)
+	}
+
+	if previousRevision == nil || previousRevision.Status != featureflagmodel.Archived {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NothingToRollbackError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NothingToRollbackError,
+		)
+	}
+
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagRollback, nil)
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		rolledBack, err := ffh.featureFlags.RollbackRevision(ctx, featureFlagRecord, liveRevision.ID, previousRevision.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := ffh.timelines.UpdateOne(ctx, featureFlagID, timelineEntry); err != nil {
+			return err
+		}
+
+		featureFlagRecord = rolledBack
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.ConcurrentUpdateError)),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.ConcurrentUpdateError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationRecord.FlagDeletionPermission())
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	err = ffh.featureFlags.SoftDelete(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		organizationID,
+		featureFlagID,
+	)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err))
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagDeletedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagDeleted, nil)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.webhooks.Dispatch(webhookmodel.FeatureFlagDeleted, organizationID, featureFlagID, userID, nil)
+
+	ffh.requestLogger(c).Info("Soft deleted feature flag",
+		zap.String("_id", featureFlagID.Hex()))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	environmentName := organizationRecord.ResolveEnvironment(c.QueryParams().Get("env"))
+	if environmentName == "" {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.MissingEnvironmentError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.MissingEnvironmentError,
+		)
+	}
+
+	var toggledEnvironment *featureflagmodel.FeatureFlagEnvironment
+	for index, environment := range featureFlagRecord.Environments {
+		if environment.Name == environmentName {
+			featureFlagRecord.Environments[index].IsEnabled = !(featureFlagRecord.Environments[index].IsEnabled)
+			toggledEnvironment = &featureFlagRecord.Environments[index]
+		}
+	}
+
+	if toggledEnvironment == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.EnvironmentNotFoundError)),
+			zap.String("env", environmentName),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.EnvironmentNotFoundError,
+		)
+	}
+
+	filters := bson.M{"$and": []bson.M{
+		{"_id": featureFlagID},
+		{"organization_id": organizationID},
+	}}
+	newValues := bson.D{
+		{
+			Key: "$set", Value: bson.D{
+				{Key: "environments", Value: featureFlagRecord.Environments},
+			},
+		},
+	}
+	err = ffh.featureFlags.UpdateOne(featureflagmodel.WithUpdatedBy(context.Background(), userID), filters, newValues)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	timelineEntry := timelinemodel.NewTimelineEntry(
+		userID,
+		fmt.Sprintf(timelinemodel.FeatureFlagToggle, environmentName),
+		map[string]interface{}{
+			timelinemodel.EnvironmentMetadataKey: environmentName,
+			timelinemodel.IsEnabledMetadataKey:   toggledEnvironment.IsEnabled,
+		},
+	)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.webhooks.Dispatch(webhookmodel.FeatureFlagToggle, organizationID, featureFlagID, userID, map[string]interface{}{
+		"environment": toggledEnvironment.Name,
+		"is_enabled":  toggledEnvironment.IsEnabled,
+	})
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagToggledEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+		Environment:    toggledEnvironment.Name,
+		Data: map[string]interface{}{
+			"is_enabled": toggledEnvironment.IsEnabled,
+		},
+	})
+
+	// The full record is kept for clients that relied on it before the
+	// minimal response was introduced
+	if c.QueryParam("verbose") == "true" {
+		return c.JSON(http.StatusOK, featureFlagRecord)
+	}
+
+	return c.JSON(http.StatusOK, ToggleFeatureFlagResponse{
+		Environment: toggledEnvironment.Name,
+		IsEnabled:   toggledEnvironment.IsEnabled,
+	})
+}
+
+// BatchToggleEnvironment turns an environment on or off for many flags at
+// once, a kill switch for incidents. Flags that can't be toggled are
+// reported in the results and don't stop the others.
+func (ffh *FeatureFlagHandler) BatchToggleEnvironment(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	environmentName := c.Param("name")
+	if len(organizationRecord.Environments) > 0 && !organizationRecord.HasEnvironment(environmentName) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.UndefinedEnvironmentError)),
+			zap.String("env", environmentName),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.UndefinedEnvironmentError,
+		)
+	}
+
+	request := new(BatchToggleRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	response := BatchToggleResponse{
+		Environment: environmentName,
+		IsEnabled:   *request.IsEnabled,
+		Results:     make(map[string]BatchToggleResult, len(request.FeatureFlagIDs)),
+	}
+	featureFlagIDs := make([]primitive.ObjectID, 0, len(request.FeatureFlagIDs))
+	for _, hex := range request.FeatureFlagIDs {
+		featureFlagID, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			response.Results[hex] = BatchToggleResult{Error: apierrors.BadRequestError}
+			continue
+		}
+		featureFlagIDs = append(featureFlagIDs, featureFlagID)
+	}
+
+	// Flags are looked up first so missing ones, or ones without the
+	// environment, get a precise error instead of a silent no-op write
+	featureFlagRecords, err := ffh.featureFlags.FindByIDs(context.Background(), organizationID, featureFlagIDs)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	recordsByID := make(map[primitive.ObjectID]*featureflagmodel.FeatureFlagRecord, len(featureFlagRecords))
+	for index, featureFlagRecord := range featureFlagRecords {
+		recordsByID[featureFlagRecord.ID] = &featureFlagRecords[index]
+	}
+
+	toggleIDs := make([]primitive.ObjectID, 0, len(featureFlagIDs))
+	for _, featureFlagID := range featureFlagIDs {
+		featureFlagRecord, ok := recordsByID[featureFlagID]
+		if !ok {
+			response.Results[featureFlagID.Hex()] = BatchToggleResult{Error: apierrors.NotFoundError}
+			continue
+		}
+
+		if featureFlagRecord.FindEnvironment(environmentName) == nil {
+			response.Results[featureFlagID.Hex()] = BatchToggleResult{Error: apierrors.EnvironmentNotFoundError}
+			continue
+		}
+		toggleIDs = append(toggleIDs, featureFlagID)
+	}
+
+	writeErrors, err := ffh.featureFlags.SetEnvironmentEnabled(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		organizationID,
+		toggleIDs,
+		environmentName,
+		*request.IsEnabled,
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	for index, featureFlagID := range toggleIDs {
+		if writeErrors[index] != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(writeErrors[index]),
+				zap.String("feature_flag_id", featureFlagID.Hex()),
+			)
+			response.Results[featureFlagID.Hex()] = BatchToggleResult{Error: apierrors.InternalServerError}
+			continue
+		}
+		response.Results[featureFlagID.Hex()] = BatchToggleResult{Success: true}
+
+		timelineEntry := timelinemodel.NewTimelineEntry(
+			userID,
+			fmt.Sprintf(timelinemodel.FeatureFlagToggle, environmentName),
+			map[string]interface{}{
+				timelinemodel.EnvironmentMetadataKey: environmentName,
+				timelinemodel.IsEnabledMetadataKey:   *request.IsEnabled,
+			},
+		)
+		if err := ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry); err != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+		}
+
+		ffh.webhooks.Dispatch(webhookmodel.FeatureFlagToggle, organizationID, featureFlagID, userID, map[string]interface{}{
+			"environment": environmentName,
+			"is_enabled":  *request.IsEnabled,
+		})
+		ffh.events.Publish(FlagEvent{
+			Type:           FlagToggledEvent,
+			OrganizationID: organizationID,
+			FeatureFlagID:  featureFlagID,
+			Environment:    environmentName,
+			Data: map[string]interface{}{
+				"is_enabled": *request.IsEnabled,
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (ffh *FeatureFlagHandler) PatchFeatureFlagTags(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	organization, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organization, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(PatchFeatureFlagTagsRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	tags := featureflagmodel.NormalizeTags(request.Tags)
+
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		if err := ffh.featureFlags.SetTags(ctx, organizationID, featureFlagID, tags); err != nil {
+			return err
+		}
+
+		// The organization keeps every tag ever used so it can suggest them
+		return ffh.organizations.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: organizationID}},
+			bson.D{{Key: "$addToSet",
+				Value: bson.M{"tags": bson.M{"$each": tags}},
+			}},
+		)
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.requestLogger(c).Info("Feature flag updated",
+		zap.String("_id", featureFlagID.Hex()))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ffh *FeatureFlagHandler) RenameFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(RenameFeatureFlagRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	environmentNames := make([]string, 0, len(featureFlagRecord.Environments))
+	for _, environment := range featureFlagRecord.Environments {
+		environmentNames = append(environmentNames, environment.Name)
+	}
+
+	nameInUse, err := ffh.featureFlags.NameInUse(
+		context.Background(),
+		organizationID,
+		request.Name,
+		environmentNames,
+		featureFlagID,
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if nameInUse {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NameConflictError,
+		)
+	}
+
+	filters := bson.M{"$and": []bson.M{
+		{"_id": featureFlagID},
+		{"organization_id": organizationID},
+	}}
+	err = ffh.featureFlags.UpdateOne(featureflagmodel.WithUpdatedBy(context.Background(), userID), filters, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: request.Name}}},
+	})
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	timelineEntry := timelinemodel.NewTimelineEntry(
+		userID,
+		fmt.Sprintf(timelinemodel.FeatureFlagRenamed, featureFlagRecord.Name, request.Name),
+		map[string]interface{}{
+			timelinemodel.OldNameMetadataKey: featureFlagRecord.Name,
+			timelinemodel.NewNameMetadataKey: request.Name,
+		},
+	)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.Name = request.Name
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+func (ffh *FeatureFlagHandler) PatchFeatureFlagDescription(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(DescriptionRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request.Description = strings.TrimSpace(request.Description)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	// The description isn't part of the flag config, so it is edited in
+	// place rather than through a revision
+	featureFlagRecord, err := ffh.featureFlags.FindActiveByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	filters := bson.M{"$and": []bson.M{
+		{"_id": featureFlagID},
+		{"organization_id": organizationID},
+	}}
+	err = ffh.featureFlags.UpdateOne(featureflagmodel.WithUpdatedBy(context.Background(), userID), filters, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "description", Value: request.Description}}},
+	})
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	timelineEntry := timelinemodel.NewTimelineEntry(
+		userID,
+		timelinemodel.DescriptionChanged,
+		nil,
+	)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.Description = request.Description
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// EvaluateFeatureFlag is also reachable with an organization API key, which
+// grants read only access to this endpoint alone
+func (ffh *FeatureFlagHandler) EvaluateFeatureFlag(c echo.Context) error {
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if !apiutils.IsAPIKeyRequest(c) {
+		userID, err := apiutils.GetUserFromContext(c)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
+
+		permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+		if !permission {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.ForbiddenError)),
+			)
+			return apiutils.PermissionDenied(c)
+		}
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(EvaluateFeatureFlagRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request.Environment = organizationRecord.ResolveEnvironment(request.Environment)
+	if request.Environment == "" {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.MissingEnvironmentError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.MissingEnvironmentError,
+		)
+	}
+
+	featureFlagRecords, err := ffh.flags.Flags(
+		context.Background(),
+		organizationID,
+		request.Environment,
+		func(ctx context.Context) ([]featureflagmodel.FeatureFlagRecord, error) {
+			return ffh.featureFlags.FindAll(ctx, organizationID)
+		},
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlags := make(map[primitive.ObjectID]*featureflagmodel.FeatureFlagRecord, len(featureFlagRecords))
+	for index := range featureFlagRecords {
+		featureFlags[featureFlagRecords[index].ID] = &featureFlagRecords[index]
+	}
+
+	featureFlagRecord, ok := featureFlags[featureFlagID]
+	if !ok {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(mongo.ErrNoDocuments),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	var detail evaluator.Detail
+	if organizationRecord.MaintenanceMode {
+		detail, err = evaluator.EvaluateMaintenance(featureFlagRecord, request.Environment)
+	} else {
+		detail, err = evaluator.EvaluateDetailWithPrerequisites(
+			featureFlagRecord,
+			request.Environment,
+			request.Context,
+			time.Now().UTC(),
+			func(id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+				prerequisite, ok := featureFlags[id]
+				if !ok {
+					return nil, mongo.ErrNoDocuments
+				}
+
+				return prerequisite, nil
+			},
+		)
+	}
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	typedValue, err := featureFlagRecord.TypedValue(detail.Value)
+	if err != nil {
+		// Values stored before they were checked against the flag type are
+		// served as they are
+		ffh.requestLogger(c).Warn("Untyped feature flag value",
+			zap.String("_id", featureFlagRecord.ID.Hex()),
+			zap.Error(err),
+		)
+		typedValue = detail.Value
+	}
+
+	return c.JSON(http.StatusOK, EvaluateFeatureFlagResponse{
+		Value:  typedValue,
+		Reason: detail.Reason,
+	})
+}
+
+// BulkApproveRevisions approves a draft revision of each flag as a single
+// change set, all of them or none
+func (ffh *FeatureFlagHandler) BulkApproveRevisions(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(BulkApproveRevisionsRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	// Approving two revisions of a flag at once would have the second
+	// overwrite the first
+	seen := make(map[primitive.ObjectID]bool, len(request.Revisions))
+	for _, approval := range request.Revisions {
+		if seen[approval.FeatureFlagID] {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.DuplicateFeatureFlagError)),
+				zap.String("feature_flag_id", approval.FeatureFlagID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusBadRequest,
+				apierrors.DuplicateFeatureFlagError,
+			)
+		}
+		seen[approval.FeatureFlagID] = true
+	}
+
+	if request.ChangeSetID == "" {
+		request.ChangeSetID = primitive.NewObjectID().Hex()
+	}
+
+	// Every revision is checked before anything is written, and everything
+	// is written in a single transaction, so a release is either approved
+	// as a whole or not at all. The writes are the same targeted updates
+	// ApproveRevision makes, a revision that changed since it was checked
+	// fails the whole change set with a conflict.
+	for _, approval := range request.Revisions {
+		featureFlagRecord, err := ffh.featureFlags.FindActiveByID(
+			context.Background(),
+			organizationID,
+			approval.FeatureFlagID,
+		)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				ffh.requestLogger(c).Debug("Client error",
+					zap.Error(err),
+				)
+				return apierrors.CustomError(c,
+					http.StatusNotFound,
+					apierrors.NotFoundError,
+				)
+			}
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		revision := featureFlagRecord.FindRevision(approval.RevisionID)
+		if revision == nil || revision.Status != featureflagmodel.Draft {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.String("cause", "revision is not a draft"),
+				zap.String("revision_id", approval.RevisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
+
+		if revision.UserID == userID && !organizationRecord.AllowSelfApproval {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.SelfApprovalError)),
+				zap.String("revision_id", approval.RevisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusForbidden,
+				apierrors.SelfApprovalError,
+			)
+		}
+
+		if revision.ApprovedBy(userID) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.AlreadyApprovedError)),
+				zap.String("revision_id", approval.RevisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.AlreadyApprovedError,
+			)
+		}
+	}
+
+	var featureFlagRecords []featureflagmodel.FeatureFlagRecord
+	var actions []string
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		featureFlagRecords = make([]featureflagmodel.FeatureFlagRecord, 0, len(request.Revisions))
+		actions = make([]string, 0, len(request.Revisions))
+		for _, approval := range request.Revisions {
+			featureFlagRecord, err := ffh.featureFlags.AddApproval(
+				ctx,
+				organizationID,
+				approval.FeatureFlagID,
+				approval.RevisionID,
+				userID,
+			)
+			if err != nil {
+				return err
+			}
+
+			action := timelinemodel.RevisionApprovalAdded
+			if len(featureFlagRecord.FindRevision(approval.RevisionID).Approvals) >= organizationRecord.ApprovalThreshold() {
+				featureFlagRecord, action, err = completeApproval(
+					ctx,
+					ffh.featureFlags,
+					featureFlagRecord,
+					approval.RevisionID,
+					nil,
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			timelineEntry := timelinemodel.NewTimelineEntry(userID, action, map[string]interface{}{
+				timelinemodel.ChangeSetIDMetadataKey: request.ChangeSetID,
+				timelinemodel.ReleaseNoteMetadataKey: request.ReleaseNote,
+				timelinemodel.RevisionIDMetadataKey:  approval.RevisionID.Hex(),
+			})
+			if err := ffh.timelines.UpdateOne(ctx, featureFlagRecord.ID, timelineEntry); err != nil {
+				return err
+			}
+
+			featureFlagRecords = append(featureFlagRecords, *featureFlagRecord)
+			actions = append(actions, action)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.RevisionNotDraftError)),
+				zap.String("change_set_id", request.ChangeSetID),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.RevisionNotDraftError,
+			)
+		}
+		if errors.Is(err, errApprovalContention) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+				zap.String("change_set_id", request.ChangeSetID),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.ConcurrentUpdateError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// Nothing goes out before the whole change set is committed
+	for index, featureFlagRecord := range featureFlagRecords {
+		if actions[index] != timelinemodel.RevisionApproved {
+			continue
+		}
+
+		ffh.webhooks.Dispatch(
+			webhookmodel.RevisionApproved,
+			organizationID,
+			featureFlagRecord.ID,
+			userID,
+			map[string]interface{}{
+				"revision_id":   request.Revisions[index].RevisionID.Hex(),
+				"version":       featureFlagRecord.Version,
+				"change_set_id": request.ChangeSetID,
+			},
+		)
+		ffh.events.Publish(FlagEvent{
+			Type:           FlagApprovedEvent,
+			OrganizationID: organizationID,
+			FeatureFlagID:  featureFlagRecord.ID,
+			Data: map[string]interface{}{
+				"revision_id":   request.Revisions[index].RevisionID.Hex(),
+				"version":       featureFlagRecord.Version,
+				"change_set_id": request.ChangeSetID,
+			},
+		})
+	}
+
+	ffh.requestLogger(c).Info("Approved change set",
+		zap.String("change_set_id", request.ChangeSetID),
+		zap.Int("revisions", len(featureFlagRecords)),
+	)
+	return c.JSON(http.StatusOK, ChangeSetResponse{
+		ChangeSetID: request.ChangeSetID,
+		Data:        featureFlagRecords,
+	})
+}
+
+func (ffh *FeatureFlagHandler) ListChangeSetFeatureFlags(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	changeSetID := c.Param("changeSetID")
+
+	featureFlagIDs, err := ffh.timelines.FindFeatureFlagIDsByChangeSet(context.Background(), changeSetID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecords, err := ffh.featureFlags.FindByIDs(context.Background(), organizationID, featureFlagIDs)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	return c.JSON(http.StatusOK, ChangeSetResponse{
+		ChangeSetID: changeSetID,
+		Data:        featureFlagRecords,
+	})
+}
+
+func (ffh *FeatureFlagHandler) RestoreFeatureFlag(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindDeletedByID(context.Background(), organizationID, featureFlagID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	environmentNames := make([]string, 0, len(featureFlagRecord.Environments))
+	for _, environment := range featureFlagRecord.Environments {
+		environmentNames = append(environmentNames, environment.Name)
+	}
+
+	nameInUse, err := ffh.featureFlags.NameInUse(
+		context.Background(),
+		organizationID,
+		featureFlagRecord.Name,
+		environmentNames,
+		featureFlagID,
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if nameInUse {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NameConflictError,
+		)
+	}
+
+	// A restored flag counts against the quota again. The flag comes back
+	// along with its timeline entry, and only if it's still deleted.
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagRestored, nil)
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		if err := ffh.checkFlagQuota(ctx, organizationRecord, 1); err != nil {
+			return err
+		}
+
+		if err := ffh.featureFlags.Restore(ctx, organizationID, featureFlagID); err != nil {
+			return err
+		}
+
+		return ffh.timelines.UpdateOne(ctx, featureFlagID, timelineEntry)
+	})
+	if err != nil {
+		if errors.Is(err, errFlagQuotaExceeded) {
+			return ffh.quotaExceeded(c, organizationRecord)
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	// The record was read while still deleted
+	featureFlagRecord.DeletedAt = 0
+
+	ffh.requestLogger(c).Info("Restored feature flag",
+		zap.String("_id", featureFlagID.Hex()))
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// ArchiveFeatureFlag hides the flag from the default list, it keeps
+// serving its default value
+func (ffh *FeatureFlagHandler) ArchiveFeatureFlag(c echo.Context) error {
+	return ffh.setArchived(c, true)
+}
+
+// UnarchiveFeatureFlag brings an archived flag back to the default list
+func (ffh *FeatureFlagHandler) UnarchiveFeatureFlag(c echo.Context) error {
+	return ffh.setArchived(c, false)
+}
+
+func (ffh *FeatureFlagHandler) setArchived(c echo.Context, isArchived bool) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	err = ffh.featureFlags.SetArchived(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		organizationID,
+		featureFlagID,
+		isArchived,
+	)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	action := timelinemodel.FeatureFlagUnarchived
+	if isArchived {
+		action = timelinemodel.FeatureFlagArchived
+	}
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, action, nil)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.requestLogger(c).Info("Archived feature flag",
+		zap.String("_id", featureFlagID.Hex()),
+		zap.Bool("is_archived", isArchived))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetExpectedConfig records the config hash the deployment pipeline expects
+// the flag to have. An empty hash pins the current live config.
+func (ffh *FeatureFlagHandler) SetExpectedConfig(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(SetExpectedConfigRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	liveHash, err := featureFlagRecord.ConfigHash()
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	expectedHash := strings.TrimSpace(request.ConfigHash)
+	if expectedHash == "" {
+		expectedHash = liveHash
+	}
+
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		bson.M{"$and": []bson.M{
+			{"_id": featureFlagID},
+			{"organization_id": organizationID},
+		}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "expected_config_hash", Value: expectedHash}}},
+			{Key: "$unset", Value: bson.M{"drift_acknowledged_hash": 1}},
+		},
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.ExpectedConfigHash = expectedHash
+	featureFlagRecord.DriftAcknowledgedHash = ""
+
+	ffh.requestLogger(c).Info("Set expected config",
+		zap.String("_id", featureFlagID.Hex()))
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// SetPrerequisites replaces the prerequisites of the flag. They're checked
+// against the organization's flags so none is missing and they can't end
+// up depending on the flag itself.
+func (ffh *FeatureFlagHandler) SetPrerequisites(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(SetPrerequisitesRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecords, err := ffh.featureFlags.FindAll(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	var featureFlagRecord *featureflagmodel.FeatureFlagRecord
+	for index, record := range featureFlagRecords {
+		if record.ID == featureFlagID {
+			featureFlagRecord = &featureFlagRecords[index]
+			break
+		}
+	}
+
+	if featureFlagRecord == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(mongo.ErrNoDocuments),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	err = featureflagmodel.ValidatePrerequisites(featureFlagID, request.Prerequisites, featureFlagRecords)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+
+		message := apierrors.BadRequestError
+		switch {
+		case errors.Is(err, featureflagmodel.ErrPrerequisiteCycle):
+			message = apierrors.PrerequisiteCycleError
+		case errors.Is(err, featureflagmodel.ErrPrerequisiteNotFound):
+			message = apierrors.PrerequisiteNotFoundError
+		case errors.Is(err, featureflagmodel.ErrInvalidValue):
+			message = apierrors.InvalidValueError
+		}
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			message,
+		)
+	}
+
+	prerequisites := request.Prerequisites
+	if prerequisites == nil {
+		prerequisites = []featureflagmodel.Prerequisite{}
+	}
+
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		bson.M{"$and": []bson.M{
+			{"_id": featureFlagID},
+			{"organization_id": organizationID},
+		}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "prerequisites", Value: prerequisites}}}},
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.PrerequisitesChanged, nil)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.Prerequisites = prerequisites
+
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// SetOverrides replaces the overrides of the flag, pinning the value served
+// to individual context keys. Every value has to be one the flag can hold.
+func (ffh *FeatureFlagHandler) SetOverrides(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(SetOverridesRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if err := featureFlagRecord.ValidateOverrides(request.Overrides); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			overrideValueMessage(err),
+		)
+	}
+
+	overrides := request.Overrides
+	if overrides == nil {
+		overrides = map[string]string{}
+	}
+
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		bson.M{"$and": []bson.M{
+			{"_id": featureFlagID},
+			{"organization_id": organizationID},
+		}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "overrides", Value: overrides}}}},
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+	})
+
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.OverridesChanged, nil)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.Overrides = overrides
+
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// ExportFlags writes the live config of every flag of the organization as
+// JSON, or YAML with ?format=yaml
+func (ffh *FeatureFlagHandler) ExportFlags(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format != "" && format != ExportFormatJSON && format != ExportFormatYAML {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("format", format),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagRecords, err := ffh.featureFlags.FindAll(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	document := featureflagmodel.NewExportDocument(featureFlagRecords)
+
+	// The document is encoded up front so its ETag can be checked against
+	// the one the client already holds
+	body := new(bytes.Buffer)
+	contentType := echo.MIMEApplicationJSONCharsetUTF8
+	filename := "flags.json"
+	if format == ExportFormatYAML {
+		contentType = MIMEApplicationYAML
+		filename = "flags.yaml"
+
+		encoder := yaml.NewEncoder(body)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	} else {
+		encoder := json.NewEncoder(body)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return apiutils.BlobWithETag(c, apiutils.ETag(body.Bytes()), http.StatusOK, contentType, body.Bytes())
+}
+
+// ImportFlags applies an export document to the organization. The whole
+// document is validated before anything is written. Config changes to
+// existing flags are proposed as draft revisions so they still go through
+// approval.
+func (ffh *FeatureFlagHandler) ImportFlags(c echo.Context) error {
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = featureflagmodel.ImportCreateOnly
+	}
+
+	if !featureflagmodel.IsValidImportMode(mode) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("mode", mode),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	document := new(featureflagmodel.ExportDocument)
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), MIMEApplicationYAML) {
+		err = yaml.NewDecoder(c.Request().Body).Decode(document)
+	} else {
+		err = json.NewDecoder(c.Request().Body).Decode(document)
+	}
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecords, err := ffh.featureFlags.FindAll(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	plan, err := featureflagmodel.PlanImport(document, featureFlagRecords, organizationRecord, mode)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		message := apierrors.BadRequestError
+		if errors.Is(err, featureflagmodel.ErrInvalidFlagType) {
+			message = apierrors.InvalidFlagTypeError
+		}
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			message,
+		)
+	}
+
+	response := ImportFlagsResponse{
+		Mode:      mode,
+		DryRun:    dryRun,
+		Created:   make([]string, 0, len(plan.Create)),
+		Updated:   make([]string, 0, len(plan.Update)),
+		Unchanged: plan.Unchanged,
+		Deleted:   make([]string, 0, len(plan.Delete)),
+	}
+	for _, flag := range plan.Create {
+		response.Created = append(response.Created, flag.Name)
+	}
+	for _, update := range plan.Update {
+		response.Updated = append(response.Updated, update.Flag.Name)
+	}
+	for _, record := range plan.Delete {
+		response.Deleted = append(response.Deleted, record.Name)
+	}
+
+	// Flags the import deletes free up room for the ones it creates
+	flags := len(featureFlagRecords) + len(plan.Create) - len(plan.Delete)
+	if len(plan.Create) > 0 && !organizationRecord.FlagQuotaAllows(flags) {
+		return ffh.quotaExceeded(c, organizationRecord)
+	}
+
+	// Deleting flags takes the same permission whether it's done one by one
+	// or through an import
+	deletionPermission := organizationRecord.FlagDeletionPermission()
+	if len(plan.Delete) > 0 && !apiutils.UserHasPermission(userID, organizationRecord, deletionPermission) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	if dryRun {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	tags := []string{}
+	for _, flag := range plan.Create {
+		tags = append(tags, flag.Tags...)
+	}
+	for _, update := range plan.Update {
+		tags = append(tags, update.Flag.Tags...)
+	}
+
+	// The import is applied as a whole or not at all, a failure on any flag
+	// rolls back the ones written before it
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		if len(tags) > 0 {
+			err := ffh.organizations.UpdateOne(
+				ctx,
+				bson.D{{Key: "_id", Value: organizationID}},
+				bson.D{{Key: "$addToSet",
+					Value: bson.M{"tags": bson.M{"$each": featureflagmodel.NormalizeTags(tags)}},
+				}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		for index := range plan.Create {
+			flag := &plan.Create[index]
+			featureFlagRecord := featureflagmodel.NewFeatureFlagRecord(
+				flag.Name,
+				flag.DefaultValue,
+				flag.Type,
+				flag.ToRules(),
+				organizationID,
+				userID,
+				flag.EnvironmentNames(),
+				flag.ProjectIn(organizationRecord),
+				flag.Tags,
+			)
+			featureFlagRecord.Environments = flag.Environments
+
+			featureFlagID, err := ffh.featureFlags.InsertOne(ctx, featureFlagRecord)
+			if err != nil {
+				return err
+			}
+
+			_, err = ffh.timelines.InsertOne(ctx,
+				&timelinemodel.TimelineRecord{
+					FeatureFlagID: featureFlagID,
+					Entries: []timelinemodel.TimelineEntry{
+						*timelinemodel.NewTimelineEntry(userID, timelinemodel.Created, nil),
+					},
+				})
+			if err != nil {
+				return err
+			}
+		}
+
+		for index := range plan.Update {
+			update := &plan.Update[index]
+			previousRevision := update.Record.LiveRevision()
+			if previousRevision == nil {
+				previousRevision = &featureflagmodel.Revision{Rules: []featureflagmodel.Rule{}}
+			}
+
+			changes := bson.D{{Key: "$set", Value: bson.D{
+				{Key: "tags", Value: update.Flag.Tags},
+				{Key: "environments", Value: update.Flag.Environments},
+				{Key: "project", Value: update.Flag.ProjectIn(organizationRecord)},
+			}}}
+
+			// A new revision bumps the version like one pushed through
+			// PatchFeatureFlag, so ETags and If-Match see the change
+			var revision *featureflagmodel.Revision
+			if update.ConfigChanged() {
+				revision = featureflagmodel.NewRevisionRecord(
+					update.Flag.DefaultValue,
+					update.Flag.ToRules(),
+					userID,
+				)
+				changes = append(changes,
+					bson.E{Key: "$push", Value: bson.M{"revisions": revision}},
+					bson.E{Key: "$inc", Value: bson.M{"version": 1}},
+				)
+			}
+
+			err := ffh.featureFlags.UpdateOne(
+				ctx,
+				bson.M{"$and": []bson.M{
+					{"_id": update.Record.ID},
+					{"organization_id": organizationID},
+				}},
+				changes,
+			)
+			if err != nil {
+				return err
+			}
+
+			if revision == nil {
+				continue
+			}
+
+			timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.RevisionCreated, map[string]interface{}{
+				timelinemodel.RevisionIDMetadataKey:      revision.ID.Hex(),
+				timelinemodel.OldDefaultValueMetadataKey: previousRevision.DefaultValue,
+				timelinemodel.NewDefaultValueMetadataKey: revision.DefaultValue,
+				timelinemodel.OldRulesMetadataKey:        previousRevision.Rules,
+				timelinemodel.NewRulesMetadataKey:        revision.Rules,
+			})
+			if err := ffh.timelines.UpdateOne(ctx, update.Record.ID, timelineEntry); err != nil {
+				return err
+			}
+		}
+
+		for _, record := range plan.Delete {
+			err := ffh.featureFlags.UpdateOne(
+				ctx,
+				bson.M{"$and": []bson.M{
+					{"_id": record.ID},
+					{"organization_id": organizationID},
+				}},
+				bson.D{{Key: "$set", Value: bson.D{
+					{Key: "deleted_at", Value: primitive.NewDateTimeFromTime(time.Now().UTC())},
+				}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagDeleted, nil)
+			if err := ffh.timelines.UpdateOne(ctx, record.ID, timelineEntry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.NameConflictError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// Any flag of the organization may have changed
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+	})
+	for _, record := range plan.Delete {
+		ffh.webhooks.Dispatch(webhookmodel.FeatureFlagDeleted, organizationID, record.ID, userID, nil)
+	}
+
+	ffh.requestLogger(c).Info("Imported feature flags",
+		zap.String("organization_id", organizationID.Hex()),
+		zap.String("mode", mode),
+	)
+	return c.JSON(http.StatusOK, response)
+}
+
+func (ffh *FeatureFlagHandler) ListDrift(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagRecords, err := ffh.featureFlags.FindWithExpectedConfigHash(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	alerts := make([]DriftAlert, 0)
+	for index := range featureFlagRecords {
+		featureFlagRecord := &featureFlagRecords[index]
+		drifted, liveHash, err := featureFlagRecord.HasDrifted()
+		if err != nil {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		if drifted {
+			alerts = append(alerts, DriftAlert{
+				FeatureFlagID:      featureFlagRecord.ID,
+				Name:               featureFlagRecord.Name,
+				ExpectedConfigHash: featureFlagRecord.ExpectedConfigHash,
+				LiveConfigHash:     liveHash,
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, ListDriftResponse{
+		Data: alerts,
+	})
+}
+
+// AcknowledgeDrift silences the drift alert for the current live config. A
+// further change to the flag raises the alert again.
+func (ffh *FeatureFlagHandler) AcknowledgeDrift(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	liveHash, err := featureFlagRecord.ConfigHash()
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		bson.M{"$and": []bson.M{
+			{"_id": featureFlagID},
+			{"organization_id": organizationID},
+		}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "drift_acknowledged_hash", Value: liveHash}}},
+		},
+	)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	featureFlagRecord.DriftAcknowledgedHash = liveHash
+
+	ffh.requestLogger(c).Info("Acknowledged drift",
+		zap.String("_id", featureFlagID.Hex()))
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+func (ffh *FeatureFlagHandler) ListRevisions(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	status := c.QueryParam("status")
+	if status != "" && !featureflagmodel.IsValidRevisionStatus(status) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("status", status),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	revisions := featureFlagRecord.FilterRevisions(status)
+	return c.JSON(http.StatusOK, common.Paginate(revisions, page, limit))
+}
+
+func (ffh *FeatureFlagHandler) GetTimeline(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	action := c.QueryParam("action")
+	if action != "" && !timelinemodel.IsValidActionFilter(action) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("action", action),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	entries := make([]timelinemodel.TimelineEntry, 0)
+	timelineRecord, err := ffh.timelines.FindByID(context.Background(), featureFlagRecord.ID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+	if timelineRecord != nil {
+		entries = timelineRecord.FilterEntries(action)
+	}
+	return c.JSON(http.StatusOK, common.Paginate(entries, page, limit))
+}
+
+func (ffh *FeatureFlagHandler) RejectRevision(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	revision := featureFlagRecord.FindRevision(revisionID)
+	if revision == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if revision.Status != featureflagmodel.Draft {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.RevisionNotDraftError)),
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.RevisionNotDraftError,
+		)
+	}
+
+	// The rejection only applies while the revision is still a draft, one
+	// racing an approval or a push can't undo it
+	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.RevisionRejected, map[string]interface{}{
+		timelinemodel.RevisionIDMetadataKey: revisionID.Hex(),
+	})
+	err = ffh.transact(featureflagmodel.WithUpdatedBy(context.Background(), userID), func(ctx context.Context) error {
+		rejected, err := ffh.featureFlags.RejectRevision(ctx, organizationID, featureFlagID, revisionID)
+		if err != nil {
+			return err
+		}
+
+		if err := ffh.timelines.UpdateOne(ctx, featureFlagID, timelineEntry); err != nil {
+			return err
+		}
+
+		featureFlagRecord = rejected
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.RevisionNotDraftError)),
+				zap.String("revision_id", revisionID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.RevisionNotDraftError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	ffh.requestLogger(c).Info("Rejected revision",
+		zap.String("_id", featureFlagID.Hex()),
+		zap.String("revision_id", revisionID.Hex()))
+	return c.JSON(http.StatusOK, featureFlagRecord)
+}
+
+// GetRevision serves a single revision of a flag, it's where PatchFeatureFlag
+// points the Location of the revision it creates
+func (ffh *FeatureFlagHandler) GetRevision(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
+	if err != nil {
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
 
-type PatchFeatureFlagTagsRequest struct {
-	Tags []string `json:"tags"`
-}
+	revision := featureFlagRecord.FindRevision(revisionID)
+	if revision == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
 
-type ListFeatureFlagResponse struct {
-	Page     int                                  `json:"page"`
-	PageSize int                                  `json:"page_size"`
-	Total    int                                  `json:"total"`
-	Data     []featureflagmodel.FeatureFlagRecord `json:"data"`
+	return c.JSON(http.StatusOK, revision)
 }
 
-func (ffh *FeatureFlagHandler) ListFeatureFlags(c echo.Context) error {
-	pageQuery := c.QueryParam("page")
-	limitQuery := c.QueryParam("page_size")
-
-	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
-
+func (ffh *FeatureFlagHandler) GetRevisionDiff(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -78,7 +5441,7 @@ func (ffh *FeatureFlagHandler) ListFeatureFlags(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -88,47 +5451,65 @@ func (ffh *FeatureFlagHandler) ListFeatureFlags(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organization, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(
-			c,
+		return apierrors.CustomError(c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organization, organizationmodel.ReadOnly)
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
 	if !permission {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
 		return apierrors.CustomError(
 			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
+	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
 
-	featureFlags, err := model.FindMany(context.Background(), organizationID, page, limit, bson.D{{
-		Key:   "timestamps.created_at",
-		Value: -1,
-	}})
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return c.JSON(http.StatusOK, ListFeatureFlagResponse{
-				Data:     featureflagmodel.EmptyFeatureRecordList,
-				Page:     page,
-				PageSize: limit,
-				Total:    0,
-			})
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
 		}
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -138,18 +5519,36 @@ func (ffh *FeatureFlagHandler) ListFeatureFlags(c echo.Context) error {
 		)
 	}
 
-	return c.JSON(http.StatusOK, ListFeatureFlagResponse{
-		Data:     featureFlags,
-		Page:     page,
-		PageSize: limit,
-		Total:    len(featureFlags),
-	})
+	revision := featureFlagRecord.FindRevision(revisionID)
+	if revision == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("revision_id", revisionID.Hex()),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	// Without a live revision the flag falls back to the default value it
+	// was created with
+	base := featureFlagRecord.LiveRevision()
+	if base == nil {
+		base = &featureflagmodel.Revision{
+			DefaultValue: featureFlagRecord.Revisions[0].DefaultValue,
+		}
+	}
+
+	return c.JSON(http.StatusOK, featureflagmodel.DiffRevisions(base, revision))
 }
 
-func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
+// GetRevisionImpact evaluates each context against the live revision and
+// the revision, so the contexts a draft would flip are known before it's
+// approved. Prerequisites are left out, they apply to both revisions alike.
+func (ffh *FeatureFlagHandler) GetRevisionImpact(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -161,7 +5560,7 @@ func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -171,10 +5570,9 @@ func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -183,21 +5581,41 @@ func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
 	if !permission {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
 		return apierrors.CustomError(
 			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	request := new(PostFeatureFlagRequest)
+	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	if err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(RevisionImpactRequest)
 	if err := c.Bind(request); err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -208,9 +5626,8 @@ func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
 	}
 
 	validate := validator.New()
-
 	if err := validate.Struct(request); err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -219,89 +5636,129 @@ func (ffh *FeatureFlagHandler) PostFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	if len(request.Tags) > 0 {
-		err = organizationModel.UpdateOne(
-			context.Background(),
-			bson.D{{Key: "_id", Value: organizationID}},
-			bson.D{{Key: "$addToSet",
-				Value: bson.M{"tags": bson.M{"$each": request.Tags}},
-			}},
+	request.Environment = organizationRecord.ResolveEnvironment(request.Environment)
+	if request.Environment == "" {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.MissingEnvironmentError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.MissingEnvironmentError,
 		)
 	}
 
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(c,
+		return apierrors.CustomError(
+			c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	featureFlagModel := featureflagmodel.New(ffh.db)
-	featureFlagRecord := featureflagmodel.NewFeatureFlagRecord(
-		request.Name,
-		request.DefaultValue,
-		request.Type,
-		request.Rules,
-		organizationID,
-		userID,
-		request.Environment,
-		request.Project,
-		request.Tags,
-	)
-
-	featureFlagID, err := featureFlagModel.InsertOne(context.Background(), featureFlagRecord)
-	if err != nil {
-		ffh.logger.Debug("Server error",
-			zap.Error(err),
+	revision := featureFlagRecord.FindRevision(revisionID)
+	if revision == nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.String("revision_id", revisionID.Hex()),
 		)
 		return apierrors.CustomError(c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
 		)
 	}
 
-	timelineModel := timelinemodel.New(ffh.db)
-	_, err = timelineModel.InsertOne(context.Background(),
-		&timelinemodel.TimelineRecord{
-			FeatureFlagID: featureFlagID,
-			Entries:       []timelinemodel.TimelineEntry{},
-		})
-	if err != nil {
-		ffh.logger.Debug("Client error",
-			zap.Error(err),
-		)
-		return apierrors.CustomError(
-			c,
-			http.StatusBadRequest,
-			apierrors.BadRequestError,
-		)
+	response := RevisionImpactResponse{
+		RevisionID: revisionID,
+		Data:       make([]RevisionImpact, 0, len(request.Contexts)),
 	}
 
-	timelineEntry := timelinemodel.NewTimelineEntry(
-		userID,
-		timelinemodel.Created,
-	)
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	// Without a live revision the flag falls back to the default value it
+	// was created with
+	base := featureFlagRecord.LiveRevision()
+	if base != nil {
+		response.BaseRevisionID = &base.ID
+	} else {
+		base = &featureflagmodel.Revision{
+			DefaultValue: featureFlagRecord.Revisions[0].DefaultValue,
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, evaluationContext := range request.Contexts {
+		oldDetail, err := evaluator.EvaluateRevisionDetail(
+			featureFlagRecord, base, request.Environment, evaluationContext, now)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+
+		newDetail, err := evaluator.EvaluateRevisionDetail(
+			featureFlagRecord, revision, request.Environment, evaluationContext, now)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+
+		impact := RevisionImpact{
+			Context:  evaluationContext,
+			OldValue: typedFlagValue(featureFlagRecord, oldDetail.Value),
+			NewValue: typedFlagValue(featureFlagRecord, newDetail.Value),
+		}
+		// Compared as typed values, so a number flag going from 1 to 1.0
+		// doesn't change
+		impact.Changed = !reflect.DeepEqual(impact.OldValue, impact.NewValue)
+		if impact.Changed {
+			response.Changed++
+		}
+		response.Data = append(response.Data, impact)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// typedFlagValue types the value after the flag. Values stored before they
+// were checked against the flag type are served as they are.
+func typedFlagValue(flag *featureflagmodel.FeatureFlagRecord, value string) interface{} {
+	typedValue, err := flag.TypedValue(value)
 	if err != nil {
-		ffh.logger.Debug("Server error",
-			zap.Error(err),
-		)
-		return apierrors.CustomError(c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
-		)
+		return value
 	}
 
-	return c.JSON(http.StatusCreated, featureFlagRecord)
+	return typedValue
 }
 
-func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
+// GetFeatureFlag serves a single flag with its created_at and updated_at
+// timestamps and the user who created it
+func (ffh *FeatureFlagHandler) GetFeatureFlag(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -313,7 +5770,7 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -323,10 +5780,9 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -335,21 +5791,17 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
 	if !permission {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -359,63 +5811,88 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	request := new(PatchFeatureFlagRequest)
-	if err := c.Bind(request); err != nil {
-		ffh.logger.Debug("Client error",
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
 			c,
-			http.StatusBadRequest,
-			apierrors.BadRequestError,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
 		)
 	}
 
-	featureFlagModel := featureflagmodel.New(ffh.db)
+	response := FeatureFlagResponse{FeatureFlagRecord: *featureFlagRecord}
 
-	revision := featureflagmodel.NewRevisionRecord(
-		request.DefaultValue,
-		request.Rules,
-		userID,
-	)
-	err = featureFlagModel.UpdateOne(
-		context.Background(),
-		bson.M{"$and": []bson.M{
-			{"_id": featureFlagID},
-			{"organization_id": organizationID},
-		}},
-		bson.D{{Key: "$push", Value: bson.M{"revisions": revision}}},
-	)
-	if err != nil {
-		ffh.logger.Debug("Server error",
+	author, err := ffh.users.FindByID(context.Background(), featureFlagRecord.UserID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(c,
+		return apierrors.CustomError(
+			c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	timelineModel := timelinemodel.New(ffh.db)
-	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.RevisionCreated)
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	response.CreatedBy = newFlagAuthorResponse(author)
+
+	if featureFlagRecord.UpdatedByID != nil {
+		editor, err := ffh.users.FindByID(context.Background(), *featureFlagRecord.UpdatedByID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+		response.UpdatedBy = newFlagAuthorResponse(editor)
+	}
+
+	// Pollers send back the ETag to skip downloading an unchanged flag
+	body, err := json.Marshal(response)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(c,
+		return apierrors.CustomError(
+			c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	return c.JSON(http.StatusOK, revision)
+	return apiutils.BlobWithETag(
+		c,
+		apiutils.VersionedETag(featureFlagRecord.Version, body),
+		http.StatusOK,
+		echo.MIMEApplicationJSONCharsetUTF8,
+		body,
+	)
 }
 
-func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
+func (ffh *FeatureFlagHandler) GetLiveConfig(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -427,7 +5904,7 @@ func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -437,10 +5914,9 @@ func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -449,21 +5925,17 @@ func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
 	if !permission {
-		ffh.logger.Debug("Server error",
-			zap.Error(errors.New(apierrors.UnauthorizedError)),
-		)
-		return apierrors.CustomError(
-			c,
-			http.StatusUnauthorized,
-			apierrors.UnauthorizedError,
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -473,72 +5945,80 @@ func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
 		)
 	}
 
-	revisionID, err := primitive.ObjectIDFromHex(c.Param("revisionID"))
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
 			c,
-			http.StatusBadRequest,
-			apierrors.BadRequestError,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
-	featureFlagRecord, err := model.FindByID(context.Background(), featureFlagID)
+	response := LiveConfigResponse{
+		Rules:        []featureflagmodel.Rule{},
+		Environments: featureFlagRecord.Environments,
+	}
+	if response.Environments == nil {
+		response.Environments = []featureflagmodel.FeatureFlagEnvironment{}
+	}
+
+	if revision := featureFlagRecord.LiveRevision(); revision != nil {
+		response.RevisionID = &revision.ID
+		response.DefaultValue = revision.DefaultValue
+		if revision.Rules != nil {
+			response.Rules = revision.Rules
+		}
+	} else if len(featureFlagRecord.Revisions) > 0 {
+		response.DefaultValue = featureFlagRecord.Revisions[0].DefaultValue
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (ffh *FeatureFlagHandler) PostEnvironment(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
 			c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	var lastRevisionID primitive.ObjectID
-	for index, revision := range featureFlagRecord.Revisions {
-		if revision.Status == featureflagmodel.Live {
-			featureFlagRecord.Revisions[index].Status = featureflagmodel.Archived
-			lastRevisionID = revision.ID
-		}
-		if revision.ID == revisionID && revision.Status == featureflagmodel.Draft {
-			featureFlagRecord.Revisions[index].Status = featureflagmodel.Live
-			featureFlagRecord.Revisions[index].LastRevisionID = &lastRevisionID
-		}
-	}
-	featureFlagRecord.Version++
-
-	filters := bson.M{"$and": []bson.M{
-		{"_id": featureFlagID},
-		{"organization_id": organizationID},
-	}}
-	newValues := bson.D{
-		{
-			Key: "$set", Value: bson.D{
-				{Key: "version", Value: featureFlagRecord.Version},
-				{Key: "revisions", Value: featureFlagRecord.Revisions},
-			},
-		},
-	}
-	err = model.UpdateOne(context.Background(), filters, newValues)
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	timelineModel := timelinemodel.New(ffh.db)
-	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.RevisionApproved)
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -547,13 +6027,17 @@ func (ffh *FeatureFlagHandler) ApproveRevision(c echo.Context) error {
 		)
 	}
 
-	return c.JSON(http.StatusOK, featureFlagRecord)
-}
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
+	if !permission {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.ForbiddenError)),
+		)
+		return apiutils.PermissionDenied(c)
+	}
 
-func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error {
-	userID, err := apiutils.GetUserFromContext(c)
+	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -563,9 +6047,9 @@ func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error
 		)
 	}
 
-	organizationID, err := apiutils.GetOrganizationFromContext(c)
-	if err != nil {
-		ffh.logger.Debug("Client error",
+	request := new(PostEnvironmentRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -575,85 +6059,112 @@ func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
-	if err != nil {
-		ffh.logger.Debug("Server error",
+	request.Name = strings.TrimSpace(request.Name)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
-	if !permission {
-		ffh.logger.Debug("Server error",
-			zap.Error(errors.New(apierrors.ForbiddenError)),
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
 		)
 		return apierrors.CustomError(
 			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
 		)
 	}
 
-	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
-	if err != nil {
-		ffh.logger.Debug("Client error",
-			zap.Error(err),
+	if len(organizationRecord.Environments) > 0 && !organizationRecord.HasEnvironment(request.Name) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.UndefinedEnvironmentError)),
+			zap.String("env", request.Name),
 		)
-		return apierrors.CustomError(
-			c,
+		return apierrors.CustomError(c,
 			http.StatusBadRequest,
-			apierrors.BadRequestError,
+			apierrors.UndefinedEnvironmentError,
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
-	featureFlagRecord, err := model.FindByID(context.Background(), featureFlagID)
+	for _, environment := range featureFlagRecord.Environments {
+		if environment.Name == request.Name {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.EnvironmentConflictError)),
+				zap.String("env", request.Name),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.EnvironmentConflictError,
+			)
+		}
+	}
+
+	// Flag names are unique per environment, so another flag with the same
+	// name may already own the new environment
+	nameInUse, err := ffh.featureFlags.NameInUse(
+		context.Background(),
+		organizationID,
+		featureFlagRecord.Name,
+		[]string{request.Name},
+		featureFlagID,
+	)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(
-			c,
+		return apierrors.CustomError(c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	newRevisionID := new(primitive.ObjectID)
-	for index, revision := range featureFlagRecord.Revisions {
-		if revision.Status == featureflagmodel.Live {
-			featureFlagRecord.Revisions[index].Status = featureflagmodel.Draft
-			newRevisionID = revision.LastRevisionID
-			featureFlagRecord.Revisions[index].LastRevisionID = nil
-		}
-	}
-	for index, revision := range featureFlagRecord.Revisions {
-		if revision.ID == *newRevisionID && revision.Status == featureflagmodel.Archived {
-			featureFlagRecord.Revisions[index].Status = featureflagmodel.Live
-		}
+	if nameInUse {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NameConflictError,
+		)
 	}
-	featureFlagRecord.Version--
 
-	filters := bson.M{"$and": []bson.M{
-		{"_id": featureFlagID},
-		{"organization_id": organizationID},
-	}}
-	newValues := bson.D{
-		{
-			Key: "$set", Value: bson.D{
-				{Key: "version", Value: featureFlagRecord.Version},
-				{Key: "revisions", Value: featureFlagRecord.Revisions},
-			},
-		},
+	environment := featureflagmodel.FeatureFlagEnvironment{
+		Name:      request.Name,
+		IsEnabled: request.IsEnabled,
 	}
-	err = model.UpdateOne(context.Background(), filters, newValues)
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
+		bson.M{"$and": []bson.M{
+			{"_id": featureFlagID},
+			{"organization_id": organizationID},
+		}},
+		bson.D{
+			{Key: "$push", Value: bson.M{"environments": environment}},
+		},
+	)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -661,11 +6172,26 @@ func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error
 			apierrors.InternalServerError,
 		)
 	}
-	timelineModel := timelinemodel.New(ffh.db)
-	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagRollback)
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+
+	featureFlagRecord.Environments = append(featureFlagRecord.Environments, environment)
+
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+		Environment:    request.Name,
+	})
+
+	timelineEntry := timelinemodel.NewTimelineEntry(
+		userID,
+		fmt.Sprintf(timelinemodel.EnvironmentAdded, request.Name),
+		map[string]interface{}{
+			timelinemodel.EnvironmentMetadataKey: request.Name,
+		},
+	)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -674,13 +6200,16 @@ func (ffh *FeatureFlagHandler) RollbackFeatureFlagVersion(c echo.Context) error
 		)
 	}
 
-	return c.JSON(http.StatusOK, featureFlagRecord)
+	ffh.requestLogger(c).Info("Added environment",
+		zap.String("_id", featureFlagID.Hex()),
+		zap.String("env", request.Name))
+	return c.JSON(http.StatusCreated, featureFlagRecord)
 }
 
-func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
+func (ffh *FeatureFlagHandler) DeleteEnvironment(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -692,7 +6221,7 @@ func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -702,10 +6231,9 @@ func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -716,19 +6244,15 @@ func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
 
 	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
 	if !permission {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -738,39 +6262,98 @@ func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
+	featureFlagRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	environmentName := c.Param("name")
+	environments := make([]featureflagmodel.FeatureFlagEnvironment, 0, len(featureFlagRecord.Environments))
+	for _, environment := range featureFlagRecord.Environments {
+		if environment.Name != environmentName {
+			environments = append(environments, environment)
+		}
+	}
+
+	if len(environments) == len(featureFlagRecord.Environments) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.EnvironmentNotFoundError)),
+			zap.String("env", environmentName),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.EnvironmentNotFoundError,
+		)
+	}
 
-	err = model.UpdateOne(
-		context.Background(),
+	if len(environments) == 0 {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.LastEnvironmentError)),
+			zap.String("env", environmentName),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.LastEnvironmentError,
+		)
+	}
+
+	err = ffh.featureFlags.UpdateOne(
+		featureflagmodel.WithUpdatedBy(context.Background(), userID),
 		bson.M{"$and": []bson.M{
 			{"_id": featureFlagID},
 			{"organization_id": organizationID},
 		}},
 		bson.D{
-			{Key: "$set", Value: bson.D{
-				{
-					Key:   "deleted_at",
-					Value: primitive.NewDateTimeFromTime(time.Now().UTC()),
-				},
-			}},
+			{Key: "$pull", Value: bson.M{"environments": bson.M{"name": environmentName}}},
 		},
 	)
-
 	if err != nil {
-		ffh.logger.Debug("Server error",
-			zap.Error(err))
-		return apierrors.CustomError(
-			c,
+		ffh.requestLogger(c).Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	timelineModel := timelinemodel.New(ffh.db)
-	timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.FeatureFlagDeleted)
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagUpdatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+		Environment:    environmentName,
+	})
+
+	timelineEntry := timelinemodel.NewTimelineEntry(
+		userID,
+		fmt.Sprintf(timelinemodel.EnvironmentRemoved, environmentName),
+		map[string]interface{}{
+			timelinemodel.EnvironmentMetadataKey: environmentName,
+		},
+	)
+	err = ffh.timelines.UpdateOne(context.Background(), featureFlagID, timelineEntry)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -779,15 +6362,16 @@ func (ffh *FeatureFlagHandler) DeleteFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	ffh.logger.Info("Soft deleted feature flag",
-		zap.String("_id", featureFlagID.Hex()))
+	ffh.requestLogger(c).Info("Removed environment",
+		zap.String("_id", featureFlagID.Hex()),
+		zap.String("env", environmentName))
 	return c.NoContent(http.StatusNoContent)
 }
 
-func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
+func (ffh *FeatureFlagHandler) CloneFeatureFlag(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -799,7 +6383,7 @@ func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
 
 	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -809,10 +6393,9 @@ func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -820,21 +6403,18 @@ func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
 			apierrors.InternalServerError,
 		)
 	}
+
 	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
 	if !permission {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(errors.New(apierrors.ForbiddenError)),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -844,115 +6424,194 @@ func (ffh *FeatureFlagHandler) ToggleFeatureFlag(c echo.Context) error {
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
-	featureFlagRecord, err := model.FindByID(context.Background(), featureFlagID)
-	if err != nil {
-		ffh.logger.Debug("Server error",
+	request := new(CloneFeatureFlagRequest)
+	if err := c.Bind(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
 			c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
 		)
 	}
 
-	environmentName := c.QueryParams().Get("env")
-	for index, environment := range featureFlagRecord.Environments {
-		if environment.Name == environmentName {
-			featureFlagRecord.Environments[index].IsEnabled = !(featureFlagRecord.Environments[index].IsEnabled)
-		}
+	request.Name = strings.TrimSpace(request.Name)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
 	}
 
-	filters := bson.M{"$and": []bson.M{
-		{"_id": featureFlagID},
-		{"organization_id": organizationID},
-	}}
-	newValues := bson.D{
-		{
-			Key: "$set", Value: bson.D{
-				{Key: "environments", Value: featureFlagRecord.Environments},
-			},
-		},
-	}
-	err = model.UpdateOne(context.Background(), filters, newValues)
+	sourceRecord, err := ffh.featureFlags.FindOne(context.Background(), bson.D{
+		{Key: "_id", Value: featureFlagID},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(c,
+		return apierrors.CustomError(
+			c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	timelineModel := timelinemodel.New(ffh.db)
-	timelineEntry := timelinemodel.NewTimelineEntry(userID, fmt.Sprintf(timelinemodel.FeatureFlagToggle, environmentName))
-	err = timelineModel.UpdateOne(context.Background(), featureFlagID, timelineEntry)
-	if err != nil {
-		ffh.logger.Debug("Server error",
-			zap.Error(err),
+	// Flags stored before a type was retired keep it, cloning one would
+	// create a new flag of a type nothing accepts anymore
+	if !sourceRecord.Type.IsValid() {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(featureflagmodel.ErrInvalidFlagType),
+			zap.String("type", string(sourceRecord.Type)),
 		)
 		return apierrors.CustomError(c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+			http.StatusBadRequest,
+			apierrors.InvalidFlagTypeError,
 		)
 	}
 
-	return c.JSON(http.StatusOK, featureFlagRecord)
-}
+	environmentNames := make([]string, 0, len(sourceRecord.Environments))
+	for _, environment := range sourceRecord.Environments {
+		environmentNames = append(environmentNames, environment.Name)
+	}
 
-func (ffh *FeatureFlagHandler) PatchFeatureFlagTags(c echo.Context) error {
-	userID, err := apiutils.GetUserFromContext(c)
+	nameInUse, err := ffh.featureFlags.NameInUse(
+		context.Background(),
+		organizationID,
+		request.Name,
+		environmentNames,
+		primitive.NilObjectID,
+	)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
-			http.StatusUnauthorized,
-			apierrors.UnauthorizedError,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
 		)
 	}
 
-	organizationID, err := apiutils.GetOrganizationFromContext(c)
-	if err != nil {
-		ffh.logger.Debug("Client error",
-			zap.Error(err),
+	if nameInUse {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
 		)
 		return apierrors.CustomError(c,
-			http.StatusUnauthorized,
-			apierrors.UnauthorizedError,
+			http.StatusConflict,
+			apierrors.NameConflictError,
 		)
 	}
 
-	organizationModel := organizationmodel.New(ffh.db)
-	organization, err := organizationModel.FindByID(context.Background(), organizationID)
+	// The clone starts from what the source currently serves, or from its
+	// latest revision when nothing is live yet
+	sourceRevision := sourceRecord.LiveRevision()
+	if sourceRevision == nil && len(sourceRecord.Revisions) > 0 {
+		sourceRevision = &sourceRecord.Revisions[len(sourceRecord.Revisions)-1]
+	}
+	if sourceRevision == nil {
+		sourceRevision = &featureflagmodel.Revision{}
+	}
+	rules := make([]featureflagmodel.Rule, len(sourceRevision.Rules))
+	copy(rules, sourceRevision.Rules)
+
+	featureFlagRecord := featureflagmodel.NewFeatureFlagRecord(
+		request.Name,
+		sourceRevision.DefaultValue,
+		sourceRecord.Type,
+		rules,
+		organizationID,
+		userID,
+		environmentNames,
+		nil,
+		nil,
+	)
+	featureFlagRecord.Environments = append([]featureflagmodel.FeatureFlagEnvironment{}, sourceRecord.Environments...)
+	featureFlagRecord.NumberBounds = sourceRecord.NumberBounds
+	featureFlagRecord.Schema = sourceRecord.Schema
+
+	// The clone is only created along with its timeline, like any other
+	// flag
+	var clonedID primitive.ObjectID
+	err = ffh.transact(context.Background(), func(ctx context.Context) error {
+		if err := ffh.checkFlagQuota(ctx, organizationRecord, 1); err != nil {
+			return err
+		}
+
+		var err error
+		clonedID, err = ffh.featureFlags.InsertOne(ctx, featureFlagRecord)
+		if err != nil {
+			return err
+		}
+
+		_, err = ffh.timelines.InsertOne(ctx,
+			&timelinemodel.TimelineRecord{
+				FeatureFlagID: clonedID,
+				Entries:       []timelinemodel.TimelineEntry{},
+			})
+		if err != nil {
+			return err
+		}
+
+		timelineEntry := timelinemodel.NewTimelineEntry(userID, timelinemodel.Created, nil)
+		return ffh.timelines.UpdateOne(ctx, clonedID, timelineEntry)
+	})
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		if errors.Is(err, errFlagQuotaExceeded) {
+			return ffh.quotaExceeded(c, organizationRecord)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.NameConflictError,
+			)
+		}
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
-		return apierrors.CustomError(
-			c,
+		return apierrors.CustomError(c,
 			http.StatusInternalServerError,
 			apierrors.InternalServerError,
 		)
 	}
 
-	permission := apiutils.UserHasPermission(userID, organization, organizationmodel.Collaborator)
-	if !permission {
-		ffh.logger.Debug("Client error",
-			zap.Error(errors.New(apierrors.ForbiddenError)),
-		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
-	}
+	ffh.events.Publish(FlagEvent{
+		Type:           FlagCreatedEvent,
+		OrganizationID: organizationID,
+		FeatureFlagID:  clonedID,
+	})
 
-	featureFlagID, err := primitive.ObjectIDFromHex(c.Param("featureFlagID"))
+	ffh.requestLogger(c).Info("Cloned feature flag",
+		zap.String("source_id", featureFlagID.Hex()),
+		zap.String("_id", clonedID.Hex()))
+	return c.JSON(http.StatusCreated, featureFlagRecord)
+}
+
+// StreamFlags holds a Server-Sent Events connection pushing the changes
+// made to the organization's flags, restricted to a single environment
+// with ?env=. Like evaluation it accepts API keys.
+func (ffh *FeatureFlagHandler) StreamFlags(c echo.Context) error {
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
 	if err != nil {
-		ffh.logger.Debug("Client error",
+		ffh.requestLogger(c).Debug("Client error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(
@@ -962,27 +6621,9 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlagTags(c echo.Context) error {
 		)
 	}
 
-	request := new(PatchFeatureFlagTagsRequest)
-	if err := c.Bind(request); err != nil {
-		ffh.logger.Debug("Client error",
-			zap.Error(err),
-		)
-		return apierrors.CustomError(c,
-			http.StatusUnauthorized,
-			apierrors.UnauthorizedError,
-		)
-	}
-
-	update := bson.D{{Key: "$addToSet",
-		Value: bson.M{"tags": bson.M{"$each": request.Tags}},
-	}}
-
-	err = organizationModel.UpdateOne(context.Background(),
-		bson.D{{Key: "_id", Value: organizationID}},
-		update,
-	)
+	organizationRecord, err := ffh.organizations.FindByID(context.Background(), organizationID)
 	if err != nil {
-		ffh.logger.Debug("Server error",
+		ffh.requestLogger(c).Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
@@ -991,28 +6632,80 @@ func (ffh *FeatureFlagHandler) PatchFeatureFlagTags(c echo.Context) error {
 		)
 	}
 
-	model := featureflagmodel.New(ffh.db)
+	if !apiutils.IsAPIKeyRequest(c) {
+		userID, err := apiutils.GetUserFromContext(c)
+		if err != nil {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
 
-	err = model.UpdateOne(
-		context.Background(),
-		bson.M{"$and": []bson.M{
-			{"_id": featureFlagID},
-			{"organization_id": organizationID},
-		}},
-		update,
-	)
-	if err != nil {
-		ffh.logger.Debug("Server error",
-			zap.Error(err),
+		permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+		if !permission {
+			ffh.requestLogger(c).Debug("Client error",
+				zap.Error(errors.New(apierrors.ForbiddenError)),
+			)
+			return apiutils.PermissionDenied(c)
+		}
+	}
+
+	environment := c.QueryParam("env")
+	if environment != "" && len(organizationRecord.Environments) > 0 && !organizationRecord.HasEnvironment(environment) {
+		ffh.requestLogger(c).Debug("Client error",
+			zap.Error(errors.New(apierrors.UndefinedEnvironmentError)),
+			zap.String("env", environment),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusInternalServerError,
-			apierrors.InternalServerError,
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.UndefinedEnvironmentError,
 		)
 	}
 
-	ffh.logger.Info("Feature flag updated",
-		zap.String("_id", featureFlagID.Hex()))
-	return c.NoContent(http.StatusNoContent)
+	subscription := ffh.events.Subscribe(organizationID, environment)
+	defer ffh.events.Unsubscribe(subscription)
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set(echo.HeaderCacheControl, "no-cache")
+	response.Header().Set(echo.HeaderConnection, "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	heartbeat := time.NewTicker(config.StreamHeartbeatInterval * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-subscription.Done:
+			ffh.requestLogger(c).Debug("Dropped flag stream",
+				zap.String("organization_id", organizationID.Hex()),
+			)
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(response, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			response.Flush()
+		case event := <-subscription.Events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				ffh.requestLogger(c).Debug("Server error",
+					zap.Error(err),
+				)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return nil
+			}
+			response.Flush()
+		}
+	}
 }
@@ -0,0 +1,2213 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers/fixtures"
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	"github.com/Roll-Play/togglelabs/pkg/evaluator"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	idempotencykeymodel "github.com/Roll-Play/togglelabs/pkg/models/idempotency_key"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// These tests run the feature flag handler against mock repositories, so
+// unlike the suite they don't need a database
+
+func newMockContext(method, target string, body interface{}, userID, organizationID primitive.ObjectID) (
+	echo.Context,
+	*httptest.ResponseRecorder,
+) {
+	var requestBody []byte
+	if body != nil {
+		requestBody, _ = json.Marshal(body)
+	}
+
+	request := httptest.NewRequest(method, target, bytes.NewBuffer(requestBody))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	recorder := httptest.NewRecorder()
+
+	c := echo.New().NewContext(request, recorder)
+	c.Set("user", userID.Hex())
+	c.Set("organization", organizationID.Hex())
+
+	return c, recorder
+}
+
+// mockHandler wires fresh mock repositories for a member of one
+// organization. The handler copies Transact and Users when it's built, so
+// tests swap those before calling build.
+type mockHandler struct {
+	t              *testing.T
+	repositories   handlers.Repositories
+	featureFlags   *fixtures.MockFeatureFlagRepository
+	organizations  *fixtures.MockOrganizationRepository
+	timelines      *fixtures.MockTimelineRepository
+	userID         primitive.ObjectID
+	organizationID primitive.ObjectID
+}
+
+func newMockHandler(t *testing.T, permission organizationmodel.PermissionLevelEnum) *mockHandler {
+	repositories, featureFlags, organizations, timelines := fixtures.NewMockRepositories()
+	m := &mockHandler{
+		t:              t,
+		repositories:   repositories,
+		featureFlags:   featureFlags,
+		organizations:  organizations,
+		timelines:      timelines,
+		userID:         primitive.NewObjectID(),
+		organizationID: primitive.NewObjectID(),
+	}
+	m.setPermission(permission)
+
+	return m
+}
+
+func (m *mockHandler) setPermission(permission organizationmodel.PermissionLevelEnum) {
+	m.organizations.FindByIDFunc = func(_ context.Context, id primitive.ObjectID) (*organizationmodel.OrganizationRecord, error) {
+		if id != m.organizationID {
+			return nil, mongo.ErrNoDocuments
+		}
+
+		return &organizationmodel.OrganizationRecord{
+			ID:   m.organizationID,
+			Name: "the company",
+			Members: []organizationmodel.OrganizationMember{{
+				User:            usermodel.UserRecord{ID: m.userID},
+				PermissionLevel: permission,
+			}},
+		}, nil
+	}
+}
+
+// editOrganization changes the organization record the member is found in
+func (m *mockHandler) editOrganization(edit func(record *organizationmodel.OrganizationRecord)) {
+	findByID := m.organizations.FindByIDFunc
+	m.organizations.FindByIDFunc = func(ctx context.Context, id primitive.ObjectID) (*organizationmodel.OrganizationRecord, error) {
+		record, err := findByID(ctx, id)
+		if err == nil {
+			edit(record)
+		}
+		return record, err
+	}
+}
+
+// freeNames reports every flag name as available
+func (m *mockHandler) freeNames() {
+	m.featureFlags.NameInUseFunc = func(
+		_ context.Context,
+		_ primitive.ObjectID,
+		_ string,
+		_ []string,
+		_ primitive.ObjectID,
+	) (bool, error) {
+		return false, nil
+	}
+}
+
+// acceptTimelines lets every timeline write through
+func (m *mockHandler) acceptTimelines() {
+	m.timelines.InsertOneFunc = func(_ context.Context, _ *timelinemodel.TimelineRecord) (primitive.ObjectID, error) {
+		return primitive.NewObjectID(), nil
+	}
+	m.timelines.InsertManyFunc = func(_ context.Context, _ []*timelinemodel.TimelineRecord) error {
+		return nil
+	}
+	m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+		return nil
+	}
+}
+
+func (m *mockHandler) build() *handlers.FeatureFlagHandler {
+	return handlers.NewFeatureFlagHandlerWithRepositories(m.repositories, zap.NewNop())
+}
+
+// context builds a request of the member, params are pairs of route param
+// name and value
+func (m *mockHandler) context(method, target string, body interface{}, params ...string) (
+	echo.Context,
+	*httptest.ResponseRecorder,
+) {
+	c, recorder := newMockContext(method, target, body, m.userID, m.organizationID)
+	names := make([]string, 0, len(params)/2)
+	values := make([]string, 0, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	return c, recorder
+}
+
+// serve runs the endpoint on a request of the member
+func (m *mockHandler) serve(
+	endpoint echo.HandlerFunc,
+	method,
+	target string,
+	body interface{},
+	params ...string,
+) *httptest.ResponseRecorder {
+	c, recorder := m.context(method, target, body, params...)
+	assert.NoError(m.t, endpoint(c))
+
+	return recorder
+}
+
+func errorMessage(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	var response apierrors.Error
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	return response.Message
+}
+
+func TestPostFeatureFlagWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+	featureFlagID := primitive.NewObjectID()
+	m.freeNames()
+
+	var inserted *featureflagmodel.FeatureFlagRecord
+	m.featureFlags.InsertOneFunc = func(_ context.Context, record *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+		record.ID = featureFlagID
+		inserted = record
+		return featureFlagID, nil
+	}
+	m.timelines.InsertOneFunc = func(_ context.Context, record *timelinemodel.TimelineRecord) (primitive.ObjectID, error) {
+		assert.Equal(t, featureFlagID, record.FeatureFlagID)
+		return primitive.NewObjectID(), nil
+	}
+	var entries []timelinemodel.TimelineEntry
+	m.timelines.UpdateOneFunc = func(_ context.Context, id primitive.ObjectID, entry *timelinemodel.TimelineEntry) error {
+		assert.Equal(t, featureFlagID, id)
+		entries = append(entries, *entry)
+		return nil
+	}
+
+	h := m.build()
+	recorder := m.serve(h.PostFeatureFlag, http.MethodPost, "/features", handlers.PostFeatureFlagRequest{
+		Name:         "cool feature",
+		Type:         featureflagmodel.Boolean,
+		DefaultValue: "true",
+		Environment:  "prod",
+	})
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var response featureflagmodel.FeatureFlagRecord
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, featureFlagID, response.ID)
+	assert.Equal(t, m.organizationID, inserted.OrganizationID)
+	assert.Equal(t, m.userID, inserted.UserID)
+	assert.Equal(t, "prod", inserted.Environments[0].Name)
+
+	assert.Len(t, entries, 1)
+	assert.Equal(t, timelinemodel.Created, entries[0].Action)
+	assert.Equal(t, m.userID, entries[0].UserID)
+}
+
+// Every way of creating a flag writes it along with its timeline, a
+// timeline failure rolls the flag back
+func TestCreationTimelineFailureWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint func(h *handlers.FeatureFlagHandler) echo.HandlerFunc
+		body     interface{}
+	}{
+		{
+			name:     "post",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlag },
+			body: handlers.PostFeatureFlagRequest{
+				Name:         "cool feature",
+				Type:         featureflagmodel.Boolean,
+				DefaultValue: "true",
+				Environment:  "prod",
+			},
+		},
+		{
+			name:     "clone",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.CloneFeatureFlag },
+			body:     handlers.CloneFeatureFlagRequest{Name: "cool feature copy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Admin)
+			sourceID := primitive.NewObjectID()
+			m.freeNames()
+
+			m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:             sourceID,
+					OrganizationID: m.organizationID,
+					Name:           "cool feature",
+					Type:           featureflagmodel.Boolean,
+				}, nil
+			}
+			m.featureFlags.InsertOneFunc = func(_ context.Context, _ *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+				return primitive.NewObjectID(), nil
+			}
+			m.timelines.InsertOneFunc = func(_ context.Context, _ *timelinemodel.TimelineRecord) (primitive.ObjectID, error) {
+				return primitive.NilObjectID, errors.New("timeline unavailable")
+			}
+
+			// The transaction hands the failure back rather than committing
+			var transactionErr error
+			m.repositories.Transact = func(ctx context.Context, fn func(ctx context.Context) error) error {
+				transactionErr = fn(ctx)
+				return transactionErr
+			}
+
+			h := m.build()
+			published := 0
+			h.Events().Listen(func(handlers.FlagEvent) {
+				published++
+			})
+
+			recorder := m.serve(tt.endpoint(h), http.MethodPost, "/features", tt.body, "featureFlagID", sourceID.Hex())
+			assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			assert.EqualError(t, transactionErr, "timeline unavailable")
+			assert.Equal(t, 0, published)
+			assert.Equal(t, apierrors.InternalServerError, errorMessage(t, recorder))
+		})
+	}
+}
+
+func TestListFeatureFlagsWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		filter featureflagmodel.ListFilter
+	}{
+		{
+			name:   "filters by tag",
+			query:  "?page_size=2&tag=beta",
+			filter: featureflagmodel.ListFilter{Tags: []string{"beta"}},
+		},
+		{
+			name:   "leaves archived flags out",
+			query:  "?page_size=2",
+			filter: featureflagmodel.ListFilter{Tags: []string{}},
+		},
+		{
+			name:   "includes archived flags on request",
+			query:  "?page_size=2&include_archived=true",
+			filter: featureflagmodel.ListFilter{Tags: []string{}, IncludeArchived: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.ReadOnly)
+			m.featureFlags.FindManyFunc = func(
+				_ context.Context,
+				_ primitive.ObjectID,
+				filter featureflagmodel.ListFilter,
+				page,
+				limit int,
+				_ bson.D,
+			) ([]featureflagmodel.FeatureFlagRecord, int, error) {
+				assert.Equal(t, tt.filter, filter)
+				assert.Equal(t, 1, page)
+				assert.Equal(t, 2, limit)
+				return []featureflagmodel.FeatureFlagRecord{
+					{ID: primitive.NewObjectID(), OrganizationID: m.organizationID, Name: "first"},
+					{ID: primitive.NewObjectID(), OrganizationID: m.organizationID, Name: "second"},
+				}, 5, nil
+			}
+			m.repositories.Users = &fixtures.MockUserRepository{
+				FindActiveByIDsFunc: func(_ context.Context, _ []primitive.ObjectID) ([]usermodel.UserRecord, error) {
+					return []usermodel.UserRecord{}, nil
+				},
+			}
+
+			h := m.build()
+			recorder := m.serve(h.ListFeatureFlags, http.MethodGet, "/features"+tt.query, nil)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var response common.PaginatedResponse[featureflagmodel.FeatureFlagRecord]
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, 5, response.Total)
+			assert.Equal(t, 3, response.TotalPages)
+			assert.True(t, response.HasNext)
+			assert.Len(t, response.Data, 2)
+			assert.Equal(t, "first", response.Data[0].Name)
+		})
+	}
+}
+
+func TestListFeatureFlagsCursorWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.ReadOnly)
+
+	first, second, third := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	m.featureFlags.FindManyFunc = func(
+		_ context.Context,
+		_ primitive.ObjectID,
+		_ featureflagmodel.ListFilter,
+		_,
+		_ int,
+		_ bson.D,
+	) ([]featureflagmodel.FeatureFlagRecord, int, error) {
+		t.Fatal("a cursor request was served offset pages")
+		return nil, 0, nil
+	}
+	var afters []*primitive.ObjectID
+	m.featureFlags.FindAfterFunc = func(
+		_ context.Context,
+		_ primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+		after *primitive.ObjectID,
+		limit int,
+	) ([]featureflagmodel.FeatureFlagRecord, *primitive.ObjectID, error) {
+		assert.Equal(t, []string{"beta"}, filter.Tags)
+		assert.Equal(t, 2, limit)
+		afters = append(afters, after)
+
+		if after == nil {
+			return []featureflagmodel.FeatureFlagRecord{{ID: third}, {ID: second}}, &second, nil
+		}
+		return []featureflagmodel.FeatureFlagRecord{{ID: first}}, nil, nil
+	}
+	m.repositories.Users = &fixtures.MockUserRepository{
+		FindActiveByIDsFunc: func(_ context.Context, _ []primitive.ObjectID) ([]usermodel.UserRecord, error) {
+			return []usermodel.UserRecord{}, nil
+		},
+	}
+
+	h := m.build()
+	listFeatureFlags := func(cursor string) *httptest.ResponseRecorder {
+		return m.serve(h.ListFeatureFlags, http.MethodGet, "/features?page=3&page_size=2&tag=beta&cursor="+cursor, nil)
+	}
+
+	// An empty cursor starts from the newest flag
+	recorder := listFeatureFlags("")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response handlers.ListFeatureFlagCursorResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.PageSize)
+	assert.Equal(t, second.Hex(), response.NextCursor)
+	assert.True(t, response.HasNext)
+	assert.Len(t, response.Data, 2)
+
+	recorder = listFeatureFlags(response.NextCursor)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	response = handlers.ListFeatureFlagCursorResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Empty(t, response.NextCursor)
+	assert.False(t, response.HasNext)
+	assert.Equal(t, first, response.Data[0].ID)
+	assert.Equal(t, []*primitive.ObjectID{nil, &second}, afters)
+
+	recorder = listFeatureFlags("not-a-cursor")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, apierrors.InvalidCursorError, errorMessage(t, recorder))
+	assert.Len(t, afters, 2)
+}
+
+func TestDeleteFeatureFlagWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name                  string
+		permission            organizationmodel.PermissionLevelEnum
+		deletePermissionLevel organizationmodel.PermissionLevelEnum
+		softDeleteErr         error
+		status                int
+		softDeletes           int
+	}{
+		{name: "admin deletes", permission: organizationmodel.Admin, status: http.StatusNoContent, softDeletes: 1},
+		{name: "collaborator is forbidden", permission: organizationmodel.Collaborator, status: http.StatusForbidden},
+		{
+			// Organizations can hand deletion back to collaborators
+			name:                  "collaborator deletes when allowed",
+			permission:            organizationmodel.Collaborator,
+			deletePermissionLevel: organizationmodel.Collaborator,
+			status:                http.StatusNoContent,
+			softDeletes:           1,
+		},
+		{
+			name:          "missing flag",
+			permission:    organizationmodel.Admin,
+			softDeleteErr: mongo.ErrNoDocuments,
+			status:        http.StatusNotFound,
+			softDeletes:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, tt.permission)
+			if tt.deletePermissionLevel != "" {
+				m.editOrganization(func(record *organizationmodel.OrganizationRecord) {
+					record.DeletePermissionLevel = tt.deletePermissionLevel
+				})
+			}
+			featureFlagID := primitive.NewObjectID()
+			softDeletes := 0
+			m.featureFlags.SoftDeleteFunc = func(_ context.Context, _, id primitive.ObjectID) error {
+				assert.Equal(t, featureFlagID, id)
+				softDeletes++
+				return tt.softDeleteErr
+			}
+			m.acceptTimelines()
+
+			h := m.build()
+			recorder := m.serve(h.DeleteFeatureFlag, http.MethodDelete, "/features/"+featureFlagID.Hex(), nil,
+				"featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, tt.softDeletes, softDeletes)
+		})
+	}
+}
+
+func TestArchiveFeatureFlagWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint func(h *handlers.FeatureFlagHandler) echo.HandlerFunc
+		missing  bool
+		status   int
+		archived bool
+		action   string
+	}{
+		{
+			name:     "archives",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.ArchiveFeatureFlag },
+			status:   http.StatusNoContent,
+			archived: true,
+			action:   timelinemodel.FeatureFlagArchived,
+		},
+		{
+			name:     "unarchives",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.UnarchiveFeatureFlag },
+			status:   http.StatusNoContent,
+			action:   timelinemodel.FeatureFlagUnarchived,
+		},
+		{
+			name:     "missing flag",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.ArchiveFeatureFlag },
+			missing:  true,
+			status:   http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			var archived []bool
+			m.featureFlags.SetArchivedFunc = func(_ context.Context, _, _ primitive.ObjectID, isArchived bool) error {
+				if tt.missing {
+					return mongo.ErrNoDocuments
+				}
+				archived = append(archived, isArchived)
+				return nil
+			}
+			var actions []string
+			m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, entry *timelinemodel.TimelineEntry) error {
+				actions = append(actions, entry.Action)
+				return nil
+			}
+
+			h := m.build()
+			recorder := m.serve(tt.endpoint(h), http.MethodPost, "/", nil, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.missing {
+				assert.Empty(t, actions)
+				return
+			}
+
+			assert.Equal(t, []bool{tt.archived}, archived)
+			assert.Equal(t, []string{tt.action}, actions)
+		})
+	}
+}
+
+func TestEvaluateFeatureFlagCacheInvalidatedOnToggleWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Admin)
+
+	stored := featureflagmodel.NewFeatureFlagRecord(
+		"cool feature",
+		"false",
+		featureflagmodel.Boolean,
+		[]featureflagmodel.Rule{{Predicate: "plan:pro", Value: "true", IsEnabled: true}},
+		m.organizationID,
+		m.userID,
+		[]string{"prod"},
+		nil,
+		nil,
+	)
+	stored.ID = primitive.NewObjectID()
+	copyStored := func() *featureflagmodel.FeatureFlagRecord {
+		record := *stored
+		record.Environments = append([]featureflagmodel.FeatureFlagEnvironment{}, stored.Environments...)
+		return &record
+	}
+
+	loads := 0
+	m.featureFlags.FindAllFunc = func(_ context.Context, _ primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error) {
+		loads++
+		return []featureflagmodel.FeatureFlagRecord{*copyStored()}, nil
+	}
+	m.featureFlags.FindActiveByIDFunc = func(_ context.Context, _, _ primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+		return copyStored(), nil
+	}
+	m.featureFlags.UpdateOneFunc = func(_ context.Context, _ interface{}, update bson.D) error {
+		changes := update[0].Value.(bson.D)
+		stored.Environments = changes[0].Value.([]featureflagmodel.FeatureFlagEnvironment)
+		return nil
+	}
+	m.acceptTimelines()
+
+	h := m.build()
+	evaluate := func() handlers.EvaluateFeatureFlagResponse {
+		recorder := m.serve(h.EvaluateFeatureFlag, http.MethodPost, "/", handlers.EvaluateFeatureFlagRequest{
+			Environment: "prod",
+			Context:     map[string]interface{}{"plan": "pro"},
+		}, "featureFlagID", stored.ID.Hex())
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var response handlers.EvaluateFeatureFlagResponse
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response
+	}
+
+	assert.Equal(t, false, evaluate().Value)
+	disabled := evaluate()
+	assert.Equal(t, false, disabled.Value)
+	assert.Equal(t, evaluator.ReasonEnvironmentDisabled, disabled.Reason.Kind)
+	assert.Equal(t, 1, loads)
+
+	recorder := m.serve(h.ToggleFeatureFlag, http.MethodPatch, "/?env=prod", nil, "featureFlagID", stored.ID.Hex())
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// The toggle is served right away, not once the cached flags expire
+	enabled := evaluate()
+	assert.Equal(t, true, enabled.Value)
+	assert.Equal(t, evaluator.ReasonRuleMatch, enabled.Reason.Kind)
+	assert.Equal(t, stored.Revisions[0].Rules[0].ID, *enabled.Reason.RuleID)
+	assert.Equal(t, 2, loads)
+
+	recorder = m.serve(h.GetEvaluationCacheStats, http.MethodGet, "/healthz/cache", nil)
+	var stats cache.Stats
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stats))
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 2}, stats)
+
+	// The stats span every organization, members below admin don't get them
+	m.setPermission(organizationmodel.Collaborator)
+	recorder = m.serve(h.GetEvaluationCacheStats, http.MethodGet, "/healthz/cache", nil)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestGetFeatureFlagConditionalRequestWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Admin)
+
+	stored := featureflagmodel.NewFeatureFlagRecord(
+		"cool feature",
+		"true",
+		featureflagmodel.Boolean,
+		nil,
+		m.organizationID,
+		m.userID,
+		[]string{"prod"},
+		nil,
+		nil,
+	)
+	stored.ID = primitive.NewObjectID()
+	copyStored := func() *featureflagmodel.FeatureFlagRecord {
+		record := *stored
+		record.Environments = append([]featureflagmodel.FeatureFlagEnvironment{}, stored.Environments...)
+		return &record
+	}
+
+	m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		return copyStored(), nil
+	}
+	m.featureFlags.FindActiveByIDFunc = func(_ context.Context, _, _ primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+		return copyStored(), nil
+	}
+	m.featureFlags.UpdateOneFunc = func(_ context.Context, _ interface{}, update bson.D) error {
+		changes := update[0].Value.(bson.D)
+		stored.Environments = changes[0].Value.([]featureflagmodel.FeatureFlagEnvironment)
+		return nil
+	}
+	m.acceptTimelines()
+	m.repositories.Users = &fixtures.MockUserRepository{
+		FindByIDFunc: func(_ context.Context, _ primitive.ObjectID) (*usermodel.UserRecord, error) {
+			return nil, mongo.ErrNoDocuments
+		},
+	}
+
+	h := m.build()
+	get := func(etag string) *httptest.ResponseRecorder {
+		c, recorder := m.context(http.MethodGet, "/features/"+stored.ID.Hex(), nil, "featureFlagID", stored.ID.Hex())
+		if etag != "" {
+			c.Request().Header.Set(apiutils.HeaderIfNoneMatch, etag)
+		}
+
+		assert.NoError(t, h.GetFeatureFlag(c))
+		return recorder
+	}
+
+	recorder := get("")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	etag := recorder.Header().Get(apiutils.HeaderETag)
+	assert.NotEmpty(t, etag)
+
+	recorder = get(etag)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Empty(t, recorder.Body.Bytes())
+	assert.Equal(t, etag, recorder.Header().Get(apiutils.HeaderETag))
+
+	toggleRecorder := m.serve(h.ToggleFeatureFlag, http.MethodPatch, "/?env=prod", nil, "featureFlagID", stored.ID.Hex())
+	assert.Equal(t, http.StatusOK, toggleRecorder.Code)
+
+	recorder = get(etag)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotEqual(t, etag, recorder.Header().Get(apiutils.HeaderETag))
+
+	var response handlers.FeatureFlagResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.True(t, response.Environments[0].IsEnabled)
+}
+
+func TestPostFeatureFlagIdempotencyKeyWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+	otherOrganizationID := primitive.NewObjectID()
+	m.organizations.FindByIDFunc = func(_ context.Context, id primitive.ObjectID) (*organizationmodel.OrganizationRecord, error) {
+		return &organizationmodel.OrganizationRecord{
+			ID: id,
+			Members: []organizationmodel.OrganizationMember{{
+				User:            usermodel.UserRecord{ID: m.userID},
+				PermissionLevel: organizationmodel.Collaborator,
+			}},
+		}, nil
+	}
+
+	flags := []*featureflagmodel.FeatureFlagRecord{}
+	m.featureFlags.NameInUseFunc = func(
+		_ context.Context,
+		organizationID primitive.ObjectID,
+		name string,
+		_ []string,
+		_ primitive.ObjectID,
+	) (bool, error) {
+		for _, flag := range flags {
+			if flag.OrganizationID == organizationID && flag.Name == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	m.featureFlags.InsertOneFunc = func(_ context.Context, record *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+		record.ID = primitive.NewObjectID()
+		flags = append(flags, record)
+		return record.ID, nil
+	}
+	m.featureFlags.FindOneFunc = func(_ context.Context, filter interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		id := filter.(bson.D).Map()["_id"]
+		for _, flag := range flags {
+			if flag.ID == id {
+				return flag, nil
+			}
+		}
+		return nil, mongo.ErrNoDocuments
+	}
+	m.acceptTimelines()
+
+	keys := []*idempotencykeymodel.IdempotencyKeyRecord{}
+	m.repositories.IdempotencyKeys = &fixtures.MockIdempotencyKeyRepository{
+		InsertOneFunc: func(_ context.Context, record *idempotencykeymodel.IdempotencyKeyRecord) (primitive.ObjectID, error) {
+			record.ID = primitive.NewObjectID()
+			keys = append(keys, record)
+			return record.ID, nil
+		},
+		FindByKeyFunc: func(
+			_ context.Context,
+			organizationID primitive.ObjectID,
+			key string,
+		) (*idempotencykeymodel.IdempotencyKeyRecord, error) {
+			for _, record := range keys {
+				if record.OrganizationID == organizationID && record.Key == key {
+					return record, nil
+				}
+			}
+			return nil, mongo.ErrNoDocuments
+		},
+	}
+
+	h := m.build()
+	post := func(organizationID primitive.ObjectID, name string) *httptest.ResponseRecorder {
+		c, recorder := newMockContext(http.MethodPost, "/features", handlers.PostFeatureFlagRequest{
+			Name:         name,
+			Type:         featureflagmodel.Boolean,
+			DefaultValue: "true",
+			Environment:  "prod",
+		}, m.userID, organizationID)
+		c.Request().Header.Set(handlers.IdempotencyKeyHeader, "create-cool-feature")
+
+		assert.NoError(t, h.PostFeatureFlag(c))
+		return recorder
+	}
+
+	first := post(m.organizationID, "cool feature")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	retry := post(m.organizationID, "cool feature")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Len(t, flags, 1)
+
+	// The key can't be reused for another flag
+	reused := post(m.organizationID, "other feature")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Len(t, flags, 1)
+
+	// Keys are scoped to their organization
+	assert.Equal(t, http.StatusCreated, post(otherOrganizationID, "cool feature").Code)
+	assert.Len(t, flags, 2)
+}
+
+func TestRequestIDInLogsWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Admin)
+	m.featureFlags.SoftDeleteFunc = func(_ context.Context, _, _ primitive.ObjectID) error {
+		return mongo.ErrNoDocuments
+	}
+
+	core, logs := observer.New(zap.DebugLevel)
+	h := handlers.NewFeatureFlagHandlerWithRepositories(m.repositories, zap.New(core))
+
+	e := echo.New()
+	e.Use(middlewares.RequestIDMiddleware)
+	e.DELETE("/features/:featureFlagID", h.DeleteFeatureFlag, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", m.userID.Hex())
+			c.Set("organization", m.organizationID.Hex())
+			return next(c)
+		}
+	})
+
+	request := httptest.NewRequest(http.MethodDelete, "/features/"+primitive.NewObjectID().Hex(), nil)
+	request.Header.Set(echo.HeaderXRequestID, "incident-4242")
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "incident-4242", recorder.Header().Get(echo.HeaderXRequestID))
+
+	entries := logs.FilterMessage("Client error").AllUntimed()
+	assert.NotEmpty(t, entries)
+	for _, entry := range entries {
+		assert.Equal(t, "incident-4242", entry.ContextMap()[apiutils.RequestIDLogField])
+	}
+}
+
+func TestInvalidFlagTypeRejectedWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint func(h *handlers.FeatureFlagHandler) echo.HandlerFunc
+		body     interface{}
+	}{
+		{
+			name:     "post",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlag },
+			body: handlers.PostFeatureFlagRequest{
+				Name:         "cool feature",
+				Type:         "date",
+				DefaultValue: "2024-01-01",
+				Environment:  "prod",
+			},
+		},
+		{
+			name:     "import",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.ImportFlags },
+			body: featureflagmodel.ExportDocument{
+				Version: featureflagmodel.ExportFormatVersion,
+				Flags: []featureflagmodel.ExportedFlag{{
+					Name:         "cool feature",
+					Type:         "date",
+					DefaultValue: "2024-01-01",
+					Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod"}},
+				}},
+			},
+		},
+		{
+			name:     "clone",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.CloneFeatureFlag },
+			body:     handlers.CloneFeatureFlagRequest{Name: "cloned feature"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			m.featureFlags.FindAllFunc = func(_ context.Context, _ primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error) {
+				return []featureflagmodel.FeatureFlagRecord{}, nil
+			}
+			m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:             featureFlagID,
+					OrganizationID: m.organizationID,
+					Name:           "legacy feature",
+					Type:           "date",
+					Environments:   []featureflagmodel.FeatureFlagEnvironment{{Name: "prod"}},
+				}, nil
+			}
+			m.featureFlags.InsertOneFunc = func(_ context.Context, _ *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+				t.Fatal("a flag with an invalid type was inserted")
+				return primitive.NilObjectID, nil
+			}
+
+			h := m.build()
+			recorder := m.serve(tt.endpoint(h), http.MethodPost, "/", tt.body, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, apierrors.InvalidFlagTypeError, errorMessage(t, recorder))
+		})
+	}
+}
+
+// Values are checked against the bounds of number flags and the schema of
+// JSON ones before a flag is created
+func TestPostFeatureFlagValueChecksWithMockRepositories(t *testing.T) {
+	min, max := 100.0, 30000.0
+	timeoutBounds := featureflagmodel.NumberBounds{Min: &min, Max: &max}
+	schema := `{"type": "object", "required": ["timeout"], "properties": {"timeout": {"type": "integer"}}}`
+
+	tests := []struct {
+		name    string
+		request handlers.PostFeatureFlagRequest
+		status  int
+		message string
+	}{
+		{
+			name: "number in range",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.Number,
+				DefaultValue: "2500",
+				NumberBounds: timeoutBounds,
+				Rules: []featureflagmodel.Rule{
+					{Predicate: "plan: pro", Value: "30000", Env: "prod", IsEnabled: true},
+				},
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "default below min",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.Number,
+				DefaultValue: "50",
+				NumberBounds: timeoutBounds,
+			},
+			status:  http.StatusBadRequest,
+			message: "default value 50 is below the min of 100",
+		},
+		{
+			name: "rule above max",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.Number,
+				DefaultValue: "2500",
+				NumberBounds: timeoutBounds,
+				Rules: []featureflagmodel.Rule{
+					{Predicate: "plan: pro", Value: "500", Env: "prod", IsEnabled: true},
+					{Predicate: "plan: free", Value: "40000", Env: "prod", IsEnabled: true},
+				},
+			},
+			status:  http.StatusBadRequest,
+			message: "rule 1 value 40000 is above the max of 30000",
+		},
+		{
+			name: "bounds on a boolean flag",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.Boolean,
+				DefaultValue: "true",
+				NumberBounds: timeoutBounds,
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "min past the max",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.Number,
+				DefaultValue: "2500",
+				NumberBounds: featureflagmodel.NumberBounds{Min: &max, Max: &min},
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "JSON matching the schema",
+			request: handlers.PostFeatureFlagRequest{Type: featureflagmodel.JSON, DefaultValue: `{"timeout": 30}`, Schema: schema},
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "missing required property",
+			request: handlers.PostFeatureFlagRequest{Type: featureflagmodel.JSON, DefaultValue: `{"retries": 3}`, Schema: schema},
+			status:  http.StatusBadRequest,
+			message: "default value at # violates the schema: missing properties: 'timeout'",
+		},
+		{
+			name: "rule of the wrong type",
+			request: handlers.PostFeatureFlagRequest{
+				Type:         featureflagmodel.JSON,
+				DefaultValue: `{"timeout": 30}`,
+				Schema:       schema,
+				Rules: []featureflagmodel.Rule{
+					{Predicate: "plan: pro", Value: `{"timeout": "60"}`, Env: "prod", IsEnabled: true},
+				},
+			},
+			status:  http.StatusBadRequest,
+			message: "rule 0 value at #/timeout violates the schema: expected integer, but got string",
+		},
+		{
+			name:    "invalid JSON without a schema",
+			request: handlers.PostFeatureFlagRequest{Type: featureflagmodel.JSON, DefaultValue: `{"timeout": 30`},
+			status:  http.StatusBadRequest,
+			message: apierrors.InvalidValueError,
+		},
+		{
+			name:    "invalid schema",
+			request: handlers.PostFeatureFlagRequest{Type: featureflagmodel.JSON, DefaultValue: `{}`, Schema: `{"type": 1}`},
+			status:  http.StatusBadRequest,
+			message: apierrors.InvalidSchemaError,
+		},
+		{
+			name:    "schema on a string flag",
+			request: handlers.PostFeatureFlagRequest{Type: featureflagmodel.String, DefaultValue: "plain", Schema: schema},
+			status:  http.StatusBadRequest,
+			message: apierrors.SchemaNotJSONError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			m.freeNames()
+			var inserted *featureflagmodel.FeatureFlagRecord
+			m.featureFlags.InsertOneFunc = func(_ context.Context, record *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+				inserted = record
+				return primitive.NewObjectID(), nil
+			}
+			m.acceptTimelines()
+
+			request := tt.request
+			request.Name = "client config"
+			request.Environment = "prod"
+
+			h := m.build()
+			recorder := m.serve(h.PostFeatureFlag, http.MethodPost, "/features", request)
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.status == http.StatusCreated {
+				assert.Equal(t, request.NumberBounds, inserted.NumberBounds)
+				assert.Equal(t, request.Schema, inserted.Schema)
+				return
+			}
+
+			assert.Nil(t, inserted)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, errorMessage(t, recorder))
+			}
+		})
+	}
+}
+
+// Patching checks the values against the bounds or schema of the stored flag
+func TestPatchFeatureFlagValueChecksWithMockRepositories(t *testing.T) {
+	min, max := 100.0, 30000.0
+
+	tests := []struct {
+		name         string
+		stored       featureflagmodel.FeatureFlagRecord
+		defaultValue string
+		message      string
+	}{
+		{
+			name: "number above max",
+			stored: featureflagmodel.FeatureFlagRecord{
+				Type:         featureflagmodel.Number,
+				NumberBounds: featureflagmodel.NumberBounds{Min: &min, Max: &max},
+			},
+			defaultValue: "30001",
+			message:      "default value 30001 is above the max of 30000",
+		},
+		{
+			name: "JSON violating the schema",
+			stored: featureflagmodel.FeatureFlagRecord{
+				Type:   featureflagmodel.JSON,
+				Schema: `{"type": "object", "required": ["timeout"], "properties": {"timeout": {"type": "integer"}}}`,
+			},
+			defaultValue: `{}`,
+			message:      "default value at # violates the schema: missing properties: 'timeout'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				record := tt.stored
+				record.ID = featureFlagID
+				record.OrganizationID = m.organizationID
+				record.Version = 1
+				record.Name = "client config"
+				return &record, nil
+			}
+			m.featureFlags.PushRevisionFunc = func(
+				_ context.Context,
+				_, _ primitive.ObjectID,
+				_ *featureflagmodel.Revision,
+				_ *int,
+			) (int, error) {
+				t.Fatal("a revision with an invalid value was pushed")
+				return 0, nil
+			}
+			m.acceptTimelines()
+
+			h := m.build()
+			recorder := m.serve(h.PatchFeatureFlag, http.MethodPatch, "/", handlers.PatchFeatureFlagRequest{
+				DefaultValue: tt.defaultValue,
+			}, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, tt.message, errorMessage(t, recorder))
+		})
+	}
+}
+
+func TestPostFeatureFlagsWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+
+	m.featureFlags.NameInUseFunc = func(
+		_ context.Context,
+		_ primitive.ObjectID,
+		name string,
+		_ []string,
+		_ primitive.ObjectID,
+	) (bool, error) {
+		return name == "taken feature", nil
+	}
+	var inserted []*featureflagmodel.FeatureFlagRecord
+	m.featureFlags.InsertManyFunc = func(
+		_ context.Context,
+		records []*featureflagmodel.FeatureFlagRecord,
+	) ([]primitive.ObjectID, error) {
+		inserted = records
+		ids := make([]primitive.ObjectID, 0, len(records))
+		for _, record := range records {
+			record.ID = primitive.NewObjectID()
+			ids = append(ids, record.ID)
+		}
+		return ids, nil
+	}
+	var timelineRecords []*timelinemodel.TimelineRecord
+	m.timelines.InsertManyFunc = func(_ context.Context, records []*timelinemodel.TimelineRecord) error {
+		timelineRecords = records
+		return nil
+	}
+
+	h := m.build()
+	post := func(requests []handlers.PostFeatureFlagRequest) (*httptest.ResponseRecorder, handlers.BulkFeatureFlagsErrorResponse) {
+		recorder := m.serve(h.PostFeatureFlags, http.MethodPost, "/features/bulk", requests)
+
+		var response handlers.BulkFeatureFlagsErrorResponse
+		if recorder.Code != http.StatusCreated {
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		}
+		return recorder, response
+	}
+	flag := func(name string) handlers.PostFeatureFlagRequest {
+		return handlers.PostFeatureFlagRequest{
+			Name:         name,
+			Type:         featureflagmodel.Boolean,
+			DefaultValue: "true",
+			Environment:  "prod",
+		}
+	}
+
+	// A name repeated within the batch fails it as a whole
+	recorder, response := post([]handlers.PostFeatureFlagRequest{
+		flag("checkout"),
+		flag("search"),
+		flag("checkout"),
+	})
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, apierrors.BulkFeatureFlagsError, response.Message)
+	assert.Equal(t, []handlers.BulkFeatureFlagError{
+		{Index: 2, Name: "checkout", Message: apierrors.NameConflictError},
+	}, response.Errors)
+	assert.Nil(t, inserted)
+
+	// Every entry at fault is listed, an invalid one makes it a 400
+	invalid := flag("broken")
+	invalid.DefaultValue = "maybe"
+	recorder, response = post([]handlers.PostFeatureFlagRequest{
+		flag("taken feature"),
+		flag("search"),
+		invalid,
+	})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, []handlers.BulkFeatureFlagError{
+		{Index: 0, Name: "taken feature", Message: apierrors.NameConflictError},
+		{Index: 2, Name: "broken", Message: apierrors.InvalidValueError},
+	}, response.Errors)
+	assert.Nil(t, inserted)
+
+	// The same name is fine in different environments
+	staging := flag("checkout")
+	staging.Environment = "staging"
+	recorder, _ = post([]handlers.PostFeatureFlagRequest{
+		flag("checkout"),
+		staging,
+		flag("search"),
+	})
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	var created []featureflagmodel.FeatureFlagRecord
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.Len(t, created, 3)
+	assert.Len(t, inserted, 3)
+	assert.Len(t, timelineRecords, 3)
+	for index, record := range created {
+		assert.Equal(t, inserted[index].ID, record.ID)
+		assert.Equal(t, record.ID, timelineRecords[index].FeatureFlagID)
+		assert.Equal(t, timelinemodel.Created, timelineRecords[index].Entries[0].Action)
+	}
+}
+
+func TestFlagQuotaWithMockRepositories(t *testing.T) {
+	flag := func(name string) handlers.PostFeatureFlagRequest {
+		return handlers.PostFeatureFlagRequest{
+			Name:         name,
+			Type:         featureflagmodel.Boolean,
+			DefaultValue: "true",
+			Environment:  "prod",
+		}
+	}
+	document := featureflagmodel.ExportDocument{
+		Version: featureflagmodel.ExportFormatVersion,
+		Flags: []featureflagmodel.ExportedFlag{{
+			Name:         "checkout",
+			Type:         featureflagmodel.Boolean,
+			DefaultValue: "true",
+			Rules:        []featureflagmodel.ExportedRule{},
+			Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+			Tags:         []string{},
+		}},
+	}
+
+	// The quota is 3, each way of adding flags is let through while it
+	// fits and turned away once it doesn't
+	tests := []struct {
+		name     string
+		endpoint func(h *handlers.FeatureFlagHandler) echo.HandlerFunc
+		target   string
+		body     interface{}
+		flags    int
+		status   int
+	}{
+		{
+			// The flag filling the quota is still created
+			name:     "post filling the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlag },
+			body:     flag("checkout"),
+			flags:    2,
+			status:   http.StatusCreated,
+		},
+		{
+			name:     "post past the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlag },
+			body:     flag("checkout"),
+			flags:    3,
+			status:   http.StatusForbidden,
+		},
+		{
+			// A batch counts as a whole
+			name:     "batch filling the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlags },
+			body:     []handlers.PostFeatureFlagRequest{flag("checkout"), flag("search")},
+			flags:    1,
+			status:   http.StatusCreated,
+		},
+		{
+			name:     "batch past the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.PostFeatureFlags },
+			body:     []handlers.PostFeatureFlagRequest{flag("checkout"), flag("search")},
+			flags:    2,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "dry run import filling the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.ImportFlags },
+			target:   "?dry_run=true",
+			body:     document,
+			flags:    2,
+			status:   http.StatusOK,
+		},
+		{
+			// Even a dry run import reports it
+			name:     "dry run import past the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.ImportFlags },
+			target:   "?dry_run=true",
+			body:     document,
+			flags:    3,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "clone filling the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.CloneFeatureFlag },
+			body:     handlers.CloneFeatureFlagRequest{Name: "checkout copy"},
+			flags:    2,
+			status:   http.StatusCreated,
+		},
+		{
+			name:     "clone past the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.CloneFeatureFlag },
+			body:     handlers.CloneFeatureFlagRequest{Name: "checkout copy"},
+			flags:    3,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "restore filling the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.RestoreFeatureFlag },
+			flags:    2,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "restore past the quota",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.RestoreFeatureFlag },
+			flags:    3,
+			status:   http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			m.editOrganization(func(record *organizationmodel.OrganizationRecord) {
+				record.MaxFlags = 3
+			})
+			m.freeNames()
+			m.acceptTimelines()
+
+			m.featureFlags.CountManyFunc = func(_ context.Context, _ primitive.ObjectID, filter featureflagmodel.ListFilter) (int, error) {
+				// Archived flags count against the quota
+				assert.True(t, filter.IncludeArchived)
+				return tt.flags, nil
+			}
+			m.featureFlags.FindAllFunc = func(_ context.Context, _ primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error) {
+				return make([]featureflagmodel.FeatureFlagRecord, tt.flags), nil
+			}
+			m.featureFlags.InsertOneFunc = func(_ context.Context, _ *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+				return primitive.NewObjectID(), nil
+			}
+			m.featureFlags.InsertManyFunc = func(
+				_ context.Context,
+				records []*featureflagmodel.FeatureFlagRecord,
+			) ([]primitive.ObjectID, error) {
+				ids := make([]primitive.ObjectID, 0, len(records))
+				for range records {
+					ids = append(ids, primitive.NewObjectID())
+				}
+				return ids, nil
+			}
+
+			// Cloning a flag or restoring a deleted one adds a flag too
+			featureFlagID := primitive.NewObjectID()
+			existing := func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:             featureFlagID,
+					OrganizationID: m.organizationID,
+					Name:           "checkout",
+					Type:           featureflagmodel.Boolean,
+				}, nil
+			}
+			m.featureFlags.FindOneFunc = existing
+			m.featureFlags.FindDeletedByIDFunc = func(ctx context.Context, _, _ primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+				return existing(ctx, nil)
+			}
+			restored := 0
+			m.featureFlags.RestoreFunc = func(_ context.Context, _, _ primitive.ObjectID) error {
+				restored++
+				return nil
+			}
+
+			h := m.build()
+			recorder := m.serve(tt.endpoint(h), http.MethodPost, "/"+tt.target, tt.body, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.status == http.StatusForbidden {
+				assert.Equal(t, fmt.Sprintf(apierrors.QuotaExceededError, 3), errorMessage(t, recorder))
+				assert.Equal(t, 0, restored)
+			}
+		})
+	}
+}
+
+func TestCollaboratorEndpointsForbidReadOnlyWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.ReadOnly)
+	h := m.build()
+	featureFlagID := primitive.NewObjectID()
+
+	endpoints := map[string]echo.HandlerFunc{
+		"PostFeatureFlag":             h.PostFeatureFlag,
+		"PostFeatureFlags":            h.PostFeatureFlags,
+		"PatchFeatureFlag":            h.PatchFeatureFlag,
+		"ApproveRevision":             h.ApproveRevision,
+		"RejectRevision":              h.RejectRevision,
+		"BulkApproveRevisions":        h.BulkApproveRevisions,
+		"RollbackFeatureFlagVersion":  h.RollbackFeatureFlagVersion,
+		"DeleteFeatureFlag":           h.DeleteFeatureFlag,
+		"ToggleFeatureFlag":           h.ToggleFeatureFlag,
+		"BatchToggleEnvironment":      h.BatchToggleEnvironment,
+		"PatchFeatureFlagTags":        h.PatchFeatureFlagTags,
+		"PostEnvironment":             h.PostEnvironment,
+		"DeleteEnvironment":           h.DeleteEnvironment,
+		"RenameFeatureFlag":           h.RenameFeatureFlag,
+		"PatchFeatureFlagDescription": h.PatchFeatureFlagDescription,
+		"CloneFeatureFlag":            h.CloneFeatureFlag,
+		"RestoreFeatureFlag":          h.RestoreFeatureFlag,
+		"ArchiveFeatureFlag":          h.ArchiveFeatureFlag,
+		"UnarchiveFeatureFlag":        h.UnarchiveFeatureFlag,
+		"SetExpectedConfig":           h.SetExpectedConfig,
+		"SetPrerequisites":            h.SetPrerequisites,
+		"SetOverrides":                h.SetOverrides,
+		"ImportFlags":                 h.ImportFlags,
+		"AcknowledgeDrift":            h.AcknowledgeDrift,
+	}
+
+	for name, endpoint := range endpoints {
+		t.Run(name, func(t *testing.T) {
+			c, recorder := m.context(http.MethodPost, "/features/"+featureFlagID.Hex(), nil,
+				"featureFlagID", featureFlagID.Hex(),
+				"name", "prod",
+				"revisionID", primitive.NewObjectID().Hex(),
+			)
+
+			assert.NoError(t, endpoint(c))
+			assert.Equal(t, http.StatusForbidden, recorder.Code)
+			assert.Equal(t, apierrors.ForbiddenError, errorMessage(t, recorder))
+		})
+	}
+}
+
+func TestGetRevisionImpactWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.ReadOnly)
+
+	liveRevision := featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		Status:       featureflagmodel.Live,
+		DefaultValue: "false",
+		Rules: []featureflagmodel.Rule{{
+			Predicate: "plan: enterprise",
+			Value:     "true",
+			IsEnabled: true,
+		}},
+	}
+	draftRevision := featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		Status:       featureflagmodel.Draft,
+		DefaultValue: "false",
+		Rules: []featureflagmodel.Rule{{
+			Predicate: "plan: enterprise, pro",
+			Operator:  featureflagmodel.OperatorIn,
+			Value:     "true",
+			IsEnabled: true,
+		}},
+	}
+	featureFlagRecord := &featureflagmodel.FeatureFlagRecord{
+		ID:             primitive.NewObjectID(),
+		OrganizationID: m.organizationID,
+		Name:           "checkout",
+		Type:           featureflagmodel.Boolean,
+		Revisions:      []featureflagmodel.Revision{liveRevision, draftRevision},
+		Environments:   []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+	}
+	m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		return featureFlagRecord, nil
+	}
+
+	h := m.build()
+	impact := func(revisionID primitive.ObjectID, request handlers.RevisionImpactRequest) *httptest.ResponseRecorder {
+		return m.serve(h.GetRevisionImpact, http.MethodPost, "/", request,
+			"featureFlagID", featureFlagRecord.ID.Hex(),
+			"revisionID", revisionID.Hex(),
+		)
+	}
+
+	recorder := impact(draftRevision.ID, handlers.RevisionImpactRequest{
+		Environment: "prod",
+		Contexts: []evaluator.Context{
+			{"plan": "pro"},
+			{"plan": "enterprise"},
+			{"plan": "free"},
+		},
+	})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response handlers.RevisionImpactResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, liveRevision.ID, *response.BaseRevisionID)
+	assert.Equal(t, draftRevision.ID, response.RevisionID)
+	assert.Equal(t, 1, response.Changed)
+	assert.Len(t, response.Data, 3)
+
+	// Only the pro plan flips, the other contexts keep their value
+	assert.Equal(t, false, response.Data[0].OldValue)
+	assert.Equal(t, true, response.Data[0].NewValue)
+	assert.True(t, response.Data[0].Changed)
+	assert.Equal(t, true, response.Data[1].OldValue)
+	assert.Equal(t, true, response.Data[1].NewValue)
+	assert.False(t, response.Data[1].Changed)
+	assert.Equal(t, false, response.Data[2].OldValue)
+	assert.Equal(t, false, response.Data[2].NewValue)
+	assert.False(t, response.Data[2].Changed)
+	assert.Equal(t, "free", response.Data[2].Context["plan"])
+
+	recorder = impact(primitive.NewObjectID(), handlers.RevisionImpactRequest{
+		Environment: "prod",
+		Contexts:    []evaluator.Context{{"plan": "pro"}},
+	})
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = impact(draftRevision.ID, handlers.RevisionImpactRequest{Environment: "prod"})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestPatchFeatureFlagLocationWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+	featureFlagID := primitive.NewObjectID()
+
+	m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		return &featureflagmodel.FeatureFlagRecord{
+			ID:             featureFlagID,
+			OrganizationID: m.organizationID,
+			Name:           "checkout",
+			Type:           featureflagmodel.Boolean,
+			Version:        1,
+			Revisions: []featureflagmodel.Revision{{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Live,
+				DefaultValue: "false",
+			}},
+		}, nil
+	}
+	var pushed *featureflagmodel.Revision
+	m.featureFlags.PushRevisionFunc = func(
+		_ context.Context,
+		_,
+		_ primitive.ObjectID,
+		revision *featureflagmodel.Revision,
+		_ *int,
+	) (int, error) {
+		pushed = revision
+		return 2, nil
+	}
+	m.acceptTimelines()
+
+	h := m.build()
+	recorder := m.serve(h.PatchFeatureFlag, http.MethodPatch, "/features/"+featureFlagID.Hex(), handlers.PatchFeatureFlagRequest{
+		DefaultValue: "true",
+	}, "featureFlagID", featureFlagID.Hex())
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response handlers.PatchFeatureFlagResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, pushed.ID, response.RevisionID)
+	assert.Equal(t, response.RevisionID, response.Revision.ID)
+	assert.Equal(t, featureflagmodel.Draft, response.Status)
+	assert.Equal(t, "true", response.DefaultValue)
+	assert.Equal(t,
+		"/features/"+featureFlagID.Hex()+"/revisions/"+response.RevisionID.Hex(),
+		recorder.Header().Get(echo.HeaderLocation),
+	)
+
+	// The ETag is ready to be sent back in If-Match by the next write
+	etag := recorder.Header().Get(apiutils.HeaderETag)
+	assert.Equal(t, apiutils.VersionedETag(2, recorder.Body.Bytes()), etag)
+}
+
+func TestSetOverridesWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+
+	featureFlagID := primitive.NewObjectID()
+	m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		return &featureflagmodel.FeatureFlagRecord{
+			ID:             featureFlagID,
+			OrganizationID: m.organizationID,
+			Name:           "checkout",
+			Type:           featureflagmodel.Boolean,
+			Revisions: []featureflagmodel.Revision{{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Live,
+				DefaultValue: "false",
+				Rules: []featureflagmodel.Rule{{
+					Predicate: "plan: pro",
+					Value:     "true",
+					IsEnabled: true,
+				}},
+			}},
+			Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+		}, nil
+	}
+	var update bson.D
+	m.featureFlags.UpdateOneFunc = func(_ context.Context, _ interface{}, u bson.D) error {
+		update = u
+		return nil
+	}
+	var timelineEntry *timelinemodel.TimelineEntry
+	m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, entry *timelinemodel.TimelineEntry) error {
+		timelineEntry = entry
+		return nil
+	}
+
+	h := m.build()
+	setOverrides := func(request handlers.SetOverridesRequest) *httptest.ResponseRecorder {
+		return m.serve(h.SetOverrides, http.MethodPatch, "/features/"+featureFlagID.Hex()+"/overrides", request,
+			"featureFlagID", featureFlagID.Hex())
+	}
+
+	recorder := setOverrides(handlers.SetOverridesRequest{Overrides: map[string]string{"qa-user": "false"}})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotNil(t, update)
+	assert.Equal(t, timelinemodel.OverridesChanged, timelineEntry.Action)
+
+	var response featureflagmodel.FeatureFlagRecord
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, map[string]string{"qa-user": "false"}, response.Overrides)
+
+	// The override beats the rule the QA user matches
+	value, err := evaluator.Evaluate(&response, "prod", evaluator.Context{
+		evaluator.KeyAttribute: "qa-user",
+		"plan":                 "pro",
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	// Values are checked against the flag type before anything is stored
+	update = nil
+	recorder = setOverrides(handlers.SetOverridesRequest{Overrides: map[string]string{"qa-user": "maybe"}})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Nil(t, update)
+	assert.Equal(t, "override qa-user value does not match the flag type", errorMessage(t, recorder))
+
+	recorder = setOverrides(handlers.SetOverridesRequest{Overrides: map[string]string{"": "true"}})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Nil(t, update)
+}
+
+func TestEvaluateFeatureFlagMaintenanceModeWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.ReadOnly)
+
+	maintenanceMode := true
+	m.editOrganization(func(record *organizationmodel.OrganizationRecord) {
+		record.MaintenanceMode = maintenanceMode
+	})
+
+	featureFlagRecord := featureflagmodel.FeatureFlagRecord{
+		ID:             primitive.NewObjectID(),
+		OrganizationID: m.organizationID,
+		Name:           "checkout",
+		Type:           featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{{
+			ID:           primitive.NewObjectID(),
+			Status:       featureflagmodel.Live,
+			DefaultValue: "false",
+			Rules: []featureflagmodel.Rule{{
+				ID:        primitive.NewObjectID(),
+				Predicate: "plan: pro",
+				Value:     "true",
+				IsEnabled: true,
+			}},
+		}},
+		Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+		Overrides:    map[string]string{"qa-user": "true"},
+	}
+	m.featureFlags.FindAllFunc = func(_ context.Context, _ primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error) {
+		return []featureflagmodel.FeatureFlagRecord{featureFlagRecord}, nil
+	}
+
+	h := m.build()
+	evaluate := func(evaluationContext map[string]interface{}) handlers.EvaluateFeatureFlagResponse {
+		recorder := m.serve(h.EvaluateFeatureFlag, http.MethodPost, "/", handlers.EvaluateFeatureFlagRequest{
+			Environment: "prod",
+			Context:     evaluationContext,
+		}, "featureFlagID", featureFlagRecord.ID.Hex())
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var response handlers.EvaluateFeatureFlagResponse
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response
+	}
+
+	// Neither the matching rule nor the override apply during maintenance
+	for _, evaluationContext := range []map[string]interface{}{
+		{"plan": "pro"},
+		{evaluator.KeyAttribute: "qa-user"},
+	} {
+		response := evaluate(evaluationContext)
+		assert.Equal(t, false, response.Value)
+		assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonMaintenance}, response.Reason)
+	}
+
+	// Turning it off serves the rules again right away
+	maintenanceMode = false
+	response := evaluate(map[string]interface{}{"plan": "pro"})
+	assert.Equal(t, true, response.Value)
+	assert.Equal(t, evaluator.ReasonRuleMatch, response.Reason.Kind)
+}
+
+func TestRestoreFeatureFlagWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+	m.freeNames()
+
+	featureFlagID := primitive.NewObjectID()
+	m.featureFlags.FindDeletedByIDFunc = func(_ context.Context, _, _ primitive.ObjectID) (
+		*featureflagmodel.FeatureFlagRecord,
+		error,
+	) {
+		record := &featureflagmodel.FeatureFlagRecord{
+			ID:             featureFlagID,
+			OrganizationID: m.organizationID,
+			Name:           "checkout",
+			Type:           featureflagmodel.Boolean,
+		}
+		record.DeletedAt = primitive.NewDateTimeFromTime(time.Now())
+		return record, nil
+	}
+	var restoreErr error
+	m.featureFlags.RestoreFunc = func(_ context.Context, _, _ primitive.ObjectID) error {
+		return restoreErr
+	}
+	timelineEntries := 0
+	m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+		timelineEntries++
+		return nil
+	}
+
+	h := m.build()
+	restore := func() *httptest.ResponseRecorder {
+		return m.serve(h.RestoreFeatureFlag, http.MethodPost, "/features/"+featureFlagID.Hex()+"/restore", nil,
+			"featureFlagID", featureFlagID.Hex())
+	}
+
+	recorder := restore()
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, timelineEntries)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, featureFlagID.Hex(), response["_id"])
+	assert.NotContains(t, response, "deleted_at")
+
+	// A flag restored in the meantime isn't restored twice
+	restoreErr = mongo.ErrNoDocuments
+	assert.Equal(t, http.StatusNotFound, restore().Code)
+	assert.Equal(t, 1, timelineEntries)
+}
+
+func TestGetRevisionWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.ReadOnly)
+
+	featureFlagID := primitive.NewObjectID()
+	draftRevision := featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		Status:       featureflagmodel.Draft,
+		DefaultValue: "true",
+	}
+	m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+		return &featureflagmodel.FeatureFlagRecord{
+			ID:             featureFlagID,
+			OrganizationID: m.organizationID,
+			Name:           "checkout",
+			Type:           featureflagmodel.Boolean,
+			Revisions: []featureflagmodel.Revision{
+				{ID: primitive.NewObjectID(), Status: featureflagmodel.Live, DefaultValue: "false"},
+				draftRevision,
+			},
+		}, nil
+	}
+
+	h := m.build()
+	getRevision := func(revisionID string) *httptest.ResponseRecorder {
+		return m.serve(h.GetRevision, http.MethodGet, "/", nil, "featureFlagID", featureFlagID.Hex(), "revisionID", revisionID)
+	}
+
+	recorder := getRevision(draftRevision.ID.Hex())
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response featureflagmodel.Revision
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, draftRevision.ID, response.ID)
+	assert.Equal(t, featureflagmodel.Draft, response.Status)
+	assert.Equal(t, "true", response.DefaultValue)
+
+	assert.Equal(t, http.StatusNotFound, getRevision(primitive.NewObjectID().Hex()).Code)
+	assert.Equal(t, http.StatusBadRequest, getRevision("latest").Code)
+}
+
+func TestWritesScopedToOrganizationWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint func(h *handlers.FeatureFlagHandler) echo.HandlerFunc
+		body     interface{}
+	}{
+		{
+			name:     "set expected config",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.SetExpectedConfig },
+			body:     handlers.SetExpectedConfigRequest{},
+		},
+		{
+			name:     "acknowledge drift",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.AcknowledgeDrift },
+		},
+		{
+			name:     "set overrides",
+			endpoint: func(h *handlers.FeatureFlagHandler) echo.HandlerFunc { return h.SetOverrides },
+			body:     handlers.SetOverridesRequest{Overrides: map[string]string{"qa-user": "false"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:                 featureFlagID,
+					OrganizationID:     m.organizationID,
+					Type:               featureflagmodel.Boolean,
+					ExpectedConfigHash: "v1:expected",
+					Revisions: []featureflagmodel.Revision{{
+						ID:           primitive.NewObjectID(),
+						Status:       featureflagmodel.Live,
+						DefaultValue: "false",
+					}},
+				}, nil
+			}
+			filters := make([]interface{}, 0)
+			m.featureFlags.UpdateOneFunc = func(_ context.Context, filter interface{}, _ bson.D) error {
+				filters = append(filters, filter)
+				return nil
+			}
+			m.acceptTimelines()
+
+			h := m.build()
+			recorder := m.serve(tt.endpoint(h), http.MethodPut, "/", tt.body, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, []interface{}{bson.M{"$and": []bson.M{
+				{"_id": featureFlagID},
+				{"organization_id": m.organizationID},
+			}}}, filters)
+		})
+	}
+}
+
+func TestBulkApproveRevisionsWithMockRepositories(t *testing.T) {
+	m := newMockHandler(t, organizationmodel.Collaborator)
+	authorID := primitive.NewObjectID()
+
+	drafts := make(map[primitive.ObjectID]primitive.ObjectID)
+	request := handlers.BulkApproveRevisionsRequest{}
+	for i := 0; i < 2; i++ {
+		approval := handlers.RevisionApproval{
+			FeatureFlagID: primitive.NewObjectID(),
+			RevisionID:    primitive.NewObjectID(),
+		}
+		drafts[approval.FeatureFlagID] = approval.RevisionID
+		request.Revisions = append(request.Revisions, approval)
+	}
+	draftFlag := func(featureFlagID primitive.ObjectID, approvals ...primitive.ObjectID) *featureflagmodel.FeatureFlagRecord {
+		return &featureflagmodel.FeatureFlagRecord{
+			ID:             featureFlagID,
+			OrganizationID: m.organizationID,
+			Type:           featureflagmodel.Boolean,
+			Version:        1,
+			Revisions: []featureflagmodel.Revision{{
+				ID:           drafts[featureFlagID],
+				UserID:       authorID,
+				Status:       featureflagmodel.Draft,
+				DefaultValue: "true",
+				Approvals:    approvals,
+			}},
+		}
+	}
+	m.featureFlags.FindActiveByIDFunc = func(_ context.Context, _, id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+		return draftFlag(id), nil
+	}
+
+	inTransaction := false
+	m.repositories.Transact = func(ctx context.Context, fn func(ctx context.Context) error) error {
+		inTransaction = true
+		defer func() { inTransaction = false }()
+		return fn(ctx)
+	}
+	var approveErr error
+	m.featureFlags.AddApprovalFunc = func(
+		_ context.Context,
+		_,
+		id,
+		_,
+		approverID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error) {
+		assert.True(t, inTransaction)
+		if approveErr != nil {
+			return nil, approveErr
+		}
+		return draftFlag(id, approverID), nil
+	}
+	promotions := 0
+	m.featureFlags.PromoteRevisionFunc = func(
+		_ context.Context,
+		record *featureflagmodel.FeatureFlagRecord,
+		revisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error) {
+		assert.True(t, inTransaction)
+		promotions++
+		promoted := *record
+		promoted.Version++
+		promoted.Revisions = []featureflagmodel.Revision{record.Revisions[0]}
+		promoted.Revisions[0].Status = featureflagmodel.Live
+		return &promoted, nil
+	}
+	var timelineErr error
+	m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+		assert.True(t, inTransaction)
+		return timelineErr
+	}
+
+	h := m.build()
+	published := 0
+	h.Events().Listen(func(handlers.FlagEvent) {
+		published++
+	})
+	bulkApprove := func(request handlers.BulkApproveRevisionsRequest) *httptest.ResponseRecorder {
+		return m.serve(h.BulkApproveRevisions, http.MethodPost, "/features/revisions/approve", request)
+	}
+
+	recorder := bulkApprove(request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, promotions)
+	assert.Equal(t, 2, published)
+
+	var changeSet handlers.ChangeSetResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &changeSet))
+	for _, featureFlag := range changeSet.Data {
+		assert.Equal(t, 2, featureFlag.Version)
+		assert.Equal(t, featureflagmodel.Live, featureFlag.Revisions[0].Status)
+	}
+
+	// A failed write rolls the whole change set back, nothing is announced
+	promotions, published = 0, 0
+	timelineErr = errors.New("write conflict")
+	recorder = bulkApprove(request)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, 0, published)
+	promotions, timelineErr = 0, nil
+
+	// A revision that changed since it was checked conflicts
+	approveErr = mongo.ErrNoDocuments
+	recorder = bulkApprove(request)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, 0, promotions)
+	assert.Equal(t, 0, published)
+	approveErr = nil
+
+	// Two revisions of the same flag can't be approved together
+	duplicate := handlers.BulkApproveRevisionsRequest{Revisions: []handlers.RevisionApproval{
+		request.Revisions[0],
+		{FeatureFlagID: request.Revisions[0].FeatureFlagID, RevisionID: primitive.NewObjectID()},
+	}}
+	recorder = bulkApprove(duplicate)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, 0, promotions)
+	assert.Equal(t, apierrors.DuplicateFeatureFlagError, errorMessage(t, recorder))
+}
+
+func TestRollbackFeatureFlagVersionWithMockRepositories(t *testing.T) {
+	previousRevision := featureflagmodel.Revision{
+		ID:     primitive.NewObjectID(),
+		Status: featureflagmodel.Archived,
+	}
+	liveRevision := featureflagmodel.Revision{
+		ID:             primitive.NewObjectID(),
+		Status:         featureflagmodel.Live,
+		LastRevisionID: &previousRevision.ID,
+	}
+
+	tests := []struct {
+		name        string
+		rollbackErr error
+		status      int
+	}{
+		{name: "moves the version forward", status: http.StatusOK},
+		{name: "conflicts when the flag changed", rollbackErr: mongo.ErrNoDocuments, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			m.featureFlags.FindActiveByIDFunc = func(_ context.Context, _, _ primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:             featureFlagID,
+					OrganizationID: m.organizationID,
+					Version:        2,
+					Revisions:      []featureflagmodel.Revision{previousRevision, liveRevision},
+				}, nil
+			}
+			m.featureFlags.RollbackRevisionFunc = func(
+				_ context.Context,
+				record *featureflagmodel.FeatureFlagRecord,
+				liveRevisionID,
+				previousRevisionID primitive.ObjectID,
+			) (*featureflagmodel.FeatureFlagRecord, error) {
+				assert.Equal(t, liveRevision.ID, liveRevisionID)
+				assert.Equal(t, previousRevision.ID, previousRevisionID)
+				if tt.rollbackErr != nil {
+					return nil, tt.rollbackErr
+				}
+
+				rolledBack := *record
+				rolledBack.Version++
+				return &rolledBack, nil
+			}
+			timelineEntries := 0
+			m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+				timelineEntries++
+				return nil
+			}
+
+			h := m.build()
+			recorder := m.serve(h.RollbackFeatureFlagVersion, http.MethodPatch, "/", nil, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.rollbackErr != nil {
+				assert.Equal(t, 0, timelineEntries)
+				return
+			}
+
+			var response featureflagmodel.FeatureFlagRecord
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, 3, response.Version)
+			assert.Equal(t, 1, timelineEntries)
+		})
+	}
+}
+
+func TestRejectRevisionWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name      string
+		rejectErr error
+		status    int
+	}{
+		{name: "rejects the draft", status: http.StatusOK},
+		{name: "conflicts when it is no longer a draft", rejectErr: mongo.ErrNoDocuments, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			revisionID := primitive.NewObjectID()
+			draftFlag := func(status featureflagmodel.RevisionStatus) *featureflagmodel.FeatureFlagRecord {
+				return &featureflagmodel.FeatureFlagRecord{
+					ID:             featureFlagID,
+					OrganizationID: m.organizationID,
+					Version:        1,
+					Revisions:      []featureflagmodel.Revision{{ID: revisionID, Status: status}},
+				}
+			}
+			m.featureFlags.FindOneFunc = func(_ context.Context, _ interface{}) (*featureflagmodel.FeatureFlagRecord, error) {
+				return draftFlag(featureflagmodel.Draft), nil
+			}
+			m.featureFlags.RejectRevisionFunc = func(
+				_ context.Context,
+				_,
+				_,
+				id primitive.ObjectID,
+			) (*featureflagmodel.FeatureFlagRecord, error) {
+				assert.Equal(t, revisionID, id)
+				if tt.rejectErr != nil {
+					return nil, tt.rejectErr
+				}
+				return draftFlag(featureflagmodel.Rejected), nil
+			}
+			timelineEntries := 0
+			m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+				timelineEntries++
+				return nil
+			}
+
+			h := m.build()
+			recorder := m.serve(h.RejectRevision, http.MethodDelete, "/", nil,
+				"featureFlagID", featureFlagID.Hex(),
+				"revisionID", revisionID.Hex(),
+			)
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.rejectErr != nil {
+				assert.Equal(t, 0, timelineEntries)
+				return
+			}
+
+			var response featureflagmodel.FeatureFlagRecord
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, featureflagmodel.Rejected, response.Revisions[0].Status)
+			assert.Equal(t, 1, timelineEntries)
+		})
+	}
+}
+
+func TestPatchFeatureFlagTagsWithMockRepositories(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       interface{}
+		setTagsErr error
+		status     int
+	}{
+		{name: "replaces the tags", body: handlers.PatchFeatureFlagTagsRequest{Tags: []string{"Beta"}}, status: http.StatusNoContent},
+		{name: "rejects a malformed body", body: "beta", status: http.StatusBadRequest},
+		{name: "requires the tags", body: map[string]string{}, status: http.StatusBadRequest},
+		{
+			name:       "does not find a deleted flag",
+			body:       handlers.PatchFeatureFlagTagsRequest{Tags: []string{"beta"}},
+			setTagsErr: mongo.ErrNoDocuments,
+			status:     http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+			featureFlagID := primitive.NewObjectID()
+			m.featureFlags.SetTagsFunc = func(_ context.Context, _, id primitive.ObjectID, tags []string) error {
+				assert.Equal(t, featureFlagID, id)
+				assert.Equal(t, []string{"beta"}, tags)
+				return tt.setTagsErr
+			}
+			organizationUpdates := 0
+			m.organizations.UpdateOneFunc = func(_ context.Context, _, _ bson.D) error {
+				organizationUpdates++
+				return nil
+			}
+
+			h := m.build()
+			recorder := m.serve(h.PatchFeatureFlagTags, http.MethodPatch, "/", tt.body, "featureFlagID", featureFlagID.Hex())
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.status == http.StatusNoContent {
+				assert.Equal(t, 1, organizationUpdates)
+			} else {
+				assert.Equal(t, 0, organizationUpdates)
+			}
+		})
+	}
+}
+
+func TestImportFlagsWithMockRepositories(t *testing.T) {
+	document := featureflagmodel.ExportDocument{
+		Version: featureflagmodel.ExportFormatVersion,
+		Flags: []featureflagmodel.ExportedFlag{
+			{
+				Name:         "checkout",
+				Type:         featureflagmodel.Boolean,
+				DefaultValue: "true",
+				Rules:        []featureflagmodel.ExportedRule{},
+				Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+				Tags:         []string{},
+			},
+			{
+				Name:         "search",
+				Type:         featureflagmodel.Boolean,
+				DefaultValue: "true",
+				Rules:        []featureflagmodel.ExportedRule{},
+				Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+				Tags:         []string{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		timelineErr error
+		status      int
+	}{
+		{name: "applies the whole import", status: http.StatusOK},
+		{name: "rolls back on a failure part way", timelineErr: errors.New("timeline unavailable"), status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockHandler(t, organizationmodel.Collaborator)
+
+			m.featureFlags.FindAllFunc = func(_ context.Context, _ primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error) {
+				return []featureflagmodel.FeatureFlagRecord{{
+					ID:             primitive.NewObjectID(),
+					OrganizationID: m.organizationID,
+					Name:           "checkout",
+					Type:           featureflagmodel.Boolean,
+					Version:        1,
+					Environments:   []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+					Revisions: []featureflagmodel.Revision{{
+						ID:           primitive.NewObjectID(),
+						Status:       featureflagmodel.Live,
+						DefaultValue: "false",
+						Rules:        []featureflagmodel.Rule{},
+					}},
+				}}, nil
+			}
+
+			inTransaction := false
+			var transactionErr error
+			m.repositories.Transact = func(ctx context.Context, fn func(ctx context.Context) error) error {
+				inTransaction = true
+				defer func() { inTransaction = false }()
+				transactionErr = fn(ctx)
+				return transactionErr
+			}
+			m.featureFlags.InsertOneFunc = func(_ context.Context, _ *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error) {
+				assert.True(t, inTransaction)
+				return primitive.NewObjectID(), nil
+			}
+			var update bson.D
+			m.featureFlags.UpdateOneFunc = func(_ context.Context, _ interface{}, changes bson.D) error {
+				assert.True(t, inTransaction)
+				update = changes
+				return nil
+			}
+			m.timelines.InsertOneFunc = func(_ context.Context, _ *timelinemodel.TimelineRecord) (primitive.ObjectID, error) {
+				assert.True(t, inTransaction)
+				return primitive.NewObjectID(), nil
+			}
+			m.timelines.UpdateOneFunc = func(_ context.Context, _ primitive.ObjectID, _ *timelinemodel.TimelineEntry) error {
+				assert.True(t, inTransaction)
+				return tt.timelineErr
+			}
+
+			h := m.build()
+			published := 0
+			h.Events().Listen(func(handlers.FlagEvent) {
+				published++
+			})
+			recorder := m.serve(h.ImportFlags, http.MethodPost, "/features/import?mode=upsert", document)
+			assert.Equal(t, tt.status, recorder.Code)
+
+			// The new revision bumps the version of the updated flag
+			assert.Contains(t, update, bson.E{Key: "$inc", Value: bson.M{"version": 1}})
+
+			if tt.timelineErr != nil {
+				assert.Equal(t, tt.timelineErr, transactionErr)
+				assert.Equal(t, 0, published)
+				return
+			}
+
+			var response handlers.ImportFlagsResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, []string{"search"}, response.Created)
+			assert.Equal(t, []string{"checkout"}, response.Updated)
+			assert.Equal(t, 1, published)
+		})
+	}
+}
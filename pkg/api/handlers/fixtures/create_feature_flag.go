@@ -84,6 +84,7 @@ func CreateRevision(
 		Status:         status,
 		DefaultValue:   fmt.Sprintf("default value %d", revisionCounter),
 		LastRevisionID: lastRevisionID,
+		CreatedAt:      primitive.NewDateTimeFromTime(time.Now().UTC()),
 		Rules: []featureflagmodel.Rule{
 			{
 				Predicate: fmt.Sprintf("predicate %d", revisionCounter),
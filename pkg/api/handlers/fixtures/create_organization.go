@@ -23,16 +23,20 @@ func CreateOrganization(
 	organizationCounter++
 	members := make([]organizationmodel.OrganizationMember, 0, len(users))
 	if len(users) < 1 {
-		user := CreateUser("", "", "", "", db)
+		user := *CreateUser("", "", "", "", db)
+		user.Password = ""
 		members = append(members, organizationmodel.OrganizationMember{
-			User:            *user,
+			User:            user,
 			PermissionLevel: organizationmodel.Admin,
 		})
 	}
 
+	// Members never carry the password hash, like the ones the handlers add
 	for _, tuple := range users {
+		user := *tuple.First
+		user.Password = ""
 		members = append(members, organizationmodel.OrganizationMember{
-			User:            *tuple.First,
+			User:            user,
 			PermissionLevel: tuple.Second,
 		})
 	}
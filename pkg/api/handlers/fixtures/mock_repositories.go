@@ -0,0 +1,446 @@
+package fixtures
+
+import (
+	"context"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	idempotencykeymodel "github.com/Roll-Play/togglelabs/pkg/models/idempotency_key"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockFeatureFlagRepository answers every call with the matching func,
+// which tests only set for the calls they expect
+type MockFeatureFlagRepository struct {
+	InsertOneFunc       func(ctx context.Context, record *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error)
+	InsertManyFunc      func(ctx context.Context, records []*featureflagmodel.FeatureFlagRecord) ([]primitive.ObjectID, error)
+	FindOneFunc         func(ctx context.Context, filter interface{}) (*featureflagmodel.FeatureFlagRecord, error)
+	FindActiveByIDFunc  func(ctx context.Context, organizationID, id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error)
+	FindDeletedByIDFunc func(ctx context.Context, organizationID, id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error)
+	FindByIDsFunc       func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		ids []primitive.ObjectID,
+	) ([]featureflagmodel.FeatureFlagRecord, error)
+	FindManyFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+		page,
+		limit int,
+		sort bson.D,
+	) ([]featureflagmodel.FeatureFlagRecord, int, error)
+	FindAfterFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+		cursor *primitive.ObjectID,
+		limit int,
+	) ([]featureflagmodel.FeatureFlagRecord, *primitive.ObjectID, error)
+	CountManyFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+	) (int, error)
+	FindAllFunc                    func(ctx context.Context, organizationID primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error)
+	FindWithExpectedConfigHashFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+	) ([]featureflagmodel.FeatureFlagRecord, error)
+	NameInUseFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		name string,
+		environments []string,
+		ignoreID primitive.ObjectID,
+	) (bool, error)
+	UpdateOneFunc    func(ctx context.Context, filter interface{}, update bson.D) error
+	PushRevisionFunc func(
+		ctx context.Context,
+		organizationID,
+		id primitive.ObjectID,
+		revision *featureflagmodel.Revision,
+		version *int,
+	) (int, error)
+	AddApprovalFunc func(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID,
+		userID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	PromoteRevisionFunc func(
+		ctx context.Context,
+		record *featureflagmodel.FeatureFlagRecord,
+		revisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	ScheduleRevisionFunc func(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID primitive.ObjectID,
+		scheduledAt primitive.DateTime,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	RejectRevisionFunc func(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	RollbackRevisionFunc func(
+		ctx context.Context,
+		record *featureflagmodel.FeatureFlagRecord,
+		liveRevisionID,
+		previousRevisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	SetEnvironmentEnabledFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		ids []primitive.ObjectID,
+		environment string,
+		isEnabled bool,
+	) ([]error, error)
+	SoftDeleteFunc  func(ctx context.Context, organizationID, id primitive.ObjectID) error
+	RestoreFunc     func(ctx context.Context, organizationID, id primitive.ObjectID) error
+	SetArchivedFunc func(ctx context.Context, organizationID, id primitive.ObjectID, isArchived bool) error
+	SetTagsFunc     func(ctx context.Context, organizationID, id primitive.ObjectID, tags []string) error
+}
+
+func (m *MockFeatureFlagRepository) InsertOne(
+	ctx context.Context,
+	record *featureflagmodel.FeatureFlagRecord,
+) (primitive.ObjectID, error) {
+	return m.InsertOneFunc(ctx, record)
+}
+
+func (m *MockFeatureFlagRepository) InsertMany(
+	ctx context.Context,
+	records []*featureflagmodel.FeatureFlagRecord,
+) ([]primitive.ObjectID, error) {
+	return m.InsertManyFunc(ctx, records)
+}
+
+func (m *MockFeatureFlagRepository) FindOne(
+	ctx context.Context,
+	filter interface{},
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindOneFunc(ctx, filter)
+}
+
+func (m *MockFeatureFlagRepository) FindActiveByID(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindActiveByIDFunc(ctx, organizationID, id)
+}
+
+func (m *MockFeatureFlagRepository) FindDeletedByID(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindDeletedByIDFunc(ctx, organizationID, id)
+}
+
+func (m *MockFeatureFlagRepository) FindByIDs(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	ids []primitive.ObjectID,
+) ([]featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindByIDsFunc(ctx, organizationID, ids)
+}
+
+func (m *MockFeatureFlagRepository) FindMany(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter featureflagmodel.ListFilter,
+	page,
+	limit int,
+	sort bson.D,
+) ([]featureflagmodel.FeatureFlagRecord, int, error) {
+	return m.FindManyFunc(ctx, organizationID, filter, page, limit, sort)
+}
+
+func (m *MockFeatureFlagRepository) FindAfter(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter featureflagmodel.ListFilter,
+	cursor *primitive.ObjectID,
+	limit int,
+) ([]featureflagmodel.FeatureFlagRecord, *primitive.ObjectID, error) {
+	return m.FindAfterFunc(ctx, organizationID, filter, cursor, limit)
+}
+
+func (m *MockFeatureFlagRepository) CountMany(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter featureflagmodel.ListFilter,
+) (int, error) {
+	return m.CountManyFunc(ctx, organizationID, filter)
+}
+
+func (m *MockFeatureFlagRepository) FindAll(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+) ([]featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindAllFunc(ctx, organizationID)
+}
+
+func (m *MockFeatureFlagRepository) FindWithExpectedConfigHash(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+) ([]featureflagmodel.FeatureFlagRecord, error) {
+	return m.FindWithExpectedConfigHashFunc(ctx, organizationID)
+}
+
+func (m *MockFeatureFlagRepository) NameInUse(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	name string,
+	environments []string,
+	ignoreID primitive.ObjectID,
+) (bool, error) {
+	return m.NameInUseFunc(ctx, organizationID, name, environments, ignoreID)
+}
+
+func (m *MockFeatureFlagRepository) UpdateOne(ctx context.Context, filter interface{}, update bson.D) error {
+	return m.UpdateOneFunc(ctx, filter, update)
+}
+
+func (m *MockFeatureFlagRepository) PushRevision(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	revision *featureflagmodel.Revision,
+	version *int,
+) (int, error) {
+	return m.PushRevisionFunc(ctx, organizationID, id, revision, version)
+}
+
+func (m *MockFeatureFlagRepository) AddApproval(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID,
+	userID primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.AddApprovalFunc(ctx, organizationID, id, revisionID, userID)
+}
+
+func (m *MockFeatureFlagRepository) PromoteRevision(
+	ctx context.Context,
+	record *featureflagmodel.FeatureFlagRecord,
+	revisionID primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.PromoteRevisionFunc(ctx, record, revisionID)
+}
+
+func (m *MockFeatureFlagRepository) ScheduleRevision(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID primitive.ObjectID,
+	scheduledAt primitive.DateTime,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.ScheduleRevisionFunc(ctx, organizationID, id, revisionID, scheduledAt)
+}
+
+func (m *MockFeatureFlagRepository) RejectRevision(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.RejectRevisionFunc(ctx, organizationID, id, revisionID)
+}
+
+func (m *MockFeatureFlagRepository) RollbackRevision(
+	ctx context.Context,
+	record *featureflagmodel.FeatureFlagRecord,
+	liveRevisionID,
+	previousRevisionID primitive.ObjectID,
+) (*featureflagmodel.FeatureFlagRecord, error) {
+	return m.RollbackRevisionFunc(ctx, record, liveRevisionID, previousRevisionID)
+}
+
+func (m *MockFeatureFlagRepository) SetEnvironmentEnabled(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	ids []primitive.ObjectID,
+	environment string,
+	isEnabled bool,
+) ([]error, error) {
+	return m.SetEnvironmentEnabledFunc(ctx, organizationID, ids, environment, isEnabled)
+}
+
+func (m *MockFeatureFlagRepository) SoftDelete(ctx context.Context, organizationID, id primitive.ObjectID) error {
+	return m.SoftDeleteFunc(ctx, organizationID, id)
+}
+
+func (m *MockFeatureFlagRepository) Restore(ctx context.Context, organizationID, id primitive.ObjectID) error {
+	return m.RestoreFunc(ctx, organizationID, id)
+}
+
+func (m *MockFeatureFlagRepository) SetArchived(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	isArchived bool,
+) error {
+	return m.SetArchivedFunc(ctx, organizationID, id, isArchived)
+}
+
+func (m *MockFeatureFlagRepository) SetTags(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	tags []string,
+) error {
+	return m.SetTagsFunc(ctx, organizationID, id, tags)
+}
+
+type MockOrganizationRepository struct {
+	FindByIDFunc  func(ctx context.Context, id primitive.ObjectID) (*organizationmodel.OrganizationRecord, error)
+	UpdateOneFunc func(ctx context.Context, filter, update bson.D) error
+}
+
+func (m *MockOrganizationRepository) FindByID(
+	ctx context.Context,
+	id primitive.ObjectID,
+) (*organizationmodel.OrganizationRecord, error) {
+	return m.FindByIDFunc(ctx, id)
+}
+
+func (m *MockOrganizationRepository) UpdateOne(ctx context.Context, filter, update bson.D) error {
+	return m.UpdateOneFunc(ctx, filter, update)
+}
+
+type MockTimelineRepository struct {
+	InsertOneFunc  func(ctx context.Context, record *timelinemodel.TimelineRecord) (primitive.ObjectID, error)
+	InsertManyFunc func(ctx context.Context, records []*timelinemodel.TimelineRecord) error
+	UpdateOneFunc  func(
+		ctx context.Context,
+		featureFlagID primitive.ObjectID,
+		entry *timelinemodel.TimelineEntry,
+	) error
+	FindByIDFunc                      func(ctx context.Context, id primitive.ObjectID) (*timelinemodel.TimelineRecord, error)
+	FindFeatureFlagIDsByChangeSetFunc func(ctx context.Context, changeSetID string) ([]primitive.ObjectID, error)
+}
+
+func (m *MockTimelineRepository) InsertOne(
+	ctx context.Context,
+	record *timelinemodel.TimelineRecord,
+) (primitive.ObjectID, error) {
+	return m.InsertOneFunc(ctx, record)
+}
+
+func (m *MockTimelineRepository) InsertMany(ctx context.Context, records []*timelinemodel.TimelineRecord) error {
+	return m.InsertManyFunc(ctx, records)
+}
+
+func (m *MockTimelineRepository) UpdateOne(
+	ctx context.Context,
+	featureFlagID primitive.ObjectID,
+	entry *timelinemodel.TimelineEntry,
+) error {
+	return m.UpdateOneFunc(ctx, featureFlagID, entry)
+}
+
+func (m *MockTimelineRepository) FindByID(
+	ctx context.Context,
+	id primitive.ObjectID,
+) (*timelinemodel.TimelineRecord, error) {
+	return m.FindByIDFunc(ctx, id)
+}
+
+func (m *MockTimelineRepository) FindFeatureFlagIDsByChangeSet(
+	ctx context.Context,
+	changeSetID string,
+) ([]primitive.ObjectID, error) {
+	return m.FindFeatureFlagIDsByChangeSetFunc(ctx, changeSetID)
+}
+
+type MockUserRepository struct {
+	FindByIDFunc        func(ctx context.Context, id primitive.ObjectID) (*usermodel.UserRecord, error)
+	FindActiveByIDsFunc func(ctx context.Context, ids []primitive.ObjectID) ([]usermodel.UserRecord, error)
+}
+
+func (m *MockUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*usermodel.UserRecord, error) {
+	return m.FindByIDFunc(ctx, id)
+}
+
+func (m *MockUserRepository) FindActiveByIDs(ctx context.Context, ids []primitive.ObjectID) ([]usermodel.UserRecord, error) {
+	return m.FindActiveByIDsFunc(ctx, ids)
+}
+
+type MockIdempotencyKeyRepository struct {
+	InsertOneFunc func(ctx context.Context, record *idempotencykeymodel.IdempotencyKeyRecord) (primitive.ObjectID, error)
+	FindByKeyFunc func(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		key string,
+	) (*idempotencykeymodel.IdempotencyKeyRecord, error)
+}
+
+func (m *MockIdempotencyKeyRepository) InsertOne(
+	ctx context.Context,
+	record *idempotencykeymodel.IdempotencyKeyRecord,
+) (primitive.ObjectID, error) {
+	return m.InsertOneFunc(ctx, record)
+}
+
+func (m *MockIdempotencyKeyRepository) FindByKey(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	key string,
+) (*idempotencykeymodel.IdempotencyKeyRecord, error) {
+	return m.FindByKeyFunc(ctx, organizationID, key)
+}
+
+// MockWebhookRepository has no webhooks subscribed to any event
+type MockWebhookRepository struct{}
+
+func (m *MockWebhookRepository) FindByEvent(
+	_ context.Context,
+	_ primitive.ObjectID,
+	_ webhookmodel.EventType,
+) ([]webhookmodel.WebhookRecord, error) {
+	return []webhookmodel.WebhookRecord{}, nil
+}
+
+// MockTransact runs fn without a transaction, the mocks have nothing to
+// roll back
+func MockTransact(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// NewMockRepositories wires empty mocks, tests fill in the funcs they expect
+// to be called
+func NewMockRepositories() (
+	handlers.Repositories,
+	*MockFeatureFlagRepository,
+	*MockOrganizationRepository,
+	*MockTimelineRepository,
+) {
+	featureFlags := &MockFeatureFlagRepository{}
+	organizations := &MockOrganizationRepository{}
+	timelines := &MockTimelineRepository{}
+
+	return handlers.Repositories{
+		FeatureFlags:    featureFlags,
+		Organizations:   organizations,
+		Timelines:       timelines,
+		Users:           &MockUserRepository{},
+		IdempotencyKeys: &MockIdempotencyKeyRepository{},
+		Webhooks:        &MockWebhookRepository{},
+		Transact:        MockTransact,
+		EvaluationCache: cache.NewMemoryBackend(),
+	}, featureFlags, organizations, timelines
+}
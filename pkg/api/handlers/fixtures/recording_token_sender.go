@@ -0,0 +1,37 @@
+package fixtures
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+)
+
+// RecordingTokenSender keeps every token it's handed so tests can use them
+// the way a user would after opening the email
+type RecordingTokenSender struct {
+	mu       sync.Mutex
+	Messages []handlers.TokenMessage
+}
+
+func (rts *RecordingTokenSender) SendToken(_ context.Context, message handlers.TokenMessage) error {
+	rts.mu.Lock()
+	defer rts.mu.Unlock()
+
+	rts.Messages = append(rts.Messages, message)
+	return nil
+}
+
+// Last returns the latest token sent for purpose, empty if there's none
+func (rts *RecordingTokenSender) Last(purpose handlers.TokenPurpose) string {
+	rts.mu.Lock()
+	defer rts.mu.Unlock()
+
+	for i := len(rts.Messages) - 1; i >= 0; i-- {
+		if rts.Messages[i].Purpose == purpose {
+			return rts.Messages[i].Token
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FlagEventType = string
+
+const (
+	FlagToggledEvent  FlagEventType = "feature_flag.toggle"
+	FlagApprovedEvent FlagEventType = "revision.approved"
+	FlagPatchedEvent  FlagEventType = "feature_flag.patched"
+	FlagCreatedEvent  FlagEventType = "feature_flag.created"
+	FlagDeletedEvent  FlagEventType = "feature_flag.deleted"
+	// FlagUpdatedEvent covers the other changes to how a flag evaluates,
+	// such as a rollback or its environments and prerequisites
+	FlagUpdatedEvent FlagEventType = "feature_flag.updated"
+)
+
+type FlagEvent struct {
+	Type           FlagEventType      `json:"type"`
+	OrganizationID primitive.ObjectID `json:"organization_id"`
+	FeatureFlagID  primitive.ObjectID `json:"feature_flag_id"`
+	// Environment is only set on events concerning a single environment
+	Environment string                 `json:"environment,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// FlagSubscription receives the events of an organization, optionally
+// narrowed down to an environment. Done is closed once the subscription
+// ends, either unsubscribed or dropped for falling behind.
+type FlagSubscription struct {
+	Events         chan FlagEvent
+	Done           chan struct{}
+	organizationID primitive.ObjectID
+	environment    string
+	once           sync.Once
+}
+
+func (fs *FlagSubscription) matches(event *FlagEvent) bool {
+	if fs.organizationID != event.OrganizationID {
+		return false
+	}
+
+	return fs.environment == "" || event.Environment == "" || fs.environment == event.Environment
+}
+
+func (fs *FlagSubscription) close() {
+	fs.once.Do(func() {
+		close(fs.Done)
+	})
+}
+
+// FlagListener is called with every event published, whatever its
+// organization
+type FlagListener func(event FlagEvent)
+
+// FlagEventBroker fans the flag changes out to the streams of this
+// process. Publishing never blocks on the streams, a subscriber whose
+// buffer is full is dropped so it reconnects and catches up instead of
+// silently missing changes.
+type FlagEventBroker struct {
+	mutex         sync.RWMutex
+	subscriptions map[*FlagSubscription]struct{}
+	listeners     []FlagListener
+	bufferSize    int
+}
+
+func NewFlagEventBroker(bufferSize int) *FlagEventBroker {
+	return &FlagEventBroker{
+		subscriptions: make(map[*FlagSubscription]struct{}),
+		bufferSize:    bufferSize,
+	}
+}
+
+func (feb *FlagEventBroker) Subscribe(organizationID primitive.ObjectID, environment string) *FlagSubscription {
+	subscription := &FlagSubscription{
+		Events:         make(chan FlagEvent, feb.bufferSize),
+		Done:           make(chan struct{}),
+		organizationID: organizationID,
+		environment:    environment,
+	}
+
+	feb.mutex.Lock()
+	feb.subscriptions[subscription] = struct{}{}
+	feb.mutex.Unlock()
+
+	return subscription
+}
+
+// Listen registers listener to be called on every publish. Listeners run
+// before Publish returns, so the write that published is seen by them
+// before its response goes out, and shouldn't be slow.
+func (feb *FlagEventBroker) Listen(listener FlagListener) {
+	feb.mutex.Lock()
+	feb.listeners = append(feb.listeners, listener)
+	feb.mutex.Unlock()
+}
+
+func (feb *FlagEventBroker) Unsubscribe(subscription *FlagSubscription) {
+	feb.mutex.Lock()
+	delete(feb.subscriptions, subscription)
+	feb.mutex.Unlock()
+
+	subscription.close()
+}
+
+func (feb *FlagEventBroker) Publish(event FlagEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	dropped := make([]*FlagSubscription, 0)
+
+	feb.mutex.RLock()
+	listeners := feb.listeners
+	feb.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+
+	feb.mutex.RLock()
+	for subscription := range feb.subscriptions {
+		if !subscription.matches(&event) {
+			continue
+		}
+
+		select {
+		case subscription.Events <- event:
+		default:
+			dropped = append(dropped, subscription)
+		}
+	}
+	feb.mutex.RUnlock()
+
+	for _, subscription := range dropped {
+		feb.Unsubscribe(subscription)
+	}
+}
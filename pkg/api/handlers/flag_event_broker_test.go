@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFlagEventBrokerFiltersEvents(t *testing.T) {
+	broker := handlers.NewFlagEventBroker(4)
+	organizationID := primitive.NewObjectID()
+
+	prod := broker.Subscribe(organizationID, "prod")
+	defer broker.Unsubscribe(prod)
+	other := broker.Subscribe(primitive.NewObjectID(), "")
+	defer broker.Unsubscribe(other)
+
+	broker.Publish(handlers.FlagEvent{
+		Type:           handlers.FlagToggledEvent,
+		OrganizationID: organizationID,
+		Environment:    "dev",
+	})
+	broker.Publish(handlers.FlagEvent{
+		Type:           handlers.FlagToggledEvent,
+		OrganizationID: organizationID,
+		Environment:    "prod",
+	})
+	broker.Publish(handlers.FlagEvent{
+		Type:           handlers.FlagApprovedEvent,
+		OrganizationID: organizationID,
+	})
+
+	assert.Equal(t, 2, len(prod.Events))
+	event := <-prod.Events
+	assert.Equal(t, "prod", event.Environment)
+	assert.False(t, event.CreatedAt.IsZero())
+	event = <-prod.Events
+	assert.Equal(t, handlers.FlagApprovedEvent, event.Type)
+	assert.Equal(t, 0, len(other.Events))
+}
+
+func TestFlagEventBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := handlers.NewFlagEventBroker(1)
+	organizationID := primitive.NewObjectID()
+
+	subscription := broker.Subscribe(organizationID, "")
+	defer broker.Unsubscribe(subscription)
+
+	broker.Publish(handlers.FlagEvent{Type: handlers.FlagPatchedEvent, OrganizationID: organizationID})
+	broker.Publish(handlers.FlagEvent{Type: handlers.FlagPatchedEvent, OrganizationID: organizationID})
+
+	select {
+	case <-subscription.Done:
+	default:
+		t.Fatal("slow subscriber was not dropped")
+	}
+}
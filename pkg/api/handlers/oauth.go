@@ -86,7 +86,19 @@ func (sh *OAuthHandler) Callback(c echo.Context) error {
 	model := usermodel.New(sh.db)
 	foundRecord, err := model.FindByEmail(context.Background(), userData.Email)
 	if err == nil {
-		token, err := apiutils.CreateJWT(foundRecord.ID, config.JWTExpireTime)
+		token, err := apiutils.CreateJWT(foundRecord.ID, config.AccessTokenExpireTime)
+		if err != nil {
+			sh.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		refreshToken, err := issueRefreshToken(sh.db, foundRecord.ID)
 		if err != nil {
 			sh.logger.Debug("Server error",
 				zap.Error(err),
@@ -99,15 +111,39 @@ func (sh *OAuthHandler) Callback(c echo.Context) error {
 		}
 
 		return c.JSON(http.StatusOK, common.AuthResponse{
-			ID:        foundRecord.ID,
-			Email:     foundRecord.Email,
-			FirstName: foundRecord.FirstName,
-			LastName:  foundRecord.LastName,
-			Token:     token,
+			ID:           foundRecord.ID,
+			Email:        foundRecord.Email,
+			FirstName:    foundRecord.FirstName,
+			LastName:     foundRecord.LastName,
+			Token:        token,
+			RefreshToken: refreshToken,
 		})
 	}
 
 	objectID, err := model.InsertOne(context.Background(), userData)
+	if err != nil {
+		// The email may still belong to a soft deleted user
+		if mongo.IsDuplicateKeyError(err) {
+			sh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusConflict,
+				apierrors.EmailConflictError,
+			)
+		}
+		sh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	token, err := apiutils.CreateJWT(objectID, config.AccessTokenExpireTime)
 	if err != nil {
 		sh.logger.Debug("Server error",
 			zap.Error(err),
@@ -119,7 +155,7 @@ func (sh *OAuthHandler) Callback(c echo.Context) error {
 		)
 	}
 
-	token, err := apiutils.CreateJWT(objectID, config.JWTExpireTime)
+	refreshToken, err := issueRefreshToken(sh.db, objectID)
 	if err != nil {
 		sh.logger.Debug("Server error",
 			zap.Error(err),
@@ -135,9 +171,10 @@ func (sh *OAuthHandler) Callback(c echo.Context) error {
 		zap.String("_id", objectID.Hex()),
 	)
 	return c.JSON(http.StatusCreated, common.AuthResponse{
-		ID:    userData.ID,
-		Email: userData.Email,
-		Token: token,
+		ID:           userData.ID,
+		Email:        userData.Email,
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -3,12 +3,20 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
 	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	auditmodel "github.com/Roll-Play/togglelabs/pkg/models/audit"
 	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
 	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
 	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
 	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -32,6 +40,79 @@ type ProjectPostRequest struct {
 	Description string `json:"description" validate:"required"`
 }
 
+type EnvironmentPostRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+type PatchOrganizationSettingsRequest struct {
+	// Name is unique among the organizations of the same creator
+	Name              *string `json:"name"`
+	RequiredApprovals *int    `json:"required_approvals" validate:"omitempty,gte=1"`
+	AllowSelfApproval *bool   `json:"allow_self_approval"`
+	// DefaultEnvironment has to be defined on the organization, an empty
+	// one clears it
+	DefaultEnvironment *string `json:"default_environment"`
+	// DeletePermissionLevel is required to delete flags, either ADMIN or
+	// COLLABORATOR
+	DeletePermissionLevel *string `json:"delete_permission_level" validate:"omitempty,oneof=ADMIN COLLABORATOR"`
+}
+
+// SetMaintenanceModeRequest turns the maintenance mode of the organization
+// on or off
+type SetMaintenanceModeRequest struct {
+	MaintenanceMode *bool `json:"maintenance_mode" validate:"required"`
+}
+
+type InviteMemberRequest struct {
+	Email           string `json:"email" validate:"required,email"`
+	PermissionLevel string `json:"permission_level" validate:"required"`
+}
+
+type UpdateMemberRoleRequest struct {
+	PermissionLevel string `json:"permission_level" validate:"required"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+type MemberResponse struct {
+	UserID          primitive.ObjectID `json:"user_id"`
+	Email           string             `json:"email"`
+	FirstName       string             `json:"first_name,omitempty"`
+	LastName        string             `json:"last_name,omitempty"`
+	PermissionLevel string             `json:"permission_level"`
+}
+
+type ListMembersResponse = common.PaginatedResponse[MemberResponse]
+
+// APIKeyResponse is only sent when a key is generated, it's the one time
+// the plain key is available
+type APIKeyResponse struct {
+	organizationmodel.APIKey
+	Key string `json:"key"`
+}
+
+type PostWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,min=1"`
+}
+
+// WebhookResponse is only sent when a webhook is registered, it's the one
+// time the signing secret is available
+type WebhookResponse struct {
+	webhookmodel.WebhookRecord
+	Secret string `json:"secret"`
+}
+
+type ListActivityResponse = common.PaginatedResponse[timelinemodel.ActivityEntry]
+
+type MembersResponse struct {
+	Members []organizationmodel.OrganizationMember `json:"members"`
+	Invites []organizationmodel.OrganizationInvite `json:"invites"`
+}
+
 func (oh *OrganizationHandler) PostOrganization(c echo.Context) error {
 	request := new(OrganizationPostRequest)
 	if err := c.Bind(request); err != nil {
@@ -44,6 +125,9 @@ func (oh *OrganizationHandler) PostOrganization(c echo.Context) error {
 		)
 	}
 
+	// A blank name would pass the required check
+	request.Name = strings.TrimSpace(request.Name)
+
 	validate := validator.New()
 
 	if err := validate.Struct(request); err != nil {
@@ -94,12 +178,36 @@ func (oh *OrganizationHandler) PostOrganization(c echo.Context) error {
 
 	user.Password = ""
 
+	model := organizationmodel.New(oh.db)
+
+	nameInUse, err := model.NameInUseByCreator(context.Background(), userID, request.Name, primitive.NilObjectID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if nameInUse {
+		oh.logger.Debug("Client error",
+			zap.Error(errors.New(apierrors.NameConflictError)),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.NameConflictError,
+		)
+	}
+
 	organization := organizationmodel.NewOrganizationRecord(request.Name, []organizationmodel.OrganizationMember{{
 		User:            *user,
-		PermissionLevel: organizationmodel.Admin,
+		PermissionLevel: organizationmodel.Owner,
 	}})
-
-	model := organizationmodel.New(oh.db)
+	organization.CreatedByID = &userID
+	organization.Environments = []organizationmodel.Environment{{Name: organizationmodel.DefaultEnvironmentName}}
+	organization.DefaultEnvironment = organizationmodel.DefaultEnvironmentName
 
 	_, err = model.InsertOne(context.Background(), organization)
 
@@ -157,11 +265,7 @@ func (oh *OrganizationHandler) PostProject(c echo.Context) error {
 		oh.logger.Debug("Client error",
 			zap.String("cause", apierrors.ForbiddenError),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	request := new(ProjectPostRequest)
@@ -247,11 +351,7 @@ func (oh *OrganizationHandler) GetOrganization(c echo.Context) error {
 		oh.logger.Debug("Client error",
 			zap.String("cause", apierrors.ForbiddenError),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	return c.JSON(http.StatusOK, organizationRecord)
@@ -296,14 +396,10 @@ func (oh *OrganizationHandler) DeleteProject(c echo.Context) error {
 
 	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Collaborator)
 	if !permission {
-		oh.logger.Debug("Server error",
+		oh.logger.Debug("Client error",
 			zap.String("cause", apierrors.ForbiddenError),
 		)
-		return apierrors.CustomError(
-			c,
-			http.StatusForbidden,
-			apierrors.ForbiddenError,
-		)
+		return apiutils.PermissionDenied(c)
 	}
 
 	projectID, err := primitive.ObjectIDFromHex(c.Param("projectID"))
@@ -360,6 +456,1795 @@ func (oh *OrganizationHandler) DeleteProject(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (oh *OrganizationHandler) PatchOrganizationSettings(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	return oh.patchOrganization(c, userID, organizationID)
+}
+
+// PatchOrganization is PatchOrganizationSettings for an organization given
+// in the path rather than the organization header
+func (oh *OrganizationHandler) PatchOrganization(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := primitive.ObjectIDFromHex(c.Param("organizationID"))
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	return oh.patchOrganization(c, userID, organizationID)
+}
+
+// patchOrganization renames the organization and updates its settings,
+// recording the changes in the audit log
+func (oh *OrganizationHandler) patchOrganization(
+	c echo.Context,
+	userID primitive.ObjectID,
+	organizationID primitive.ObjectID,
+) error {
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			oh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(PatchOrganizationSettingsRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	settings := bson.D{}
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			oh.logger.Debug("Client error",
+				zap.String("cause", "empty organization name"),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
+
+		if organizationRecord.CreatedByID != nil {
+			nameInUse, err := organizationModel.NameInUseByCreator(
+				context.Background(),
+				*organizationRecord.CreatedByID,
+				name,
+				organizationID,
+			)
+			if err != nil {
+				oh.logger.Debug("Server error",
+					zap.Error(err),
+				)
+				return apierrors.CustomError(c,
+					http.StatusInternalServerError,
+					apierrors.InternalServerError,
+				)
+			}
+
+			if nameInUse {
+				oh.logger.Debug("Client error",
+					zap.Error(errors.New(apierrors.NameConflictError)),
+				)
+				return apierrors.CustomError(c,
+					http.StatusConflict,
+					apierrors.NameConflictError,
+				)
+			}
+		}
+
+		organizationRecord.Name = name
+		settings = append(settings, bson.E{Key: "name", Value: name})
+	}
+
+	if request.RequiredApprovals != nil {
+		organizationRecord.RequiredApprovals = *request.RequiredApprovals
+		settings = append(settings, bson.E{Key: "required_approvals", Value: *request.RequiredApprovals})
+	}
+
+	if request.AllowSelfApproval != nil {
+		organizationRecord.AllowSelfApproval = *request.AllowSelfApproval
+		settings = append(settings, bson.E{Key: "allow_self_approval", Value: *request.AllowSelfApproval})
+	}
+
+	if request.DefaultEnvironment != nil {
+		if *request.DefaultEnvironment != "" && !organizationRecord.HasEnvironment(*request.DefaultEnvironment) {
+			oh.logger.Debug("Client error",
+				zap.String("cause", apierrors.UndefinedEnvironmentError),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusBadRequest,
+				apierrors.UndefinedEnvironmentError,
+			)
+		}
+		organizationRecord.DefaultEnvironment = *request.DefaultEnvironment
+		settings = append(settings, bson.E{Key: "default_environment", Value: *request.DefaultEnvironment})
+	}
+
+	if request.DeletePermissionLevel != nil {
+		organizationRecord.DeletePermissionLevel = *request.DeletePermissionLevel
+		settings = append(settings, bson.E{Key: "delete_permission_level", Value: *request.DeletePermissionLevel})
+	}
+
+	if len(settings) > 0 {
+		err = organizationModel.UpdateOne(
+			context.Background(),
+			bson.D{{Key: "_id", Value: organizationID}},
+			bson.D{{Key: "$set", Value: settings}},
+		)
+		if err != nil {
+			oh.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+
+		metadata := make(map[string]interface{}, len(settings))
+		for _, setting := range settings {
+			metadata[setting.Key] = setting.Value
+		}
+
+		auditModel := auditmodel.New(oh.db)
+		auditEntry := auditmodel.NewAuditEntry(userID, auditmodel.OrganizationUpdated, metadata)
+		err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+		if err != nil {
+			oh.logger.Debug("Server error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusInternalServerError,
+				apierrors.InternalServerError,
+			)
+		}
+	}
+
+	return c.JSON(http.StatusOK, organizationRecord)
+}
+
+// SetMaintenanceMode turns the maintenance mode of the organization on or
+// off. While it's on every flag evaluates to its default value, so it's kept
+// to admins and every change is recorded in the audit log.
+func (oh *OrganizationHandler) SetMaintenanceMode(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			oh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(SetMaintenanceModeRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	// Nothing changes, so there's nothing to record either
+	if *request.MaintenanceMode == organizationRecord.MaintenanceMode {
+		return c.JSON(http.StatusOK, organizationRecord)
+	}
+
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "maintenance_mode", Value: *request.MaintenanceMode}}}},
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+	organizationRecord.MaintenanceMode = *request.MaintenanceMode
+
+	action := auditmodel.MaintenanceDisabled
+	if organizationRecord.MaintenanceMode {
+		action = auditmodel.MaintenanceEnabled
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(userID, action, nil)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Maintenance mode set",
+		zap.String("organization_id", organizationID.Hex()),
+		zap.Bool("maintenance_mode", organizationRecord.MaintenanceMode))
+	return c.JSON(http.StatusOK, organizationRecord)
+}
+
+// PostEnvironment defines an environment on the organization. Flags created
+// afterwards get every environment the organization defines.
+func (oh *OrganizationHandler) PostEnvironment(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(EnvironmentPostRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if organizationRecord.HasEnvironment(request.Name) {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.EnvironmentConflictError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.EnvironmentConflictError,
+		)
+	}
+
+	environment := organizationmodel.Environment{
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		bson.D{{Key: "$push", Value: bson.M{"environments": environment}}},
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	return c.JSON(http.StatusCreated, environment)
+}
+
+// DeleteEnvironment removes an environment definition. Flags already in
+// that environment keep it, they are only barred from new ones.
+func (oh *OrganizationHandler) DeleteEnvironment(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	environmentName := c.Param("name")
+	if !organizationRecord.HasEnvironment(environmentName) {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.EnvironmentNotFoundError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusNotFound,
+			apierrors.EnvironmentNotFoundError,
+		)
+	}
+
+	update := bson.D{{Key: "$pull", Value: bson.M{"environments": bson.M{"name": environmentName}}}}
+	if organizationRecord.DefaultEnvironment == environmentName {
+		update = append(update, bson.E{Key: "$unset", Value: bson.M{"default_environment": ""}})
+	}
+
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		update,
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Environment deleted",
+		zap.String("name", environmentName))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// InviteMember adds a user to the organization. Users that don't have an
+// account yet are given a pending invite keyed by their email instead.
+func (oh *OrganizationHandler) InviteMember(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(InviteMemberRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	// Normalized once so the membership checks, the user lookup and the
+	// stored invite all use the same email
+	request.Email = usermodel.NormalizeEmail(request.Email)
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if !organizationmodel.IsValidPermissionLevel(request.PermissionLevel) {
+		oh.logger.Debug("Client error",
+			zap.String("permission_level", request.PermissionLevel),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if organizationRecord.HasMember(request.Email) || organizationRecord.HasPendingInvite(request.Email) {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.MemberConflictError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.MemberConflictError,
+		)
+	}
+
+	var update bson.D
+	userModel := usermodel.New(oh.db)
+	user, err := userModel.FindByEmail(context.Background(), request.Email)
+	switch {
+	case err == nil:
+		user.Password = ""
+		member := organizationmodel.OrganizationMember{
+			User:            *user,
+			PermissionLevel: request.PermissionLevel,
+		}
+		organizationRecord.Members = append(organizationRecord.Members, member)
+		update = bson.D{{Key: "$push", Value: bson.M{"members": member}}}
+	case errors.Is(err, mongo.ErrNoDocuments):
+		invite := organizationmodel.OrganizationInvite{
+			Email:           request.Email,
+			Status:          organizationmodel.Pending,
+			PermissionLevel: request.PermissionLevel,
+		}
+		// Older organizations were stored with a null invite list, which
+		// can't be pushed to
+		organizationRecord.Invites = append(organizationRecord.Invites, invite)
+		update = bson.D{{Key: "$set", Value: bson.M{"invites": organizationRecord.Invites}}}
+	default:
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		update,
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Member invited",
+		zap.String("email", request.Email))
+	return c.JSON(http.StatusCreated, MembersResponse{
+		Members: organizationRecord.Members,
+		Invites: organizationRecord.Invites,
+	})
+}
+
+func (oh *OrganizationHandler) UpdateMemberRole(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Param("userID"))
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	request := new(UpdateMemberRoleRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if !organizationmodel.IsValidPermissionLevel(request.PermissionLevel) {
+		oh.logger.Debug("Client error",
+			zap.String("permission_level", request.PermissionLevel),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	member := organizationRecord.FindMember(memberID)
+	if member == nil {
+		oh.logger.Debug("Client error",
+			zap.String("member_id", memberID.Hex()),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if member.PermissionLevel == organizationmodel.Owner {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.OwnerChangeError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.OwnerChangeError,
+		)
+	}
+
+	if member.PermissionLevel == organizationmodel.Admin &&
+		request.PermissionLevel != organizationmodel.Admin &&
+		organizationRecord.AdminCount() == 1 {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.LastAdminError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.LastAdminError,
+		)
+	}
+
+	oldPermissionLevel := member.PermissionLevel
+	member.PermissionLevel = request.PermissionLevel
+	// The guards above are checked again as part of the update, another
+	// admin may have been demoted since the organization was read
+	err = organizationModel.SetMemberRole(context.Background(), organizationID, memberID, request.PermissionLevel)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			oh.logger.Debug("Client error",
+				zap.String("cause", apierrors.LastAdminError),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusConflict,
+				apierrors.LastAdminError,
+			)
+		}
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.MemberRoleChanged, member.User.Email, oldPermissionLevel, request.PermissionLevel),
+		map[string]interface{}{
+			auditmodel.MemberIDMetadataKey:           memberID.Hex(),
+			auditmodel.OldPermissionLevelMetadataKey: oldPermissionLevel,
+			auditmodel.NewPermissionLevelMetadataKey: request.PermissionLevel,
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Member role updated",
+		zap.String("member_id", memberID.Hex()),
+		zap.String("permission_level", request.PermissionLevel))
+	return c.JSON(http.StatusOK, member)
+}
+
+func (oh *OrganizationHandler) ListMembers(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	memberIDs := make([]primitive.ObjectID, 0, len(organizationRecord.Members))
+	for _, member := range organizationRecord.Members {
+		memberIDs = append(memberIDs, member.User.ID)
+	}
+
+	// Members hold a copy of the user taken when they joined, the user
+	// collection has the up to date profile
+	userModel := usermodel.New(oh.db)
+	users, err := userModel.FindActiveByIDs(context.Background(), memberIDs)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	usersByID := make(map[primitive.ObjectID]usermodel.UserRecord, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	members := make([]MemberResponse, 0, len(users))
+	for _, member := range organizationRecord.Members {
+		user, ok := usersByID[member.User.ID]
+		if !ok {
+			continue
+		}
+
+		members = append(members, MemberResponse{
+			UserID:          user.ID,
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			PermissionLevel: member.PermissionLevel,
+		})
+	}
+	return c.JSON(http.StatusOK, common.Paginate(members, page, limit))
+}
+
+// GetOrganizationActivity is the feed of every timeline entry recorded on
+// the organization's flags. It can be narrowed down to an actor, an action
+// and a from/to RFC 3339 range.
+func (oh *OrganizationHandler) GetOrganizationActivity(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	filter := timelinemodel.ActivityFilter{
+		Action: c.QueryParam("action"),
+	}
+	if filter.Action != "" && !timelinemodel.IsValidActionFilter(filter.Action) {
+		oh.logger.Debug("Client error",
+			zap.String("action", filter.Action),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	var err error
+	if actor := c.QueryParam("actor"); actor != "" {
+		filter.UserID, err = primitive.ObjectIDFromHex(actor)
+	}
+	if from := c.QueryParam("from"); err == nil && from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+	}
+	if to := c.QueryParam("to"); err == nil && to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+	}
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.ReadOnly)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	timelineModel := timelinemodel.New(oh.db)
+	entries, total, err := timelineModel.FindOrganizationActivity(
+		context.Background(),
+		organizationID,
+		filter,
+		page,
+		limit,
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	return c.JSON(http.StatusOK, common.NewPaginatedResponse(entries, page, limit, total))
+}
+
+func (oh *OrganizationHandler) RemoveMember(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Param("userID"))
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	member := organizationRecord.FindMember(memberID)
+	if member == nil {
+		oh.logger.Debug("Client error",
+			zap.String("member_id", memberID.Hex()),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if member.PermissionLevel == organizationmodel.Owner {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.OwnerChangeError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.OwnerChangeError,
+		)
+	}
+
+	if member.PermissionLevel == organizationmodel.Admin && organizationRecord.AdminCount() == 1 {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.LastAdminError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusConflict,
+			apierrors.LastAdminError,
+		)
+	}
+
+	// Permissions are resolved from the member list on every request, so
+	// tokens held by the removed user stop granting access right away. The
+	// guards above are checked again as part of the removal.
+	err = organizationModel.RemoveMember(context.Background(), organizationID, memberID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			oh.logger.Debug("Client error",
+				zap.String("cause", apierrors.LastAdminError),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusConflict,
+				apierrors.LastAdminError,
+			)
+		}
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.MemberRemoved, member.User.Email),
+		map[string]interface{}{
+			auditmodel.MemberIDMetadataKey:           memberID.Hex(),
+			auditmodel.OldPermissionLevelMetadataKey: member.PermissionLevel,
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Member removed",
+		zap.String("member_id", memberID.Hex()))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TransferOwnership hands the organization over to another member, the
+// previous owner stays on as an admin. Only the owner can transfer it, or
+// an admin for organizations created before there were owners.
+func (oh *OrganizationHandler) TransferOwnership(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	var previousOwnerID *primitive.ObjectID
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if owner := organizationRecord.Owner(); owner != nil {
+		previousOwnerID = &owner.User.ID
+		permission = owner.User.ID == userID
+	}
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	request := new(TransferOwnershipRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(request.UserID)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	member := organizationRecord.FindMember(memberID)
+	if member == nil {
+		oh.logger.Debug("Client error",
+			zap.String("member_id", memberID.Hex()),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if member.PermissionLevel == organizationmodel.Owner {
+		oh.logger.Debug("Client error",
+			zap.String("cause", "member already owns the organization"),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	err = organizationModel.TransferOwnership(context.Background(), organizationID, previousOwnerID, memberID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			oh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusConflict,
+				apierrors.OwnerChangeError,
+			)
+		}
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oldPermissionLevel := member.PermissionLevel
+	member.PermissionLevel = organizationmodel.Owner
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.OwnershipTransferred, member.User.Email),
+		map[string]interface{}{
+			auditmodel.MemberIDMetadataKey:           memberID.Hex(),
+			auditmodel.OldPermissionLevelMetadataKey: oldPermissionLevel,
+			auditmodel.NewPermissionLevelMetadataKey: organizationmodel.Owner,
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Ownership transferred",
+		zap.String("member_id", memberID.Hex()))
+	return c.JSON(http.StatusOK, member)
+}
+
+func (oh *OrganizationHandler) PostAPIKey(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	apiKey, key, err := organizationmodel.NewAPIKey(userID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		bson.D{{Key: "$push", Value: bson.M{"api_keys": apiKey}}},
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.APIKeyCreated, apiKey.Prefix),
+		map[string]interface{}{
+			auditmodel.APIKeyIDMetadataKey: apiKey.ID.Hex(),
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("API key created",
+		zap.String("_id", apiKey.ID.Hex()))
+	return c.JSON(http.StatusCreated, APIKeyResponse{
+		APIKey: *apiKey,
+		Key:    key,
+	})
+}
+
+func (oh *OrganizationHandler) ListAPIKeys(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	apiKeys := organizationRecord.APIKeys
+	if apiKeys == nil {
+		apiKeys = []organizationmodel.APIKey{}
+	}
+
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+func (oh *OrganizationHandler) DeleteAPIKey(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	apiKeyID, err := primitive.ObjectIDFromHex(c.Param("apiKeyID"))
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	apiKey := organizationRecord.FindAPIKey(apiKeyID)
+	if apiKey == nil {
+		oh.logger.Debug("Client error",
+			zap.String("api_key_id", apiKeyID.Hex()),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	err = organizationModel.UpdateOne(
+		context.Background(),
+		bson.D{{Key: "_id", Value: organizationID}},
+		bson.D{{Key: "$pull", Value: bson.M{"api_keys": bson.M{"_id": apiKeyID}}}},
+	)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.APIKeyRevoked, apiKey.Prefix),
+		map[string]interface{}{
+			auditmodel.APIKeyIDMetadataKey: apiKey.ID.Hex(),
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("API key revoked",
+		zap.String("_id", apiKeyID.Hex()))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oh *OrganizationHandler) PostWebhook(c echo.Context) error {
+	request := new(PostWebhookRequest)
+	if err := c.Bind(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	targetURL, err := url.Parse(request.URL)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	if err := checkWebhookURL(context.Background(), targetURL); err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	for _, event := range request.Events {
+		if !webhookmodel.IsValidEventType(event) {
+			oh.logger.Debug("Client error",
+				zap.String("event", event),
+			)
+			return apierrors.CustomError(c,
+				http.StatusBadRequest,
+				apierrors.BadRequestError,
+			)
+		}
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationID, err := apiutils.GetOrganizationFromContext(c)
+	if err != nil {
+		oh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(oh.db)
+	organizationRecord, err := organizationModel.FindByID(context.Background(), organizationID)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	permission := apiutils.UserHasPermission(userID, organizationRecord, organizationmodel.Admin)
+	if !permission {
+		oh.logger.Debug("Client error",
+			zap.String("cause", apierrors.ForbiddenError),
+		)
+		return apiutils.PermissionDenied(c)
+	}
+
+	webhook, err := webhookmodel.NewWebhookRecord(organizationID, userID, request.URL, request.Events)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	model := webhookmodel.New(oh.db)
+	if _, err := model.InsertOne(context.Background(), webhook); err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	auditModel := auditmodel.New(oh.db)
+	auditEntry := auditmodel.NewAuditEntry(
+		userID,
+		fmt.Sprintf(auditmodel.WebhookCreated, targetURL.Host),
+		map[string]interface{}{
+			auditmodel.WebhookIDMetadataKey: webhook.ID.Hex(),
+		},
+	)
+	err = auditModel.UpdateOne(context.Background(), organizationID, auditEntry)
+	if err != nil {
+		oh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	oh.logger.Info("Webhook created",
+		zap.String("_id", webhook.ID.Hex()))
+	return c.JSON(http.StatusCreated, WebhookResponse{
+		WebhookRecord: *webhook,
+		Secret:        webhook.Secret,
+	})
+}
+
 func NewOrganizationHandler(db *mongo.Database, logger *zap.Logger) *OrganizationHandler {
 	return &OrganizationHandler{
 		db:     db,
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
+
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// DependencyCheck reports whether a dependency the API can't serve without
+// is reachable
+type DependencyCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// MongoCheck pings the primary of the database's deployment
+func MongoCheck(db *mongo.Database) DependencyCheck {
+	return DependencyCheck{
+		Name: "mongo",
+		Check: func(ctx context.Context) error {
+			return db.Client().Ping(ctx, readpref.Primary())
+		},
+	}
+}
+
+type ReadinessResponse struct {
+	Ready        bool              `json:"ready"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+type ReadinessHandler struct {
+	checks []DependencyCheck
+	logger *zap.Logger
+}
+
+func NewReadinessHandler(logger *zap.Logger, checks ...DependencyCheck) *ReadinessHandler {
+	return &ReadinessHandler{
+		checks: checks,
+		logger: logger,
+	}
+}
+
+// Ready runs every check at once, the API is only ready once they all pass.
+// Why a dependency is down is logged rather than served, as the route is
+// public.
+func (rh *ReadinessHandler) Ready(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), config.ReadinessTimeout*time.Second)
+	defer cancel()
+
+	response := ReadinessResponse{
+		Ready:        true,
+		Dependencies: make(map[string]string, len(rh.checks)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range rh.checks {
+		wg.Add(1)
+		go func(check DependencyCheck) {
+			defer wg.Done()
+
+			status := DependencyUp
+			if err := check.Check(ctx); err != nil {
+				rh.logger.Warn("Dependency down",
+					zap.String("dependency", check.Name),
+					zap.Error(err),
+				)
+				status = DependencyDown
+			}
+
+			mutex.Lock()
+			response.Dependencies[check.Name] = status
+			if status == DependencyDown {
+				response.Ready = false
+			}
+			mutex.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	if !response.Ready {
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
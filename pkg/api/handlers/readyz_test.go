@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+func serveReadiness(t *testing.T, h *handlers.ReadinessHandler) (int, handlers.ReadinessResponse) {
+	request := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	recorder := httptest.NewRecorder()
+	c := echo.New().NewContext(request, recorder)
+
+	require.NoError(t, h.Ready(c))
+
+	var response handlers.ReadinessResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	return recorder.Code, response
+}
+
+func upCheck(name string) handlers.DependencyCheck {
+	return handlers.DependencyCheck{
+		Name:  name,
+		Check: func(_ context.Context) error { return nil },
+	}
+}
+
+func TestReadyWhenDependenciesAreUp(t *testing.T) {
+	code, response := serveReadiness(t, handlers.NewReadinessHandler(zap.NewNop(), upCheck("mongo"), upCheck("redis")))
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, handlers.ReadinessResponse{
+		Ready: true,
+		Dependencies: map[string]string{
+			"mongo": handlers.DependencyUp,
+			"redis": handlers.DependencyUp,
+		},
+	}, response)
+}
+
+func TestNotReadyWhenMongoIsDown(t *testing.T) {
+	// Nothing listens on the port, so the ping fails once server selection
+	// times out
+	client, err := mongo.Connect(
+		context.Background(),
+		options.Client().ApplyURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200"),
+	)
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Disconnect(context.Background())
+	}()
+
+	h := handlers.NewReadinessHandler(zap.NewNop(), handlers.MongoCheck(client.Database("togglelabs")), upCheck("redis"))
+	code, response := serveReadiness(t, h)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, handlers.ReadinessResponse{
+		Ready: false,
+		Dependencies: map[string]string{
+			"mongo": handlers.DependencyDown,
+			"redis": handlers.DependencyUp,
+		},
+	}, response)
+}
+
+func TestNotReadyWhenAnyDependencyIsDown(t *testing.T) {
+	redisDown := handlers.DependencyCheck{
+		Name:  "redis",
+		Check: func(_ context.Context) error { return errors.New("connection refused") },
+	}
+	code, response := serveReadiness(t, handlers.NewReadinessHandler(zap.NewNop(), upCheck("mongo"), redisDown))
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, response.Ready)
+	assert.Equal(t, handlers.DependencyDown, response.Dependencies["redis"])
+}
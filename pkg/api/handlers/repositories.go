@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	"github.com/Roll-Play/togglelabs/pkg/models"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	idempotencykeymodel "github.com/Roll-Play/togglelabs/pkg/models/idempotency_key"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FeatureFlagRepository is what the handlers need from the feature flag
+// store, featureflagmodel.FeatureFlagModel being the Mongo backed one
+type FeatureFlagRepository interface {
+	InsertOne(ctx context.Context, record *featureflagmodel.FeatureFlagRecord) (primitive.ObjectID, error)
+	InsertMany(ctx context.Context, records []*featureflagmodel.FeatureFlagRecord) ([]primitive.ObjectID, error)
+	FindOne(ctx context.Context, filter interface{}) (*featureflagmodel.FeatureFlagRecord, error)
+	FindActiveByID(ctx context.Context, organizationID, id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error)
+	FindDeletedByID(ctx context.Context, organizationID, id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error)
+	FindByIDs(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		ids []primitive.ObjectID,
+	) ([]featureflagmodel.FeatureFlagRecord, error)
+	FindMany(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+		page,
+		limit int,
+		sort bson.D,
+	) ([]featureflagmodel.FeatureFlagRecord, int, error)
+	FindAfter(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		filter featureflagmodel.ListFilter,
+		cursor *primitive.ObjectID,
+		limit int,
+	) ([]featureflagmodel.FeatureFlagRecord, *primitive.ObjectID, error)
+	CountMany(ctx context.Context, organizationID primitive.ObjectID, filter featureflagmodel.ListFilter) (int, error)
+	FindAll(ctx context.Context, organizationID primitive.ObjectID) ([]featureflagmodel.FeatureFlagRecord, error)
+	FindWithExpectedConfigHash(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+	) ([]featureflagmodel.FeatureFlagRecord, error)
+	NameInUse(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		name string,
+		environments []string,
+		ignoreID primitive.ObjectID,
+	) (bool, error)
+	UpdateOne(ctx context.Context, filter interface{}, update bson.D) error
+	PushRevision(
+		ctx context.Context,
+		organizationID,
+		id primitive.ObjectID,
+		revision *featureflagmodel.Revision,
+		version *int,
+	) (int, error)
+	AddApproval(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID,
+		userID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	PromoteRevision(
+		ctx context.Context,
+		record *featureflagmodel.FeatureFlagRecord,
+		revisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	ScheduleRevision(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID primitive.ObjectID,
+		scheduledAt primitive.DateTime,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	RejectRevision(
+		ctx context.Context,
+		organizationID,
+		id,
+		revisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	RollbackRevision(
+		ctx context.Context,
+		record *featureflagmodel.FeatureFlagRecord,
+		liveRevisionID,
+		previousRevisionID primitive.ObjectID,
+	) (*featureflagmodel.FeatureFlagRecord, error)
+	SetEnvironmentEnabled(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		ids []primitive.ObjectID,
+		environment string,
+		isEnabled bool,
+	) ([]error, error)
+	SoftDelete(ctx context.Context, organizationID, id primitive.ObjectID) error
+	Restore(ctx context.Context, organizationID, id primitive.ObjectID) error
+	SetArchived(ctx context.Context, organizationID, id primitive.ObjectID, isArchived bool) error
+	SetTags(ctx context.Context, organizationID, id primitive.ObjectID, tags []string) error
+}
+
+type OrganizationRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*organizationmodel.OrganizationRecord, error)
+	UpdateOne(ctx context.Context, filter, update bson.D) error
+}
+
+type TimelineRepository interface {
+	InsertOne(ctx context.Context, record *timelinemodel.TimelineRecord) (primitive.ObjectID, error)
+	InsertMany(ctx context.Context, records []*timelinemodel.TimelineRecord) error
+	UpdateOne(ctx context.Context, featureFlagID primitive.ObjectID, entry *timelinemodel.TimelineEntry) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*timelinemodel.TimelineRecord, error)
+	FindFeatureFlagIDsByChangeSet(ctx context.Context, changeSetID string) ([]primitive.ObjectID, error)
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*usermodel.UserRecord, error)
+	FindActiveByIDs(ctx context.Context, ids []primitive.ObjectID) ([]usermodel.UserRecord, error)
+}
+
+type IdempotencyKeyRepository interface {
+	InsertOne(ctx context.Context, record *idempotencykeymodel.IdempotencyKeyRecord) (primitive.ObjectID, error)
+	FindByKey(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		key string,
+	) (*idempotencykeymodel.IdempotencyKeyRecord, error)
+}
+
+type WebhookRepository interface {
+	FindByEvent(
+		ctx context.Context,
+		organizationID primitive.ObjectID,
+		event webhookmodel.EventType,
+	) ([]webhookmodel.WebhookRecord, error)
+}
+
+// Transactor runs fn atomically, the repository calls made with the context
+// handed to fn commit or roll back together
+type Transactor func(ctx context.Context, fn func(ctx context.Context) error) error
+
+// Repositories are the stores the handlers read and write through
+type Repositories struct {
+	FeatureFlags    FeatureFlagRepository
+	Organizations   OrganizationRepository
+	Timelines       TimelineRepository
+	Users           UserRepository
+	IdempotencyKeys IdempotencyKeyRepository
+	Webhooks        WebhookRepository
+	Transact        Transactor
+	// EvaluationCache holds the flags evaluation reads, invalidated on
+	// every write made through the handler
+	EvaluationCache cache.Backend
+}
+
+// NewMongoRepositories backs every repository with its model on db
+func NewMongoRepositories(db *mongo.Database) Repositories {
+	return Repositories{
+		FeatureFlags:    featureflagmodel.New(db),
+		Organizations:   organizationmodel.New(db),
+		Timelines:       timelinemodel.New(db),
+		Users:           usermodel.New(db),
+		IdempotencyKeys: idempotencykeymodel.New(db),
+		Webhooks:        webhookmodel.New(db),
+		Transact: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return models.WithTransaction(ctx, db, fn)
+		},
+		EvaluationCache: cache.NewMemoryBackend(),
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/models"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	timelinemodel "github.com/Roll-Play/togglelabs/pkg/models/timeline"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// RevisionScheduler promotes scheduled revisions once their activation
+// time has passed. Promotions are published on events and sent to webhooks
+// like approvals made through the handler, so cached flags are invalidated
+// and subscribers told.
+type RevisionScheduler struct {
+	db       *mongo.Database
+	logger   *zap.Logger
+	events   *FlagEventBroker
+	webhooks *WebhookDispatcher
+	interval time.Duration
+}
+
+func NewRevisionScheduler(
+	db *mongo.Database,
+	logger *zap.Logger,
+	events *FlagEventBroker,
+	interval time.Duration,
+) *RevisionScheduler {
+	return &RevisionScheduler{
+		db:       db,
+		logger:   logger,
+		events:   events,
+		webhooks: NewWebhookDispatcher(db, logger),
+		interval: interval,
+	}
+}
+
+// Start checks for due revisions every interval until the context is done
+func (rs *RevisionScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rs.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := rs.PromoteDueRevisions(ctx, now); err != nil {
+					rs.logger.Error("Failed to promote scheduled revisions",
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// PromoteDueRevisions makes every scheduled revision due at now live. Flags
+// deleted before activation are skipped. Each promotion only applies to the
+// flag as it was read, so revisions pushed in the meantime are kept and a
+// revision another scheduler promoted first is left alone.
+func (rs *RevisionScheduler) PromoteDueRevisions(ctx context.Context, now time.Time) error {
+	model := featureflagmodel.New(rs.db)
+	featureFlagRecords, err := model.FindDueScheduled(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	timelineModel := timelinemodel.New(rs.db)
+	for index := range featureFlagRecords {
+		featureFlagRecord := &featureFlagRecords[index]
+
+		due := make([]featureflagmodel.Revision, 0)
+		for _, revision := range featureFlagRecord.Revisions {
+			if revision.Status == featureflagmodel.Scheduled &&
+				revision.ScheduledAt != nil &&
+				!revision.ScheduledAt.Time().After(now) {
+				due = append(due, revision)
+			}
+		}
+
+		// With several due revisions the latest scheduled one ends up live
+		sort.SliceStable(due, func(i, j int) bool {
+			return *due[i].ScheduledAt < *due[j].ScheduledAt
+		})
+
+		promoted := 0
+		for _, revision := range due {
+			approverID := lastApprover(revision)
+
+			var promotedRecord *featureflagmodel.FeatureFlagRecord
+			err := models.WithTransaction(ctx, rs.db, func(ctx context.Context) error {
+				record, err := model.PromoteRevision(ctx, featureFlagRecord, revision.ID)
+				if err != nil {
+					return err
+				}
+
+				timelineEntry := timelinemodel.NewTimelineEntry(
+					approverID,
+					timelinemodel.RevisionApproved,
+					map[string]interface{}{
+						timelinemodel.RevisionIDMetadataKey: revision.ID.Hex(),
+					},
+				)
+				if err := timelineModel.UpdateOne(ctx, featureFlagRecord.ID, timelineEntry); err != nil {
+					return err
+				}
+
+				promotedRecord = record
+				return nil
+			})
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				// The flag changed since it was read, what is still due is
+				// picked up on the next run
+				rs.logger.Debug("Scheduled revision changed before promotion",
+					zap.String("_id", featureFlagRecord.ID.Hex()),
+					zap.String("revision_id", revision.ID.Hex()),
+				)
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			featureFlagRecord = promotedRecord
+			promoted++
+
+			data := map[string]interface{}{
+				"revision_id": revision.ID.Hex(),
+				"version":     featureFlagRecord.Version,
+			}
+			rs.webhooks.Dispatch(
+				webhookmodel.RevisionApproved,
+				featureFlagRecord.OrganizationID,
+				featureFlagRecord.ID,
+				approverID,
+				data,
+			)
+			rs.events.Publish(FlagEvent{
+				Type:           FlagApprovedEvent,
+				OrganizationID: featureFlagRecord.OrganizationID,
+				FeatureFlagID:  featureFlagRecord.ID,
+				Data:           data,
+			})
+		}
+
+		rs.logger.Info("Promoted scheduled revisions",
+			zap.String("_id", featureFlagRecord.ID.Hex()),
+			zap.Int("revisions", promoted),
+		)
+	}
+
+	return nil
+}
+
+func lastApprover(revision featureflagmodel.Revision) primitive.ObjectID {
+	if len(revision.Approvals) == 0 {
+		return revision.UserID
+	}
+
+	return revision.Approvals[len(revision.Approvals)-1]
+}
@@ -88,7 +88,18 @@ func (sh *SignInHandler) PostSignIn(c echo.Context) error {
 		)
 	}
 
-	token, err := apiutils.CreateJWT(ur.ID, config.JWTExpireTime)
+	token, err := apiutils.CreateJWT(ur.ID, config.AccessTokenExpireTime)
+	if err != nil {
+		sh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	refreshToken, err := issueRefreshToken(sh.db, ur.ID)
 	if err != nil {
 		sh.logger.Debug("Server error",
 			zap.Error(err),
@@ -103,11 +114,12 @@ func (sh *SignInHandler) PostSignIn(c echo.Context) error {
 		zap.String("_id", ur.ID.Hex()),
 	)
 	return c.JSON(http.StatusOK, common.AuthResponse{
-		ID:        ur.ID,
-		Email:     ur.Email,
-		FirstName: ur.FirstName,
-		LastName:  ur.LastName,
-		Token:     token,
+		ID:           ur.ID,
+		Email:        ur.Email,
+		FirstName:    ur.FirstName,
+		LastName:     ur.LastName,
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Roll-Play/togglelabs/pkg/api/common"
@@ -30,7 +31,7 @@ func NewSignUpHandler(db *mongo.Database, logger *zap.Logger) *SignUpHandler {
 
 type SignUpRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"gte=8"`
+	Password string `json:"password" validate:"required"`
 }
 
 func (sh *SignUpHandler) PostUser(c echo.Context) error {
@@ -44,6 +45,7 @@ func (sh *SignUpHandler) PostUser(c echo.Context) error {
 			apierrors.BadRequestError,
 		)
 	}
+	request.Email = usermodel.NormalizeEmail(request.Email)
 
 	validate := validator.New()
 
@@ -51,9 +53,24 @@ func (sh *SignUpHandler) PostUser(c echo.Context) error {
 		sh.logger.Debug("Client error",
 			zap.Error(err),
 		)
+		message := apierrors.BadRequestError
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) && validationErrors[0].Field() == "Email" {
+			message = apierrors.InvalidEmailError
+		}
 		return apierrors.CustomError(c,
 			http.StatusBadRequest,
-			apierrors.BadRequestError,
+			message,
+		)
+	}
+
+	if err := usermodel.CheckPassword(request.Password); err != nil {
+		sh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			passwordPolicyMessage(err),
 		)
 	}
 
@@ -81,6 +98,27 @@ func (sh *SignUpHandler) PostUser(c echo.Context) error {
 	}
 
 	objectID, err := model.InsertOne(context.Background(), ur)
+	if err != nil {
+		// The email may still belong to a soft deleted user
+		if mongo.IsDuplicateKeyError(err) {
+			sh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.EmailConflictError,
+			)
+		}
+		sh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	token, err := apiutils.CreateJWT(objectID, config.AccessTokenExpireTime)
 	if err != nil {
 		sh.logger.Debug("Server error",
 			zap.Error(err),
@@ -91,7 +129,7 @@ func (sh *SignUpHandler) PostUser(c echo.Context) error {
 		)
 	}
 
-	token, err := apiutils.CreateJWT(objectID, config.JWTExpireTime)
+	refreshToken, err := issueRefreshToken(sh.db, objectID)
 	if err != nil {
 		sh.logger.Debug("Server error",
 			zap.Error(err),
@@ -101,14 +139,26 @@ func (sh *SignUpHandler) PostUser(c echo.Context) error {
 			apierrors.InternalServerError,
 		)
 	}
+
 	sh.logger.Debug("Created user",
 		zap.String("_id", objectID.Hex()),
 	)
 	return c.JSON(http.StatusCreated, common.AuthResponse{
-		ID:        objectID,
-		Email:     ur.Email,
-		FirstName: ur.FirstName,
-		LastName:  ur.LastName,
-		Token:     token,
+		ID:           objectID,
+		Email:        ur.Email,
+		FirstName:    ur.FirstName,
+		LastName:     ur.LastName,
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
+
+// passwordPolicyMessage names the rule of the password policy the password
+// breaks
+func passwordPolicyMessage(err error) apierrors.ErrorMessage {
+	if errors.Is(err, usermodel.ErrPasswordTooShort) {
+		return fmt.Sprintf(apierrors.PasswordTooShortError, config.PasswordMinLength)
+	}
+
+	return fmt.Sprintf(apierrors.PasswordTooFewClassesError, config.PasswordMinCharacterClasses)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenPurpose tells a TokenSender what a token lets its holder do, so it
+// can pick what to write around it
+type TokenPurpose string
+
+const (
+	PasswordResetPurpose TokenPurpose = "password_reset"
+	EmailChangePurpose   TokenPurpose = "email_change"
+)
+
+// TokenMessage is a single-use token on its way to the only address allowed
+// to see it
+type TokenMessage struct {
+	UserID  primitive.ObjectID
+	Email   string
+	Purpose TokenPurpose
+	Token   string
+}
+
+// TokenSender delivers tokens out of band. Tokens are never part of a
+// response or a log line, whoever asked for one has to prove they own the
+// address it was sent to.
+type TokenSender interface {
+	SendToken(ctx context.Context, message TokenMessage) error
+}
+
+// DiscardTokenSender drops every token, it's what handlers use until a
+// sender is configured
+type DiscardTokenSender struct{}
+
+func (DiscardTokenSender) SendToken(context.Context, TokenMessage) error {
+	return nil
+}
@@ -3,10 +3,22 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
 	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	"github.com/Roll-Play/togglelabs/pkg/models"
+	auditmodel "github.com/Roll-Play/togglelabs/pkg/models/audit"
+	emailchangemodel "github.com/Roll-Play/togglelabs/pkg/models/email_change"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	passwordresetmodel "github.com/Roll-Play/togglelabs/pkg/models/password_reset"
+	refreshtokenmodel "github.com/Roll-Play/togglelabs/pkg/models/refresh_token"
+	tokendenylistmodel "github.com/Roll-Play/togglelabs/pkg/models/token_denylist"
 	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
 	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/go-playground/validator/v10"
@@ -15,23 +27,42 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type UserHandler struct {
 	db     *mongo.Database
 	logger *zap.Logger
+	tokens TokenSender
 }
 
 func NewUserHandler(db *mongo.Database, logger *zap.Logger) *UserHandler {
 	return &UserHandler{
 		db:     db,
 		logger: logger,
+		tokens: DiscardTokenSender{},
 	}
 }
 
+// WithTokenSender sets where email change verification tokens are delivered
+func (uh *UserHandler) WithTokenSender(sender TokenSender) *UserHandler {
+	uh.tokens = sender
+	return uh
+}
+
+// UserPatchRequest only changes the names it's given, at least one of them
 type UserPatchRequest struct {
-	FirstName string `json:"first_name" validate:"required"`
-	LastName  string `json:"last_name" validate:"required"`
+	FirstName *string `json:"first_name,omitempty" validate:"required_without=LastName,omitempty,min=1"`
+	LastName  *string `json:"last_name,omitempty" validate:"required_without=FirstName,omitempty,min=1"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type EmailChangeRequest struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type UserPatchResponse struct {
@@ -41,6 +72,16 @@ type UserPatchResponse struct {
 	LastName  string             `json:"last_name,omitempty" `
 }
 
+// UserOrganizationResponse is an organization the user belongs to, with
+// their permission level within it
+type UserOrganizationResponse struct {
+	ID              primitive.ObjectID `json:"_id"`
+	Name            string             `json:"name"`
+	PermissionLevel string             `json:"permission_level"`
+}
+
+type ListUserOrganizationsResponse = common.PaginatedResponse[UserOrganizationResponse]
+
 func (uh *UserHandler) GetUser(c echo.Context) error {
 	userID, err := apiutils.GetUserFromContext(c)
 	if err != nil {
@@ -70,12 +111,22 @@ func (uh *UserHandler) GetUser(c echo.Context) error {
 	model := usermodel.New(uh.db)
 	user, err := model.FindUserOrganization(context.Background(), userID)
 	if err != nil {
-		uh.logger.Debug("Client error",
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			uh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(c,
+				http.StatusNotFound,
+				apierrors.NotFoundError,
+			)
+		}
+
+		uh.logger.Debug("Server error",
 			zap.Error(err),
 		)
 		return apierrors.CustomError(c,
-			http.StatusNotFound,
-			apierrors.NotFoundError,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
 		)
 	}
 
@@ -85,6 +136,57 @@ func (uh *UserHandler) GetUser(c echo.Context) error {
 	)
 }
 
+// ListOrganizations lists the organizations the user is a member of, by
+// name, so they can switch between them
+func (uh *UserHandler) ListOrganizations(c echo.Context) error {
+	pageQuery := c.QueryParam("page")
+	limitQuery := c.QueryParam("page_size")
+
+	page, limit := apiutils.GetPaginationParams(pageQuery, limitQuery)
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(uh.db)
+	organizations, total, err := organizationModel.FindActiveByMember(context.Background(), userID, page, limit)
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	response := make([]UserOrganizationResponse, 0, len(organizations))
+	for _, organization := range organizations {
+		for _, member := range organization.Members {
+			if member.User.ID != userID {
+				continue
+			}
+
+			response = append(response, UserOrganizationResponse{
+				ID:              organization.ID,
+				Name:            organization.Name,
+				PermissionLevel: member.PermissionLevel,
+			})
+			break
+		}
+	}
+
+	return c.JSON(http.StatusOK, common.NewPaginatedResponse(response, page, limit, total))
+}
+
 func (uh *UserHandler) PatchUser(c echo.Context) error {
 	request := new(UserPatchRequest)
 	if err := c.Bind(request); err != nil {
@@ -97,6 +199,13 @@ func (uh *UserHandler) PatchUser(c echo.Context) error {
 		)
 	}
 
+	// A blank name would pass the min check
+	for _, name := range []*string{request.FirstName, request.LastName} {
+		if name != nil {
+			*name = strings.TrimSpace(*name)
+		}
+	}
+
 	validate := validator.New()
 
 	if err := validate.Struct(request); err != nil {
@@ -146,14 +255,17 @@ func (uh *UserHandler) PatchUser(c echo.Context) error {
 		)
 	}
 
-	err = model.UpdateOne(
-		context.Background(),
-		userID,
-		bson.D{
-			{Key: "first_name", Value: request.FirstName},
-			{Key: "last_name", Value: request.LastName},
-		},
-	)
+	update := bson.D{}
+	if request.FirstName != nil {
+		ur.FirstName = *request.FirstName
+		update = append(update, bson.E{Key: "first_name", Value: ur.FirstName})
+	}
+	if request.LastName != nil {
+		ur.LastName = *request.LastName
+		update = append(update, bson.E{Key: "last_name", Value: ur.LastName})
+	}
+
+	err = model.UpdateOne(context.Background(), userID, update)
 
 	if err != nil {
 		uh.logger.Debug("Server error",
@@ -168,7 +280,429 @@ func (uh *UserHandler) PatchUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, UserPatchResponse{
 		ID:        userID,
 		Email:     ur.Email,
-		FirstName: request.FirstName,
-		LastName:  request.LastName,
+		FirstName: ur.FirstName,
+		LastName:  ur.LastName,
+	})
+}
+
+// ChangePassword replaces the password of the logged in user, signing them
+// out of every other session
+func (uh *UserHandler) ChangePassword(c echo.Context) error {
+	request := new(ChangePasswordRequest)
+	if err := c.Bind(request); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(request); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		// Should never happen but better safe than sorry
+		if errors.Is(err, apiutils.ErrNotAuthenticated) {
+			uh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	model := usermodel.New(uh.db)
+	ur, err := model.FindByID(context.Background(), userID)
+	if err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(ur.Password), []byte(request.CurrentPassword)); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusUnauthorized,
+			apierrors.UnauthorizedError,
+		)
+	}
+
+	if !usermodel.IsStrongPassword(request.NewPassword) {
+		uh.logger.Debug("Client error",
+			zap.String("cause", apierrors.WeakPasswordError),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusBadRequest,
+			apierrors.WeakPasswordError,
+		)
+	}
+
+	if err := model.UpdatePassword(context.Background(), userID, request.NewPassword); err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	refreshTokenModel := refreshtokenmodel.New(uh.db)
+	if err := refreshTokenModel.RevokeAllForUser(context.Background(), userID); err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	uh.logger.Info("Password changed",
+		zap.String("_id", userID.Hex()),
+	)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteUser soft deletes the logged in user, removing them from their
+// organizations and revoking their sessions
+func (uh *UserHandler) DeleteUser(c echo.Context) error {
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		if errors.Is(err, apiutils.ErrNotAuthenticated) {
+			uh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	model := usermodel.New(uh.db)
+	if _, err := model.FindByID(context.Background(), userID); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	organizationModel := organizationmodel.New(uh.db)
+	organizations, err := organizationModel.FindByMember(context.Background(), userID)
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// Leaving would lock the other members out of administering the
+	// organization
+	for _, organization := range organizations {
+		member := organization.FindMember(userID)
+		// The owner has to hand the organization over before leaving it
+		if member != nil &&
+			member.PermissionLevel == organizationmodel.Owner &&
+			len(organization.Members) > 1 {
+			uh.logger.Debug("Client error",
+				zap.String("cause", apierrors.OwnerChangeError),
+				zap.String("organization_id", organization.ID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.OwnerChangeError,
+			)
+		}
+
+		if member != nil &&
+			member.PermissionLevel == organizationmodel.Admin &&
+			organization.AdminCount() == 1 &&
+			len(organization.Members) > 1 {
+			uh.logger.Debug("Client error",
+				zap.String("cause", apierrors.LastAdminError),
+				zap.String("organization_id", organization.ID.Hex()),
+			)
+			return apierrors.CustomError(c,
+				http.StatusConflict,
+				apierrors.LastAdminError,
+			)
+		}
+	}
+
+	// The user is only gone once they are out of every organization and
+	// their sessions are revoked
+	err = models.WithTransaction(context.Background(), uh.db, func(ctx context.Context) error {
+		if err := model.SoftDelete(ctx, userID); err != nil {
+			return err
+		}
+
+		auditModel := auditmodel.New(uh.db)
+		for _, organization := range organizations {
+			member := organization.FindMember(userID)
+			if member == nil {
+				continue
+			}
+
+			err := organizationModel.UpdateOne(
+				ctx,
+				bson.D{{Key: "_id", Value: organization.ID}},
+				bson.D{{Key: "$pull", Value: bson.M{"members": bson.M{"user._id": userID}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			auditEntry := auditmodel.NewAuditEntry(
+				userID,
+				fmt.Sprintf(auditmodel.MemberRemoved, member.User.Email),
+				map[string]interface{}{
+					auditmodel.MemberIDMetadataKey:           userID.Hex(),
+					auditmodel.OldPermissionLevelMetadataKey: member.PermissionLevel,
+				},
+			)
+			if err := auditModel.UpdateOne(ctx, organization.ID, auditEntry); err != nil {
+				return err
+			}
+		}
+
+		if err := refreshtokenmodel.New(uh.db).RevokeAllForUser(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := passwordresetmodel.New(uh.db).RevokeAllForUser(ctx, userID); err != nil {
+			return err
+		}
+
+		if tokenID, expiresAt, err := apiutils.GetTokenFromContext(c); err == nil {
+			record := tokendenylistmodel.NewTokenDenylistRecord(tokenID, expiresAt)
+			if _, err := tokendenylistmodel.New(uh.db).InsertOne(ctx, record); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	uh.logger.Info("User deleted",
+		zap.String("_id", userID.Hex()),
+	)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PatchEmail starts changing the email of the logged in user. The new email
+// is kept as pending until it is confirmed at /auth/verify-email-change.
+func (uh *UserHandler) PatchEmail(c echo.Context) error {
+	request := new(EmailChangeRequest)
+	if err := c.Bind(request); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+	request.Email = usermodel.NormalizeEmail(request.Email)
+
+	validate := validator.New()
+
+	if err := validate.Struct(request); err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	userID, err := apiutils.GetUserFromContext(c)
+	if err != nil {
+		if errors.Is(err, apiutils.ErrNotAuthenticated) {
+			uh.logger.Debug("Client error",
+				zap.Error(err),
+			)
+			return apierrors.CustomError(
+				c,
+				http.StatusUnauthorized,
+				apierrors.UnauthorizedError,
+			)
+		}
+
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(
+			c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	model := usermodel.New(uh.db)
+	ur, err := model.FindByID(context.Background(), userID)
+	if err != nil {
+		uh.logger.Debug("Client error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusNotFound,
+			apierrors.NotFoundError,
+		)
+	}
+
+	if ur.Email == request.Email {
+		uh.logger.Debug("Client error",
+			zap.String("cause", "email unchanged"),
+		)
+		return apierrors.CustomError(c,
+			http.StatusBadRequest,
+			apierrors.BadRequestError,
+		)
+	}
+
+	inUse, err := model.EmailInUse(context.Background(), request.Email)
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if inUse {
+		uh.logger.Debug("Client error",
+			zap.String("cause", apierrors.EmailConflictError),
+		)
+		return apierrors.CustomError(c,
+			http.StatusConflict,
+			apierrors.EmailConflictError,
+		)
+	}
+
+	// Only the latest requested email can be confirmed
+	emailChangeModel := emailchangemodel.New(uh.db)
+	if err := emailChangeModel.RevokeAllForUser(context.Background(), userID); err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	record, token, err := emailchangemodel.NewEmailChangeRecord(
+		userID,
+		request.Email,
+		config.EmailChangeExpireTime*time.Millisecond,
+	)
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	if _, err := emailChangeModel.InsertOne(context.Background(), record); err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	err = model.UpdateOne(context.Background(), userID, bson.D{{Key: "pending_email", Value: request.Email}})
+	if err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	// The token goes to the new address, only its owner can confirm it
+	if err := uh.tokens.SendToken(context.Background(), TokenMessage{
+		UserID:  userID,
+		Email:   request.Email,
+		Purpose: EmailChangePurpose,
+		Token:   token,
+	}); err != nil {
+		uh.logger.Debug("Server error",
+			zap.Error(err),
+		)
+		return apierrors.CustomError(c,
+			http.StatusInternalServerError,
+			apierrors.InternalServerError,
+		)
+	}
+
+	return c.NoContent(http.StatusAccepted)
 }
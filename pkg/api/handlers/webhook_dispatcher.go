@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	webhookmodel "github.com/Roll-Play/togglelabs/pkg/models/webhook"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	WebhookSignatureHeader = "X-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+)
+
+var errInvalidWebhookURL = errors.New("invalid webhook url")
+var errWebhookAddressNotAllowed = errors.New("webhook address not allowed")
+
+type WebhookPayload struct {
+	ID             primitive.ObjectID     `json:"_id"`
+	Event          webhookmodel.EventType `json:"event"`
+	OrganizationID primitive.ObjectID     `json:"organization_id"`
+	FeatureFlagID  primitive.ObjectID     `json:"feature_flag_id"`
+	UserID         primitive.ObjectID     `json:"user_id"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// WebhookDispatcher delivers events to the webhooks subscribed to them. The
+// delivery happens in the background so handlers don't wait on third party
+// servers.
+type WebhookDispatcher struct {
+	webhooks    WebhookRepository
+	logger      *zap.Logger
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewWebhookDispatcher(db *mongo.Database, logger *zap.Logger) *WebhookDispatcher {
+	return newWebhookDispatcher(webhookmodel.New(db), logger)
+}
+
+func newWebhookDispatcher(webhooks WebhookRepository, logger *zap.Logger) *WebhookDispatcher {
+	// The address is checked as the connection is made, after the host was
+	// resolved, so a host can't resolve to a public address when the webhook
+	// is registered and to a private one when it's delivered. Redirects go
+	// through the same check. Proxies are skipped, the check would only
+	// ever see the proxy address.
+	dialer := &net.Dialer{
+		Timeout: config.WebhookTimeout * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !webhookAddressAllowed(ip) {
+				return errWebhookAddressNotAllowed
+			}
+
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &WebhookDispatcher{
+		webhooks: webhooks,
+		logger:   logger,
+		client: &http.Client{
+			Timeout:   config.WebhookTimeout * time.Second,
+			Transport: transport,
+		},
+		maxAttempts: config.WebhookMaxAttempts,
+		backoff:     config.WebhookRetryBackoff * time.Second,
+	}
+}
+
+// webhookAddressAllowed reports whether webhooks may be delivered to the
+// address. Private, loopback and link local addresses are off limits
+// unless config.WebhookAllowPrivateAddresses is set.
+func webhookAddressAllowed(ip net.IP) bool {
+	if config.WebhookAllowPrivateAddresses {
+		return true
+	}
+
+	return !ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// checkWebhookURL validates the URL of a webhook being registered, it has
+// to be http or https and its host can't resolve to an address webhooks
+// aren't allowed to reach. Hosts that don't resolve are let through since
+// every delivery checks the address it connects to again.
+func checkWebhookURL(ctx context.Context, target *url.URL) error {
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errInvalidWebhookURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.WebhookTimeout*time.Second)
+	defer cancel()
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return nil
+	}
+
+	for _, address := range addresses {
+		if !webhookAddressAllowed(address.IP) {
+			return errWebhookAddressNotAllowed
+		}
+	}
+
+	return nil
+}
+
+// Dispatch sends the event of the feature flag to every webhook of the
+// organization subscribed to it
+func (wd *WebhookDispatcher) Dispatch(
+	event webhookmodel.EventType,
+	organizationID,
+	featureFlagID,
+	userID primitive.ObjectID,
+	data map[string]interface{},
+) {
+	payload := WebhookPayload{
+		ID:             primitive.NewObjectID(),
+		Event:          event,
+		OrganizationID: organizationID,
+		FeatureFlagID:  featureFlagID,
+		UserID:         userID,
+		Data:           data,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	go func() {
+		webhooks, err := wd.webhooks.FindByEvent(context.Background(), organizationID, event)
+		if err != nil {
+			wd.logger.Error("Failed to find webhooks",
+				zap.Error(err),
+				zap.String("event", event),
+			)
+			return
+		}
+
+		if len(webhooks) == 0 {
+			return
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			wd.logger.Error("Failed to encode webhook payload",
+				zap.Error(err),
+			)
+			return
+		}
+
+		for index := range webhooks {
+			go wd.deliver(&webhooks[index], event, body)
+		}
+	}()
+}
+
+// deliver posts the payload until it is accepted or the attempts run out.
+// Client errors other than rate limiting aren't retried since they'd only
+// fail again.
+func (wd *WebhookDispatcher) deliver(webhook *webhookmodel.WebhookRecord, event webhookmodel.EventType, body []byte) {
+	backoff := wd.backoff
+	for attempt := 1; attempt <= wd.maxAttempts; attempt++ {
+		status, err := wd.post(webhook, event, body)
+		if err == nil && status >= 200 && status < 300 {
+			return
+		}
+
+		wd.logger.Debug("Webhook delivery failed",
+			zap.Error(err),
+			zap.Int("status", status),
+			zap.Int("attempt", attempt),
+			zap.String("webhook_id", webhook.ID.Hex()),
+		)
+
+		if err == nil && status < 500 && status != http.StatusTooManyRequests {
+			break
+		}
+
+		if errors.Is(err, errWebhookAddressNotAllowed) {
+			break
+		}
+
+		if attempt < wd.maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	wd.logger.Info("Webhook delivery abandoned",
+		zap.String("webhook_id", webhook.ID.Hex()),
+		zap.String("event", event),
+	)
+}
+
+func (wd *WebhookDispatcher) post(
+	webhook *webhookmodel.WebhookRecord,
+	event webhookmodel.EventType,
+	body []byte,
+) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	request.Header.Set(WebhookEventHeader, event)
+	request.Header.Set(WebhookSignatureHeader, fmt.Sprintf("sha256=%s", webhook.Sign(body)))
+
+	response, err := wd.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	return response.StatusCode, nil
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/logger"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const APIKeyScheme = "Api-Key "
+
+// APIKeyMiddleware lets SDKs authenticate with an organization API key, in
+// which case the organization comes from the key itself. Requests carrying
+// a user token go through the usual auth and organization middlewares.
+func APIKeyMiddleware(db *mongo.Database) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		userNext := AuthMiddleware(db)(OrganizationMiddleware(next))
+
+		return func(c echo.Context) error {
+			logger, _ := logger.GetInstance()
+
+			authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+			if !strings.HasPrefix(authHeader, APIKeyScheme) {
+				return userNext(c)
+			}
+
+			key := strings.TrimSpace(strings.TrimPrefix(authHeader, APIKeyScheme))
+			model := organizationmodel.New(db)
+			organization, err := model.FindByAPIKeyHash(context.Background(), organizationmodel.HashAPIKey(key))
+			if err != nil {
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					logger.Debug("Client error",
+						zap.Error(err))
+					return apierrors.CustomError(
+						c,
+						http.StatusUnauthorized,
+						apierrors.UnauthorizedError,
+					)
+				}
+				logger.Debug("Server error",
+					zap.Error(err))
+				return apierrors.CustomError(
+					c,
+					http.StatusInternalServerError,
+					apierrors.InternalServerError,
+				)
+			}
+
+			c.Set("organization", organization.ID.Hex())
+			c.Set(apiutils.APIKeyContextKey, true)
+			return next(c)
+		}
+	}
+}
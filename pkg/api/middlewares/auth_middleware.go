@@ -1,78 +1,129 @@
 package middlewares
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
 	"github.com/Roll-Play/togglelabs/pkg/logger"
+	tokendenylistmodel "github.com/Roll-Play/togglelabs/pkg/models/token_denylist"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
 	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
 var ErrMissingAuthHeader = errors.New("missing authorization header")
-var ErrInvalidSignMethod = errors.New("invalid signing method")
 var ErrInvalidToken = errors.New("invalid token")
+var ErrRevokedToken = errors.New("revoked token")
+var ErrDeletedUser = errors.New("deleted user")
 
-func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		logger, _ := logger.GetInstance()
-		authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+// AuthMiddleware authenticates requests carrying a JWT, rejecting tokens
+// that were revoked on logout and tokens of users that were deleted
+func AuthMiddleware(db *mongo.Database) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			logger, _ := logger.GetInstance()
+			authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
 
-		if authHeader == "" {
-			logger.Debug("Client error",
-				zap.Error(errors.New("missing Authorization header")))
-			return c.JSON(http.StatusUnauthorized, apierrors.Error{
-				Error:   "missing Authorization header",
-				Message: http.StatusText(http.StatusUnauthorized),
-			})
-		}
-
-		tokenString := strings.TrimSpace(strings.Replace(authHeader, "Bearer ", "", 1))
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			secretKey := []byte("your-secret-key")
-
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			if authHeader == "" {
 				logger.Debug("Client error",
-					zap.Error(ErrInvalidSignMethod))
-				return nil, ErrInvalidSignMethod
+					zap.Error(errors.New("missing Authorization header")))
+				return c.JSON(http.StatusUnauthorized, apierrors.Error{
+					Error:   "missing Authorization header",
+					Message: http.StatusText(http.StatusUnauthorized),
+				})
 			}
-			return secretKey, nil
-		})
-
-		if err != nil {
-			logger.Debug("Client error",
-				zap.Error(err))
-			return c.JSON(http.StatusUnauthorized, apierrors.Error{
-				Error:   ErrInvalidToken.Error(),
-				Message: http.StatusText(http.StatusUnauthorized),
-			})
-		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			sub, _ := claims["sub"].(string)
-			userID, err := primitive.ObjectIDFromHex(sub)
+			tokenString := strings.TrimSpace(strings.Replace(authHeader, "Bearer ", "", 1))
+			token, err := apiutils.ParseJWT(tokenString)
 			if err != nil {
-				log.Println(apiutils.HandlerErrorLogMessage(err, c))
+				logger.Debug("Client error",
+					zap.Error(err))
 				return c.JSON(http.StatusUnauthorized, apierrors.Error{
-					Error:   "invalid token sub",
+					Error:   ErrInvalidToken.Error(),
 					Message: http.StatusText(http.StatusUnauthorized),
 				})
 			}
 
-			c.Set("user", userID.Hex())
-			return next(c)
-		}
+			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+				sub, _ := claims["sub"].(string)
+				userID, err := primitive.ObjectIDFromHex(sub)
+				if err != nil {
+					log.Println(apiutils.HandlerErrorLogMessage(err, c))
+					return c.JSON(http.StatusUnauthorized, apierrors.Error{
+						Error:   "invalid token sub",
+						Message: http.StatusText(http.StatusUnauthorized),
+					})
+				}
+
+				tokenID, _ := claims["jti"].(string)
+				if tokenID != "" {
+					denied, err := tokendenylistmodel.New(db).IsDenied(context.Background(), tokenID)
+					if err != nil {
+						logger.Debug("Server error",
+							zap.Error(err))
+						return apierrors.CustomError(
+							c,
+							http.StatusInternalServerError,
+							apierrors.InternalServerError,
+						)
+					}
+
+					if denied {
+						logger.Debug("Client error",
+							zap.Error(ErrRevokedToken))
+						return c.JSON(http.StatusUnauthorized, apierrors.Error{
+							Error:   ErrRevokedToken.Error(),
+							Message: http.StatusText(http.StatusUnauthorized),
+						})
+					}
+
+					c.Set(apiutils.TokenIDContextKey, tokenID)
+				}
+
+				// Deleting an account only revokes the token it was deleted
+				// with, the other tokens of the user are turned away here
+				deleted, err := usermodel.New(db).IsDeleted(context.Background(), userID)
+				if err != nil {
+					logger.Debug("Server error",
+						zap.Error(err))
+					return apierrors.CustomError(
+						c,
+						http.StatusInternalServerError,
+						apierrors.InternalServerError,
+					)
+				}
+
+				if deleted {
+					logger.Debug("Client error",
+						zap.Error(ErrDeletedUser))
+					return c.JSON(http.StatusUnauthorized, apierrors.Error{
+						Error:   ErrRevokedToken.Error(),
+						Message: http.StatusText(http.StatusUnauthorized),
+					})
+				}
+
+				if exp, ok := claims["exp"].(float64); ok {
+					c.Set(apiutils.TokenExpiresAtContextKey, time.Unix(int64(exp), 0).UTC())
+				}
 
-		log.Println(apiutils.HandlerErrorLogMessage(ErrInvalidToken, c))
-		return c.JSON(http.StatusUnauthorized, apierrors.Error{
-			Error:   ErrInvalidToken.Error(),
-			Message: http.StatusText(http.StatusUnauthorized),
-		})
+				c.Set("user", userID.Hex())
+				return next(c)
+			}
+
+			log.Println(apiutils.HandlerErrorLogMessage(ErrInvalidToken, c))
+			return c.JSON(http.StatusUnauthorized, apierrors.Error{
+				Error:   ErrInvalidToken.Error(),
+				Message: http.StatusText(http.StatusUnauthorized),
+			})
+		}
 	}
 }
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// CORSMaxAge is how long, in seconds, browsers may cache a preflight
+const CORSMaxAge = 600
+
+// CORSMiddleware lets dashboards served from the allowed origins call the
+// API with credentials. Origins are matched exactly, so without any allowed
+// origin every cross origin request is refused.
+func CORSMiddleware(allowedOrigins []string) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return allowed[origin], nil
+		},
+		AllowMethods: []string{
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodPut,
+			http.MethodDelete,
+		},
+		AllowHeaders: []string{
+			echo.HeaderAuthorization,
+			echo.HeaderContentType,
+			XOrganizationHeader,
+			"If-Match",
+			apiutils.HeaderIfNoneMatch,
+			"Idempotency-Key",
+			echo.HeaderXRequestID,
+		},
+		ExposeHeaders:    []string{apiutils.HeaderETag, echo.HeaderXRequestID},
+		AllowCredentials: true,
+		MaxAge:           CORSMaxAge,
+	})
+}
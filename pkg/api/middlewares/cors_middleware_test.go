@@ -0,0 +1,74 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const dashboardOrigin = "https://dashboard.togglelabs.dev"
+
+func newCORSServer(allowedOrigins []string) *echo.Echo {
+	e := echo.New()
+	e.Use(middlewares.CORSMiddleware(allowedOrigins))
+	e.GET("/features", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	return e
+}
+
+func serveCORS(e *echo.Echo, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/features", nil)
+	request.Header.Set(echo.HeaderOrigin, origin)
+	if preflight {
+		request.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		request.Header.Set(echo.HeaderAccessControlRequestHeaders, "authorization,x-organization")
+	}
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	e := newCORSServer([]string{dashboardOrigin})
+
+	recorder := serveCORS(e, http.MethodGet, dashboardOrigin, false)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, dashboardOrigin, recorder.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", recorder.Header().Get(echo.HeaderAccessControlAllowCredentials))
+
+	recorder = serveCORS(e, http.MethodOptions, dashboardOrigin, true)
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, dashboardOrigin, recorder.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", recorder.Header().Get(echo.HeaderAccessControlAllowCredentials))
+	assert.Contains(t, recorder.Header().Get(echo.HeaderAccessControlAllowHeaders), echo.HeaderAuthorization)
+	assert.Contains(t, recorder.Header().Get(echo.HeaderAccessControlAllowHeaders), middlewares.XOrganizationHeader)
+	assert.Contains(t, recorder.Header().Get(echo.HeaderAccessControlAllowMethods), http.MethodPatch)
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	e := newCORSServer([]string{dashboardOrigin})
+
+	for _, origin := range []string{"https://evil.example.com", "https://sub.dashboard.togglelabs.dev"} {
+		recorder := serveCORS(e, http.MethodGet, origin, false)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderAccessControlAllowCredentials), origin)
+
+		recorder = serveCORS(e, http.MethodOptions, origin, true)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderAccessControlAllowOrigin), origin)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderAccessControlAllowHeaders), origin)
+	}
+}
+
+func TestCORSRestrictiveByDefault(t *testing.T) {
+	e := newCORSServer(nil)
+
+	recorder := serveCORS(e, http.MethodOptions, dashboardOrigin, true)
+	assert.Empty(t, recorder.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
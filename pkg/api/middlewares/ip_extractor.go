@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor tells clients apart for c.RealIP, which the rate limiter keys
+// on. Forwarding headers are only believed when the request comes from one
+// of trustedProxies, anyone else could set them to get a fresh bucket on
+// every request.
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipRange := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// RateLimiter is an in-memory token bucket per key. Every bucket holds up to
+// limit tokens and is refilled at limit tokens per interval.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	limit     float64
+	interval  time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(limit int, interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		limit:     float64(limit),
+		interval:  interval,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (rl *RateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.lastRefill)
+	bucket.tokens = math.Min(rl.limit, bucket.tokens+rl.limit*float64(elapsed)/float64(rl.interval))
+	bucket.lastRefill = now
+}
+
+// sweep forgets buckets that are full again, they behave the same as a new one
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.interval {
+		return
+	}
+
+	for key, bucket := range rl.buckets {
+		rl.refill(bucket, now)
+		if bucket.tokens >= rl.limit {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false along with how long until the next token is available.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.limit, lastRefill: now}
+		rl.buckets[key] = bucket
+	}
+	rl.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		missing := 1 - bucket.tokens
+		return false, time.Duration(missing * float64(rl.interval) / rl.limit)
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// RateLimitMiddleware rejects clients, identified by their IP, that exhaust
+// their bucket on limiter
+func RateLimitMiddleware(limiter *RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			logger, _ := logger.GetInstance()
+
+			allowed, retryAfter := limiter.Allow(c.RealIP())
+			if !allowed {
+				logger.Debug("Client error",
+					zap.String("cause", apierrors.TooManyRequestsError),
+					zap.String("ip", c.RealIP()),
+				)
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(seconds))
+				return apierrors.CustomError(
+					c,
+					http.StatusTooManyRequests,
+					apierrors.TooManyRequestsError,
+				)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package middlewares_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func rateLimitedServer(limit int, trustedProxies ...*net.IPNet) *echo.Echo {
+	server := echo.New()
+	server.IPExtractor = middlewares.IPExtractor(trustedProxies)
+	server.POST("/signin", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, middlewares.RateLimitMiddleware(middlewares.NewRateLimiter(limit, time.Minute)))
+
+	return server
+}
+
+func post(server *echo.Echo, ip string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/signin", nil)
+	request.RemoteAddr = net.JoinHostPort(ip, "54321")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+
+	server.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func TestRateLimitMiddlewareRejectsPastLimit(t *testing.T) {
+	server := rateLimitedServer(10)
+
+	for i := 0; i < 10; i++ {
+		recorder := post(server, "10.0.0.1")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	recorder := post(server, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+
+	retryAfter, err := strconv.Atoi(recorder.Header().Get(echo.HeaderRetryAfter))
+	assert.NoError(t, err)
+	assert.Greater(t, retryAfter, 0)
+	assert.LessOrEqual(t, retryAfter, 6)
+}
+
+func TestRateLimitMiddlewareIsPerClient(t *testing.T) {
+	server := rateLimitedServer(1)
+
+	assert.Equal(t, http.StatusOK, post(server, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, post(server, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, post(server, "10.0.0.2").Code)
+}
+
+func TestRateLimitMiddlewareIgnoresSpoofedHeaders(t *testing.T) {
+	server := rateLimitedServer(1)
+
+	assert.Equal(t, http.StatusOK, post(server, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests,
+		post(server, "10.0.0.1", echo.HeaderXForwardedFor, "203.0.113.7").Code)
+	assert.Equal(t, http.StatusTooManyRequests,
+		post(server, "10.0.0.1", echo.HeaderXRealIP, "203.0.113.8").Code)
+}
+
+func TestRateLimitMiddlewareTrustsConfiguredProxies(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	server := rateLimitedServer(1, proxies)
+
+	assert.Equal(t, http.StatusOK, post(server, "10.0.0.1", echo.HeaderXForwardedFor, "203.0.113.7").Code)
+	assert.Equal(t, http.StatusTooManyRequests,
+		post(server, "10.0.0.2", echo.HeaderXForwardedFor, "203.0.113.7").Code)
+	assert.Equal(t, http.StatusOK, post(server, "10.0.0.1", echo.HeaderXForwardedFor, "203.0.113.8").Code)
+
+	// Anyone else forwarding is one client, whatever they claim
+	assert.Equal(t, http.StatusOK, post(server, "192.0.2.1", echo.HeaderXForwardedFor, "203.0.113.9").Code)
+	assert.Equal(t, http.StatusTooManyRequests,
+		post(server, "192.0.2.1", echo.HeaderXForwardedFor, "203.0.113.10").Code)
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxRequestIDLength bounds the X-Request-ID taken from clients
+const MaxRequestIDLength = 128
+
+// RequestIDMiddleware keeps the X-Request-ID a client or proxy sent, or
+// makes one up, and hands it back in the response so a failing request
+// can be looked up in the logs
+func RequestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request := c.Request()
+		requestID := request.Header.Get(echo.HeaderXRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+			request.Header.Set(echo.HeaderXRequestID, requestID)
+		}
+
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		c.Set(apiutils.RequestIDContextKey, requestID)
+
+		return next(c)
+	}
+}
+
+// validRequestID refuses IDs that could forge or split log lines
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > MaxRequestIDLength {
+		return false
+	}
+
+	for index := 0; index < len(requestID); index++ {
+		if requestID[index] < '!' || requestID[index] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return primitive.NewObjectID().Hex()
+	}
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,55 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveRequestID(requestID string) (*httptest.ResponseRecorder, string) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestID != "" {
+		request.Header.Set(echo.HeaderXRequestID, requestID)
+	}
+	recorder := httptest.NewRecorder()
+	c := echo.New().NewContext(request, recorder)
+
+	var contextID string
+	_ = middlewares.RequestIDMiddleware(func(c echo.Context) error {
+		contextID = apiutils.GetRequestIDFromContext(c)
+		return c.NoContent(http.StatusOK)
+	})(c)
+
+	return recorder, contextID
+}
+
+func TestRequestIDRoundTrips(t *testing.T) {
+	recorder, contextID := serveRequestID("incident-4242")
+
+	assert.Equal(t, "incident-4242", recorder.Header().Get(echo.HeaderXRequestID))
+	assert.Equal(t, "incident-4242", contextID)
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	recorder, contextID := serveRequestID("")
+	otherRecorder, _ := serveRequestID("")
+
+	assert.Len(t, contextID, 32)
+	assert.Equal(t, contextID, recorder.Header().Get(echo.HeaderXRequestID))
+	assert.NotEqual(t, contextID, otherRecorder.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestRequestIDReplacedWhenInvalid(t *testing.T) {
+	for _, requestID := range []string{"forged\nline", "has spaces", string(make([]byte, middlewares.MaxRequestIDLength+1))} {
+		recorder, contextID := serveRequestID(requestID)
+
+		assert.NotEqual(t, requestID, contextID)
+		assert.Len(t, contextID, 32)
+		assert.Equal(t, contextID, recorder.Header().Get(echo.HeaderXRequestID))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -22,13 +23,13 @@ func ZapLogger(log *zap.Logger) echo.MiddlewareFunc {
 			request := c.Request()
 			response := c.Response()
 
-			id := request.Header.Get(echo.HeaderXRequestID)
+			id := apiutils.GetRequestIDFromContext(c)
 			if id == "" {
-				id = response.Header().Get(echo.HeaderXRequestID)
+				id = request.Header.Get(echo.HeaderXRequestID)
 			}
 
 			fields := []zapcore.Field{
-				zap.String("id", id),
+				zap.String(apiutils.RequestIDLogField, id),
 				zap.String("remote_ip", c.RealIP()),
 				zap.String("host", request.Host),
 				zap.String("method", request.Method),
@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+var (
+	document     *Document
+	documentOnce sync.Once
+)
+
+// SpecHandler serves the OpenAPI document, which is only built on the first
+// request since the routes can't change while the API runs
+func SpecHandler(c echo.Context) error {
+	documentOnce.Do(func() {
+		document = NewDocument()
+	})
+
+	return c.JSON(http.StatusOK, document)
+}
+
+// DocsHandler serves Swagger UI pointed at the OpenAPI document. The UI
+// itself is loaded from a CDN rather than bundled with the API.
+func DocsHandler(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>` + Title + `</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "` + SpecPath + `", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
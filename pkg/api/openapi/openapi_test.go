@@ -0,0 +1,129 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/openapi"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveSpec(t *testing.T) map[string]interface{} {
+	e := echo.New()
+	request := httptest.NewRequest(http.MethodGet, openapi.SpecPath, nil)
+	recorder := httptest.NewRecorder()
+	c := e.NewContext(request, recorder)
+
+	require.NoError(t, openapi.SpecHandler(c))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var spec map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &spec))
+
+	return spec
+}
+
+func schema(t *testing.T, spec map[string]interface{}, name string) map[string]interface{} {
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	require.Contains(t, schemas, name)
+
+	return schemas[name].(map[string]interface{})
+}
+
+func TestSpecListsKnownPaths(t *testing.T) {
+	spec := serveSpec(t)
+
+	assert.True(t, strings.HasPrefix(spec["openapi"].(string), "3.0."))
+
+	paths := spec["paths"].(map[string]interface{})
+	expected := map[string][]string{
+		"/features":                 {"get", "post"},
+		"/features/{featureFlagID}": {"get", "patch", "delete"},
+		"/features/{featureFlagID}/revisions/{revisionID}": {"patch"},
+		"/features/{featureFlagID}/evaluate":               {"post"},
+		"/user":                                            {"get", "patch", "delete"},
+		"/organizations":                                   {"get", "post"},
+		"/organizations/members/{userID}":                  {"patch", "delete"},
+		"/signin":                                          {"post"},
+	}
+	for path, methods := range expected {
+		require.Contains(t, paths, path)
+		for _, method := range methods {
+			assert.Contains(t, paths[path], method, path)
+		}
+	}
+
+	for path := range paths {
+		assert.NotContains(t, path, ":", "path %s keeps an echo parameter", path)
+	}
+}
+
+func TestSpecReflectsPostFeatureFlagRequest(t *testing.T) {
+	spec := serveSpec(t)
+
+	post := spec["paths"].(map[string]interface{})["/features"].(map[string]interface{})["post"].(map[string]interface{})
+	body := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"]
+	assert.Equal(t,
+		"#/components/schemas/PostFeatureFlagRequest",
+		body.(map[string]interface{})["schema"].(map[string]interface{})["$ref"],
+	)
+
+	request := schema(t, spec, "PostFeatureFlagRequest")
+	assert.ElementsMatch(t, []interface{}{"name", "default_value", "type"}, request["required"])
+
+	properties := request["properties"].(map[string]interface{})
+	flagType := properties["type"].(map[string]interface{})
+	assert.Equal(t, "string", flagType["type"])
+	assert.Equal(t, []interface{}{"boolean", "json", "string", "number"}, flagType["enum"])
+	assert.Equal(t, float64(1000), properties["description"].(map[string]interface{})["maxLength"])
+
+	rules := properties["rules"].(map[string]interface{})
+	assert.Equal(t, "array", rules["type"])
+	assert.Equal(t, "#/components/schemas/Rule", rules["items"].(map[string]interface{})["$ref"])
+}
+
+func TestSpecReflectsListFeatureFlagResponse(t *testing.T) {
+	spec := serveSpec(t)
+
+	list := spec["paths"].(map[string]interface{})["/features"].(map[string]interface{})["get"].(map[string]interface{})
+	ok := list["responses"].(map[string]interface{})["200"].(map[string]interface{})
+	content := ok["content"].(map[string]interface{})["application/json"].(map[string]interface{})
+	assert.Equal(t,
+		"#/components/schemas/PaginatedResponseFeatureFlagResponse",
+		content["schema"].(map[string]interface{})["$ref"],
+	)
+
+	response := schema(t, spec, "PaginatedResponseFeatureFlagResponse")
+	properties := response["properties"].(map[string]interface{})
+	for _, name := range []string{"page", "page_size", "total", "total_pages", "has_next", "data"} {
+		assert.Contains(t, properties, name)
+	}
+	data := properties["data"].(map[string]interface{})
+	assert.Equal(t, "#/components/schemas/FeatureFlagResponse", data["items"].(map[string]interface{})["$ref"])
+
+	record := schema(t, spec, "FeatureFlagResponse")
+	recordProperties := record["properties"].(map[string]interface{})
+	// The record and its Timestamps are embedded, so their fields sit on the
+	// response itself
+	assert.Contains(t, recordProperties, "created_at")
+	assert.Contains(t, recordProperties, "updated_by")
+	assert.Equal(t, "date-time", recordProperties["created_at"].(map[string]interface{})["format"])
+	assert.Equal(t, "string", recordProperties["_id"].(map[string]interface{})["type"])
+}
+
+func TestDocsServesSwaggerUI(t *testing.T) {
+	e := echo.New()
+	request := httptest.NewRequest(http.MethodGet, openapi.DocsPath, nil)
+	recorder := httptest.NewRecorder()
+	c := e.NewContext(request, recorder)
+
+	require.NoError(t, openapi.DocsHandler(c))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "SwaggerUIBundle")
+	assert.Contains(t, recorder.Body.String(), openapi.SpecPath)
+}
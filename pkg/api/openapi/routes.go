@@ -0,0 +1,451 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/common"
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	"github.com/labstack/echo/v4"
+)
+
+type authKind int
+
+const (
+	// public routes take no credentials
+	public authKind = iota
+	// userAuth takes the bearer token of a signed in user
+	userAuth
+	// organizationAuth also takes the organization in the X-organization
+	// header
+	organizationAuth
+	// sdkAuth takes either an API key or what organizationAuth does
+	sdkAuth
+)
+
+// route describes an operation registered in api.registerRoutes. The table
+// is kept by hand next to the handlers it describes, a route missing from it
+// is simply left out of the document.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	tag         string
+	summary     string
+	auth        authKind
+	query       []string
+	request     interface{}
+	status      int
+	response    interface{}
+	// mediaType of the response, JSON when empty
+	mediaType string
+}
+
+var routes = []route{
+	{
+		method: http.MethodGet, path: "/healthz", operationID: "Health", tag: "health",
+		summary: "Report the API is alive",
+		status:  http.StatusOK, response: handlers.HealthResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/readyz", operationID: "Readiness", tag: "health",
+		summary: "Report whether the API's dependencies are reachable, 503 when one is down",
+		status:  http.StatusOK, response: handlers.ReadinessResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/healthz/cache", operationID: "EvaluationCacheStats", tag: "health",
+		summary: "Report the evaluation cache hits and misses, admins only", auth: organizationAuth,
+		status: http.StatusOK, response: cache.Stats{},
+	},
+
+	{
+		method: http.MethodPost, path: "/signup", operationID: "SignUp", tag: "auth",
+		summary: "Create a user",
+		request: handlers.SignUpRequest{}, status: http.StatusCreated, response: common.AuthResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/signin", operationID: "SignIn", tag: "auth",
+		summary: "Sign in with email and password",
+		request: handlers.SignInRequest{}, status: http.StatusOK, response: common.AuthResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/oauth", operationID: "OAuthSignIn", tag: "auth",
+		summary: "Redirect to the OAuth provider",
+		status:  http.StatusTemporaryRedirect,
+	},
+	{
+		method: http.MethodGet, path: "/callback", operationID: "OAuthCallback", tag: "auth",
+		summary: "Sign in with the code issued by the OAuth provider",
+		query:   []string{"code", "state"}, status: http.StatusOK, response: common.AuthResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/auth/refresh", operationID: "RefreshToken", tag: "auth",
+		summary: "Exchange a refresh token for a new token",
+		request: handlers.RefreshRequest{}, status: http.StatusOK, response: common.AuthResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/auth/forgot-password", operationID: "ForgotPassword", tag: "auth",
+		summary: "Send a password reset token",
+		request: handlers.ForgotPasswordRequest{}, status: http.StatusAccepted,
+	},
+	{
+		method: http.MethodPost, path: "/auth/reset-password", operationID: "ResetPassword", tag: "auth",
+		summary: "Reset a password with a reset token",
+		request: handlers.ResetPasswordRequest{}, status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/auth/logout", operationID: "Logout", tag: "auth",
+		summary: "Revoke a refresh token", auth: userAuth,
+		request: handlers.LogoutRequest{}, status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodGet, path: "/auth/verify-email-change", operationID: "VerifyEmailChange", tag: "auth",
+		summary: "Confirm the new email of a user",
+		query:   []string{"token"}, status: http.StatusNoContent,
+	},
+
+	{
+		method: http.MethodGet, path: "/user", operationID: "GetUser", tag: "user",
+		summary: "Get the signed in user and their organizations", auth: userAuth,
+		status: http.StatusOK, response: usermodel.UserWithOrganization{},
+	},
+	{
+		method: http.MethodPatch, path: "/user", operationID: "PatchUser", tag: "user",
+		summary: "Update the name of the signed in user", auth: userAuth,
+		request: handlers.UserPatchRequest{}, status: http.StatusOK, response: handlers.UserPatchResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/user/password", operationID: "ChangePassword", tag: "user",
+		summary: "Change the password of the signed in user", auth: userAuth,
+		request: handlers.ChangePasswordRequest{}, status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPatch, path: "/user/email", operationID: "PatchEmail", tag: "user",
+		summary: "Request an email change for the signed in user", auth: userAuth,
+		request: handlers.EmailChangeRequest{}, status: http.StatusAccepted,
+	},
+	{
+		method: http.MethodGet, path: "/user/organizations", operationID: "ListUserOrganizations", tag: "user",
+		summary: "List the organizations the signed in user belongs to", auth: userAuth,
+		query: []string{"page", "page_size"}, status: http.StatusOK, response: handlers.ListUserOrganizationsResponse{},
+	},
+	{
+		method: http.MethodDelete, path: "/user", operationID: "DeleteUser", tag: "user",
+		summary: "Delete the signed in user", auth: userAuth,
+		status: http.StatusNoContent,
+	},
+
+	{
+		method: http.MethodPost, path: "/organizations", operationID: "PostOrganization", tag: "organizations",
+		summary: "Create an organization", auth: userAuth,
+		request: handlers.OrganizationPostRequest{}, status: http.StatusCreated,
+		response: organizationmodel.OrganizationRecord{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations", operationID: "GetOrganization", tag: "organizations",
+		summary: "Get the organization", auth: organizationAuth,
+		status: http.StatusOK, response: organizationmodel.OrganizationRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/organizations/settings", operationID: "PatchOrganizationSettings",
+		tag: "organizations", summary: "Update the settings of the organization", auth: organizationAuth,
+		request: handlers.PatchOrganizationSettingsRequest{}, status: http.StatusOK,
+		response: organizationmodel.OrganizationRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/organizations/:organizationID", operationID: "PatchOrganization",
+		tag: "organizations", summary: "Rename the organization and update its settings", auth: userAuth,
+		request: handlers.PatchOrganizationSettingsRequest{}, status: http.StatusOK,
+		response: organizationmodel.OrganizationRecord{},
+	},
+	{
+		method: http.MethodPost, path: "/organizations/environments", operationID: "PostOrganizationEnvironment",
+		tag: "organizations", summary: "Define an environment on the organization", auth: organizationAuth,
+		request: handlers.EnvironmentPostRequest{}, status: http.StatusCreated,
+		response: organizationmodel.Environment{},
+	},
+	{
+		method: http.MethodDelete, path: "/organizations/environments/:name", operationID: "DeleteOrganizationEnvironment",
+		tag: "organizations", summary: "Delete an environment of the organization", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/organizations/environments/:name/toggle", operationID: "BatchToggleEnvironment",
+		tag: "features", summary: "Toggle many flags in an environment", auth: organizationAuth,
+		request: handlers.BatchToggleRequest{}, status: http.StatusOK, response: handlers.BatchToggleResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations/members", operationID: "ListMembers", tag: "organizations",
+		summary: "List the members of the organization", auth: organizationAuth,
+		query: []string{"page", "page_size"}, status: http.StatusOK, response: handlers.ListMembersResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/organizations/members", operationID: "InviteMember", tag: "organizations",
+		summary: "Invite a user to the organization", auth: organizationAuth,
+		request: handlers.InviteMemberRequest{}, status: http.StatusCreated, response: handlers.MembersResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/organizations/members/:userID", operationID: "UpdateMemberRole",
+		tag: "organizations", summary: "Change the permission level of a member", auth: organizationAuth,
+		request: handlers.UpdateMemberRoleRequest{}, status: http.StatusOK,
+		response: organizationmodel.OrganizationMember{},
+	},
+	{
+		method: http.MethodDelete, path: "/organizations/members/:userID", operationID: "RemoveMember",
+		tag: "organizations", summary: "Remove a member from the organization", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/organizations/owner", operationID: "TransferOwnership",
+		tag: "organizations", summary: "Hand the organization over to another member", auth: organizationAuth,
+		request: handlers.TransferOwnershipRequest{}, status: http.StatusOK,
+		response: organizationmodel.OrganizationMember{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations/activity", operationID: "GetOrganizationActivity",
+		tag: "organizations", summary: "List the flag activity of the organization", auth: organizationAuth,
+		query:  []string{"page", "page_size", "action", "actor", "from", "to"},
+		status: http.StatusOK, response: handlers.ListActivityResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/organizations/api-keys", operationID: "PostAPIKey", tag: "organizations",
+		summary: "Generate an API key", auth: organizationAuth,
+		status: http.StatusCreated, response: handlers.APIKeyResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations/api-keys", operationID: "ListAPIKeys", tag: "organizations",
+		summary: "List the API keys of the organization", auth: organizationAuth,
+		status: http.StatusOK, response: []organizationmodel.APIKey{},
+	},
+	{
+		method: http.MethodDelete, path: "/organizations/api-keys/:apiKeyID", operationID: "DeleteAPIKey",
+		tag: "organizations", summary: "Revoke an API key", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/organizations/webhooks", operationID: "PostWebhook", tag: "organizations",
+		summary: "Register a webhook", auth: organizationAuth,
+		request: handlers.PostWebhookRequest{}, status: http.StatusCreated, response: handlers.WebhookResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations/export", operationID: "ExportFlags", tag: "features",
+		summary: "Export the live config of every flag", auth: organizationAuth,
+		query: []string{"format"}, status: http.StatusOK, response: featureflagmodel.ExportDocument{},
+	},
+	{
+		method: http.MethodPost, path: "/organizations/import", operationID: "ImportFlags", tag: "features",
+		summary: "Import flags from an export document", auth: organizationAuth,
+		query:   []string{"mode", "dry_run"},
+		request: featureflagmodel.ExportDocument{}, status: http.StatusOK, response: handlers.ImportFlagsResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/organizations/drift", operationID: "ListDrift", tag: "drift",
+		summary: "List the flags whose live config drifted", auth: organizationAuth,
+		status: http.StatusOK, response: handlers.ListDriftResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/organizations/drift/:featureFlagID/acknowledge", operationID: "AcknowledgeDrift",
+		tag: "drift", summary: "Acknowledge the drift of a flag", auth: organizationAuth,
+		status: http.StatusOK, response: featureflagmodel.FeatureFlagRecord{},
+	},
+
+	{
+		method: http.MethodPost, path: "/projects", operationID: "PostProject", tag: "projects",
+		summary: "Create a project", auth: organizationAuth,
+		request: handlers.ProjectPostRequest{}, status: http.StatusOK, response: organizationmodel.Project{},
+	},
+	{
+		method: http.MethodDelete, path: "/projects/:projectID", operationID: "DeleteProject", tag: "projects",
+		summary: "Delete a project", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+
+	{
+		method: http.MethodPost, path: "/features", operationID: "PostFeatureFlag", tag: "features",
+		summary: "Create a flag", auth: organizationAuth,
+		request: handlers.PostFeatureFlagRequest{}, status: http.StatusCreated,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPost, path: "/features/bulk", operationID: "PostFeatureFlags", tag: "features",
+		summary: "Create many flags at once, or none of them", auth: organizationAuth,
+		request: []handlers.PostFeatureFlagRequest{}, status: http.StatusCreated,
+		response: []featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodGet, path: "/features", operationID: "ListFeatureFlags", tag: "features",
+		summary: "List the flags of the organization", auth: organizationAuth,
+		query:  []string{"page", "page_size", "cursor", "q", "include_archived"},
+		status: http.StatusOK, response: handlers.ListFeatureFlagResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID", operationID: "GetFeatureFlag", tag: "features",
+		summary: "Get a flag", auth: organizationAuth,
+		status: http.StatusOK, response: handlers.FeatureFlagResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID", operationID: "PatchFeatureFlag", tag: "features",
+		summary: "Propose a new revision of a flag", auth: organizationAuth,
+		request: handlers.PatchFeatureFlagRequest{}, status: http.StatusOK, response: handlers.PatchFeatureFlagResponse{},
+	},
+	{
+		method: http.MethodDelete, path: "/features/:featureFlagID", operationID: "DeleteFeatureFlag", tag: "features",
+		summary: "Delete a flag", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/revisions/:revisionID", operationID: "ApproveRevision",
+		tag: "features", summary: "Approve a draft revision", auth: organizationAuth,
+		request: handlers.ApproveRevisionRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/revisions/:revisionID/reject",
+		operationID: "RejectRevision", tag: "features", summary: "Reject a draft revision", auth: organizationAuth,
+		status: http.StatusOK, response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID/revisions", operationID: "ListRevisions",
+		tag: "features", summary: "List the revisions of a flag", auth: organizationAuth,
+		query: []string{"page", "page_size", "status"}, status: http.StatusOK,
+		response: handlers.ListRevisionsResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID/revisions/:revisionID", operationID: "GetRevision",
+		tag: "features", summary: "Get a revision of a flag", auth: organizationAuth,
+		status: http.StatusOK, response: featureflagmodel.Revision{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID/revisions/:revisionID/diff",
+		operationID: "GetRevisionDiff", tag: "features", summary: "Diff a revision against the live one",
+		auth: organizationAuth, status: http.StatusOK, response: featureflagmodel.RevisionDiff{},
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/revisions/:revisionID/impact",
+		operationID: "GetRevisionImpact", tag: "features",
+		summary: "Compare the values contexts are served by a revision and the live one", auth: organizationAuth,
+		request: handlers.RevisionImpactRequest{}, status: http.StatusOK,
+		response: handlers.RevisionImpactResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/features/revisions/approve", operationID: "BulkApproveRevisions",
+		tag: "features", summary: "Approve revisions of many flags as a change set", auth: organizationAuth,
+		request: handlers.BulkApproveRevisionsRequest{}, status: http.StatusOK,
+		response: handlers.ChangeSetResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/features/change-sets/:changeSetID", operationID: "ListChangeSetFeatureFlags",
+		tag: "features", summary: "List the flags released by a change set", auth: organizationAuth,
+		status: http.StatusOK, response: handlers.ChangeSetResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/rollback", operationID: "RollbackFeatureFlagVersion",
+		tag: "features", summary: "Roll a flag back to its previous revision", auth: organizationAuth,
+		status: http.StatusOK, response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/toggle", operationID: "ToggleFeatureFlag",
+		tag: "features", summary: "Toggle a flag in an environment", auth: organizationAuth,
+		query: []string{"env", "verbose"}, status: http.StatusOK, response: handlers.ToggleFeatureFlagResponse{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/tags", operationID: "PatchFeatureFlagTags",
+		tag: "features", summary: "Replace the tags of a flag", auth: organizationAuth,
+		request: handlers.PatchFeatureFlagTagsRequest{}, status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/name", operationID: "RenameFeatureFlag",
+		tag: "features", summary: "Rename a flag", auth: organizationAuth,
+		request: handlers.RenameFeatureFlagRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/description",
+		operationID: "PatchFeatureFlagDescription", tag: "features", summary: "Replace the description of a flag",
+		auth: organizationAuth, request: handlers.DescriptionRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/environments", operationID: "PostEnvironment",
+		tag: "features", summary: "Add an environment to a flag", auth: organizationAuth,
+		request: handlers.PostEnvironmentRequest{}, status: http.StatusCreated,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodDelete, path: "/features/:featureFlagID/environments/:name", operationID: "DeleteEnvironment",
+		tag: "features", summary: "Remove an environment from a flag", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/clone", operationID: "CloneFeatureFlag",
+		tag: "features", summary: "Clone a flag under a new name", auth: organizationAuth,
+		request: handlers.CloneFeatureFlagRequest{}, status: http.StatusCreated,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/restore", operationID: "RestoreFeatureFlag",
+		tag: "features", summary: "Restore a deleted flag", auth: organizationAuth,
+		status: http.StatusOK, response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/archive", operationID: "ArchiveFeatureFlag",
+		tag: "features", summary: "Hide a flag from the default list", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/unarchive", operationID: "UnarchiveFeatureFlag",
+		tag: "features", summary: "Bring an archived flag back to the default list", auth: organizationAuth,
+		status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/expected-config", operationID: "SetExpectedConfig",
+		tag: "drift", summary: "Set the config hash a flag is expected to have", auth: organizationAuth,
+		request: handlers.SetExpectedConfigRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/prerequisites", operationID: "SetPrerequisites",
+		tag: "features", summary: "Replace the prerequisites of a flag", auth: organizationAuth,
+		request: handlers.SetPrerequisitesRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodPatch, path: "/features/:featureFlagID/overrides", operationID: "SetOverrides",
+		tag: "features", summary: "Replace the per key value overrides of a flag", auth: organizationAuth,
+		request: handlers.SetOverridesRequest{}, status: http.StatusOK,
+		response: featureflagmodel.FeatureFlagRecord{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID/timeline", operationID: "GetTimeline",
+		tag: "features", summary: "List the timeline of a flag", auth: organizationAuth,
+		query: []string{"page", "page_size", "action"}, status: http.StatusOK,
+		response: handlers.ListTimelineResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/features/:featureFlagID/live", operationID: "GetLiveConfig",
+		tag: "features", summary: "Get what a flag currently serves", auth: organizationAuth,
+		status: http.StatusOK, response: handlers.LiveConfigResponse{},
+	},
+
+	{
+		method: http.MethodPost, path: "/features/:featureFlagID/evaluate", operationID: "EvaluateFeatureFlag",
+		tag: "evaluation", summary: "Evaluate a flag against a context", auth: sdkAuth,
+		request: handlers.EvaluateFeatureFlagRequest{}, status: http.StatusOK,
+		response: handlers.EvaluateFeatureFlagResponse{},
+	},
+	{
+		method: http.MethodGet, path: "/stream", operationID: "StreamFlags", tag: "evaluation",
+		summary: "Stream flag changes as server-sent events", auth: sdkAuth,
+		query: []string{"env"}, status: http.StatusOK, mediaType: "text/event-stream",
+	},
+}
+
+// errorResponse is what every failing operation answers with
+var errorResponse = apierrors.Error{}
+
+// mediaTypeJSON is the content type of request and response bodies
+const mediaTypeJSON = echo.MIMEApplicationJSON
@@ -0,0 +1,243 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const componentsPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	objectIDSchema = Schema{Type: "string", Pattern: "^[0-9a-f]{24}$"}
+	flagTypeType   = reflect.TypeOf(featureflagmodel.FlagType(""))
+)
+
+// schemaRegistry builds schemas out of the Go types the handlers bind and
+// serve, registering every named struct as a component so it's described
+// once and referenced everywhere else
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	types   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: map[string]*Schema{},
+		types:   map[reflect.Type]string{},
+	}
+}
+
+func (sr *schemaRegistry) schemaOf(value interface{}) *Schema {
+	return sr.schemaFor(reflect.TypeOf(value))
+}
+
+func (sr *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, dateTimeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		schema := objectIDSchema
+		return &schema
+	case flagTypeType:
+		schema := &Schema{Type: "string"}
+		for _, flagType := range featureflagmodel.FlagTypes {
+			schema.Enum = append(schema.Enum, string(flagType))
+		}
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: sr.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: sr.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.structSchema(t)
+		}
+		return &Schema{Ref: componentsPrefix + sr.register(t)}
+	}
+
+	// Interfaces hold whatever the flag type dictates, which is any JSON
+	// value
+	return &Schema{}
+}
+
+// register adds the struct as a component the first time it's met. The
+// name is reserved before the fields are walked so recursive types end up
+// referencing themselves.
+func (sr *schemaRegistry) register(t reflect.Type) string {
+	if name, ok := sr.types[t]; ok {
+		return name
+	}
+
+	name := componentName(t)
+	if _, taken := sr.schemas[name]; taken {
+		name = packageName(t) + name
+	}
+
+	sr.types[t] = name
+	sr.schemas[name] = &Schema{}
+	*sr.schemas[name] = *sr.structSchema(t)
+
+	return name
+}
+
+func (sr *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened by encoding/json
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				flattened := sr.structSchema(embedded)
+				for property, propertySchema := range flattened.Properties {
+					schema.Properties[property] = propertySchema
+				}
+				schema.Required = append(schema.Required, flattened.Required...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := sr.schemaFor(field.Type)
+		if applyValidation(fieldSchema, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fieldSchema
+	}
+
+	return schema
+}
+
+// jsonName reads the name encoding/json gives the field, ok is false for
+// fields it skips
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	return name, true
+}
+
+// applyValidation carries the validator rules OpenAPI can express over to
+// the schema and reports whether the field is required. Rules after dive
+// apply to the elements, which aren't described that precisely.
+func applyValidation(schema *Schema, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "oneof":
+			schema.Enum = strings.Fields(value)
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "min", "max", "gte", "lte":
+			applyBound(schema, key, value)
+		}
+	}
+
+	return required
+}
+
+func applyBound(schema *Schema, key, value string) {
+	bound, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+
+	lower := key == "min" || key == "gte"
+	switch schema.Type {
+	case "string":
+		if lower {
+			schema.MinLength = &bound
+		} else {
+			schema.MaxLength = &bound
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &bound
+		} else {
+			schema.MaxItems = &bound
+		}
+	case "integer", "number":
+		number := float64(bound)
+		if lower {
+			schema.Minimum = &number
+		} else {
+			schema.Maximum = &number
+		}
+	}
+}
+
+// componentName strips the package paths off generic type arguments, so
+// common.PaginatedResponse[featureflagmodel.FeatureFlagRecord] becomes
+// PaginatedResponseFeatureFlagRecord
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	base, arguments, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+
+	for _, argument := range strings.Split(strings.TrimSuffix(arguments, "]"), ",") {
+		argument = strings.TrimLeft(argument, "*[]")
+		base += argument[strings.LastIndex(argument, ".")+1:]
+	}
+
+	return base
+}
+
+func packageName(t reflect.Type) string {
+	path := t.PkgPath()
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,139 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+)
+
+const (
+	Title      = "Togglelabs API"
+	APIVersion = "1.0.0"
+
+	bearerScheme = "bearerAuth"
+	apiKeyScheme = "apiKeyAuth"
+)
+
+// NewDocument describes every route of the routes table, with the schemas
+// reflected from the types the handlers bind and serve
+func NewDocument() *Document {
+	registry := newSchemaRegistry()
+	errorSchema := registry.schemaOf(errorResponse)
+
+	paths := map[string]*PathItem{}
+	for _, route := range routes {
+		path := openAPIPath(route.path)
+		if paths[path] == nil {
+			paths[path] = &PathItem{}
+		}
+		(*paths[path])[strings.ToLower(route.method)] = newOperation(registry, route, errorSchema)
+	}
+
+	return &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   Title,
+			Version: APIVersion,
+		},
+		Paths: paths,
+		Components: Components{
+			Schemas: registry.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerScheme: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+				},
+				apiKeyScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "Organization API key, prefixed by " + strings.TrimSpace(middlewares.APIKeyScheme),
+				},
+			},
+		},
+	}
+}
+
+func newOperation(registry *schemaRegistry, route route, errorSchema *Schema) *Operation {
+	operation := &Operation{
+		Tags:        []string{route.tag},
+		Summary:     route.summary,
+		OperationID: route.operationID,
+		Parameters:  []Parameter{},
+		Responses:   map[string]*Response{},
+	}
+
+	for _, segment := range strings.Split(route.path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name:     strings.TrimPrefix(segment, ":"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	for _, name := range route.query {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:   name,
+			In:     "query",
+			Schema: &Schema{Type: "string"},
+		})
+	}
+
+	switch route.auth {
+	case userAuth:
+		operation.Security = []SecurityRequirement{{bearerScheme: {}}}
+	case organizationAuth, sdkAuth:
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     middlewares.XOrganizationHeader,
+			In:       "header",
+			Required: route.auth == organizationAuth,
+			Schema:   &objectIDSchema,
+		})
+		operation.Security = []SecurityRequirement{{bearerScheme: {}}}
+		if route.auth == sdkAuth {
+			operation.Security = append(operation.Security, SecurityRequirement{apiKeyScheme: {}})
+		}
+	}
+
+	if route.request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{mediaTypeJSON: {Schema: registry.schemaOf(route.request)}},
+		}
+	}
+
+	response := &Response{Description: http.StatusText(route.status)}
+	switch {
+	case route.mediaType != "":
+		response.Content = map[string]*MediaType{route.mediaType: {Schema: &Schema{Type: "string"}}}
+	case route.response != nil:
+		response.Content = map[string]*MediaType{mediaTypeJSON: {Schema: registry.schemaOf(route.response)}}
+	}
+	operation.Responses[strconv.Itoa(route.status)] = response
+
+	operation.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{mediaTypeJSON: {Schema: errorSchema}},
+	}
+
+	return operation
+}
+
+// openAPIPath turns the echo path parameters into OpenAPI ones, :name
+// becomes {name}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[index] = "{" + strings.TrimPrefix(segment, ":") + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
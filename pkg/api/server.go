@@ -1,10 +1,15 @@
 package api
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/Roll-Play/togglelabs/pkg/api/handlers"
 	"github.com/Roll-Play/togglelabs/pkg/api/middlewares"
+	"github.com/Roll-Play/togglelabs/pkg/api/openapi"
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	"github.com/Roll-Play/togglelabs/pkg/config"
 	"github.com/Roll-Play/togglelabs/pkg/storage"
 	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
 	"github.com/labstack/echo/v4"
@@ -34,6 +39,7 @@ func normalizePort(port string) string {
 
 func NewApp(port string, storage *storage.MongoStorage, logger *zap.Logger) *App {
 	server := echo.New()
+	server.IPExtractor = middlewares.IPExtractor(config.TrustedProxies)
 
 	app := &App{
 		server:  server,
@@ -41,15 +47,59 @@ func NewApp(port string, storage *storage.MongoStorage, logger *zap.Logger) *App
 		storage: storage,
 		logger:  logger,
 	}
+	app.server.Use(middlewares.RequestIDMiddleware)
 	app.server.Use(middlewares.ZapLogger(logger))
+	app.server.Use(middlewares.CORSMiddleware(config.CORSAllowedOrigins))
 
-	registerRoutes(app)
+	featureFlagHandler := registerRoutes(app)
+	startWorkers(app, featureFlagHandler.Events())
 
 	return app
 }
 
-func registerRoutes(app *App) {
+func startWorkers(app *App, events *handlers.FlagEventBroker) {
+	revisionScheduler := handlers.NewRevisionScheduler(
+		app.storage.DB(),
+		app.logger,
+		events,
+		config.RevisionSchedulerInterval*time.Second,
+	)
+	revisionScheduler.Start(context.Background())
+
+	if config.DeletedFlagPurgeEnabled {
+		deletedFlagPurger := handlers.NewDeletedFlagPurger(
+			app.storage.DB(),
+			app.logger,
+			time.Duration(config.DeletedFlagRetention)*24*time.Hour,
+			time.Duration(config.DeletedFlagPurgeInterval)*time.Second,
+		)
+		deletedFlagPurger.Start(context.Background())
+	}
+}
+
+func registerRoutes(app *App) *handlers.FeatureFlagHandler {
+	repositories := handlers.NewMongoRepositories(app.storage.DB())
+	dependencies := []handlers.DependencyCheck{handlers.MongoCheck(app.storage.DB())}
+	if config.RedisURL != "" {
+		backend, err := cache.NewRedisBackend(config.RedisURL)
+		if err != nil {
+			app.logger.Fatal("Invalid REDIS_URL", zap.Error(err))
+		}
+		repositories.EvaluationCache = backend
+		dependencies = append(dependencies, handlers.DependencyCheck{Name: "redis", Check: backend.Ping})
+	}
+
 	app.server.GET("/healthz", handlers.HealthHandler)
+	app.server.GET("/readyz", handlers.NewReadinessHandler(app.logger, dependencies...).Ready)
+	app.server.GET(openapi.SpecPath, openapi.SpecHandler)
+	app.server.GET(openapi.DocsPath, openapi.DocsHandler)
+
+	authMiddleware := middlewares.AuthMiddleware(app.storage.DB())
+
+	authRateLimit := middlewares.RateLimitMiddleware(middlewares.NewRateLimiter(
+		config.AuthRateLimit,
+		config.AuthRateLimitInterval*time.Second,
+	))
 
 	oauthConfig := &oauth2.Config{
 		RedirectURL:  os.Getenv("REDIRECT_URL"),
@@ -66,35 +116,128 @@ func registerRoutes(app *App) {
 		&apiutils.HTTPClient{},
 		apiutils.NewOAuthClient(oauthConfig),
 	)
-	app.server.POST("/oauth", oauthHandler.SignIn)
+	app.server.POST("/oauth", oauthHandler.SignIn, authRateLimit)
 	app.server.GET("/callback", oauthHandler.Callback)
 
 	signUpHandler := handlers.NewSignUpHandler(app.storage.DB(), app.logger)
-	app.server.POST("/signup", signUpHandler.PostUser)
+	app.server.POST("/signup", signUpHandler.PostUser, authRateLimit)
 
 	signInHandler := handlers.NewSignInHandler(app.storage.DB(), app.logger)
-	app.server.POST("/signin", signInHandler.PostSignIn)
+	app.server.POST("/signin", signInHandler.PostSignIn, authRateLimit)
+
+	authHandler := handlers.NewAuthHandler(app.storage.DB(), app.logger)
+	app.server.POST("/auth/refresh", authHandler.PostRefresh, authRateLimit)
+	app.server.POST("/auth/forgot-password", authHandler.PostForgotPassword, authRateLimit)
+	app.server.POST("/auth/reset-password", authHandler.PostResetPassword, authRateLimit)
+	app.server.POST("/auth/logout", authMiddleware(authHandler.PostLogout))
+	app.server.GET("/auth/verify-email-change", authHandler.GetVerifyEmailChange, authRateLimit)
 
 	userHandler := handlers.NewUserHandler(app.storage.DB(), app.logger)
-	userGroup := app.server.Group("/user", middlewares.AuthMiddleware)
+	userGroup := app.server.Group("/user", authMiddleware)
 	userGroup.GET("", userHandler.GetUser)
 	userGroup.PATCH("", userHandler.PatchUser)
+	userGroup.PATCH("/password", userHandler.ChangePassword)
+	userGroup.PATCH("/email", userHandler.PatchEmail)
+	userGroup.GET("/organizations", userHandler.ListOrganizations)
+	userGroup.DELETE("", userHandler.DeleteUser)
 
 	organizationHandler := handlers.NewOrganizationHandler(app.storage.DB(), app.logger)
-	app.server.POST("/organizations", middlewares.AuthMiddleware(organizationHandler.PostOrganization))
-	app.server.GET("/organizations", middlewares.AuthMiddleware(organizationHandler.GetOrganization), middlewares.OrganizationMiddleware)
-	app.server.POST("/projects", middlewares.AuthMiddleware(organizationHandler.PostProject), middlewares.OrganizationMiddleware)
-	app.server.DELETE("/projects/:projectID", middlewares.AuthMiddleware(organizationHandler.DeleteProject), middlewares.OrganizationMiddleware)
+	app.server.POST("/organizations", authMiddleware(organizationHandler.PostOrganization))
+	app.server.GET("/organizations", authMiddleware(organizationHandler.GetOrganization), middlewares.OrganizationMiddleware)
+	app.server.PATCH(
+		"/organizations/settings",
+		authMiddleware(organizationHandler.PatchOrganizationSettings),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.PATCH(
+		"/organizations/maintenance",
+		authMiddleware(organizationHandler.SetMaintenanceMode),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.PATCH("/organizations/:organizationID", authMiddleware(organizationHandler.PatchOrganization))
+	app.server.POST(
+		"/organizations/environments",
+		authMiddleware(organizationHandler.PostEnvironment),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.DELETE(
+		"/organizations/environments/:name",
+		authMiddleware(organizationHandler.DeleteEnvironment),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.GET(
+		"/organizations/members",
+		authMiddleware(organizationHandler.ListMembers),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.GET(
+		"/organizations/activity",
+		authMiddleware(organizationHandler.GetOrganizationActivity),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/members",
+		authMiddleware(organizationHandler.InviteMember),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.PATCH(
+		"/organizations/members/:userID",
+		authMiddleware(organizationHandler.UpdateMemberRole),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.DELETE(
+		"/organizations/members/:userID",
+		authMiddleware(organizationHandler.RemoveMember),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/owner",
+		authMiddleware(organizationHandler.TransferOwnership),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/api-keys",
+		authMiddleware(organizationHandler.PostAPIKey),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.GET(
+		"/organizations/api-keys",
+		authMiddleware(organizationHandler.ListAPIKeys),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.DELETE(
+		"/organizations/api-keys/:apiKeyID",
+		authMiddleware(organizationHandler.DeleteAPIKey),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/webhooks",
+		authMiddleware(organizationHandler.PostWebhook),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST("/projects", authMiddleware(organizationHandler.PostProject), middlewares.OrganizationMiddleware)
+	app.server.DELETE("/projects/:projectID", authMiddleware(organizationHandler.DeleteProject), middlewares.OrganizationMiddleware)
 
-	featureFlagHandler := handlers.NewFeatureFlagHandler(app.storage.DB(), app.logger)
-	featureGroup := app.server.Group("/features", middlewares.AuthMiddleware, middlewares.OrganizationMiddleware)
+	featureFlagHandler := handlers.NewFeatureFlagHandlerWithRepositories(repositories, app.logger)
+	app.server.GET(
+		"/healthz/cache",
+		authMiddleware(featureFlagHandler.GetEvaluationCacheStats),
+		middlewares.OrganizationMiddleware,
+	)
+	featureGroup := app.server.Group("/features", authMiddleware, middlewares.OrganizationMiddleware)
 	featureGroup.POST("", featureFlagHandler.PostFeatureFlag)
+	featureGroup.POST("/bulk", featureFlagHandler.PostFeatureFlags)
 	featureGroup.GET("", featureFlagHandler.ListFeatureFlags)
+	featureGroup.GET("/:featureFlagID", featureFlagHandler.GetFeatureFlag)
 	featureGroup.PATCH("/:featureFlagID", featureFlagHandler.PatchFeatureFlag)
 	featureGroup.PATCH(
 		"/:featureFlagID/revisions/:revisionID",
 		featureFlagHandler.ApproveRevision,
 	)
+	featureGroup.PATCH(
+		"/:featureFlagID/revisions/:revisionID/reject",
+		featureFlagHandler.RejectRevision,
+	)
 	featureGroup.DELETE("/:featureFlagID", featureFlagHandler.DeleteFeatureFlag)
 	featureGroup.PATCH(
 		"/:featureFlagID/rollback",
@@ -105,4 +248,59 @@ func registerRoutes(app *App) {
 		featureFlagHandler.ToggleFeatureFlag,
 	)
 	featureGroup.PATCH("/:featureFlagID/tags", featureFlagHandler.PatchFeatureFlagTags)
+	featureGroup.POST("/:featureFlagID/environments", featureFlagHandler.PostEnvironment)
+	featureGroup.DELETE("/:featureFlagID/environments/:name", featureFlagHandler.DeleteEnvironment)
+	featureGroup.PATCH("/:featureFlagID/name", featureFlagHandler.RenameFeatureFlag)
+	featureGroup.PATCH("/:featureFlagID/description", featureFlagHandler.PatchFeatureFlagDescription)
+	featureGroup.POST("/:featureFlagID/clone", featureFlagHandler.CloneFeatureFlag)
+	featureGroup.POST("/revisions/approve", featureFlagHandler.BulkApproveRevisions)
+	featureGroup.POST("/:featureFlagID/restore", featureFlagHandler.RestoreFeatureFlag)
+	featureGroup.POST("/:featureFlagID/archive", featureFlagHandler.ArchiveFeatureFlag)
+	featureGroup.POST("/:featureFlagID/unarchive", featureFlagHandler.UnarchiveFeatureFlag)
+	featureGroup.GET("/change-sets/:changeSetID", featureFlagHandler.ListChangeSetFeatureFlags)
+	featureGroup.PATCH("/:featureFlagID/expected-config", featureFlagHandler.SetExpectedConfig)
+	featureGroup.PATCH("/:featureFlagID/prerequisites", featureFlagHandler.SetPrerequisites)
+	featureGroup.PATCH("/:featureFlagID/overrides", featureFlagHandler.SetOverrides)
+	featureGroup.GET("/:featureFlagID/revisions", featureFlagHandler.ListRevisions)
+	featureGroup.GET("/:featureFlagID/revisions/:revisionID", featureFlagHandler.GetRevision)
+	featureGroup.GET("/:featureFlagID/revisions/:revisionID/diff", featureFlagHandler.GetRevisionDiff)
+	featureGroup.POST("/:featureFlagID/revisions/:revisionID/impact", featureFlagHandler.GetRevisionImpact)
+	featureGroup.GET("/:featureFlagID/timeline", featureFlagHandler.GetTimeline)
+	featureGroup.GET("/:featureFlagID/live", featureFlagHandler.GetLiveConfig)
+
+	// Evaluation and the flag stream are the only routes SDKs can reach
+	// with an API key
+	app.server.POST(
+		"/features/:featureFlagID/evaluate",
+		featureFlagHandler.EvaluateFeatureFlag,
+		middlewares.APIKeyMiddleware(app.storage.DB()),
+	)
+
+	app.server.GET(
+		"/stream",
+		featureFlagHandler.StreamFlags,
+		middlewares.APIKeyMiddleware(app.storage.DB()),
+	)
+
+	app.server.GET(
+		"/organizations/export",
+		authMiddleware(featureFlagHandler.ExportFlags),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/import",
+		authMiddleware(featureFlagHandler.ImportFlags),
+		middlewares.OrganizationMiddleware,
+	)
+	app.server.POST(
+		"/organizations/environments/:name/toggle",
+		authMiddleware(featureFlagHandler.BatchToggleEnvironment),
+		middlewares.OrganizationMiddleware,
+	)
+
+	driftGroup := app.server.Group("/organizations/drift", authMiddleware, middlewares.OrganizationMiddleware)
+	driftGroup.GET("", featureFlagHandler.ListDrift)
+	driftGroup.POST("/:featureFlagID/acknowledge", featureFlagHandler.AcknowledgeDrift)
+
+	return featureFlagHandler
 }
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend stores the cached values, entries expire after the ttl they were
+// set with
+type Backend interface {
+	// Get reports whether key was found, expired entries are never found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryBackend keeps the entries in the process, so every instance of the
+// API caches on its own
+type MemoryBackend struct {
+	mutex   sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (mb *MemoryBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	mb.mutex.RLock()
+	entry, ok := mb.entries[key]
+	mb.mutex.RUnlock()
+
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+func (mb *MemoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	// Expired entries are only swept on writes, reads just skip them
+	for entryKey, entry := range mb.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(mb.entries, entryKey)
+		}
+	}
+	mb.entries[key] = memoryEntry{
+		value:     value,
+		expiresAt: now.Add(ttl),
+	}
+
+	return nil
+}
+
+func (mb *MemoryBackend) Delete(_ context.Context, key string) error {
+	mb.mutex.Lock()
+	delete(mb.entries, key)
+	mb.mutex.Unlock()
+
+	return nil
+}
+
+func (mb *MemoryBackend) DeletePrefix(_ context.Context, prefix string) error {
+	mb.mutex.Lock()
+	for key := range mb.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(mb.entries, key)
+		}
+	}
+	mb.mutex.Unlock()
+
+	return nil
+}
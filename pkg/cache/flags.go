@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const flagsKeyPrefix = "flags:"
+
+// FlagLoader reads the flags of an organization from the store when they
+// aren't cached
+type FlagLoader func(ctx context.Context) ([]featureflagmodel.FeatureFlagRecord, error)
+
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// flagSnapshot wraps the records since bson documents can't be arrays
+type flagSnapshot struct {
+	Flags []featureflagmodel.FeatureFlagRecord `bson:"flags"`
+}
+
+// FlagCache keeps the flags of an organization per environment for
+// evaluation. Entries are invalidated on writes, the ttl only bounds how
+// stale they get should an invalidation be missed.
+type FlagCache struct {
+	backend Backend
+	ttl     time.Duration
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+func NewFlagCache(backend Backend, ttl time.Duration) *FlagCache {
+	return &FlagCache{
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+func flagsKey(organizationID primitive.ObjectID, environment string) string {
+	return flagsOrganizationPrefix(organizationID) + environment
+}
+
+func flagsOrganizationPrefix(organizationID primitive.ObjectID) string {
+	return flagsKeyPrefix + organizationID.Hex() + ":"
+}
+
+// Flags returns the cached flags of the organization for environment,
+// loading and caching them on a miss. A backend failure is treated as a
+// miss so evaluation keeps working off the store.
+func (fc *FlagCache) Flags(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	environment string,
+	load FlagLoader,
+) ([]featureflagmodel.FeatureFlagRecord, error) {
+	key := flagsKey(organizationID, environment)
+
+	value, ok, err := fc.backend.Get(ctx, key)
+	if err == nil && ok {
+		snapshot := new(flagSnapshot)
+		if err := bson.Unmarshal(value, snapshot); err == nil {
+			fc.hits.Add(1)
+			return snapshot.Flags, nil
+		}
+	}
+	fc.misses.Add(1)
+
+	records, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, err := bson.Marshal(flagSnapshot{Flags: records}); err == nil {
+		// Failing to cache only costs the next evaluation a load
+		_ = fc.backend.Set(ctx, key, value, fc.ttl)
+	}
+
+	return records, nil
+}
+
+// Invalidate drops the flags cached for environment, or for every
+// environment of the organization when environment is empty
+func (fc *FlagCache) Invalidate(ctx context.Context, organizationID primitive.ObjectID, environment string) error {
+	if environment == "" {
+		return fc.backend.DeletePrefix(ctx, flagsOrganizationPrefix(organizationID))
+	}
+
+	return fc.backend.Delete(ctx, flagsKey(organizationID, environment))
+}
+
+func (fc *FlagCache) Stats() Stats {
+	return Stats{
+		Hits:   fc.hits.Load(),
+		Misses: fc.misses.Load(),
+	}
+}
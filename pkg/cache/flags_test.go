@@ -0,0 +1,128 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/cache"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingLoader serves name as the only flag and counts how often it ran
+func countingLoader(loads *int, name string) cache.FlagLoader {
+	return func(_ context.Context) ([]featureflagmodel.FeatureFlagRecord, error) {
+		*loads++
+		record := featureflagmodel.NewFeatureFlagRecord(
+			name,
+			"true",
+			featureflagmodel.Boolean,
+			nil,
+			primitive.NewObjectID(),
+			primitive.NewObjectID(),
+			[]string{"prod"},
+			nil,
+			nil,
+		)
+		return []featureflagmodel.FeatureFlagRecord{*record}, nil
+	}
+}
+
+func TestFlagsHit(t *testing.T) {
+	flagCache := cache.NewFlagCache(cache.NewMemoryBackend(), time.Minute)
+	organizationID := primitive.NewObjectID()
+	loads := 0
+
+	first, err := flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+	second, err := flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "other feature"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, loads)
+	assert.Equal(t, "cool feature", second[0].Name)
+	assert.Equal(t, first[0].ID, second[0].ID)
+	assert.Equal(t, first[0].Environments, second[0].Environments)
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1}, flagCache.Stats())
+}
+
+func TestFlagsKeyedByOrganizationAndEnvironment(t *testing.T) {
+	flagCache := cache.NewFlagCache(cache.NewMemoryBackend(), time.Minute)
+	organizationID := primitive.NewObjectID()
+	loads := 0
+
+	for _, environment := range []string{"prod", "staging"} {
+		_, err := flagCache.Flags(context.Background(), organizationID, environment, countingLoader(&loads, "cool feature"))
+		assert.NoError(t, err)
+	}
+	_, err := flagCache.Flags(context.Background(), primitive.NewObjectID(), "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, loads)
+}
+
+func TestInvalidateEnvironment(t *testing.T) {
+	flagCache := cache.NewFlagCache(cache.NewMemoryBackend(), time.Minute)
+	organizationID := primitive.NewObjectID()
+	loads := 0
+	for _, environment := range []string{"prod", "staging"} {
+		_, err := flagCache.Flags(context.Background(), organizationID, environment, countingLoader(&loads, "cool feature"))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, flagCache.Invalidate(context.Background(), organizationID, "prod"))
+
+	flags, err := flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "renamed feature"))
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed feature", flags[0].Name)
+	flags, err = flagCache.Flags(context.Background(), organizationID, "staging", countingLoader(&loads, "renamed feature"))
+	assert.NoError(t, err)
+	assert.Equal(t, "cool feature", flags[0].Name)
+	assert.Equal(t, 3, loads)
+}
+
+func TestInvalidateOrganization(t *testing.T) {
+	flagCache := cache.NewFlagCache(cache.NewMemoryBackend(), time.Minute)
+	organizationID := primitive.NewObjectID()
+	otherOrganizationID := primitive.NewObjectID()
+	loads := 0
+	for _, environment := range []string{"prod", "staging"} {
+		_, err := flagCache.Flags(context.Background(), organizationID, environment, countingLoader(&loads, "cool feature"))
+		assert.NoError(t, err)
+	}
+	_, err := flagCache.Flags(context.Background(), otherOrganizationID, "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, flagCache.Invalidate(context.Background(), organizationID, ""))
+
+	for _, environment := range []string{"prod", "staging"} {
+		_, err := flagCache.Flags(context.Background(), organizationID, environment, countingLoader(&loads, "cool feature"))
+		assert.NoError(t, err)
+	}
+	_, err = flagCache.Flags(context.Background(), otherOrganizationID, "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, loads)
+}
+
+func TestFlagsExpireAfterTTL(t *testing.T) {
+	ttl := 50 * time.Millisecond
+	flagCache := cache.NewFlagCache(cache.NewMemoryBackend(), ttl)
+	organizationID := primitive.NewObjectID()
+	loads := 0
+
+	_, err := flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+	_, err = flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "cool feature"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loads)
+
+	time.Sleep(2 * ttl)
+
+	flags, err := flagCache.Flags(context.Background(), organizationID, "prod", countingLoader(&loads, "renamed feature"))
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed feature", flags[0].Name)
+	assert.Equal(t, 2, loads)
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 2}, flagCache.Stats())
+}
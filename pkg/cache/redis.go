@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisScanCount = 100
+
+// RedisBackend shares the entries between every instance of the API, so an
+// invalidation made by one of them is seen by all
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// NewRedisBackend connects to the server at url, a redis:// URL
+func NewRedisBackend(url string) (*RedisBackend, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisBackend{
+		client: redis.NewClient(options),
+	}, nil
+}
+
+func (rb *RedisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := rb.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (rb *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return rb.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (rb *RedisBackend) Delete(ctx context.Context, key string) error {
+	return rb.client.Del(ctx, key).Err()
+}
+
+func (rb *RedisBackend) DeletePrefix(ctx context.Context, prefix string) error {
+	iterator := rb.client.Scan(ctx, 0, prefix+"*", redisScanCount).Iterator()
+	for iterator.Next(ctx) {
+		if err := rb.client.Del(ctx, iterator.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iterator.Err()
+}
+
+// Ping reports whether the server can be reached
+func (rb *RedisBackend) Ping(ctx context.Context) error {
+	return rb.client.Ping(ctx).Err()
+}
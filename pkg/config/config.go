@@ -1,19 +1,126 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
-	DBConnectionTimeout   = 10
-	DBFetchTimeout        = 5
-	JWTExpireTime         = 60 * 60 * 1000 * 24
+	DBConnectionTimeout = 10
+	DBFetchTimeout      = 5
+	JWTExpireTime       = 60 * 60 * 1000 * 24
+	// RefreshTokenExpireTime is in milliseconds, like JWTExpireTime
+	RefreshTokenExpireTime = 60 * 60 * 1000 * 24 * 30
+	// PasswordResetExpireTime is in milliseconds, like JWTExpireTime
+	PasswordResetExpireTime = 60 * 60 * 1000
+	// EmailChangeExpireTime is in milliseconds, like JWTExpireTime
+	EmailChangeExpireTime = 60 * 60 * 1000 * 24
 	BCryptCost            = 8
 	TestDBName            = "togglelabs_test"
 	DevEnvironment        = "DEV"
 	ProductionEnvironment = "PRODUCTION"
+	// RevisionSchedulerInterval is how often, in seconds, scheduled
+	// revisions are checked for activation
+	RevisionSchedulerInterval = 30
+	// AuthRateLimit is how many requests a single client can make to the
+	// authentication endpoints every AuthRateLimitInterval seconds
+	AuthRateLimit         = 10
+	AuthRateLimitInterval = 60
+	// WebhookMaxAttempts bounds how many times a webhook delivery is tried,
+	// waiting WebhookRetryBackoff seconds, doubled on every retry, in between
+	WebhookMaxAttempts  = 3
+	WebhookRetryBackoff = 1
+	// WebhookTimeout is how long, in seconds, a single delivery may take
+	WebhookTimeout = 10
+	// StreamHeartbeatInterval is how often, in seconds, a comment is sent on
+	// idle flag streams so proxies don't close them
+	StreamHeartbeatInterval = 30
+	// StreamBufferSize is how many events a flag stream can fall behind
+	// before it gets disconnected
+	StreamBufferSize = 32
+	// DefaultPageSize is used when a list is requested without a valid
+	// page_size, which can never go above MaxPageSize
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+	// IdempotencyKeyWindow is how long, in seconds, a retried creation is
+	// answered with the flag its Idempotency-Key created
+	IdempotencyKeyWindow = 60 * 60 * 24
+	// MaxIdempotencyKeyLength bounds the Idempotency-Key header
+	MaxIdempotencyKeyLength = 255
+	// MaxBulkFeatureFlags bounds how many flags a single bulk creation
+	// takes
+	MaxBulkFeatureFlags = 100
+	// ReadinessTimeout is how long, in seconds, the readiness probe waits on
+	// the dependencies before reporting them down
+	ReadinessTimeout = 2
 )
 
 var Environment string
 
+// JWTSigningKey is a secret JWTs are signed with, told apart by the kid
+// header of the tokens
+type JWTSigningKey struct {
+	ID     string
+	Secret string
+}
+
+var (
+	// JWTSigningKeys verify the tokens of incoming requests and the first
+	// one signs new tokens. Retired keys are kept after it for as long as
+	// the tokens they signed can still be valid.
+	JWTSigningKeys = []JWTSigningKey{{ID: "default", Secret: "your-secret-key"}}
+	// AccessTokenExpireTime is in milliseconds, like JWTExpireTime
+	AccessTokenExpireTime time.Duration = JWTExpireTime
+)
+
+var (
+	// EvaluationCacheTTL is how long, in seconds, the flags cached for
+	// evaluation are served at most, bounding how stale they get should an
+	// invalidation be missed
+	EvaluationCacheTTL time.Duration = 30
+	// RedisURL shares the evaluation cache between instances when set,
+	// otherwise every instance caches in memory
+	RedisURL string
+	// CORSAllowedOrigins are the origins browsers may call the API from,
+	// none by default
+	CORSAllowedOrigins = []string{}
+	// TrustedProxies are the ranges whose X-Forwarded-For is believed when
+	// telling clients apart. Without any the peer address is the client.
+	TrustedProxies = []*net.IPNet{}
+	// PasswordMinLength and PasswordMinCharacterClasses make up the password
+	// policy. The classes are lowercase letters, uppercase letters, digits
+	// and symbols.
+	PasswordMinLength           = 8
+	PasswordMinCharacterClasses = 2
+	// DeletedFlagRetention is how long, in days, soft deleted flags can be
+	// restored before the purge job deletes them for good, along with their
+	// timelines. The job runs every DeletedFlagPurgeInterval seconds unless
+	// DeletedFlagPurgeEnabled is off.
+	DeletedFlagRetention     = 30
+	DeletedFlagPurgeInterval = 60 * 60
+	DeletedFlagPurgeEnabled  = true
+	// WebhookAllowPrivateAddresses lets webhooks reach private, loopback and
+	// link local addresses, which are off limits by default so webhooks
+	// can't be pointed at services behind the API
+	WebhookAllowPrivateAddresses = false
+)
+
+var ErrInvalidJWTSigningKeys = errors.New("JWT_SIGNING_KEYS must be a list of unique kid:secret pairs")
+var ErrInvalidAccessTokenExpireTime = errors.New("ACCESS_TOKEN_EXPIRE_TIME must be a positive number of seconds")
+var ErrInvalidCORSAllowedOrigins = errors.New("CORS_ALLOWED_ORIGINS must be a list of scheme://host origins")
+var ErrInvalidTrustedProxies = errors.New("TRUSTED_PROXIES must be a list of CIDR ranges")
+var ErrInvalidEvaluationCacheTTL = errors.New("EVALUATION_CACHE_TTL must be a positive number of seconds")
+var ErrInvalidPasswordMinLength = errors.New("PASSWORD_MIN_LENGTH must be a positive number")
+var ErrInvalidPasswordMinCharacterClasses = errors.New("PASSWORD_MIN_CHARACTER_CLASSES must be between 1 and 4")
+var ErrInvalidDeletedFlagRetention = errors.New("DELETED_FLAG_RETENTION must be a positive number of days")
+var ErrInvalidDeletedFlagPurgeInterval = errors.New("DELETED_FLAG_PURGE_INTERVAL must be a positive number of seconds")
+var ErrInvalidDeletedFlagPurgeEnabled = errors.New("DELETED_FLAG_PURGE_ENABLED must be true or false")
+var ErrInvalidWebhookAllowPrivateAddresses = errors.New("WEBHOOK_ALLOW_PRIVATE_ADDRESSES must be true or false")
+
 func StartEnvironment() {
 	env := os.Getenv("ENV")
 
@@ -24,3 +131,169 @@ func StartEnvironment() {
 
 	Environment = DevEnvironment
 }
+
+// StartJWT reads the signing keys from JWT_SIGNING_KEYS, a comma separated
+// list of kid:secret pairs with the current key first, falling back to the
+// single JWT_SECRET. ACCESS_TOKEN_EXPIRE_TIME sets how long, in seconds,
+// access tokens are valid.
+func StartJWT() error {
+	if signingKeys := os.Getenv("JWT_SIGNING_KEYS"); signingKeys != "" {
+		keys, err := parseJWTSigningKeys(signingKeys)
+		if err != nil {
+			return err
+		}
+		JWTSigningKeys = keys
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		JWTSigningKeys = []JWTSigningKey{{ID: "default", Secret: secret}}
+	}
+
+	if expireTime := os.Getenv("ACCESS_TOKEN_EXPIRE_TIME"); expireTime != "" {
+		seconds, err := strconv.Atoi(expireTime)
+		if err != nil || seconds < 1 {
+			return ErrInvalidAccessTokenExpireTime
+		}
+		AccessTokenExpireTime = time.Duration(seconds) * 1000
+	}
+
+	return nil
+}
+
+func parseJWTSigningKeys(value string) ([]JWTSigningKey, error) {
+	pairs := strings.Split(value, ",")
+	keys := make([]JWTSigningKey, 0, len(pairs))
+	seen := make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" || seen[id] {
+			return nil, ErrInvalidJWTSigningKeys
+		}
+		seen[id] = true
+		keys = append(keys, JWTSigningKey{ID: id, Secret: secret})
+	}
+
+	return keys, nil
+}
+
+// StartCache reads EVALUATION_CACHE_TTL, in seconds, and REDIS_URL
+func StartCache() error {
+	if ttl := os.Getenv("EVALUATION_CACHE_TTL"); ttl != "" {
+		seconds, err := strconv.Atoi(ttl)
+		if err != nil || seconds < 1 {
+			return ErrInvalidEvaluationCacheTTL
+		}
+		EvaluationCacheTTL = time.Duration(seconds)
+	}
+
+	RedisURL = os.Getenv("REDIS_URL")
+
+	return nil
+}
+
+// StartCORS reads CORS_ALLOWED_ORIGINS, a comma separated list of origins
+// such as https://dashboard.example.com. Wildcards aren't accepted since
+// the allowed origins can send credentials.
+func StartCORS() error {
+	CORSAllowedOrigins = []string{}
+
+	origins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if origins == "" {
+		return nil
+	}
+
+	for _, origin := range strings.Split(origins, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "*/?#") {
+			return ErrInvalidCORSAllowedOrigins
+		}
+		CORSAllowedOrigins = append(CORSAllowedOrigins, origin)
+	}
+
+	return nil
+}
+
+// StartTrustedProxies reads TRUSTED_PROXIES, a comma separated list of CIDR
+// ranges such as 10.0.0.0/8 the API is reached through
+func StartTrustedProxies() error {
+	TrustedProxies = []*net.IPNet{}
+
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return nil
+	}
+
+	for _, proxy := range strings.Split(proxies, ",") {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(proxy))
+		if err != nil {
+			return ErrInvalidTrustedProxies
+		}
+		TrustedProxies = append(TrustedProxies, ipRange)
+	}
+
+	return nil
+}
+
+// StartPasswordPolicy reads PASSWORD_MIN_LENGTH and
+// PASSWORD_MIN_CHARACTER_CLASSES
+func StartPasswordPolicy() error {
+	if minLength := os.Getenv("PASSWORD_MIN_LENGTH"); minLength != "" {
+		length, err := strconv.Atoi(minLength)
+		if err != nil || length < 1 {
+			return ErrInvalidPasswordMinLength
+		}
+		PasswordMinLength = length
+	}
+
+	if minClasses := os.Getenv("PASSWORD_MIN_CHARACTER_CLASSES"); minClasses != "" {
+		classes, err := strconv.Atoi(minClasses)
+		if err != nil || classes < 1 || classes > 4 {
+			return ErrInvalidPasswordMinCharacterClasses
+		}
+		PasswordMinCharacterClasses = classes
+	}
+
+	return nil
+}
+
+// StartPurge reads DELETED_FLAG_RETENTION, in days,
+// DELETED_FLAG_PURGE_INTERVAL, in seconds, and DELETED_FLAG_PURGE_ENABLED
+func StartPurge() error {
+	if retention := os.Getenv("DELETED_FLAG_RETENTION"); retention != "" {
+		days, err := strconv.Atoi(retention)
+		if err != nil || days < 1 {
+			return ErrInvalidDeletedFlagRetention
+		}
+		DeletedFlagRetention = days
+	}
+
+	if interval := os.Getenv("DELETED_FLAG_PURGE_INTERVAL"); interval != "" {
+		seconds, err := strconv.Atoi(interval)
+		if err != nil || seconds < 1 {
+			return ErrInvalidDeletedFlagPurgeInterval
+		}
+		DeletedFlagPurgeInterval = seconds
+	}
+
+	if enabled := os.Getenv("DELETED_FLAG_PURGE_ENABLED"); enabled != "" {
+		isEnabled, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return ErrInvalidDeletedFlagPurgeEnabled
+		}
+		DeletedFlagPurgeEnabled = isEnabled
+	}
+
+	return nil
+}
+
+// StartWebhooks reads WEBHOOK_ALLOW_PRIVATE_ADDRESSES
+func StartWebhooks() error {
+	if allow := os.Getenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES"); allow != "" {
+		isAllowed, err := strconv.ParseBool(allow)
+		if err != nil {
+			return ErrInvalidWebhookAllowPrivateAddresses
+		}
+		WebhookAllowPrivateAddresses = isAllowed
+	}
+
+	return nil
+}
@@ -0,0 +1,520 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrEnvironmentNotFound = errors.New("environment not found on feature flag")
+var ErrNoLiveRevision = errors.New("feature flag has no live revision")
+
+// KeyAttribute is the context attribute identifying the entity a flag is
+// being evaluated for
+const KeyAttribute = "key"
+
+// Context holds the attributes of the entity a flag is being evaluated for
+type Context map[string]interface{}
+
+// ReasonKind names why an evaluation served its value
+type ReasonKind = string
+
+const (
+	// ReasonDefault serves the default value when no rule matched
+	ReasonDefault ReasonKind = "default"
+	// ReasonRuleMatch serves the value of a rule
+	ReasonRuleMatch ReasonKind = "rule_match"
+	// ReasonEnvironmentDisabled serves the default value of a flag turned
+	// off in the environment
+	ReasonEnvironmentDisabled ReasonKind = "environment_disabled"
+	// ReasonPrerequisiteFailed serves the default value of a flag with an
+	// unmet prerequisite
+	ReasonPrerequisiteFailed ReasonKind = "prerequisite_failed"
+	// ReasonArchived serves the default value of an archived flag
+	ReasonArchived ReasonKind = "archived"
+	// ReasonOverride serves the value pinned to the context key
+	ReasonOverride ReasonKind = "override"
+	// ReasonMaintenance serves the default value of every flag of an
+	// organization in maintenance mode
+	ReasonMaintenance ReasonKind = "maintenance"
+)
+
+// Reason explains an evaluation. RuleID is the rule served, and Bucket the
+// rollout bucket of the context when that rule is a rollout. PrerequisiteID
+// is the first prerequisite found unmet.
+type Reason struct {
+	Kind           ReasonKind          `json:"kind"`
+	RuleID         *primitive.ObjectID `json:"rule_id,omitempty"`
+	Bucket         *int                `json:"bucket,omitempty"`
+	PrerequisiteID *primitive.ObjectID `json:"prerequisite_id,omitempty"`
+}
+
+// Detail is the value an evaluation served along with why
+type Detail struct {
+	Value  string
+	Reason Reason
+}
+
+// Evaluate resolves the value served by the flag's live revision in the
+// given environment. A disabled environment always serves the default value.
+// Otherwise an override pinned to the context key wins. Failing that, rules
+// are checked by ascending priority, in revision order among equal
+// priorities, and the first enabled rule applying to the environment,
+// scheduled at now, whose predicate and window match wins, falling back to
+// the revision's default value.
+func Evaluate(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context Context,
+	now time.Time,
+) (string, error) {
+	detail, err := EvaluateDetail(flag, environment, context, now)
+	return detail.Value, err
+}
+
+// EvaluateDetail evaluates the flag like Evaluate, explaining the value
+func EvaluateDetail(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context Context,
+	now time.Time,
+) (Detail, error) {
+	return EvaluateRevisionDetail(flag, flag.LiveRevision(), environment, context, now)
+}
+
+// EvaluateRevisionDetail evaluates the flag like EvaluateDetail as if the
+// revision were live, whatever its status. The revision is nil for flags
+// without a live revision.
+func EvaluateRevisionDetail(
+	flag *featureflagmodel.FeatureFlagRecord,
+	revision *featureflagmodel.Revision,
+	environment string,
+	context Context,
+	now time.Time,
+) (Detail, error) {
+	var flagEnvironment *featureflagmodel.FeatureFlagEnvironment
+	for index, env := range flag.Environments {
+		if env.Name == environment {
+			flagEnvironment = &flag.Environments[index]
+			break
+		}
+	}
+
+	if flagEnvironment == nil {
+		return Detail{}, ErrEnvironmentNotFound
+	}
+
+	if revision == nil {
+		return Detail{}, ErrNoLiveRevision
+	}
+
+	if flag.IsArchived {
+		return Detail{
+			Value:  revision.DefaultValue,
+			Reason: Reason{Kind: ReasonArchived},
+		}, nil
+	}
+
+	if !flagEnvironment.IsEnabled {
+		return Detail{
+			Value:  revision.DefaultValue,
+			Reason: Reason{Kind: ReasonEnvironmentDisabled},
+		}, nil
+	}
+
+	if key, ok := bucketKey(context[KeyAttribute]); ok {
+		if value, ok := flag.Overrides[key]; ok {
+			return Detail{
+				Value:  value,
+				Reason: Reason{Kind: ReasonOverride},
+			}, nil
+		}
+	}
+
+	for _, index := range featureflagmodel.RulesByPriority(revision.Rules) {
+		rule := revision.Rules[index]
+		if !rule.IsEnabled || !rule.AppliesTo(environment) {
+			continue
+		}
+
+		if !MatchesConditions(&rule, context) {
+			continue
+		}
+
+		if !rule.ActiveAt(now) {
+			continue
+		}
+
+		if rule.Window != nil && !MatchesWindow(rule.Window, context, now) {
+			continue
+		}
+
+		reason := Reason{Kind: ReasonRuleMatch, RuleID: &revision.Rules[index].ID}
+		if rule.Rollout != nil {
+			bucket, ok := rolloutBucket(rule.Rollout, flag, context)
+			if !ok || bucket >= rule.Rollout.Percentage {
+				continue
+			}
+			reason.Bucket = &bucket
+		}
+
+		return Detail{Value: rule.Value, Reason: reason}, nil
+	}
+
+	return Detail{
+		Value:  revision.DefaultValue,
+		Reason: Reason{Kind: ReasonDefault},
+	}, nil
+}
+
+// EvaluateMaintenance serves the default value of the flag's live revision
+// in the environment, ignoring its rules, overrides and prerequisites, the
+// way flags evaluate while their organization is in maintenance mode
+func EvaluateMaintenance(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+) (Detail, error) {
+	if flag.FindEnvironment(environment) == nil {
+		return Detail{}, ErrEnvironmentNotFound
+	}
+
+	revision := flag.LiveRevision()
+	if revision == nil {
+		return Detail{}, ErrNoLiveRevision
+	}
+
+	return Detail{
+		Value:  revision.DefaultValue,
+		Reason: Reason{Kind: ReasonMaintenance},
+	}, nil
+}
+
+// FlagLookup finds the flags prerequisites point to
+type FlagLookup func(id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error)
+
+// EvaluateWithPrerequisites evaluates the flag once all its prerequisites,
+// and theirs, are met. Until then the flag serves the default value of its
+// live revision. A prerequisite that can't be found or evaluated is unmet.
+func EvaluateWithPrerequisites(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context Context,
+	now time.Time,
+	lookup FlagLookup,
+) (string, error) {
+	detail, err := EvaluateDetailWithPrerequisites(flag, environment, context, now, lookup)
+	return detail.Value, err
+}
+
+// EvaluateDetailWithPrerequisites evaluates the flag like
+// EvaluateWithPrerequisites, explaining the value
+func EvaluateDetailWithPrerequisites(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context Context,
+	now time.Time,
+	lookup FlagLookup,
+) (Detail, error) {
+	return evaluateWithPrerequisites(flag, environment, context, now, lookup, map[primitive.ObjectID]bool{})
+}
+
+func evaluateWithPrerequisites(
+	flag *featureflagmodel.FeatureFlagRecord,
+	environment string,
+	context Context,
+	now time.Time,
+	lookup FlagLookup,
+	evaluating map[primitive.ObjectID]bool,
+) (Detail, error) {
+	detail, err := EvaluateDetail(flag, environment, context, now)
+	if err != nil || len(flag.Prerequisites) == 0 {
+		return detail, err
+	}
+
+	// Cycles are rejected when prerequisites are set, this only keeps a
+	// stale one from recursing forever
+	evaluating[flag.ID] = true
+	defer delete(evaluating, flag.ID)
+
+	for index, prerequisite := range flag.Prerequisites {
+		if !prerequisiteMet(prerequisite, environment, context, now, lookup, evaluating) {
+			return Detail{
+				Value: flag.LiveRevision().DefaultValue,
+				Reason: Reason{
+					Kind:           ReasonPrerequisiteFailed,
+					PrerequisiteID: &flag.Prerequisites[index].FeatureFlagID,
+				},
+			}, nil
+		}
+	}
+
+	return detail, nil
+}
+
+func prerequisiteMet(
+	prerequisite featureflagmodel.Prerequisite,
+	environment string,
+	context Context,
+	now time.Time,
+	lookup FlagLookup,
+	evaluating map[primitive.ObjectID]bool,
+) bool {
+	if evaluating[prerequisite.FeatureFlagID] {
+		return false
+	}
+
+	flag, err := lookup(prerequisite.FeatureFlagID)
+	if err != nil {
+		return false
+	}
+
+	detail, err := evaluateWithPrerequisites(flag, environment, context, now, lookup, evaluating)
+	if err != nil {
+		return false
+	}
+	value := detail.Value
+
+	// Compared as typed values, so a number prerequisite of 1 is met by 1.0
+	served, servedErr := flag.TypedValue(value)
+	required, requiredErr := flag.TypedValue(prerequisite.Value)
+	if servedErr != nil || requiredErr != nil {
+		return value == prerequisite.Value
+	}
+
+	return reflect.DeepEqual(served, required)
+}
+
+// MatchesPredicate checks a predicate in the "attribute: value" format
+// against the context. Predicates in any other format never match.
+func MatchesPredicate(predicate string, context Context) bool {
+	return MatchesOperator(featureflagmodel.OperatorEquals, predicate, context)
+}
+
+// MatchesConditions combines the predicate and the conditions of the rule,
+// it stops at the first condition deciding the outcome
+func MatchesConditions(rule *featureflagmodel.Rule, context Context) bool {
+	conditions := rule.AllConditions()
+	if len(conditions) == 0 {
+		return false
+	}
+
+	matchAny := rule.Combinator == featureflagmodel.CombinatorAny
+	for _, condition := range conditions {
+		if MatchesOperator(condition.Operator, condition.Predicate, context) == matchAny {
+			return matchAny
+		}
+	}
+
+	return !matchAny
+}
+
+// MatchesOperator compares the context attribute of a predicate in the
+// "attribute: value" format with its value. Contexts missing the attribute,
+// or holding a value of the wrong type for the operator, never match.
+func MatchesOperator(operator featureflagmodel.RuleOperator, predicate string, context Context) bool {
+	attribute, value, found := strings.Cut(predicate, ":")
+	if !found {
+		return false
+	}
+	value = strings.TrimSpace(value)
+
+	contextValue, ok := context[strings.TrimSpace(attribute)]
+	if !ok || contextValue == nil {
+		return false
+	}
+
+	switch operator {
+	case "", featureflagmodel.OperatorEquals:
+		return fmt.Sprint(contextValue) == value
+	case featureflagmodel.OperatorNotEquals:
+		return fmt.Sprint(contextValue) != value
+	case featureflagmodel.OperatorIn:
+		for _, candidate := range strings.Split(value, ",") {
+			if fmt.Sprint(contextValue) == strings.TrimSpace(candidate) {
+				return true
+			}
+		}
+		return false
+	case featureflagmodel.OperatorContains:
+		return contains(contextValue, value)
+	case featureflagmodel.OperatorStartsWith:
+		text, ok := contextValue.(string)
+		return ok && strings.HasPrefix(text, value)
+	case featureflagmodel.OperatorGreaterThan, featureflagmodel.OperatorLessThan:
+		number, ok := toNumber(contextValue)
+		if !ok {
+			return false
+		}
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		if operator == featureflagmodel.OperatorGreaterThan {
+			return number > threshold
+		}
+		return number < threshold
+	case featureflagmodel.OperatorSemverGT:
+		text, ok := contextValue.(string)
+		if !ok {
+			return false
+		}
+		result, ok := featureflagmodel.CompareVersions(text, value)
+		return ok && result > 0
+	}
+
+	return false
+}
+
+// contains looks for the value within string attributes, and among the
+// elements of list attributes
+func contains(contextValue interface{}, value string) bool {
+	switch typed := contextValue.(type) {
+	case string:
+		return strings.Contains(typed, value)
+	case []interface{}:
+		for _, element := range typed {
+			if fmt.Sprint(element) == value {
+				return true
+			}
+		}
+	case []string:
+		for _, element := range typed {
+			if element == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// toNumber reads numeric attributes, whatever type they were decoded into.
+// Numbers sent as strings aren't numeric attributes.
+func toNumber(contextValue interface{}) (float64, bool) {
+	switch typed := contextValue.(type) {
+	case float64:
+		return typed, true
+	case float32:
+		return float64(typed), true
+	case int:
+		return float64(typed), true
+	case int32:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case json.Number:
+		number, err := typed.Float64()
+		return number, err == nil
+	}
+
+	return 0, false
+}
+
+func MatchesWindow(window *featureflagmodel.TimeWindow, context Context, now time.Time) bool {
+	location, err := windowLocation(window, context)
+	if err != nil {
+		return false
+	}
+
+	start, err := time.Parse(featureflagmodel.TimeWindowLayout, window.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse(featureflagmodel.TimeWindowLayout, window.End)
+	if err != nil {
+		return false
+	}
+
+	localNow := now.In(location)
+	minutes := localNow.Hour()*60 + localNow.Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+func windowLocation(window *featureflagmodel.TimeWindow, context Context) (*time.Location, error) {
+	if window.TimezoneAttribute != "" {
+		if timezone, ok := context[window.TimezoneAttribute].(string); ok {
+			return time.LoadLocation(timezone)
+		}
+	}
+
+	if window.Timezone != "" {
+		return time.LoadLocation(window.Timezone)
+	}
+
+	return time.UTC, nil
+}
+
+// InRollout reports whether the context falls within the rollout. Excluded
+// keys are checked before bucketing so they always stay in control.
+// Contexts without the attribute the rollout buckets by are never in it,
+// there is nothing to keep their bucket stable across requests.
+func InRollout(rollout *featureflagmodel.Rollout, flag *featureflagmodel.FeatureFlagRecord, context Context) bool {
+	bucket, ok := rolloutBucket(rollout, flag, context)
+	return ok && bucket < rollout.Percentage
+}
+
+// rolloutBucket finds the bucket of the context, ok is false for contexts
+// never in the rollout whatever its percentage
+func rolloutBucket(
+	rollout *featureflagmodel.Rollout,
+	flag *featureflagmodel.FeatureFlagRecord,
+	context Context,
+) (int, bool) {
+	attribute := rollout.BucketBy
+	if attribute == "" {
+		attribute = KeyAttribute
+	}
+
+	key, ok := bucketKey(context[attribute])
+	if !ok {
+		return 0, false
+	}
+
+	for _, excludedKey := range rollout.ExcludeKeys {
+		if excludedKey == key {
+			return 0, false
+		}
+	}
+
+	return Bucket(flag.ID.Hex(), key), true
+}
+
+// bucketKey reads the value contexts are bucketed by. Numbers and booleans
+// are bucketed by how they print, so an id sent as 42 or "42" lands in the
+// same bucket. Anything else can't identify a context.
+func bucketKey(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, value != ""
+	case bool, float64, float32, int, int32, int64:
+		return fmt.Sprint(value), true
+	}
+
+	return "", false
+}
+
+// Bucket deterministically maps a key to a bucket between 0 and 99 for
+// the given flag
+func Bucket(flagID, key string) int {
+	hash := fnv.New32a()
+	// Writes to a hash never return an error
+	_, _ = hash.Write([]byte(flagID + "." + key))
+
+	return int(hash.Sum32() % 100)
+}
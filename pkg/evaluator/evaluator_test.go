@@ -0,0 +1,966 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/evaluator"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EvaluatorTestSuite struct {
+	suite.Suite
+}
+
+func newFlag(rules []featureflagmodel.Rule) *featureflagmodel.FeatureFlagRecord {
+	return &featureflagmodel.FeatureFlagRecord{
+		Name: "cool feature",
+		Type: featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{
+			{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Live,
+				DefaultValue: "false",
+				Rules:        rules,
+			},
+		},
+		Environments: []featureflagmodel.FeatureFlagEnvironment{
+			{
+				Name:      "prod",
+				IsEnabled: true,
+			},
+		},
+	}
+}
+
+func businessHoursRule() featureflagmodel.Rule {
+	return featureflagmodel.Rule{
+		Predicate: "plan: pro",
+		Value:     "true",
+		Env:       "prod",
+		IsEnabled: true,
+		Window: &featureflagmodel.TimeWindow{
+			Start:             "09:00",
+			End:               "17:00",
+			TimezoneAttribute: "timezone",
+		},
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateMatchingRule() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{"plan": "free"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateEnvironmentScopedRules() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "staging",
+			IsEnabled: true,
+		},
+		{
+			Predicate: "plan: enterprise",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	flag.Environments = append(flag.Environments, featureflagmodel.FeatureFlagEnvironment{
+		Name:      "staging",
+		IsEnabled: true,
+	})
+
+	value, err := evaluator.Evaluate(flag, "staging", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	value, err = evaluator.Evaluate(flag, "staging", evaluator.Context{"plan": "enterprise"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{"plan": "enterprise"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateUnscopedRuleAppliesToEveryEnvironment() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "plan: pro",
+			Value:     "true",
+			IsEnabled: true,
+		},
+	})
+	flag.Environments = append(flag.Environments, featureflagmodel.FeatureFlagEnvironment{
+		Name:      "staging",
+		IsEnabled: true,
+	})
+
+	for _, environment := range []string{"prod", "staging"} {
+		value, err := evaluator.Evaluate(flag, environment, evaluator.Context{"plan": "pro"}, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "true", value)
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateUnknownEnvironment() {
+	t := suite.T()
+
+	_, err := evaluator.Evaluate(newFlag(nil), "staging", evaluator.Context{}, time.Now())
+	assert.ErrorIs(t, err, evaluator.ErrEnvironmentNotFound)
+}
+
+func (suite *EvaluatorTestSuite) TestWindowUsesContextTimezone() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{businessHoursRule()})
+	// 14:00 UTC is inside business hours in UTC but 23:00 in Tokyo
+	now := time.Date(2024, time.March, 4, 14, 0, 0, 0, time.UTC)
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{
+		"plan":     "pro",
+		"timezone": "Asia/Tokyo",
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	// 01:00 UTC is outside business hours in UTC but 10:00 in Tokyo
+	now = time.Date(2024, time.March, 4, 1, 0, 0, 0, time.UTC)
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{
+		"plan":     "pro",
+		"timezone": "Asia/Tokyo",
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+}
+
+func (suite *EvaluatorTestSuite) TestWindowFallsBackToRuleTimezone() {
+	t := suite.T()
+
+	rule := businessHoursRule()
+	rule.Window.Timezone = "America/New_York"
+	flag := newFlag([]featureflagmodel.Rule{rule})
+	// 14:00 UTC is 09:00 in New York
+	now := time.Date(2024, time.March, 4, 14, 0, 0, 0, time.UTC)
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{"plan": "pro"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+}
+
+func (suite *EvaluatorTestSuite) TestWindowInvalidContextTimezone() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{businessHoursRule()})
+	now := time.Date(2024, time.March, 4, 14, 0, 0, 0, time.UTC)
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{
+		"plan":     "pro",
+		"timezone": "Mars/Olympus_Mons",
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func (suite *EvaluatorTestSuite) TestWindowSpanningMidnight() {
+	t := suite.T()
+
+	window := &featureflagmodel.TimeWindow{
+		Start: "22:00",
+		End:   "06:00",
+	}
+
+	assert.True(t, evaluator.MatchesWindow(window, evaluator.Context{},
+		time.Date(2024, time.March, 4, 23, 30, 0, 0, time.UTC)))
+	assert.True(t, evaluator.MatchesWindow(window, evaluator.Context{},
+		time.Date(2024, time.March, 4, 5, 59, 0, 0, time.UTC)))
+	assert.False(t, evaluator.MatchesWindow(window, evaluator.Context{},
+		time.Date(2024, time.March, 4, 12, 0, 0, 0, time.UTC)))
+}
+
+func (suite *EvaluatorTestSuite) TestTimeWindowValidation() {
+	t := suite.T()
+
+	assert.NoError(t, (&featureflagmodel.TimeWindow{
+		Start:    "09:00",
+		End:      "17:00",
+		Timezone: "Europe/Lisbon",
+	}).Validate())
+	assert.ErrorIs(t, (&featureflagmodel.TimeWindow{
+		Start:    "09:00",
+		End:      "17:00",
+		Timezone: "Europe/Atlantis",
+	}).Validate(), featureflagmodel.ErrInvalidTimezone)
+	assert.ErrorIs(t, (&featureflagmodel.TimeWindow{
+		Start: "9am",
+		End:   "17:00",
+	}).Validate(), featureflagmodel.ErrInvalidTimeWindow)
+}
+
+func (suite *EvaluatorTestSuite) TestScheduledRule() {
+	t := suite.T()
+
+	startsAt := time.Date(2024, time.November, 29, 0, 0, 0, 0, time.UTC)
+	endsAt := time.Date(2024, time.December, 2, 0, 0, 0, 0, time.UTC)
+	promotion := featureflagmodel.Rule{
+		Predicate: "plan: pro",
+		Value:     "true",
+		Env:       "prod",
+		IsEnabled: true,
+		StartsAt:  &startsAt,
+		EndsAt:    &endsAt,
+	}
+	flag := newFlag([]featureflagmodel.Rule{promotion})
+	context := evaluator.Context{"plan": "pro"}
+
+	testCases := []struct {
+		now      time.Time
+		expected string
+	}{
+		{startsAt.Add(-time.Second), "false"},
+		{startsAt, "true"},
+		{startsAt.Add(36 * time.Hour), "true"},
+		{endsAt, "false"},
+		{endsAt.Add(time.Hour), "false"},
+	}
+
+	for _, testCase := range testCases {
+		value, err := evaluator.Evaluate(flag, "prod", context, testCase.now)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, value, testCase.now)
+	}
+
+	// A rule without a schedule is always active, and either end can be open
+	unscheduled := promotion
+	unscheduled.StartsAt = nil
+	unscheduled.EndsAt = nil
+	assert.True(t, unscheduled.ActiveAt(time.Time{}))
+
+	openEnded := promotion
+	openEnded.EndsAt = nil
+	assert.False(t, openEnded.ActiveAt(startsAt.Add(-time.Second)))
+	assert.True(t, openEnded.ActiveAt(endsAt.AddDate(10, 0, 0)))
+}
+
+func (suite *EvaluatorTestSuite) TestRuleScheduleValidation() {
+	t := suite.T()
+
+	startsAt := time.Date(2024, time.November, 29, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(72 * time.Hour)
+
+	assert.NoError(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{StartsAt: &startsAt, EndsAt: &endsAt},
+		{StartsAt: &startsAt},
+		{EndsAt: &endsAt},
+	}))
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{StartsAt: &endsAt, EndsAt: &startsAt},
+	}), featureflagmodel.ErrInvalidRuleSchedule)
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{StartsAt: &startsAt, EndsAt: &startsAt},
+	}), featureflagmodel.ErrInvalidRuleSchedule)
+}
+
+func (suite *EvaluatorTestSuite) TestRolloutExcludedKeyStaysInControl() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+			Rollout: &featureflagmodel.Rollout{
+				Percentage:  50,
+				ExcludeKeys: []string{"bad-account"},
+			},
+		},
+	})
+
+	// Bump the percentage until the excluded key's bucket is included so the
+	// assertion proves exclusion wins over bucketing
+	bucket := evaluator.Bucket(flag.ID.Hex(), "bad-account")
+	flag.Revisions[0].Rules[0].Rollout.Percentage = bucket + 1
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{
+		"key":  "bad-account",
+		"plan": "pro",
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	flag.Revisions[0].Rules[0].Rollout.ExcludeKeys = nil
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{
+		"key":  "bad-account",
+		"plan": "pro",
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+}
+
+func (suite *EvaluatorTestSuite) TestRolloutBucketIsStable() {
+	t := suite.T()
+
+	flag := newFlag(nil)
+	rollout := &featureflagmodel.Rollout{Percentage: 50}
+
+	for _, key := range []string{"user-1", "user-2", "user-3"} {
+		first := evaluator.InRollout(rollout, flag, evaluator.Context{"key": key})
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, first, evaluator.InRollout(rollout, flag, evaluator.Context{"key": key}))
+		}
+	}
+
+	assert.False(t, evaluator.InRollout(&featureflagmodel.Rollout{Percentage: 100}, flag, evaluator.Context{}))
+	assert.False(t, evaluator.InRollout(&featureflagmodel.Rollout{Percentage: 0}, flag, evaluator.Context{"key": "user-1"}))
+}
+
+func (suite *EvaluatorTestSuite) TestRolloutBucketsByAttribute() {
+	t := suite.T()
+
+	flag := newFlag(nil)
+	flag.ID = primitive.NewObjectID()
+
+	// The bucket follows the device whatever user or key the requests carry
+	bucket := evaluator.Bucket(flag.ID.Hex(), "device-7")
+	included := &featureflagmodel.Rollout{Percentage: bucket + 1, BucketBy: "deviceId"}
+	excluded := &featureflagmodel.Rollout{Percentage: bucket, BucketBy: "deviceId"}
+	for _, key := range []string{"user-1", "user-2", "user-3"} {
+		context := evaluator.Context{"key": key, "deviceId": "device-7"}
+		assert.True(t, evaluator.InRollout(included, flag, context), key)
+		assert.False(t, evaluator.InRollout(excluded, flag, context), key)
+	}
+
+	// Numeric ids bucket like the same id sent as a string
+	bucket = evaluator.Bucket(flag.ID.Hex(), "42")
+	accounts := &featureflagmodel.Rollout{Percentage: bucket + 1, BucketBy: "accountId"}
+	assert.True(t, evaluator.InRollout(accounts, flag, evaluator.Context{"accountId": float64(42)}))
+	assert.True(t, evaluator.InRollout(accounts, flag, evaluator.Context{"accountId": "42"}))
+
+	// Without the attribute the context is left out, even with a key
+	everyone := &featureflagmodel.Rollout{Percentage: 100, BucketBy: "deviceId"}
+	assert.False(t, evaluator.InRollout(everyone, flag, evaluator.Context{"key": "user-1"}))
+	assert.False(t, evaluator.InRollout(everyone, flag, evaluator.Context{"deviceId": ""}))
+	assert.False(t, evaluator.InRollout(everyone, flag, evaluator.Context{"deviceId": []interface{}{"a"}}))
+
+	// Excluded keys are matched against the attribute too
+	everyone.ExcludeKeys = []string{"device-7"}
+	assert.False(t, evaluator.InRollout(everyone, flag, evaluator.Context{"deviceId": "device-7"}))
+	assert.True(t, evaluator.InRollout(everyone, flag, evaluator.Context{"deviceId": "device-8"}))
+}
+
+// prerequisiteFlags is an always-on dependent flag and the flag it depends
+// on, which serves its default value of requiredValue
+func prerequisiteFlags(requiredValue string) (*featureflagmodel.FeatureFlagRecord, evaluator.FlagLookup) {
+	prerequisite := newFlag(nil)
+	prerequisite.ID = primitive.NewObjectID()
+	prerequisite.Revisions[0].DefaultValue = "true"
+
+	dependent := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	dependent.ID = primitive.NewObjectID()
+	dependent.Prerequisites = []featureflagmodel.Prerequisite{
+		{FeatureFlagID: prerequisite.ID, Value: requiredValue},
+	}
+
+	lookup := func(id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+		if id == prerequisite.ID {
+			return prerequisite, nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	return dependent, lookup
+}
+
+func (suite *EvaluatorTestSuite) TestPrerequisiteMet() {
+	t := suite.T()
+
+	flag, lookup := prerequisiteFlags("true")
+
+	value, err := evaluator.EvaluateWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+}
+
+func (suite *EvaluatorTestSuite) TestPrerequisiteUnmetServesDefault() {
+	t := suite.T()
+
+	flag, lookup := prerequisiteFlags("false")
+
+	value, err := evaluator.EvaluateWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+
+	// A prerequisite that's gone is never met either
+	flag.Prerequisites[0].FeatureFlagID = primitive.NewObjectID()
+	flag.Prerequisites[0].Value = "true"
+	value, err = evaluator.EvaluateWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateDetailReasons() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: free",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+			Rollout:   &featureflagmodel.Rollout{Percentage: 100},
+		},
+	})
+	flag.ID = primitive.NewObjectID()
+	rules := flag.Revisions[0].Rules
+
+	detail, err := evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", detail.Value)
+	assert.Equal(t, evaluator.ReasonRuleMatch, detail.Reason.Kind)
+	assert.Equal(t, rules[0].ID, *detail.Reason.RuleID)
+	assert.Nil(t, detail.Reason.Bucket)
+
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "free", "key": "user-1"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, evaluator.ReasonRuleMatch, detail.Reason.Kind)
+	assert.Equal(t, rules[1].ID, *detail.Reason.RuleID)
+	assert.Equal(t, evaluator.Bucket(flag.ID.Hex(), "user-1"), *detail.Reason.Bucket)
+
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "enterprise"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonDefault}, detail.Reason)
+
+	flag.Environments[0].IsEnabled = false
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonEnvironmentDisabled}, detail.Reason)
+}
+
+func overlappingRules(firstPriority, secondPriority int) []featureflagmodel.Rule {
+	return []featureflagmodel.Rule{
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: pro",
+			Value:     "pro",
+			Env:       "prod",
+			IsEnabled: true,
+			Priority:  firstPriority,
+		},
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "country: br",
+			Value:     "br",
+			Env:       "prod",
+			IsEnabled: true,
+			Priority:  secondPriority,
+		},
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestOverlappingRulesKeepRevisionOrder() {
+	t := suite.T()
+
+	flag := newFlag(overlappingRules(0, 0))
+	flag.Type = featureflagmodel.String
+	rules := flag.Revisions[0].Rules
+	context := evaluator.Context{"plan": "pro", "country": "br"}
+
+	// Same priority, the first rule of the revision wins every time
+	for i := 0; i < 10; i++ {
+		detail, err := evaluator.EvaluateDetail(flag, "prod", context, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "pro", detail.Value)
+		assert.Equal(t, rules[0].ID, *detail.Reason.RuleID)
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestOverlappingRulesByPriority() {
+	t := suite.T()
+
+	flag := newFlag(overlappingRules(2, 1))
+	flag.Type = featureflagmodel.String
+	rules := flag.Revisions[0].Rules
+
+	detail, err := evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro", "country": "br"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "br", detail.Value)
+	assert.Equal(t, rules[1].ID, *detail.Reason.RuleID)
+
+	// A higher priority rule that doesn't match falls through to the next
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro", "country": "us"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "pro", detail.Value)
+	assert.Equal(t, rules[0].ID, *detail.Reason.RuleID)
+
+	rules[1].IsEnabled = false
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro", "country": "br"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "pro", detail.Value)
+
+	// Neither matches, the default value is the fallthrough
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "free"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonDefault}, detail.Reason)
+}
+
+func (suite *EvaluatorTestSuite) TestRulesByPriority() {
+	t := suite.T()
+
+	rules := []featureflagmodel.Rule{
+		{Priority: 3},
+		{Priority: 1},
+		{},
+		{Priority: 1},
+		{Priority: 3},
+	}
+
+	assert.Equal(t, []int{2, 1, 3, 0, 4}, featureflagmodel.RulesByPriority(rules))
+	assert.Empty(t, featureflagmodel.RulesByPriority(nil))
+}
+
+func (suite *EvaluatorTestSuite) TestMatchesOperator() {
+	t := suite.T()
+
+	context := evaluator.Context{
+		"plan":     "pro",
+		"country":  "BR",
+		"email":    "ada@togglelabs.io",
+		"seats":    float64(12),
+		"age":      30,
+		"version":  "2.4.1",
+		"tags":     []interface{}{"beta", "staff"},
+		"verified": true,
+	}
+
+	testCases := []struct {
+		operator  featureflagmodel.RuleOperator
+		predicate string
+		expected  bool
+	}{
+		{"", "plan: pro", true},
+		{"", "plan: free", false},
+		{featureflagmodel.OperatorEquals, "plan: pro", true},
+		{featureflagmodel.OperatorEquals, "verified: true", true},
+		{featureflagmodel.OperatorEquals, "seats: 12", true},
+		{featureflagmodel.OperatorEquals, "plan: free", false},
+		{featureflagmodel.OperatorNotEquals, "plan: free", true},
+		{featureflagmodel.OperatorNotEquals, "plan: pro", false},
+		{featureflagmodel.OperatorNotEquals, "missing: pro", false},
+		{featureflagmodel.OperatorIn, "country: US, BR, PT", true},
+		{featureflagmodel.OperatorIn, "country: US,PT", false},
+		{featureflagmodel.OperatorIn, "seats: 10, 12", true},
+		{featureflagmodel.OperatorContains, "email: @togglelabs", true},
+		{featureflagmodel.OperatorContains, "email: @example", false},
+		{featureflagmodel.OperatorContains, "tags: beta", true},
+		{featureflagmodel.OperatorContains, "tags: alpha", false},
+		{featureflagmodel.OperatorContains, "seats: 1", false},
+		{featureflagmodel.OperatorStartsWith, "email: ada@", true},
+		{featureflagmodel.OperatorStartsWith, "email: togglelabs", false},
+		{featureflagmodel.OperatorStartsWith, "seats: 1", false},
+		{featureflagmodel.OperatorGreaterThan, "seats: 10", true},
+		{featureflagmodel.OperatorGreaterThan, "seats: 12", false},
+		{featureflagmodel.OperatorGreaterThan, "age: 18", true},
+		{featureflagmodel.OperatorGreaterThan, "plan: 10", false},
+		{featureflagmodel.OperatorGreaterThan, "version: 1", false},
+		{featureflagmodel.OperatorLessThan, "seats: 12.5", true},
+		{featureflagmodel.OperatorLessThan, "age: 30", false},
+		{featureflagmodel.OperatorLessThan, "verified: 2", false},
+		{featureflagmodel.OperatorSemverGT, "version: 2.4.0", true},
+		{featureflagmodel.OperatorSemverGT, "version: v2.10.0", false},
+		{featureflagmodel.OperatorSemverGT, "version: 2.4.1", false},
+		{featureflagmodel.OperatorSemverGT, "version: 2.4.1-rc.1", true},
+		{featureflagmodel.OperatorSemverGT, "plan: 1.0.0", false},
+		{featureflagmodel.OperatorSemverGT, "seats: 1.0.0", false},
+		{featureflagmodel.OperatorGreaterThan, "missing: 1", false},
+		{featureflagmodel.OperatorEquals, "malformed predicate", false},
+		{"regex", "plan: pro", false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected,
+			evaluator.MatchesOperator(testCase.operator, testCase.predicate, context),
+			"%s %s", testCase.operator, testCase.predicate)
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateRuleOperator() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			Predicate: "seats: 10",
+			Operator:  featureflagmodel.OperatorGreaterThan,
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	now := time.Now()
+
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{"seats": float64(25)}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	// A string attribute can't be ordered, the rule doesn't match
+	value, err = evaluator.Evaluate(flag, "prod", evaluator.Context{"seats": "25"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func compoundRule(combinator featureflagmodel.Combinator) featureflagmodel.Rule {
+	return featureflagmodel.Rule{
+		Value:     "true",
+		Env:       "prod",
+		IsEnabled: true,
+		Conditions: []featureflagmodel.Condition{
+			{Predicate: "country: US"},
+			{Predicate: "plan: pro"},
+		},
+		Combinator: combinator,
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestCompoundRuleAll() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{compoundRule(featureflagmodel.CombinatorAll)})
+	now := time.Now()
+
+	testCases := []struct {
+		context  evaluator.Context
+		expected string
+	}{
+		{evaluator.Context{"country": "US", "plan": "pro"}, "true"},
+		{evaluator.Context{"country": "US", "plan": "free"}, "false"},
+		{evaluator.Context{"country": "BR", "plan": "pro"}, "false"},
+		{evaluator.Context{}, "false"},
+	}
+
+	for _, testCase := range testCases {
+		value, err := evaluator.Evaluate(flag, "prod", testCase.context, now)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, value, testCase.context)
+	}
+
+	// Rules without a combinator need every condition to hold
+	unset := compoundRule("")
+	assert.True(t, evaluator.MatchesConditions(&unset, evaluator.Context{"country": "US", "plan": "pro"}))
+	assert.False(t, evaluator.MatchesConditions(&unset, evaluator.Context{"country": "US"}))
+}
+
+func (suite *EvaluatorTestSuite) TestCompoundRuleAny() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{compoundRule(featureflagmodel.CombinatorAny)})
+	now := time.Now()
+
+	testCases := []struct {
+		context  evaluator.Context
+		expected string
+	}{
+		{evaluator.Context{"country": "US", "plan": "pro"}, "true"},
+		{evaluator.Context{"country": "US", "plan": "free"}, "true"},
+		{evaluator.Context{"country": "BR", "plan": "pro"}, "true"},
+		{evaluator.Context{"country": "BR", "plan": "free"}, "false"},
+	}
+
+	for _, testCase := range testCases {
+		value, err := evaluator.Evaluate(flag, "prod", testCase.context, now)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, value, testCase.context)
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestCompoundRuleMixedOperators() {
+	t := suite.T()
+
+	rule := featureflagmodel.Rule{
+		Predicate: "country: US, CA",
+		Operator:  featureflagmodel.OperatorIn,
+		Conditions: []featureflagmodel.Condition{
+			{Predicate: "seats: 10", Operator: featureflagmodel.OperatorGreaterThan},
+			{Predicate: "email: @togglelabs.io", Operator: featureflagmodel.OperatorContains},
+		},
+	}
+	context := evaluator.Context{
+		"country": "CA",
+		"seats":   float64(25),
+		"email":   "ada@example.com",
+	}
+
+	// Two of the three conditions hold
+	assert.False(t, evaluator.MatchesConditions(&rule, context))
+
+	rule.Combinator = featureflagmodel.CombinatorAny
+	assert.True(t, evaluator.MatchesConditions(&rule, context))
+
+	context["email"] = "ada@togglelabs.io"
+	rule.Combinator = featureflagmodel.CombinatorAll
+	assert.True(t, evaluator.MatchesConditions(&rule, context))
+
+	assert.False(t, evaluator.MatchesConditions(&featureflagmodel.Rule{}, context))
+}
+
+func (suite *EvaluatorTestSuite) TestCompoundRuleValidation() {
+	t := suite.T()
+
+	assert.NoError(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		compoundRule(featureflagmodel.CombinatorAny),
+		compoundRule(""),
+	}))
+
+	invalidOperator := compoundRule(featureflagmodel.CombinatorAll)
+	invalidOperator.Conditions = append(invalidOperator.Conditions,
+		featureflagmodel.Condition{Predicate: "plan: pro", Operator: "matches"})
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{invalidOperator}),
+		featureflagmodel.ErrInvalidRuleOperator)
+
+	invalidOperand := compoundRule(featureflagmodel.CombinatorAll)
+	invalidOperand.Conditions[0].Operator = featureflagmodel.OperatorLessThan
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{invalidOperand}),
+		featureflagmodel.ErrInvalidRuleOperand)
+
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		compoundRule("every"),
+	}), featureflagmodel.ErrInvalidRuleCombinator)
+
+	incomplete := compoundRule(featureflagmodel.CombinatorAll)
+	incomplete.Conditions = append(incomplete.Conditions, featureflagmodel.Condition{})
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{incomplete}),
+		featureflagmodel.ErrIncompleteCondition)
+}
+
+func (suite *EvaluatorTestSuite) TestCompareVersions() {
+	t := suite.T()
+
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0.0+build.5", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.11", "1.0.0-beta.2", 1},
+		{"1.0.0-rc.1", "1.0.0-beta", 1},
+	}
+
+	for _, testCase := range testCases {
+		result, ok := featureflagmodel.CompareVersions(testCase.a, testCase.b)
+		assert.True(t, ok)
+		assert.Equal(t, testCase.expected, result, "%s %s", testCase.a, testCase.b)
+	}
+
+	for _, invalid := range []string{"1.0", "1.0.0.0", "one.two.three", "1.0.0-", "1.0.0-rc..1"} {
+		_, ok := featureflagmodel.CompareVersions(invalid, "1.0.0")
+		assert.False(t, ok, invalid)
+	}
+}
+
+func (suite *EvaluatorTestSuite) TestRuleOperatorValidation() {
+	t := suite.T()
+
+	assert.NoError(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{Predicate: "plan: pro"},
+		{Predicate: "country: BR, US", Operator: featureflagmodel.OperatorIn},
+		{Predicate: "seats: 10.5", Operator: featureflagmodel.OperatorLessThan},
+		{Predicate: "version: v1.2.3", Operator: featureflagmodel.OperatorSemverGT},
+	}))
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{Predicate: "plan: pro", Operator: "matches"},
+	}), featureflagmodel.ErrInvalidRuleOperator)
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{Predicate: "seats: ten", Operator: featureflagmodel.OperatorGreaterThan},
+	}), featureflagmodel.ErrInvalidRuleOperand)
+	assert.ErrorIs(t, featureflagmodel.ValidateRules([]featureflagmodel.Rule{
+		{Predicate: "version: latest", Operator: featureflagmodel.OperatorSemverGT},
+	}), featureflagmodel.ErrInvalidRuleOperand)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateDetailArchived() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	flag.IsArchived = true
+
+	// An archived flag serves its default even to a matching context
+	detail, err := evaluator.EvaluateDetail(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonArchived}, detail.Reason)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateDetailPrerequisiteFailed() {
+	t := suite.T()
+
+	flag, lookup := prerequisiteFlags("false")
+
+	detail, err := evaluator.EvaluateDetailWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.ReasonPrerequisiteFailed, detail.Reason.Kind)
+	assert.Equal(t, flag.Prerequisites[0].FeatureFlagID, *detail.Reason.PrerequisiteID)
+
+	// Once the prerequisite is met the reason is the flag's own
+	flag.Prerequisites[0].Value = "true"
+	detail, err = evaluator.EvaluateDetailWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", detail.Value)
+	assert.Equal(t, evaluator.ReasonRuleMatch, detail.Reason.Kind)
+}
+
+func (suite *EvaluatorTestSuite) TestPrerequisiteCycleIsUnmet() {
+	t := suite.T()
+
+	flag, _ := prerequisiteFlags("true")
+	flag.Prerequisites[0].FeatureFlagID = flag.ID
+	lookup := func(id primitive.ObjectID) (*featureflagmodel.FeatureFlagRecord, error) {
+		return flag, nil
+	}
+
+	value, err := evaluator.EvaluateWithPrerequisites(flag, "prod", evaluator.Context{"plan": "pro"}, time.Now(), lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
+
+func (suite *EvaluatorTestSuite) TestOverrideBeatsMatchingRule() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	flag.Overrides = map[string]string{"qa-user": "false", "42": "true"}
+
+	detail, err := evaluator.EvaluateDetail(flag, "prod", evaluator.Context{
+		evaluator.KeyAttribute: "qa-user",
+		"plan":                 "pro",
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonOverride}, detail.Reason)
+
+	// Keys are matched the way rollouts bucket them, numbers included
+	value, err := evaluator.Evaluate(flag, "prod", evaluator.Context{evaluator.KeyAttribute: 42}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", value)
+
+	// Any other key goes through the rules as usual
+	detail, err = evaluator.EvaluateDetail(flag, "prod", evaluator.Context{
+		evaluator.KeyAttribute: "someone-else",
+		"plan":                 "pro",
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "true", detail.Value)
+	assert.Equal(t, evaluator.ReasonRuleMatch, detail.Reason.Kind)
+}
+
+func (suite *EvaluatorTestSuite) TestOverrideIgnoredWhenDisabled() {
+	t := suite.T()
+
+	flag := newFlag(nil)
+	flag.Overrides = map[string]string{"qa-user": "true"}
+	flag.Environments[0].IsEnabled = false
+
+	// Turning the flag off wins over any override
+	detail, err := evaluator.EvaluateDetail(flag, "prod", evaluator.Context{evaluator.KeyAttribute: "qa-user"}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.ReasonEnvironmentDisabled, detail.Reason.Kind)
+}
+
+func (suite *EvaluatorTestSuite) TestEvaluateMaintenance() {
+	t := suite.T()
+
+	flag := newFlag([]featureflagmodel.Rule{
+		{
+			ID:        primitive.NewObjectID(),
+			Predicate: "plan: pro",
+			Value:     "true",
+			Env:       "prod",
+			IsEnabled: true,
+		},
+	})
+	flag.Overrides = map[string]string{"qa-user": "true"}
+
+	detail, err := evaluator.EvaluateMaintenance(flag, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "false", detail.Value)
+	assert.Equal(t, evaluator.Reason{Kind: evaluator.ReasonMaintenance}, detail.Reason)
+
+	_, err = evaluator.EvaluateMaintenance(flag, "staging")
+	assert.ErrorIs(t, err, evaluator.ErrEnvironmentNotFound)
+
+	flag.Revisions[0].Status = featureflagmodel.Draft
+	_, err = evaluator.EvaluateMaintenance(flag, "prod")
+	assert.ErrorIs(t, err, evaluator.ErrNoLiveRevision)
+}
+
+func TestEvaluatorTestSuite(t *testing.T) {
+	suite.Run(t, new(EvaluatorTestSuite))
+}
@@ -0,0 +1,98 @@
+package auditmodel
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const AuditCollectionName = "audit"
+
+const (
+	MemberIDMetadataKey           = "member_id"
+	OldPermissionLevelMetadataKey = "old_permission_level"
+	NewPermissionLevelMetadataKey = "new_permission_level"
+	APIKeyIDMetadataKey           = "api_key_id"
+	WebhookIDMetadataKey          = "webhook_id"
+)
+
+const (
+	MemberRoleChanged    = "Member %s role changed from %s to %s"
+	MemberRemoved        = "Member %s removed"
+	APIKeyCreated        = "API key %s created"
+	APIKeyRevoked        = "API key %s revoked"
+	WebhookCreated       = "Webhook for %s created"
+	OwnershipTransferred = "Ownership transferred to %s"
+	MaintenanceEnabled   = "Maintenance mode enabled"
+	MaintenanceDisabled  = "Maintenance mode disabled"
+	// OrganizationUpdated entries carry the new value of every changed
+	// setting as metadata
+	OrganizationUpdated = "Organization settings updated"
+)
+
+type AuditModel struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func New(db *mongo.Database) *AuditModel {
+	return &AuditModel{
+		db:         db,
+		collection: db.Collection(AuditCollectionName),
+	}
+}
+
+type AuditEntry struct {
+	UserID    primitive.ObjectID     `json:"user_id" bson:"user_id"`
+	Action    string                 `json:"action" bson:"action"`
+	Timestamp primitive.DateTime     `json:"timestamp" bson:"timestamp"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+}
+
+// AuditRecord is the organization counterpart of a feature flag timeline,
+// it tracks changes made to the organization itself
+type AuditRecord struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	Entries        []AuditEntry       `json:"entries" bson:"entries"`
+}
+
+func NewAuditEntry(userID primitive.ObjectID, action string, metadata map[string]interface{}) *AuditEntry {
+	return &AuditEntry{
+		UserID:    userID,
+		Action:    action,
+		Timestamp: primitive.NewDateTimeFromTime(time.Now().UTC()),
+		Metadata:  metadata,
+	}
+}
+
+// UpdateOne appends the entry to the organization's audit record, creating
+// the record on the first entry
+func (am *AuditModel) UpdateOne(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	entry *AuditEntry,
+) error {
+	_, err := am.collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "organization_id", Value: organizationID}},
+		bson.D{{Key: "$push", Value: bson.M{"entries": entry}}},
+		options.Update().SetUpsert(true),
+	)
+
+	return err
+}
+
+func (am *AuditModel) FindByID(ctx context.Context, organizationID primitive.ObjectID) (*AuditRecord, error) {
+	record := new(AuditRecord)
+	err := am.collection.FindOne(ctx, bson.D{{Key: "organization_id", Value: organizationID}}).Decode(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
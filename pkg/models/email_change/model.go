@@ -0,0 +1,42 @@
+package emailchangemodel
+
+import (
+	"time"
+
+	singleusetokenmodel "github.com/Roll-Play/togglelabs/pkg/models/single_use_token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const EmailChangeCollectionName = "email_change"
+
+// EmailChange is the email the user asked to change to
+type EmailChange struct {
+	Email string `json:"email" bson:"email"`
+}
+
+type EmailChangeModel = singleusetokenmodel.SingleUseTokenModel[EmailChange]
+
+// EmailChangeRecord holds the hash of the token confirming a user owns the
+// new email, the token itself is only ever sent to that email. UsedAt is set
+// once the email is changed or the token revoked.
+type EmailChangeRecord = singleusetokenmodel.SingleUseTokenRecord[EmailChange]
+
+func New(db *mongo.Database) *EmailChangeModel {
+	return singleusetokenmodel.New[EmailChange](db, EmailChangeCollectionName)
+}
+
+// NewEmailChangeRecord generates a token confirming the change of the user
+// email, valid for the given duration, returning the record to store along
+// with the plain token
+func NewEmailChangeRecord(
+	userID primitive.ObjectID,
+	email string,
+	expireIn time.Duration,
+) (*EmailChangeRecord, string, error) {
+	return singleusetokenmodel.NewRecord(userID, EmailChange{Email: email}, expireIn)
+}
+
+func HashToken(token string) string {
+	return singleusetokenmodel.HashToken(token)
+}
@@ -0,0 +1,214 @@
+package featureflagmodel
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Approvals are written with targeted updates of the revision they concern
+// rather than by replacing the revisions array, so concurrent approvals
+// can't overwrite each other. Each update only applies while the revision
+// is still in the state it was read in, otherwise mongo.ErrNoDocuments is
+// returned and the caller has to read the flag again.
+
+func activeFlagFilter(organizationID, id primitive.ObjectID) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	}
+}
+
+// AddApproval records the user's approval on a draft revision they haven't
+// approved yet and returns the flag as it is after the update
+func (ffm *FeatureFlagModel) AddApproval(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID,
+	userID primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	filter := append(activeFlagFilter(organizationID, id), bson.E{
+		Key: "revisions",
+		Value: bson.M{"$elemMatch": bson.M{
+			"_id":       revisionID,
+			"status":    Draft,
+			"approvals": bson.M{"$ne": userID},
+		}},
+	})
+	update := withUpdatedAt(ctx, bson.D{{
+		Key:   "$push",
+		Value: bson.M{"revisions.$[revision].approvals": userID},
+	}})
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"revision._id": revisionID},
+		}}).
+		SetReturnDocument(options.After)
+
+	record := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// PromoteRevision turns a fully approved draft or scheduled revision live,
+// archiving the live one and bumping the version. It only applies if
+// neither the version nor the live revision of the record changed since it
+// was read.
+func (ffm *FeatureFlagModel) PromoteRevision(
+	ctx context.Context,
+	record *FeatureFlagRecord,
+	revisionID primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	promoted := bson.M{"$elemMatch": bson.M{
+		"_id":    revisionID,
+		"status": bson.M{"$in": bson.A{Draft, Scheduled}},
+	}}
+	filter := append(
+		activeFlagFilter(record.OrganizationID, record.ID),
+		bson.E{Key: "version", Value: record.Version},
+	)
+
+	var lastRevisionID primitive.ObjectID
+	if live := record.LiveRevision(); live != nil {
+		lastRevisionID = live.ID
+		filter = append(filter, bson.E{Key: "revisions", Value: bson.M{"$all": bson.A{
+			promoted,
+			bson.M{"$elemMatch": bson.M{"_id": live.ID, "status": Live}},
+		}}})
+	} else {
+		filter = append(filter,
+			bson.E{Key: "revisions", Value: promoted},
+			bson.E{Key: "revisions.status", Value: bson.M{"$ne": Live}},
+		)
+	}
+
+	update := withUpdatedAt(ctx, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "version", Value: record.Version + 1},
+		{Key: "revisions.$[live].status", Value: Archived},
+		{Key: "revisions.$[promoted].status", Value: Live},
+		{Key: "revisions.$[promoted].last_revision_id", Value: lastRevisionID},
+	}}})
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"live.status": Live},
+			bson.M{"promoted._id": revisionID},
+		}}).
+		SetReturnDocument(options.After)
+
+	promotedRecord := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(promotedRecord); err != nil {
+		return nil, err
+	}
+
+	return promotedRecord, nil
+}
+
+// ScheduleRevision sets a fully approved draft revision to go live at the
+// given time
+func (ffm *FeatureFlagModel) ScheduleRevision(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID primitive.ObjectID,
+	scheduledAt primitive.DateTime,
+) (*FeatureFlagRecord, error) {
+	filter := append(activeFlagFilter(organizationID, id), bson.E{
+		Key:   "revisions",
+		Value: bson.M{"$elemMatch": bson.M{"_id": revisionID, "status": Draft}},
+	})
+	update := withUpdatedAt(ctx, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "revisions.$[revision].status", Value: Scheduled},
+		{Key: "revisions.$[revision].scheduled_at", Value: scheduledAt},
+	}}})
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"revision._id": revisionID},
+		}}).
+		SetReturnDocument(options.After)
+
+	record := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// RejectRevision rejects a draft revision and returns the flag as it is
+// after the update
+func (ffm *FeatureFlagModel) RejectRevision(
+	ctx context.Context,
+	organizationID,
+	id,
+	revisionID primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	filter := append(activeFlagFilter(organizationID, id), bson.E{
+		Key:   "revisions",
+		Value: bson.M{"$elemMatch": bson.M{"_id": revisionID, "status": Draft}},
+	})
+	update := withUpdatedAt(ctx, bson.D{{
+		Key:   "$set",
+		Value: bson.M{"revisions.$[revision].status": Rejected},
+	}})
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"revision._id": revisionID},
+		}}).
+		SetReturnDocument(options.After)
+
+	record := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// RollbackRevision turns the live revision back into a draft and the
+// archived revision it replaced live again. The version moves forward like
+// on any other change, so a rolled back flag never shares a version with
+// what it was before. It only applies if neither the version nor the
+// state of the two revisions changed since the record was read.
+func (ffm *FeatureFlagModel) RollbackRevision(
+	ctx context.Context,
+	record *FeatureFlagRecord,
+	liveRevisionID,
+	previousRevisionID primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	filter := append(
+		activeFlagFilter(record.OrganizationID, record.ID),
+		bson.E{Key: "version", Value: record.Version},
+		bson.E{Key: "revisions", Value: bson.M{"$all": bson.A{
+			bson.M{"$elemMatch": bson.M{"_id": liveRevisionID, "status": Live}},
+			bson.M{"$elemMatch": bson.M{"_id": previousRevisionID, "status": Archived}},
+		}}},
+	)
+	update := withUpdatedAt(ctx, bson.D{
+		{Key: "$inc", Value: bson.M{"version": 1}},
+		{Key: "$set", Value: bson.D{
+			{Key: "revisions.$[live].status", Value: Draft},
+			{Key: "revisions.$[previous].status", Value: Live},
+		}},
+		{Key: "$unset", Value: bson.M{"revisions.$[live].last_revision_id": ""}},
+	})
+	opts := options.FindOneAndUpdate().
+		SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"live._id": liveRevisionID},
+			bson.M{"previous._id": previousRevisionID},
+		}}).
+		SetReturnDocument(options.After)
+
+	rolledBack := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(rolledBack); err != nil {
+		return nil, err
+	}
+
+	return rolledBack, nil
+}
@@ -0,0 +1,89 @@
+package featureflagmodel
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrBoundsNotNumber = errors.New("only number flags can have a min or max")
+	ErrInvalidBounds   = errors.New("min is greater than max")
+)
+
+// NumberBounds optionally constrain the values a number flag serves, both
+// ends are inclusive
+type NumberBounds struct {
+	Min *float64 `json:"min,omitempty" bson:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" bson:"max,omitempty"`
+}
+
+// BoundsError reports a value past the min, or the max, of the flag
+type BoundsError struct {
+	Value float64
+	Bound float64
+	Below bool
+}
+
+func (be *BoundsError) Error() string {
+	if be.Below {
+		return fmt.Sprintf("value %g is below the min of %g", be.Value, be.Bound)
+	}
+
+	return fmt.Sprintf("value %g is above the max of %g", be.Value, be.Bound)
+}
+
+func (nb NumberBounds) IsSet() bool {
+	return nb.Min != nil || nb.Max != nil
+}
+
+// Validate checks the bounds can be put on a flag of the type
+func (nb NumberBounds) Validate(flagType FlagType) error {
+	if !nb.IsSet() {
+		return nil
+	}
+
+	if flagType != Number {
+		return ErrBoundsNotNumber
+	}
+
+	if nb.Min != nil && nb.Max != nil && *nb.Min > *nb.Max {
+		return ErrInvalidBounds
+	}
+
+	return nil
+}
+
+// Check reports a *BoundsError for values out of bounds. Values that
+// aren't numbers are ParseValue's concern and pass.
+func (nb NumberBounds) Check(value string) error {
+	if !nb.IsSet() {
+		return nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+
+	if nb.Min != nil && number < *nb.Min {
+		return &BoundsError{Value: number, Bound: *nb.Min, Below: true}
+	}
+
+	if nb.Max != nil && number > *nb.Max {
+		return &BoundsError{Value: number, Bound: *nb.Max}
+	}
+
+	return nil
+}
+
+// CheckRules points at the first rule serving a value out of bounds
+func (nb NumberBounds) CheckRules(rules []Rule) error {
+	for index, rule := range rules {
+		if err := nb.Check(rule.Value); err != nil {
+			return &RuleValueError{Index: index, Err: err}
+		}
+	}
+
+	return nil
+}
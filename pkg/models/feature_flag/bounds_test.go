@@ -0,0 +1,75 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BoundsTestSuite struct {
+	suite.Suite
+}
+
+func bounds(min, max float64) featureflagmodel.NumberBounds {
+	return featureflagmodel.NumberBounds{Min: &min, Max: &max}
+}
+
+func (suite *BoundsTestSuite) TestValidateBounds() {
+	t := suite.T()
+
+	assert.NoError(t, bounds(100, 30000).Validate(featureflagmodel.Number))
+	assert.NoError(t, bounds(5, 5).Validate(featureflagmodel.Number))
+	assert.NoError(t, featureflagmodel.NumberBounds{}.Validate(featureflagmodel.Boolean))
+	assert.ErrorIs(t, bounds(10, 1).Validate(featureflagmodel.Number), featureflagmodel.ErrInvalidBounds)
+	assert.ErrorIs(t, bounds(1, 10).Validate(featureflagmodel.String), featureflagmodel.ErrBoundsNotNumber)
+}
+
+func (suite *BoundsTestSuite) TestCheckValues() {
+	t := suite.T()
+
+	timeout := bounds(100, 30000)
+	for _, value := range []string{"100", "2500", "30000"} {
+		assert.NoError(t, timeout.Check(value), value)
+	}
+
+	var boundsError *featureflagmodel.BoundsError
+	assert.ErrorAs(t, timeout.Check("99.5"), &boundsError)
+	assert.True(t, boundsError.Below)
+	assert.EqualError(t, boundsError, "value 99.5 is below the min of 100")
+
+	assert.ErrorAs(t, timeout.Check("30001"), &boundsError)
+	assert.False(t, boundsError.Below)
+	assert.EqualError(t, boundsError, "value 30001 is above the max of 30000")
+
+	// A single bound leaves the other end open
+	min := 0.0
+	nonNegative := featureflagmodel.NumberBounds{Min: &min}
+	assert.NoError(t, nonNegative.Check("1e9"))
+	assert.Error(t, nonNegative.Check("-1"))
+}
+
+func (suite *BoundsTestSuite) TestCheckRulesReportsIndex() {
+	t := suite.T()
+
+	rules := []featureflagmodel.Rule{
+		rule("plan: pro", "500"),
+		rule("plan: free", "50000"),
+	}
+
+	err := bounds(100, 30000).CheckRules(rules)
+	var ruleValueError *featureflagmodel.RuleValueError
+	assert.ErrorAs(t, err, &ruleValueError)
+	assert.Equal(t, 1, ruleValueError.Index)
+
+	var boundsError *featureflagmodel.BoundsError
+	assert.ErrorAs(t, err, &boundsError)
+	assert.Equal(t, 30000.0, boundsError.Bound)
+
+	assert.NoError(t, bounds(100, 50000).CheckRules(rules))
+}
+
+func TestBoundsTestSuite(t *testing.T) {
+	suite.Run(t, new(BoundsTestSuite))
+}
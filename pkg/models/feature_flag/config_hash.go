@@ -0,0 +1,152 @@
+package featureflagmodel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ConfigHashVersion leads every config hash. The hash covers an explicit
+// projection of the live config rather than the stored structs, so fields
+// added to them don't move every hash. It's bumped whenever the projection
+// itself changes, which is the only time existing hashes stop matching.
+const ConfigHashVersion = 1
+
+type hashedConfig struct {
+	Version      int                 `json:"version"`
+	Type         FlagType            `json:"type"`
+	DefaultValue string              `json:"default_value"`
+	Rules        []hashedRule        `json:"rules"`
+	Environments []hashedEnvironment `json:"environments"`
+}
+
+// hashedRule leaves the rule ID out since it's regenerated on every
+// revision
+type hashedRule struct {
+	Predicate  string            `json:"predicate"`
+	Value      string            `json:"value"`
+	Env        string            `json:"env"`
+	IsEnabled  bool              `json:"is_enabled"`
+	Window     *hashedTimeWindow `json:"window"`
+	Rollout    *hashedRollout    `json:"rollout"`
+	StartsAt   *time.Time        `json:"starts_at"`
+	EndsAt     *time.Time        `json:"ends_at"`
+	Priority   int               `json:"priority"`
+	Operator   RuleOperator      `json:"operator"`
+	Conditions []hashedCondition `json:"conditions"`
+	Combinator Combinator        `json:"combinator"`
+}
+
+type hashedTimeWindow struct {
+	Start             string `json:"start"`
+	End               string `json:"end"`
+	Timezone          string `json:"timezone"`
+	TimezoneAttribute string `json:"timezone_attribute"`
+}
+
+type hashedRollout struct {
+	Percentage  int      `json:"percentage"`
+	ExcludeKeys []string `json:"exclude_keys"`
+	BucketBy    string   `json:"bucket_by"`
+}
+
+type hashedCondition struct {
+	Predicate string       `json:"predicate"`
+	Operator  RuleOperator `json:"operator"`
+}
+
+type hashedEnvironment struct {
+	Name      string `json:"name"`
+	IsEnabled bool   `json:"is_enabled"`
+}
+
+func newHashedRule(rule Rule) hashedRule {
+	hashed := hashedRule{
+		Predicate:  rule.Predicate,
+		Value:      rule.Value,
+		Env:        rule.Env,
+		IsEnabled:  rule.IsEnabled,
+		StartsAt:   rule.StartsAt,
+		EndsAt:     rule.EndsAt,
+		Priority:   rule.Priority,
+		Operator:   rule.Operator,
+		Conditions: []hashedCondition{},
+		Combinator: rule.Combinator,
+	}
+
+	if rule.Window != nil {
+		hashed.Window = &hashedTimeWindow{
+			Start:             rule.Window.Start,
+			End:               rule.Window.End,
+			Timezone:          rule.Window.Timezone,
+			TimezoneAttribute: rule.Window.TimezoneAttribute,
+		}
+	}
+
+	if rule.Rollout != nil {
+		hashed.Rollout = &hashedRollout{
+			Percentage:  rule.Rollout.Percentage,
+			ExcludeKeys: append([]string{}, rule.Rollout.ExcludeKeys...),
+			BucketBy:    rule.Rollout.BucketBy,
+		}
+	}
+
+	for _, condition := range rule.Conditions {
+		hashed.Conditions = append(hashed.Conditions, hashedCondition{
+			Predicate: condition.Predicate,
+			Operator:  condition.Operator,
+		})
+	}
+
+	return hashed
+}
+
+// ConfigHash fingerprints the live configuration of the flag: its type, the
+// default value and rules of the live revision and its environments
+func (ffr *FeatureFlagRecord) ConfigHash() (string, error) {
+	config := hashedConfig{
+		Version:      ConfigHashVersion,
+		Type:         ffr.Type,
+		Rules:        []hashedRule{},
+		Environments: []hashedEnvironment{},
+	}
+
+	if revision := ffr.LiveRevision(); revision != nil {
+		config.DefaultValue = revision.DefaultValue
+		for _, rule := range revision.Rules {
+			config.Rules = append(config.Rules, newHashedRule(rule))
+		}
+	}
+
+	for _, environment := range ffr.Environments {
+		config.Environments = append(config.Environments, hashedEnvironment{
+			Name:      environment.Name,
+			IsEnabled: environment.IsEnabled,
+		})
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("v%d:%s", ConfigHashVersion, hex.EncodeToString(sum[:])), nil
+}
+
+// HasDrifted reports whether the live config moved away from the expected
+// hash without the change having been acknowledged
+func (ffr *FeatureFlagRecord) HasDrifted() (bool, string, error) {
+	liveHash, err := ffr.ConfigHash()
+	if err != nil {
+		return false, "", err
+	}
+
+	if ffr.ExpectedConfigHash == "" || ffr.ExpectedConfigHash == liveHash {
+		return false, liveHash, nil
+	}
+
+	return ffr.DriftAcknowledgedHash != liveHash, liveHash, nil
+}
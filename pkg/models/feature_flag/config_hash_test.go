@@ -0,0 +1,58 @@
+package featureflagmodel_test
+
+import (
+	"strings"
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func hashedFlag() *featureflagmodel.FeatureFlagRecord {
+	return &featureflagmodel.FeatureFlagRecord{
+		Type: featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{{
+			ID:           primitive.NewObjectID(),
+			Status:       featureflagmodel.Live,
+			DefaultValue: "false",
+			Rules: []featureflagmodel.Rule{{
+				ID:        primitive.NewObjectID(),
+				Predicate: "plan: pro",
+				Value:     "true",
+				IsEnabled: true,
+				Rollout:   &featureflagmodel.Rollout{Percentage: 50},
+			}},
+		}},
+		Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	hash, err := hashedFlag().ConfigHash()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "v1:"))
+
+	// Pinned so a change to what the hash covers comes with a new version
+	assert.Equal(t, "v1:e9dde7404345cb6e57ef6174a5f5b044bf9244e5812cba9b23bc2670b03156f8", hash)
+
+	// Rule IDs, overrides and the revision bookkeeping aren't config
+	flag := hashedFlag()
+	flag.Overrides = map[string]string{"qa-user": "true"}
+	flag.Revisions[0].Approvals = []primitive.ObjectID{primitive.NewObjectID()}
+	sameHash, err := flag.ConfigHash()
+	assert.NoError(t, err)
+	assert.Equal(t, hash, sameHash)
+
+	flag = hashedFlag()
+	flag.Revisions[0].Rules[0].Rollout.Percentage = 60
+	changedHash, err := flag.ConfigHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+
+	flag = hashedFlag()
+	flag.Environments[0].IsEnabled = false
+	changedHash, err = flag.ConfigHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+}
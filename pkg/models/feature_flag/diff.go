@@ -0,0 +1,103 @@
+package featureflagmodel
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DefaultValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+type RuleChange struct {
+	Old Rule `json:"old"`
+	New Rule `json:"new"`
+}
+
+type RevisionDiff struct {
+	BaseRevisionID *primitive.ObjectID `json:"base_revision_id,omitempty"`
+	RevisionID     primitive.ObjectID  `json:"revision_id"`
+	DefaultValue   *DefaultValueChange `json:"default_value,omitempty"`
+	AddedRules     []Rule              `json:"added_rules"`
+	RemovedRules   []Rule              `json:"removed_rules"`
+	ModifiedRules  []RuleChange        `json:"modified_rules"`
+}
+
+// ruleIdentity keys a rule by what it targets. Rule IDs can't be used since
+// every revision generates new ones.
+type ruleIdentity struct {
+	env       string
+	predicate string
+}
+
+func identityOf(rule Rule) ruleIdentity {
+	return ruleIdentity{env: rule.Env, predicate: rule.Predicate}
+}
+
+func sameRule(a, b Rule) bool {
+	a.ID = primitive.NilObjectID
+	b.ID = primitive.NilObjectID
+
+	return reflect.DeepEqual(a, b)
+}
+
+// DiffRevisions compares target against base. Rules are matched by
+// environment and predicate, so reordering rules alone is not a change.
+func DiffRevisions(base, target *Revision) RevisionDiff {
+	diff := RevisionDiff{
+		RevisionID:    target.ID,
+		AddedRules:    []Rule{},
+		RemovedRules:  []Rule{},
+		ModifiedRules: []RuleChange{},
+	}
+
+	if base.ID != primitive.NilObjectID {
+		baseID := base.ID
+		diff.BaseRevisionID = &baseID
+	}
+
+	if base.DefaultValue != target.DefaultValue {
+		diff.DefaultValue = &DefaultValueChange{
+			Old: base.DefaultValue,
+			New: target.DefaultValue,
+		}
+	}
+
+	baseRules := make(map[ruleIdentity][]Rule)
+	for _, rule := range base.Rules {
+		identity := identityOf(rule)
+		baseRules[identity] = append(baseRules[identity], rule)
+	}
+
+	for _, rule := range target.Rules {
+		identity := identityOf(rule)
+		candidates := baseRules[identity]
+		if len(candidates) == 0 {
+			diff.AddedRules = append(diff.AddedRules, rule)
+			continue
+		}
+
+		baseRule := candidates[0]
+		baseRules[identity] = candidates[1:]
+		if !sameRule(baseRule, rule) {
+			diff.ModifiedRules = append(diff.ModifiedRules, RuleChange{
+				Old: baseRule,
+				New: rule,
+			})
+		}
+	}
+
+	for _, rule := range base.Rules {
+		identity := identityOf(rule)
+		if len(baseRules[identity]) == 0 {
+			continue
+		}
+
+		diff.RemovedRules = append(diff.RemovedRules, baseRules[identity][0])
+		baseRules[identity] = baseRules[identity][1:]
+	}
+
+	return diff
+}
@@ -0,0 +1,87 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DiffTestSuite struct {
+	suite.Suite
+}
+
+func rule(predicate, value string) featureflagmodel.Rule {
+	return featureflagmodel.Rule{
+		ID:        primitive.NewObjectID(),
+		Predicate: predicate,
+		Value:     value,
+		Env:       "prod",
+		IsEnabled: true,
+	}
+}
+
+func (suite *DiffTestSuite) TestReorderedRulesAreNotModified() {
+	t := suite.T()
+
+	base := &featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		DefaultValue: "false",
+		Rules: []featureflagmodel.Rule{
+			rule("plan: pro", "true"),
+			rule("country: br", "true"),
+		},
+	}
+	target := &featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		DefaultValue: "false",
+		Rules: []featureflagmodel.Rule{
+			rule("country: br", "true"),
+			rule("plan: pro", "true"),
+		},
+	}
+
+	diff := featureflagmodel.DiffRevisions(base, target)
+	assert.Nil(t, diff.DefaultValue)
+	assert.Empty(t, diff.AddedRules)
+	assert.Empty(t, diff.RemovedRules)
+	assert.Empty(t, diff.ModifiedRules)
+	assert.Equal(t, base.ID, *diff.BaseRevisionID)
+}
+
+func (suite *DiffTestSuite) TestDiffReportsChanges() {
+	t := suite.T()
+
+	base := &featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		DefaultValue: "false",
+		Rules: []featureflagmodel.Rule{
+			rule("plan: pro", "true"),
+			rule("country: br", "true"),
+		},
+	}
+	target := &featureflagmodel.Revision{
+		ID:           primitive.NewObjectID(),
+		DefaultValue: "true",
+		Rules: []featureflagmodel.Rule{
+			rule("beta: yes", "true"),
+			rule("plan: pro", "false"),
+		},
+	}
+
+	diff := featureflagmodel.DiffRevisions(base, target)
+	assert.Equal(t, &featureflagmodel.DefaultValueChange{Old: "false", New: "true"}, diff.DefaultValue)
+	assert.Equal(t, 1, len(diff.AddedRules))
+	assert.Equal(t, "beta: yes", diff.AddedRules[0].Predicate)
+	assert.Equal(t, 1, len(diff.RemovedRules))
+	assert.Equal(t, "country: br", diff.RemovedRules[0].Predicate)
+	assert.Equal(t, 1, len(diff.ModifiedRules))
+	assert.Equal(t, "true", diff.ModifiedRules[0].Old.Value)
+	assert.Equal(t, "false", diff.ModifiedRules[0].New.Value)
+}
+
+func TestDiffTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
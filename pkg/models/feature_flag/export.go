@@ -0,0 +1,138 @@
+package featureflagmodel
+
+import (
+	"sort"
+	"time"
+)
+
+// ExportFormatVersion is bumped whenever ExportDocument changes in a way
+// older imports can't read
+const ExportFormatVersion = 1
+
+// ExportDocument is the portable form of an organization's flags. It only
+// carries what the flags serve, ids and history are left out so exports of
+// the same config are identical.
+type ExportDocument struct {
+	Version int            `json:"version" yaml:"version"`
+	Flags   []ExportedFlag `json:"flags" yaml:"flags"`
+}
+
+type ExportedFlag struct {
+	Name         string                   `json:"name" yaml:"name"`
+	Type         FlagType                 `json:"type" yaml:"type"`
+	DefaultValue string                   `json:"default_value" yaml:"default_value"`
+	Rules        []ExportedRule           `json:"rules" yaml:"rules"`
+	Environments []FeatureFlagEnvironment `json:"environments" yaml:"environments"`
+	Tags         []string                 `json:"tags" yaml:"tags"`
+	Project      string                   `json:"project,omitempty" yaml:"project,omitempty"`
+}
+
+type ExportedRule struct {
+	Predicate  string      `json:"predicate" yaml:"predicate"`
+	Value      string      `json:"value" yaml:"value"`
+	Env        string      `json:"env,omitempty" yaml:"env,omitempty"`
+	IsEnabled  bool        `json:"is_enabled" yaml:"is_enabled"`
+	Window     *TimeWindow `json:"window,omitempty" yaml:"window,omitempty"`
+	Rollout    *Rollout    `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+	StartsAt   *time.Time  `json:"starts_at,omitempty" yaml:"starts_at,omitempty"`
+	EndsAt     *time.Time  `json:"ends_at,omitempty" yaml:"ends_at,omitempty"`
+	Priority   int         `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Operator   string      `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Combinator string      `json:"combinator,omitempty" yaml:"combinator,omitempty"`
+}
+
+// NewExportedFlag captures the live config of the flag. Without a live
+// revision the flag serves the default value it was created with.
+func NewExportedFlag(record *FeatureFlagRecord) ExportedFlag {
+	flag := ExportedFlag{
+		Name:         record.Name,
+		Type:         record.Type,
+		Rules:        []ExportedRule{},
+		Environments: record.Environments,
+		Tags:         record.Tags,
+	}
+
+	if flag.Environments == nil {
+		flag.Environments = []FeatureFlagEnvironment{}
+	}
+
+	if flag.Tags == nil {
+		flag.Tags = []string{}
+	}
+
+	if record.Project != nil {
+		flag.Project = record.Project.Name
+	}
+
+	if revision := record.LiveRevision(); revision != nil {
+		flag.DefaultValue = revision.DefaultValue
+		for _, rule := range revision.Rules {
+			flag.Rules = append(flag.Rules, ExportedRule{
+				Predicate:  rule.Predicate,
+				Value:      rule.Value,
+				Env:        rule.Env,
+				IsEnabled:  rule.IsEnabled,
+				Window:     rule.Window,
+				Rollout:    rule.Rollout,
+				StartsAt:   rule.StartsAt,
+				EndsAt:     rule.EndsAt,
+				Priority:   rule.Priority,
+				Operator:   rule.Operator,
+				Conditions: rule.Conditions,
+				Combinator: rule.Combinator,
+			})
+		}
+	} else if len(record.Revisions) > 0 {
+		flag.DefaultValue = record.Revisions[0].DefaultValue
+	}
+
+	return flag
+}
+
+// NewExportDocument exports the flags ordered by name, and by id for flags
+// sharing a name across environments, so the output is stable
+func NewExportDocument(records []FeatureFlagRecord) *ExportDocument {
+	sorted := make([]FeatureFlagRecord, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+
+		return sorted[i].ID.Hex() < sorted[j].ID.Hex()
+	})
+
+	document := &ExportDocument{
+		Version: ExportFormatVersion,
+		Flags:   make([]ExportedFlag, 0, len(sorted)),
+	}
+	for index := range sorted {
+		document.Flags = append(document.Flags, NewExportedFlag(&sorted[index]))
+	}
+
+	return document
+}
+
+// ToRules turns the exported rules back into rules of a new revision
+func (ef *ExportedFlag) ToRules() []Rule {
+	rules := make([]Rule, 0, len(ef.Rules))
+	for _, rule := range ef.Rules {
+		rules = append(rules, NewRuleRecord(Rule{
+			Predicate:  rule.Predicate,
+			Value:      rule.Value,
+			Env:        rule.Env,
+			IsEnabled:  rule.IsEnabled,
+			Window:     rule.Window,
+			Rollout:    rule.Rollout,
+			StartsAt:   rule.StartsAt,
+			EndsAt:     rule.EndsAt,
+			Priority:   rule.Priority,
+			Operator:   rule.Operator,
+			Conditions: rule.Conditions,
+			Combinator: rule.Combinator,
+		}))
+	}
+
+	return rules
+}
@@ -0,0 +1,87 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExportTestSuite) TestExportUsesLiveRevision() {
+	t := suite.T()
+
+	record := featureflagmodel.FeatureFlagRecord{
+		ID:   primitive.NewObjectID(),
+		Name: "beta",
+		Type: featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{
+			{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Archived,
+				DefaultValue: "false",
+			},
+			{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Live,
+				DefaultValue: "true",
+				Rules:        []featureflagmodel.Rule{rule("plan: pro", "false")},
+			},
+		},
+	}
+
+	flag := featureflagmodel.NewExportedFlag(&record)
+	assert.Equal(t, "true", flag.DefaultValue)
+	assert.Equal(t, 1, len(flag.Rules))
+	assert.Equal(t, "plan: pro", flag.Rules[0].Predicate)
+	assert.Equal(t, []string{}, flag.Tags)
+
+	rules := flag.ToRules()
+	assert.Equal(t, 1, len(rules))
+	assert.False(t, rules[0].ID.IsZero())
+}
+
+func (suite *ExportTestSuite) TestExportWithoutLiveRevision() {
+	t := suite.T()
+
+	record := featureflagmodel.FeatureFlagRecord{
+		ID:   primitive.NewObjectID(),
+		Name: "beta",
+		Type: featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{
+			{
+				ID:           primitive.NewObjectID(),
+				Status:       featureflagmodel.Draft,
+				DefaultValue: "false",
+				Rules:        []featureflagmodel.Rule{rule("plan: pro", "true")},
+			},
+		},
+	}
+
+	flag := featureflagmodel.NewExportedFlag(&record)
+	assert.Equal(t, "false", flag.DefaultValue)
+	assert.Empty(t, flag.Rules)
+}
+
+func (suite *ExportTestSuite) TestExportIsSortedByName() {
+	t := suite.T()
+
+	document := featureflagmodel.NewExportDocument([]featureflagmodel.FeatureFlagRecord{
+		{ID: primitive.NewObjectID(), Name: "zeta"},
+		{ID: primitive.NewObjectID(), Name: "alpha"},
+	})
+
+	assert.Equal(t, featureflagmodel.ExportFormatVersion, document.Version)
+	assert.Equal(t, 2, len(document.Flags))
+	assert.Equal(t, "alpha", document.Flags[0].Name)
+	assert.Equal(t, "zeta", document.Flags[1].Name)
+}
+
+func TestExportTestSuite(t *testing.T) {
+	suite.Run(t, new(ExportTestSuite))
+}
@@ -0,0 +1,235 @@
+package featureflagmodel
+
+import (
+	"errors"
+	"reflect"
+
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+)
+
+type ImportMode = string
+
+const (
+	// ImportCreateOnly creates the flags missing from the organization and
+	// leaves the existing ones untouched
+	ImportCreateOnly ImportMode = "create_only"
+	// ImportUpsert also proposes the imported config for existing flags
+	ImportUpsert ImportMode = "upsert"
+	// ImportReplace upserts and deletes the flags missing from the document
+	ImportReplace ImportMode = "replace"
+)
+
+func IsValidImportMode(mode string) bool {
+	switch mode {
+	case ImportCreateOnly, ImportUpsert, ImportReplace:
+		return true
+	}
+
+	return false
+}
+
+var (
+	ErrUnsupportedExportVersion = errors.New("unsupported export format version")
+	ErrMissingFlagName          = errors.New("flag name is required")
+	ErrMissingDefaultValue      = errors.New("flag default value is required")
+	ErrInvalidFlagType          = errors.New("flag type is not supported")
+	ErrFlagTypeChanged          = errors.New("flag type cannot change on import")
+	ErrDuplicateImportName      = errors.New("flag is listed more than once")
+	ErrAmbiguousImportName      = errors.New("flag name is shared by more than one flag")
+	ErrMissingEnvironment       = errors.New("flag needs at least one environment")
+	ErrUndefinedEnvironment     = errors.New("environment not defined on organization")
+	ErrUndefinedProject         = errors.New("project not defined on organization")
+	ErrIncompleteRule           = errors.New("rule predicate, or conditions, and value are required")
+)
+
+// ImportError points at the flag of the document that failed validation
+type ImportError struct {
+	Name string
+	Err  error
+}
+
+func (ie *ImportError) Error() string {
+	return ie.Name + ": " + ie.Err.Error()
+}
+
+func (ie *ImportError) Unwrap() error {
+	return ie.Err
+}
+
+// ImportUpdate pairs an existing flag with the config imported for it
+type ImportUpdate struct {
+	Record *FeatureFlagRecord
+	Flag   *ExportedFlag
+}
+
+// ImportPlan lists what applying a document does to the organization's flags
+type ImportPlan struct {
+	Create    []ExportedFlag
+	Update    []ImportUpdate
+	Unchanged []string
+	Delete    []FeatureFlagRecord
+}
+
+// Validate checks the flag can be imported into the organization
+func (ef *ExportedFlag) Validate(organization *organizationmodel.OrganizationRecord) error {
+	if ef.Name == "" {
+		return ErrMissingFlagName
+	}
+
+	if ef.DefaultValue == "" {
+		return ErrMissingDefaultValue
+	}
+
+	if !ef.Type.IsValid() {
+		return ErrInvalidFlagType
+	}
+
+	if _, err := ParseValue(ef.Type, ef.DefaultValue); err != nil {
+		return err
+	}
+
+	if len(ef.Environments) == 0 {
+		return ErrMissingEnvironment
+	}
+
+	if len(organization.Environments) > 0 {
+		for _, environment := range ef.Environments {
+			if !organization.HasEnvironment(environment.Name) {
+				return ErrUndefinedEnvironment
+			}
+		}
+	}
+
+	if ef.Project != "" && ef.findProject(organization) == nil {
+		return ErrUndefinedProject
+	}
+
+	for _, rule := range ef.Rules {
+		if (rule.Predicate == "" && len(rule.Conditions) == 0) || rule.Value == "" {
+			return ErrIncompleteRule
+		}
+	}
+
+	rules := ef.ToRules()
+	if err := ValidateRuleValues(ef.Type, rules); err != nil {
+		return err
+	}
+
+	return ValidateRules(rules)
+}
+
+// ProjectIn resolves the project of the flag on the organization, nil when
+// the flag isn't part of any
+func (ef *ExportedFlag) ProjectIn(organization *organizationmodel.OrganizationRecord) *organizationmodel.Project {
+	if ef.Project == "" {
+		return nil
+	}
+
+	return ef.findProject(organization)
+}
+
+func (ef *ExportedFlag) findProject(organization *organizationmodel.OrganizationRecord) *organizationmodel.Project {
+	for index, project := range organization.Projects {
+		if project.Name == ef.Project {
+			return &organization.Projects[index]
+		}
+	}
+
+	return nil
+}
+
+// EnvironmentNames lists the environments the flag is imported into
+func (ef *ExportedFlag) EnvironmentNames() []string {
+	names := make([]string, 0, len(ef.Environments))
+	for _, environment := range ef.Environments {
+		names = append(names, environment.Name)
+	}
+
+	return names
+}
+
+// PlanImport validates every flag of the document before deciding what to
+// do with it, so a single invalid flag rejects the whole document. Flags
+// are matched to the existing ones by name.
+func PlanImport(
+	document *ExportDocument,
+	existing []FeatureFlagRecord,
+	organization *organizationmodel.OrganizationRecord,
+	mode ImportMode,
+) (*ImportPlan, error) {
+	if document.Version != ExportFormatVersion {
+		return nil, ErrUnsupportedExportVersion
+	}
+
+	existingByName := make(map[string]*FeatureFlagRecord, len(existing))
+	ambiguous := make(map[string]bool)
+	for index, record := range existing {
+		if _, ok := existingByName[record.Name]; ok {
+			ambiguous[record.Name] = true
+		}
+		existingByName[record.Name] = &existing[index]
+	}
+
+	plan := &ImportPlan{
+		Create:    []ExportedFlag{},
+		Update:    []ImportUpdate{},
+		Unchanged: []string{},
+		Delete:    []FeatureFlagRecord{},
+	}
+	imported := make(map[string]bool, len(document.Flags))
+	for index := range document.Flags {
+		flag := &document.Flags[index]
+		flag.Tags = NormalizeTags(flag.Tags)
+		if flag.Rules == nil {
+			flag.Rules = []ExportedRule{}
+		}
+
+		if err := flag.Validate(organization); err != nil {
+			return nil, &ImportError{Name: flag.Name, Err: err}
+		}
+
+		if imported[flag.Name] {
+			return nil, &ImportError{Name: flag.Name, Err: ErrDuplicateImportName}
+		}
+		imported[flag.Name] = true
+
+		record, ok := existingByName[flag.Name]
+		if !ok {
+			plan.Create = append(plan.Create, *flag)
+			continue
+		}
+
+		if ambiguous[flag.Name] {
+			return nil, &ImportError{Name: flag.Name, Err: ErrAmbiguousImportName}
+		}
+
+		if record.Type != flag.Type {
+			return nil, &ImportError{Name: flag.Name, Err: ErrFlagTypeChanged}
+		}
+
+		if mode == ImportCreateOnly || reflect.DeepEqual(NewExportedFlag(record), *flag) {
+			plan.Unchanged = append(plan.Unchanged, flag.Name)
+			continue
+		}
+
+		plan.Update = append(plan.Update, ImportUpdate{Record: record, Flag: flag})
+	}
+
+	if mode == ImportReplace {
+		for _, record := range existing {
+			if !imported[record.Name] {
+				plan.Delete = append(plan.Delete, record)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// ConfigChanged reports whether the import proposes a new default value or
+// rules, which go through a draft revision like any other edit
+func (iu *ImportUpdate) ConfigChanged() bool {
+	current := NewExportedFlag(iu.Record)
+
+	return current.DefaultValue != iu.Flag.DefaultValue || !reflect.DeepEqual(current.Rules, iu.Flag.Rules)
+}
@@ -0,0 +1,142 @@
+package featureflagmodel_test
+
+import (
+	"errors"
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ImportTestSuite struct {
+	suite.Suite
+}
+
+func importedFlag(name, defaultValue string) featureflagmodel.ExportedFlag {
+	return featureflagmodel.ExportedFlag{
+		Name:         name,
+		Type:         featureflagmodel.Boolean,
+		DefaultValue: defaultValue,
+		Rules:        []featureflagmodel.ExportedRule{},
+		Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+		Tags:         []string{},
+	}
+}
+
+func existingFlag(name, defaultValue string) featureflagmodel.FeatureFlagRecord {
+	return featureflagmodel.FeatureFlagRecord{
+		ID:   primitive.NewObjectID(),
+		Name: name,
+		Type: featureflagmodel.Boolean,
+		Revisions: []featureflagmodel.Revision{
+			{ID: primitive.NewObjectID(), Status: featureflagmodel.Live, DefaultValue: defaultValue},
+		},
+		Environments: []featureflagmodel.FeatureFlagEnvironment{{Name: "prod", IsEnabled: true}},
+		Tags:         []string{},
+	}
+}
+
+func (suite *ImportTestSuite) TestPlanImportModes() {
+	t := suite.T()
+
+	organization := &organizationmodel.OrganizationRecord{}
+	existing := []featureflagmodel.FeatureFlagRecord{
+		existingFlag("kept", "true"),
+		existingFlag("changed", "true"),
+		existingFlag("missing", "true"),
+	}
+
+	newDocument := func() *featureflagmodel.ExportDocument {
+		return &featureflagmodel.ExportDocument{
+			Version: featureflagmodel.ExportFormatVersion,
+			Flags: []featureflagmodel.ExportedFlag{
+				importedFlag("kept", "true"),
+				importedFlag("changed", "false"),
+				importedFlag("new", "true"),
+			},
+		}
+	}
+
+	plan, err := featureflagmodel.PlanImport(newDocument(), existing, organization, featureflagmodel.ImportCreateOnly)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(plan.Create))
+	assert.Equal(t, "new", plan.Create[0].Name)
+	assert.Empty(t, plan.Update)
+	assert.Equal(t, []string{"kept", "changed"}, plan.Unchanged)
+	assert.Empty(t, plan.Delete)
+
+	plan, err = featureflagmodel.PlanImport(newDocument(), existing, organization, featureflagmodel.ImportUpsert)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(plan.Create))
+	assert.Equal(t, 1, len(plan.Update))
+	assert.Equal(t, "changed", plan.Update[0].Record.Name)
+	assert.True(t, plan.Update[0].ConfigChanged())
+	assert.Equal(t, []string{"kept"}, plan.Unchanged)
+	assert.Empty(t, plan.Delete)
+
+	plan, err = featureflagmodel.PlanImport(newDocument(), existing, organization, featureflagmodel.ImportReplace)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(plan.Delete))
+	assert.Equal(t, "missing", plan.Delete[0].Name)
+}
+
+func (suite *ImportTestSuite) TestPlanImportRejectsInvalidFlags() {
+	t := suite.T()
+
+	organization := &organizationmodel.OrganizationRecord{
+		Environments: []organizationmodel.Environment{{Name: "prod"}},
+	}
+
+	invalidType := importedFlag("invalid type", "true")
+	invalidType.Type = "date"
+
+	undefinedEnvironment := importedFlag("undefined environment", "true")
+	undefinedEnvironment.Environments[0].Name = "staging"
+
+	invalidRollout := importedFlag("invalid rollout", "true")
+	invalidRollout.Rules = []featureflagmodel.ExportedRule{{
+		Predicate: "plan: pro",
+		Value:     "false",
+		Env:       "prod",
+		Rollout:   &featureflagmodel.Rollout{Percentage: 120},
+	}}
+
+	testCases := []struct {
+		flag featureflagmodel.ExportedFlag
+		err  error
+	}{
+		{invalidType, featureflagmodel.ErrInvalidFlagType},
+		{undefinedEnvironment, featureflagmodel.ErrUndefinedEnvironment},
+		{invalidRollout, featureflagmodel.ErrInvalidRolloutPercentage},
+		{importedFlag("", "true"), featureflagmodel.ErrMissingFlagName},
+	}
+
+	for _, testCase := range testCases {
+		document := &featureflagmodel.ExportDocument{
+			Version: featureflagmodel.ExportFormatVersion,
+			Flags:   []featureflagmodel.ExportedFlag{importedFlag("valid", "true"), testCase.flag},
+		}
+
+		plan, err := featureflagmodel.PlanImport(document, nil, organization, featureflagmodel.ImportUpsert)
+		assert.Nil(t, plan)
+		assert.True(t, errors.Is(err, testCase.err))
+	}
+
+	document := &featureflagmodel.ExportDocument{
+		Version: featureflagmodel.ExportFormatVersion,
+		Flags:   []featureflagmodel.ExportedFlag{importedFlag("twice", "true"), importedFlag("twice", "false")},
+	}
+	_, err := featureflagmodel.PlanImport(document, nil, organization, featureflagmodel.ImportUpsert)
+	assert.True(t, errors.Is(err, featureflagmodel.ErrDuplicateImportName))
+
+	document.Version = featureflagmodel.ExportFormatVersion + 1
+	_, err = featureflagmodel.PlanImport(document, nil, organization, featureflagmodel.ImportUpsert)
+	assert.True(t, errors.Is(err, featureflagmodel.ErrUnsupportedExportVersion))
+}
+
+func TestImportTestSuite(t *testing.T) {
+	suite.Run(t, new(ImportTestSuite))
+}
@@ -0,0 +1,102 @@
+package featureflagmodel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL names the flag schema while it's compiled, it's never fetched
+const schemaURL = "flag.schema.json"
+
+var (
+	ErrSchemaNotJSON = errors.New("only json flags can have a schema")
+	ErrInvalidSchema = errors.New("schema is not a valid JSON schema")
+)
+
+// SchemaError reports a value violating the flag schema. Path is the JSON
+// pointer to the offending part of the value, empty for the whole value.
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (se *SchemaError) Error() string {
+	return fmt.Sprintf("value at #%s violates the schema: %s", se.Path, se.Message)
+}
+
+// CompileSchema compiles the JSON Schema of a json flag, nil when the flag
+// has none. References only resolve within the schema, nothing is fetched.
+func CompileSchema(flagType FlagType, schema string) (*jsonschema.Schema, error) {
+	if schema == "" {
+		return nil, nil
+	}
+
+	if flagType != JSON {
+		return nil, ErrSchemaNotJSON
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("%s is outside of the schema", url)
+	}
+	if err := compiler.AddResource(schemaURL, strings.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+
+	return compiled, nil
+}
+
+// CheckSchema reports a *SchemaError for values violating the schema, a nil
+// schema accepts anything. Values that aren't JSON are ParseValue's concern
+// and pass.
+func CheckSchema(schema *jsonschema.Schema, value string) error {
+	if schema == nil {
+		return nil
+	}
+
+	// Numbers stay json.Number so the schema sees them exactly as written
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil
+	}
+
+	err := schema.Validate(decoded)
+	var validationError *jsonschema.ValidationError
+	if !errors.As(err, &validationError) {
+		return err
+	}
+
+	// The innermost cause is the one pointing at the offending value
+	for len(validationError.Causes) > 0 {
+		validationError = validationError.Causes[0]
+	}
+
+	return &SchemaError{
+		Path:    validationError.InstanceLocation,
+		Message: validationError.Message,
+	}
+}
+
+// CheckSchemaRules points at the first rule serving a value violating the
+// schema
+func CheckSchemaRules(schema *jsonschema.Schema, rules []Rule) error {
+	for index, rule := range rules {
+		if err := CheckSchema(schema, rule.Value); err != nil {
+			return &RuleValueError{Index: index, Err: err}
+		}
+	}
+
+	return nil
+}
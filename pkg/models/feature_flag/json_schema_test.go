@@ -0,0 +1,89 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const timeoutSchema = `{
+	"type": "object",
+	"required": ["timeout"],
+	"properties": {
+		"timeout": {"type": "integer", "minimum": 0},
+		"retries": {"type": "integer"}
+	}
+}`
+
+type JSONSchemaTestSuite struct {
+	suite.Suite
+}
+
+func (suite *JSONSchemaTestSuite) TestCompileSchema() {
+	t := suite.T()
+
+	schema, err := featureflagmodel.CompileSchema(featureflagmodel.JSON, "")
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+
+	schema, err = featureflagmodel.CompileSchema(featureflagmodel.JSON, timeoutSchema)
+	assert.NoError(t, err)
+	assert.NotNil(t, schema)
+
+	_, err = featureflagmodel.CompileSchema(featureflagmodel.String, timeoutSchema)
+	assert.ErrorIs(t, err, featureflagmodel.ErrSchemaNotJSON)
+
+	_, err = featureflagmodel.CompileSchema(featureflagmodel.JSON, `{"type": "object"`)
+	assert.ErrorIs(t, err, featureflagmodel.ErrInvalidSchema)
+
+	_, err = featureflagmodel.CompileSchema(featureflagmodel.JSON, `{"type": "nothing"}`)
+	assert.ErrorIs(t, err, featureflagmodel.ErrInvalidSchema)
+
+	// References outside of the schema are never fetched
+	_, err = featureflagmodel.CompileSchema(featureflagmodel.JSON, `{"$ref": "http://127.0.0.1:1/schema.json"}`)
+	assert.ErrorIs(t, err, featureflagmodel.ErrInvalidSchema)
+}
+
+func (suite *JSONSchemaTestSuite) TestCheckSchema() {
+	t := suite.T()
+
+	schema, err := featureflagmodel.CompileSchema(featureflagmodel.JSON, timeoutSchema)
+	assert.NoError(t, err)
+
+	assert.NoError(t, featureflagmodel.CheckSchema(schema, `{"timeout": 30, "retries": 2}`))
+	assert.NoError(t, featureflagmodel.CheckSchema(nil, `{"anything": true}`))
+
+	var schemaError *featureflagmodel.SchemaError
+	assert.ErrorAs(t, featureflagmodel.CheckSchema(schema, `{"retries": 2}`), &schemaError)
+	assert.Equal(t, "", schemaError.Path)
+	assert.Contains(t, schemaError.Message, "timeout")
+
+	assert.ErrorAs(t, featureflagmodel.CheckSchema(schema, `{"timeout": "30"}`), &schemaError)
+	assert.Equal(t, "/timeout", schemaError.Path)
+}
+
+func (suite *JSONSchemaTestSuite) TestCheckSchemaRulesReportsIndex() {
+	t := suite.T()
+
+	schema, err := featureflagmodel.CompileSchema(featureflagmodel.JSON, timeoutSchema)
+	assert.NoError(t, err)
+
+	err = featureflagmodel.CheckSchemaRules(schema, []featureflagmodel.Rule{
+		rule("plan: pro", `{"timeout": 60}`),
+		rule("plan: free", `{"timeout": -1}`),
+	})
+
+	var ruleValueError *featureflagmodel.RuleValueError
+	assert.ErrorAs(t, err, &ruleValueError)
+	assert.Equal(t, 1, ruleValueError.Index)
+
+	var schemaError *featureflagmodel.SchemaError
+	assert.ErrorAs(t, err, &schemaError)
+	assert.Equal(t, "/timeout", schemaError.Path)
+}
+
+func TestJSONSchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(JSONSchemaTestSuite))
+}
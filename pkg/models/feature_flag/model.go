@@ -3,7 +3,11 @@ package featureflagmodel
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"time"
+	// Embedded so timezone validation doesn't depend on the host zoneinfo
+	_ "time/tzdata"
 
 	"github.com/Roll-Play/togglelabs/pkg/models"
 	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
@@ -33,14 +37,167 @@ const (
 	Live     RevisionStatus = "live"
 	Draft    RevisionStatus = "draft"
 	Archived RevisionStatus = "archived"
+	Rejected RevisionStatus = "rejected"
+	// Scheduled revisions are fully approved and wait for ScheduledAt to
+	// go live
+	Scheduled RevisionStatus = "scheduled"
 )
 
+func IsValidRevisionStatus(status string) bool {
+	switch status {
+	case Live, Draft, Archived, Rejected, Scheduled:
+		return true
+	}
+
+	return false
+}
+
+// Rule serves its value to the contexts matching the predicate. Env scopes
+// the rule to a single environment, rules without one predate environment
+// scoping and apply to every environment of the flag.
 type Rule struct {
 	ID        primitive.ObjectID `json:"_id" bson:"_id"`
-	Predicate string             `json:"predicate" bson:"predicate" validate:"required"`
+	Predicate string             `json:"predicate" bson:"predicate" validate:"required_without=Conditions"`
 	Value     string             `json:"value" bson:"value" validate:"required"`
-	Env       string             `json:"env" bson:"env" validate:"required"`
+	Env       string             `json:"env" bson:"env"`
 	IsEnabled bool               `json:"is_enabled" bson:"is_enabled" validate:"required,boolean"`
+	Window    *TimeWindow        `json:"window,omitempty" bson:"window,omitempty"`
+	Rollout   *Rollout           `json:"rollout,omitempty" bson:"rollout,omitempty"`
+	// StartsAt and EndsAt schedule the rule, outside of them it never
+	// matches. Either end can be left open.
+	StartsAt *time.Time `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Priority orders the evaluation of the rules, lower first. Rules of
+	// the same priority keep their order in the revision.
+	Priority int `json:"priority,omitempty" bson:"priority,omitempty"`
+	// Operator compares the context attribute with the predicate value,
+	// rules without one compare for equality
+	Operator RuleOperator `json:"operator,omitempty" bson:"operator,omitempty"`
+	// Conditions are combined with the predicate, when the rule has one, by
+	// the combinator. The rule matches when all of them hold, or any of
+	// them with the any combinator.
+	Conditions []Condition `json:"conditions,omitempty" bson:"conditions,omitempty" validate:"dive"`
+	Combinator Combinator  `json:"combinator,omitempty" bson:"combinator,omitempty"`
+}
+
+// AllConditions lists the predicate of the rule, when it has one, followed
+// by its conditions
+func (r *Rule) AllConditions() []Condition {
+	conditions := make([]Condition, 0, len(r.Conditions)+1)
+	if r.Predicate != "" {
+		conditions = append(conditions, Condition{Predicate: r.Predicate, Operator: r.Operator})
+	}
+
+	return append(conditions, r.Conditions...)
+}
+
+// RulesByPriority returns the indexes of the rules in the order they're
+// evaluated, by ascending priority and then by position
+func RulesByPriority(rules []Rule) []int {
+	order := make([]int, len(rules))
+	for index := range rules {
+		order[index] = index
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return rules[order[i]].Priority < rules[order[j]].Priority
+	})
+
+	return order
+}
+
+// AppliesTo reports whether the rule is considered in the environment
+func (r *Rule) AppliesTo(environment string) bool {
+	return r.Env == "" || r.Env == environment
+}
+
+// ActiveAt reports whether the schedule of the rule covers the instant,
+// StartsAt included and EndsAt excluded
+func (r *Rule) ActiveAt(now time.Time) bool {
+	if r.StartsAt != nil && now.Before(*r.StartsAt) {
+		return false
+	}
+
+	return r.EndsAt == nil || now.Before(*r.EndsAt)
+}
+
+var ErrInvalidRolloutPercentage = errors.New("rollout percentage must be between 0 and 100")
+
+// Rollout serves a rule to a percentage of the matching contexts. Contexts
+// are bucketed by the value of their BucketBy attribute, the key attribute
+// when it's empty, so the same value always lands in the same bucket.
+// Contexts whose value is listed in ExcludeKeys never receive the rule,
+// whatever bucket they fall in.
+type Rollout struct {
+	Percentage  int      `json:"percentage" bson:"percentage" yaml:"percentage" validate:"gte=0,lte=100"`
+	ExcludeKeys []string `json:"exclude_keys,omitempty" bson:"exclude_keys,omitempty" yaml:"exclude_keys,omitempty"`
+	BucketBy    string   `json:"bucket_by,omitempty" bson:"bucket_by,omitempty" yaml:"bucket_by,omitempty"`
+}
+
+// TimeWindowLayout is the time of day format used by TimeWindow boundaries
+const TimeWindowLayout = "15:04"
+
+var ErrInvalidTimeWindow = errors.New("time window boundaries must use the HH:MM format")
+var ErrInvalidTimezone = errors.New("timezone is not a valid IANA timezone")
+var ErrInvalidRuleSchedule = errors.New("rule must start before it ends")
+
+// TimeWindow restricts a rule to a daily time of day range. The window is
+// evaluated in the timezone read from the context attribute named by
+// TimezoneAttribute, falling back to Timezone and then to UTC.
+// An End before Start describes a window that spans midnight.
+type TimeWindow struct {
+	Start             string `json:"start" bson:"start" yaml:"start" validate:"required"`
+	End               string `json:"end" bson:"end" yaml:"end" validate:"required"`
+	Timezone          string `json:"timezone,omitempty" bson:"timezone,omitempty" yaml:"timezone,omitempty"`
+	TimezoneAttribute string `json:"timezone_attribute,omitempty" bson:"timezone_attribute,omitempty" yaml:"timezone_attribute,omitempty"`
+}
+
+func (tw *TimeWindow) Validate() error {
+	if _, err := time.Parse(TimeWindowLayout, tw.Start); err != nil {
+		return ErrInvalidTimeWindow
+	}
+
+	if _, err := time.Parse(TimeWindowLayout, tw.End); err != nil {
+		return ErrInvalidTimeWindow
+	}
+
+	if tw.Timezone != "" {
+		if _, err := time.LoadLocation(tw.Timezone); err != nil {
+			return ErrInvalidTimezone
+		}
+	}
+
+	return nil
+}
+
+func ValidateRules(rules []Rule) error {
+	for _, rule := range rules {
+		for _, condition := range rule.AllConditions() {
+			if err := condition.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if !IsValidCombinator(rule.Combinator) {
+			return ErrInvalidRuleCombinator
+		}
+
+		if rule.Window != nil {
+			if err := rule.Window.Validate(); err != nil {
+				return err
+			}
+		}
+
+		if rule.Rollout != nil && (rule.Rollout.Percentage < 0 || rule.Rollout.Percentage > 100) {
+			return ErrInvalidRolloutPercentage
+		}
+
+		if rule.StartsAt != nil && rule.EndsAt != nil && !rule.StartsAt.Before(*rule.EndsAt) {
+			return ErrInvalidRuleSchedule
+		}
+	}
+
+	return nil
 }
 
 type Revision struct {
@@ -51,9 +208,23 @@ type Revision struct {
 	LastRevisionID *primitive.ObjectID `json:"last_revision_id,omitempty" bson:"last_revision_id,omitempty"`
 	ChangeSet      string              `json:"change_set,omitempty" bson:"change_set,omitempty"`
 	Rules          []Rule              `json:"rules,omitempty" bson:"rules,omitempty"`
+	CreatedAt      primitive.DateTime  `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	// Approvals lists the users who signed off on a draft revision
+	Approvals   []primitive.ObjectID `json:"approvals,omitempty" bson:"approvals,omitempty"`
+	ScheduledAt *primitive.DateTime  `json:"scheduled_at,omitempty" bson:"scheduled_at,omitempty"`
+}
+
+func (r *Revision) ApprovedBy(userID primitive.ObjectID) bool {
+	for _, approval := range r.Approvals {
+		if approval == userID {
+			return true
+		}
+	}
+
+	return false
 }
 
-type FlagType = string
+type FlagType string
 
 const (
 	Boolean FlagType = "boolean"
@@ -62,23 +233,88 @@ const (
 	Number  FlagType = "number"
 )
 
+// FlagTypes are all the supported flag types, every entry point checks
+// types against it so adding one here is enough for it to be accepted
+var FlagTypes = []FlagType{Boolean, JSON, String, Number}
+
+func (ft FlagType) IsValid() bool {
+	for _, flagType := range FlagTypes {
+		if ft == flagType {
+			return true
+		}
+	}
+
+	return false
+}
+
 type FeatureFlagRecord struct {
 	ID             primitive.ObjectID         `json:"_id,omitempty" bson:"_id"`
 	OrganizationID primitive.ObjectID         `json:"organization_id" bson:"organization_id"`
 	UserID         primitive.ObjectID         `json:"user_id" bson:"user_id"`
 	Version        int                        `json:"version" bson:"version"`
 	Name           string                     `json:"name" bson:"name"`
+	Description    string                     `json:"description,omitempty" bson:"description,omitempty"`
 	Type           FlagType                   `json:"type" bson:"type"`
 	Revisions      []Revision                 `json:"revisions" bson:"revisions"`
 	Environments   []FeatureFlagEnvironment   `json:"environments,omitempty" bson:"environments,omitempty"`
 	Project        *organizationmodel.Project `json:"project,omitempty" bson:"project,omitempty"`
 	Tags           []string                   `json:"tags" bson:"tags"`
+	// ExpectedConfigHash is the ConfigHash the deployment pipeline expects
+	// the flag to have, anything else means the live config drifted
+	ExpectedConfigHash    string `json:"expected_config_hash,omitempty" bson:"expected_config_hash,omitempty"`
+	DriftAcknowledgedHash string `json:"drift_acknowledged_hash,omitempty" bson:"drift_acknowledged_hash,omitempty"`
+	// Prerequisites have to be met for the flag to serve anything but its
+	// default value
+	Prerequisites []Prerequisite `json:"prerequisites,omitempty" bson:"prerequisites,omitempty"`
+	// Overrides pin the value served to a context key, in every environment
+	// the flag is enabled in, ahead of any rule
+	Overrides map[string]string `json:"overrides,omitempty" bson:"overrides,omitempty"`
+	// NumberBounds only ever apply to number flags
+	NumberBounds `bson:",inline"`
+	// Schema is the JSON Schema every value of a json flag has to follow
+	Schema string `json:"schema,omitempty" bson:"schema,omitempty"`
+	// IsArchived hides the flag from the default list, it only ever serves
+	// its default value
+	IsArchived bool `json:"is_archived" bson:"is_archived"`
+	// UpdatedByID is the user behind the last update, unset until the flag
+	// is first updated
+	UpdatedByID *primitive.ObjectID `json:"updated_by_id,omitempty" bson:"updated_by_id,omitempty"`
 	models.Timestamps
 }
 
+func (ffr *FeatureFlagRecord) LiveRevision() *Revision {
+	for index, revision := range ffr.Revisions {
+		if revision.Status == Live {
+			return &ffr.Revisions[index]
+		}
+	}
+
+	return nil
+}
+
+func (ffr *FeatureFlagRecord) FindRevision(revisionID primitive.ObjectID) *Revision {
+	for index, revision := range ffr.Revisions {
+		if revision.ID == revisionID {
+			return &ffr.Revisions[index]
+		}
+	}
+
+	return nil
+}
+
+func (ffr *FeatureFlagRecord) FindEnvironment(name string) *FeatureFlagEnvironment {
+	for index, environment := range ffr.Environments {
+		if environment.Name == name {
+			return &ffr.Environments[index]
+		}
+	}
+
+	return nil
+}
+
 type FeatureFlagEnvironment struct {
-	Name      string `json:"name" bson:"name"`
-	IsEnabled bool   `json:"is_enabled" bson:"is_enabled"`
+	Name      string `json:"name" bson:"name" yaml:"name"`
+	IsEnabled bool   `json:"is_enabled" bson:"is_enabled" yaml:"is_enabled"`
 }
 
 func NewFeatureFlagRecord(
@@ -88,7 +324,7 @@ func NewFeatureFlagRecord(
 	rules []Rule,
 	organizationID primitive.ObjectID,
 	userID primitive.ObjectID,
-	environmentName string,
+	environmentNames []string,
 	project *organizationmodel.Project,
 	tags []string,
 ) *FeatureFlagRecord {
@@ -110,16 +346,12 @@ func NewFeatureFlagRecord(
 				DefaultValue:   defaultValue,
 				Rules:          NewRuleRecordList(rules),
 				LastRevisionID: nil,
+				CreatedAt:      primitive.NewDateTimeFromTime(time.Now().UTC()),
 			},
 		},
-		Environments: []FeatureFlagEnvironment{
-			{
-				Name:      environmentName,
-				IsEnabled: false,
-			},
-		},
-		Tags:    tags,
-		Project: project,
+		Environments: NewEnvironmentList(environmentNames),
+		Tags:         tags,
+		Project:      project,
 		Timestamps: models.Timestamps{
 			CreatedAt: primitive.NewDateTimeFromTime(time.Now().UTC()),
 			UpdatedAt: primitive.NewDateTimeFromTime(time.Now().UTC()),
@@ -127,6 +359,36 @@ func NewFeatureFlagRecord(
 	}
 }
 
+// NormalizeTags lowercases and trims tags, dropping empty and duplicated ones
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// NewEnvironmentList builds the environments of a new flag, all of them
+// start disabled
+func NewEnvironmentList(names []string) []FeatureFlagEnvironment {
+	environments := make([]FeatureFlagEnvironment, 0, len(names))
+	for _, name := range names {
+		environments = append(environments, FeatureFlagEnvironment{
+			Name:      name,
+			IsEnabled: false,
+		})
+	}
+
+	return environments
+}
+
 func NewRuleRecordList(rules []Rule) []Rule {
 	for index, rule := range rules {
 		rules[index] = NewRuleRecord(rule)
@@ -138,11 +400,19 @@ func NewRuleRecordList(rules []Rule) []Rule {
 func NewRuleRecord(rule Rule) Rule {
 	rule.ID = primitive.NewObjectID()
 	return Rule{
-		ID:        primitive.NewObjectID(),
-		Predicate: rule.Predicate,
-		Value:     rule.Value,
-		Env:       rule.Env,
-		IsEnabled: rule.IsEnabled,
+		ID:         primitive.NewObjectID(),
+		Predicate:  rule.Predicate,
+		Value:      rule.Value,
+		Env:        rule.Env,
+		IsEnabled:  rule.IsEnabled,
+		Window:     rule.Window,
+		Rollout:    rule.Rollout,
+		StartsAt:   rule.StartsAt,
+		EndsAt:     rule.EndsAt,
+		Priority:   rule.Priority,
+		Operator:   rule.Operator,
+		Conditions: rule.Conditions,
+		Combinator: rule.Combinator,
 	}
 }
 
@@ -153,9 +423,23 @@ func NewRevisionRecord(defaultValue string, rules []Rule, userID primitive.Objec
 		Status:       Draft,
 		DefaultValue: defaultValue,
 		Rules:        rules,
+		CreatedAt:    primitive.NewDateTimeFromTime(time.Now().UTC()),
 	}
 }
 
+// FilterRevisions returns the revisions of the flag newest first, keeping
+// only the ones with the given status when one is set
+func (ffr *FeatureFlagRecord) FilterRevisions(status RevisionStatus) []Revision {
+	revisions := make([]Revision, 0, len(ffr.Revisions))
+	for index := len(ffr.Revisions) - 1; index >= 0; index-- {
+		if status == "" || ffr.Revisions[index].Status == status {
+			revisions = append(revisions, ffr.Revisions[index])
+		}
+	}
+
+	return revisions
+}
+
 func (ffm *FeatureFlagModel) InsertOne(ctx context.Context, record *FeatureFlagRecord) (primitive.ObjectID, error) {
 	record.ID = primitive.NewObjectID()
 	result, err := ffm.collection.InsertOne(ctx, record)
@@ -171,6 +455,24 @@ func (ffm *FeatureFlagModel) InsertOne(ctx context.Context, record *FeatureFlagR
 	return objectID, nil
 }
 
+// InsertMany inserts the records in order, giving each an ID, and returns
+// their IDs in the same order
+func (ffm *FeatureFlagModel) InsertMany(ctx context.Context, records []*FeatureFlagRecord) ([]primitive.ObjectID, error) {
+	documents := make([]interface{}, 0, len(records))
+	ids := make([]primitive.ObjectID, 0, len(records))
+	for _, record := range records {
+		record.ID = primitive.NewObjectID()
+		documents = append(documents, record)
+		ids = append(ids, record.ID)
+	}
+
+	if _, err := ffm.collection.InsertMany(ctx, documents); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
 func (ffm *FeatureFlagModel) FindByID(ctx context.Context, id primitive.ObjectID) (*FeatureFlagRecord, error) {
 	record := new(FeatureFlagRecord)
 	if err := ffm.collection.FindOne(ctx, bson.D{
@@ -183,26 +485,490 @@ func (ffm *FeatureFlagModel) FindByID(ctx context.Context, id primitive.ObjectID
 	return record, nil
 }
 
+// FindActiveByID finds a flag of the organization that hasn't been deleted,
+// the one every operation on a flag but restoring it starts from
+func (ffm *FeatureFlagModel) FindActiveByID(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	return ffm.FindOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	})
+}
+
+// FindDeletedByID finds a soft deleted flag of the organization
+func (ffm *FeatureFlagModel) FindDeletedByID(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+) (*FeatureFlagRecord, error) {
+	return ffm.FindOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": true}},
+	})
+}
+
+// SoftDelete stamps deleted_at on a flag of the organization. It returns
+// mongo.ErrNoDocuments when there's no such flag or it's already deleted.
+func (ffm *FeatureFlagModel) SoftDelete(ctx context.Context, organizationID, id primitive.ObjectID) error {
+	result, err := ffm.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "organization_id", Value: organizationID},
+			{Key: "deleted_at", Value: bson.M{"$exists": false}},
+		},
+		withUpdatedAt(ctx, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "deleted_at", Value: primitive.NewDateTimeFromTime(time.Now().UTC())},
+		}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// Restore brings back a soft deleted flag of the organization. Flags that
+// aren't deleted are left as they are and reported as not found.
+func (ffm *FeatureFlagModel) Restore(ctx context.Context, organizationID, id primitive.ObjectID) error {
+	result, err := ffm.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "organization_id", Value: organizationID},
+			{Key: "deleted_at", Value: bson.M{"$exists": true}},
+		},
+		withUpdatedAt(ctx, bson.D{{Key: "$unset", Value: bson.M{"deleted_at": 1}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// FindDeletedBefore lists the flags, of every organization, soft deleted
+// before the cutoff
+func (ffm *FeatureFlagModel) FindDeletedBefore(ctx context.Context, cutoff time.Time) ([]primitive.ObjectID, error) {
+	cursor, err := ffm.collection.Find(
+		ctx,
+		bson.D{{Key: "deleted_at", Value: bson.M{"$lt": primitive.NewDateTimeFromTime(cutoff)}}},
+		options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+
+	return ids, nil
+}
+
+// Purge hard deletes a flag soft deleted before the cutoff. It reports
+// false when the flag was restored, or deleted again, since.
+func (ffm *FeatureFlagModel) Purge(ctx context.Context, id primitive.ObjectID, cutoff time.Time) (bool, error) {
+	result, err := ffm.collection.DeleteOne(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "deleted_at", Value: bson.M{"$lt": primitive.NewDateTimeFromTime(cutoff)}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// SetArchived archives, or unarchives, a flag of the organization. It
+// returns mongo.ErrNoDocuments when the flag is gone.
+func (ffm *FeatureFlagModel) SetArchived(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	isArchived bool,
+) error {
+	result, err := ffm.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "organization_id", Value: organizationID},
+			{Key: "deleted_at", Value: bson.M{"$exists": false}},
+		},
+		withUpdatedAt(ctx, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "is_archived", Value: isArchived},
+		}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// SetTags replaces the tags of a flag of the organization. It returns
+// mongo.ErrNoDocuments when the flag is gone.
+func (ffm *FeatureFlagModel) SetTags(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	tags []string,
+) error {
+	result, err := ffm.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "organization_id", Value: organizationID},
+			{Key: "deleted_at", Value: bson.M{"$exists": false}},
+		},
+		withUpdatedAt(ctx, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "tags", Value: tags},
+		}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// PushRevision adds a revision to a flag of the organization and bumps its
+// version, returning the new one. When version is set the flag has to
+// still be at that version, mongo.ErrNoDocuments is returned when it isn't
+// or the flag is gone.
+func (ffm *FeatureFlagModel) PushRevision(
+	ctx context.Context,
+	organizationID,
+	id primitive.ObjectID,
+	revision *Revision,
+	version *int,
+) (int, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	}
+	if version != nil {
+		filter = append(filter, bson.E{Key: "version", Value: *version})
+	}
+
+	update := withUpdatedAt(ctx, bson.D{
+		{Key: "$push", Value: bson.M{"revisions": revision}},
+		{Key: "$inc", Value: bson.M{"version": 1}},
+	})
+	opts := options.FindOneAndUpdate().
+		SetProjection(bson.M{"version": 1}).
+		SetReturnDocument(options.After)
+
+	record := new(FeatureFlagRecord)
+	if err := ffm.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(record); err != nil {
+		return 0, err
+	}
+
+	return record.Version, nil
+}
+
 var EmptyFeatureRecordList = []FeatureFlagRecord{}
 
+// ListFilter narrows down the flags FindMany pages through. Tags keeps the
+// flags carrying all of them and Value the ones whose live revision serves
+// it, as its default value or the value of an enabled rule. Search is a
+// text search over the name, description and tags, which ranks the flags
+// by relevance before the requested sort. Archived flags are left out
+// unless IncludeArchived is set.
+type ListFilter struct {
+	Tags            []string
+	Value           string
+	Search          string
+	IncludeArchived bool
+}
+
+// SearchIndex is the text index ListFilter.Search runs on
+var SearchIndex = mongo.IndexModel{
+	Keys: bson.D{
+		{Key: "name", Value: "text"},
+		{Key: "description", Value: "text"},
+		{Key: "tags", Value: "text"},
+	},
+}
+
+// FindMany pages through the organization's flags matching the filter,
+// along with how many of them there are
 func (ffm *FeatureFlagModel) FindMany(
 	ctx context.Context,
 	organizationID primitive.ObjectID,
+	filter ListFilter,
 	page,
 	limit int,
 	sort bson.D,
+) ([]FeatureFlagRecord, int, error) {
+	if filter.Search != "" {
+		sort = append(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}, sort...)
+	}
+
+	pipeline := append(
+		listPipeline(organizationID, filter),
+		bson.D{{Key: "$sort", Value: sort}},
+	)
+
+	records, total, err := models.Paginate[FeatureFlagRecord](ctx, ffm.collection, pipeline, page, limit)
+	if err != nil {
+		return EmptyFeatureRecordList, 0, err
+	}
+
+	return records, total, nil
+}
+
+// FindAfter pages through the organization's flags matching the filter like
+// FindMany, but from the newest flag to the oldest by ID, starting right
+// after the flag with the after ID, or from the newest flag when it's nil.
+// Flags inserted meanwhile get newer IDs than the ones already served, so
+// they never shift the pages still to come. Search results aren't ranked by
+// relevance. The ID returned is where the following page starts after, nil
+// on the last page.
+func (ffm *FeatureFlagModel) FindAfter(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter ListFilter,
+	after *primitive.ObjectID,
+	limit int,
+) ([]FeatureFlagRecord, *primitive.ObjectID, error) {
+	pipeline := listPipeline(organizationID, filter)
+	if after != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"_id": bson.M{"$lt": *after}}}})
+	}
+	// One more flag than the page holds tells whether there's a next page
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: limit + 1}},
+	)
+
+	cursor, err := ffm.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return EmptyFeatureRecordList, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := make([]FeatureFlagRecord, 0, limit+1)
+	if err := cursor.All(ctx, &records); err != nil {
+		return EmptyFeatureRecordList, nil, err
+	}
+
+	if len(records) <= limit {
+		return records, nil, nil
+	}
+
+	records = records[:limit]
+	return records, &records[limit-1].ID, nil
+}
+
+// CountMany counts the flags FindMany pages through, without fetching any
+func (ffm *FeatureFlagModel) CountMany(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter ListFilter,
+) (int, error) {
+	pipeline := append(
+		listPipeline(organizationID, filter),
+		bson.D{{Key: "$count", Value: "total"}},
+	)
+
+	cursor, err := ffm.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int `bson:"total"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+
+	if len(result) == 0 {
+		return 0, nil
+	}
+
+	return result[0].Total, nil
+}
+
+// listPipeline matches the flags of the list filter. The live revision is
+// nested in the revisions array, so searching by value first pulls it out
+// into a temporary field.
+func listPipeline(organizationID primitive.ObjectID, filter ListFilter) mongo.Pipeline {
+	match := bson.D{
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{
+			"$exists": false},
+		}}
+	if !filter.IncludeArchived {
+		match = append(match, bson.E{Key: "is_archived", Value: bson.M{"$ne": true}})
+	}
+	if len(filter.Tags) > 0 {
+		match = append(match, bson.E{Key: "tags", Value: bson.M{"$all": filter.Tags}})
+	}
+	// $text is only allowed in the first stage of a pipeline
+	if filter.Search != "" {
+		match = append(match, bson.E{Key: "$text", Value: bson.M{"$search": filter.Search}})
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if filter.Value == "" {
+		return pipeline
+	}
+
+	return append(pipeline,
+		bson.D{{Key: "$addFields", Value: bson.M{
+			"live_revision": bson.M{"$arrayElemAt": bson.A{
+				bson.M{"$filter": bson.M{
+					"input": "$revisions",
+					"cond":  bson.M{"$eq": bson.A{"$$this.status", Live}},
+				}},
+				0,
+			}},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"$expr": servesValueExpr(filter.Value)}}},
+		bson.D{{Key: "$project", Value: bson.M{"live_revision": 0}}},
+	)
+}
+
+// FindAll returns every non-deleted flag of the organization ordered by name
+func (ffm *FeatureFlagModel) FindAll(ctx context.Context, organizationID primitive.ObjectID) ([]FeatureFlagRecord, error) {
+	opts := options.Find().SetSort(bson.D{
+		{Key: "name", Value: 1},
+		{Key: "_id", Value: 1},
+	})
+
+	records := make([]FeatureFlagRecord, 0)
+	cursor, err := ffm.collection.Find(ctx, bson.D{
+		{Key: "organization_id", Value: organizationID},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	}, opts)
+	if err != nil {
+		return EmptyFeatureRecordList, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &records); err != nil {
+		return EmptyFeatureRecordList, err
+	}
+
+	return records, nil
+}
+
+// NameInUse reports whether a non-deleted flag of the organization other than
+// ignoreID already uses the name in any of the given environments
+func (ffm *FeatureFlagModel) NameInUse(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	name string,
+	environments []string,
+	ignoreID primitive.ObjectID,
+) (bool, error) {
+	count, err := ffm.collection.CountDocuments(ctx, bson.D{
+		{Key: "_id", Value: bson.M{"$ne": ignoreID}},
+		{Key: "organization_id", Value: organizationID},
+		{Key: "name", Value: name},
+		{Key: "environments.name", Value: bson.M{"$in": environments}},
+		{Key: "deleted_at", Value: bson.M{"$exists": false}},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// FindDueScheduled returns the non-deleted flags holding a scheduled
+// revision whose activation time is not after now
+func (ffm *FeatureFlagModel) FindDueScheduled(ctx context.Context, now time.Time) ([]FeatureFlagRecord, error) {
+	records := make([]FeatureFlagRecord, 0)
+	cursor, err := ffm.collection.Find(ctx, bson.D{
+		{Key: "revisions", Value: bson.M{"$elemMatch": bson.M{
+			"status":       Scheduled,
+			"scheduled_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+		}}},
+		{Key: "deleted_at", Value: bson.M{
+			"$exists": false},
+		}})
+	if err != nil {
+		return EmptyFeatureRecordList, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &records); err != nil {
+		return EmptyFeatureRecordList, err
+	}
+
+	return records, nil
+}
+
+func (ffm *FeatureFlagModel) FindWithExpectedConfigHash(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
 ) ([]FeatureFlagRecord, error) {
-	opts := options.Find()
-	opts.SetSkip(int64((page - 1) * limit))
-	opts.SetLimit(int64(limit))
-	opts.SetSort(sort)
+	records := make([]FeatureFlagRecord, 0)
+	cursor, err := ffm.collection.Find(ctx, bson.D{
+		{Key: "organization_id", Value: organizationID},
+		{Key: "expected_config_hash", Value: bson.M{"$exists": true}},
+		{Key: "deleted_at", Value: bson.M{
+			"$exists": false},
+		}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return EmptyFeatureRecordList, err
+	}
+	defer cursor.Close(ctx)
 
+	if err := cursor.All(ctx, &records); err != nil {
+		return EmptyFeatureRecordList, err
+	}
+
+	return records, nil
+}
+
+func (ffm *FeatureFlagModel) FindByIDs(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	ids []primitive.ObjectID,
+) ([]FeatureFlagRecord, error) {
 	records := make([]FeatureFlagRecord, 0)
 	cursor, err := ffm.collection.Find(ctx, bson.D{
+		{Key: "_id", Value: bson.M{"$in": ids}},
 		{Key: "organization_id", Value: organizationID},
 		{Key: "deleted_at", Value: bson.M{
 			"$exists": false},
-		}}, opts)
+		}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
 	if err != nil {
 		return EmptyFeatureRecordList, err
 	}
@@ -215,30 +981,124 @@ func (ffm *FeatureFlagModel) FindMany(
 	return records, nil
 }
 
+// SetEnvironmentEnabled turns the environment of every given flag on or off
+// in a single unordered bulk write. The returned errors line up with the
+// ids, nil for every flag that was written.
+func (ffm *FeatureFlagModel) SetEnvironmentEnabled(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	ids []primitive.ObjectID,
+	environment string,
+	isEnabled bool,
+) ([]error, error) {
+	writes := make([]mongo.WriteModel, 0, len(ids))
+	for _, id := range ids {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{Key: "_id", Value: id},
+				{Key: "organization_id", Value: organizationID},
+				{Key: "deleted_at", Value: bson.M{"$exists": false}},
+				{Key: "environments.name", Value: environment},
+			}).
+			SetUpdate(withUpdatedAt(ctx, bson.D{{
+				Key:   "$set",
+				Value: bson.D{{Key: "environments.$.is_enabled", Value: isEnabled}},
+			}})))
+	}
+
+	writeErrors := make([]error, len(ids))
+	if len(writes) == 0 {
+		return writeErrors, nil
+	}
+
+	_, err := ffm.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return nil, err
+		}
+
+		for _, writeErr := range bulkErr.WriteErrors {
+			writeErrors[writeErr.Index] = writeErr
+		}
+	}
+
+	return writeErrors, nil
+}
+
+// UpdateOne and UpdateMany stamp updated_at on every flag they touch. It's
+// merged into the $set of the update, when there's one, as the same
+// operator can't be given twice.
 func (ffm *FeatureFlagModel) UpdateOne(
 	ctx context.Context,
 	filter interface{},
 	update bson.D,
 ) error {
-	update = append(update, bson.E{
-		Key: "$set",
-		Value: bson.D{
-			{
-				Key:   "timestamps.updated_at",
-				Value: primitive.NewDateTimeFromTime(time.Now().UTC()),
-			},
-		},
-	})
-	_, err := ffm.collection.UpdateOne(ctx, filter, update)
+	_, err := ffm.collection.UpdateOne(ctx, filter, withUpdatedAt(ctx, update))
 
 	return err
 }
 
 func (ffm *FeatureFlagModel) UpdateMany(ctx context.Context, filter bson.D, update bson.D) error {
-	_, err := ffm.collection.UpdateMany(ctx, filter, update)
+	_, err := ffm.collection.UpdateMany(ctx, filter, withUpdatedAt(ctx, update))
 	return err
 }
 
+type updatedByContextKey struct{}
+
+// WithUpdatedBy makes the updates run with the context record the user
+// behind them on the flag
+func WithUpdatedBy(ctx context.Context, userID primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, updatedByContextKey{}, userID)
+}
+
+// UpdatedBy is the user WithUpdatedBy put on the context, if any
+func UpdatedBy(ctx context.Context) (primitive.ObjectID, bool) {
+	userID, ok := ctx.Value(updatedByContextKey{}).(primitive.ObjectID)
+	return userID, ok
+}
+
+// withUpdatedAt stamps the update time, and the user behind the update when
+// the context carries one, into the $set of the update
+func withUpdatedAt(ctx context.Context, update bson.D) bson.D {
+	stamps := bson.D{{
+		Key:   "timestamps.updated_at",
+		Value: primitive.NewDateTimeFromTime(time.Now().UTC()),
+	}}
+	if userID, ok := UpdatedBy(ctx); ok {
+		stamps = append(stamps, bson.E{Key: "updated_by_id", Value: userID})
+	}
+
+	stamped := make(bson.D, 0, len(update)+1)
+	merged := false
+	for _, operator := range update {
+		if operator.Key == "$set" && !merged {
+			switch set := operator.Value.(type) {
+			case bson.D:
+				operator.Value = append(append(bson.D{}, set...), stamps...)
+				merged = true
+			case bson.M:
+				stampedSet := bson.M{}
+				for _, stamp := range stamps {
+					stampedSet[stamp.Key] = stamp.Value
+				}
+				for key, value := range set {
+					stampedSet[key] = value
+				}
+				operator.Value = stampedSet
+				merged = true
+			}
+		}
+		stamped = append(stamped, operator)
+	}
+
+	if !merged {
+		stamped = append(stamped, bson.E{Key: "$set", Value: stamps})
+	}
+
+	return stamped
+}
+
 func (ffm *FeatureFlagModel) FindOne(
 	ctx context.Context,
 	filter interface{},
@@ -0,0 +1,214 @@
+package featureflagmodel
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type RuleOperator = string
+
+const (
+	OperatorEquals    RuleOperator = "equals"
+	OperatorNotEquals RuleOperator = "not_equals"
+	// OperatorIn matches any of the comma separated values of the predicate
+	OperatorIn          RuleOperator = "in"
+	OperatorContains    RuleOperator = "contains"
+	OperatorStartsWith  RuleOperator = "starts_with"
+	OperatorGreaterThan RuleOperator = "greater_than"
+	OperatorLessThan    RuleOperator = "less_than"
+	OperatorSemverGT    RuleOperator = "semver_gt"
+)
+
+type Combinator = string
+
+const (
+	// CombinatorAll matches when every condition holds, rules without a
+	// combinator combine their conditions this way
+	CombinatorAll Combinator = "all"
+	CombinatorAny Combinator = "any"
+)
+
+var (
+	ErrInvalidRuleOperator   = errors.New("rule operator is not supported")
+	ErrInvalidRuleOperand    = errors.New("predicate value doesn't suit the rule operator")
+	ErrInvalidRuleCombinator = errors.New("rule combinator must be all or any")
+	ErrIncompleteCondition   = errors.New("condition predicate is required")
+)
+
+// Condition is one predicate of a compound rule, compared with its operator
+// the same way a rule compares its own predicate
+type Condition struct {
+	Predicate string       `json:"predicate" bson:"predicate" yaml:"predicate" validate:"required"`
+	Operator  RuleOperator `json:"operator,omitempty" bson:"operator,omitempty" yaml:"operator,omitempty"`
+}
+
+func (c Condition) Validate() error {
+	if c.Predicate == "" {
+		return ErrIncompleteCondition
+	}
+
+	if !IsValidRuleOperator(c.Operator) {
+		return ErrInvalidRuleOperator
+	}
+
+	return validateOperand(c.Operator, c.Predicate)
+}
+
+func IsValidCombinator(combinator string) bool {
+	switch combinator {
+	case "", CombinatorAll, CombinatorAny:
+		return true
+	}
+
+	return false
+}
+
+// IsValidRuleOperator accepts the empty operator, rules without one
+// predate operators and compare for equality
+func IsValidRuleOperator(operator string) bool {
+	switch operator {
+	case "", OperatorEquals, OperatorNotEquals, OperatorIn, OperatorContains,
+		OperatorStartsWith, OperatorGreaterThan, OperatorLessThan, OperatorSemverGT:
+		return true
+	}
+
+	return false
+}
+
+// validateOperand checks the value of a predicate in the "attribute: value"
+// format can be compared with the operator. Predicates in any other format
+// never match and are left alone.
+func validateOperand(operator RuleOperator, predicate string) error {
+	_, value, found := strings.Cut(predicate, ":")
+	if !found {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+
+	switch operator {
+	case OperatorGreaterThan, OperatorLessThan:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return ErrInvalidRuleOperand
+		}
+	case OperatorSemverGT:
+		if _, ok := parseVersion(value); !ok {
+			return ErrInvalidRuleOperand
+		}
+	}
+
+	return nil
+}
+
+type version struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// parseVersion reads a semantic version, the leading "v" and the build
+// metadata are optional and ignored
+func parseVersion(value string) (version, bool) {
+	var parsed version
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	value, _, _ = strings.Cut(value, "+")
+	value, prerelease, hasPrerelease := strings.Cut(value, "-")
+
+	parts := strings.Split(value, ".")
+	if len(parts) != len(parsed.core) {
+		return parsed, false
+	}
+	for index, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return parsed, false
+		}
+		parsed.core[index] = number
+	}
+
+	if hasPrerelease {
+		parsed.prerelease = strings.Split(prerelease, ".")
+		for _, identifier := range parsed.prerelease {
+			if identifier == "" {
+				return parsed, false
+			}
+		}
+	}
+
+	return parsed, true
+}
+
+// compare follows the semver precedence, a prerelease ranks below its
+// release and numeric identifiers rank below alphanumeric ones
+func (v version) compare(other version) int {
+	for index := range v.core {
+		if v.core[index] != other.core[index] {
+			if v.core[index] < other.core[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+
+	for index := 0; index < len(v.prerelease) && index < len(other.prerelease); index++ {
+		if result := compareIdentifiers(v.prerelease[index], other.prerelease[index]); result != 0 {
+			return result
+		}
+	}
+
+	switch {
+	case len(v.prerelease) < len(other.prerelease):
+		return -1
+	case len(v.prerelease) > len(other.prerelease):
+		return 1
+	}
+
+	return 0
+}
+
+func compareIdentifiers(a, b string) int {
+	aNumber, aErr := strconv.ParseUint(a, 10, 64)
+	bNumber, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		if aNumber == bNumber {
+			return 0
+		}
+		if aNumber < bNumber {
+			return -1
+		}
+		return 1
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// CompareVersions compares two semantic versions, -1 when a is lower, 1
+// when it's greater. ok is false when either isn't a semantic version.
+func CompareVersions(a, b string) (result int, ok bool) {
+	aVersion, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+
+	bVersion, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+
+	return aVersion.compare(bVersion), true
+}
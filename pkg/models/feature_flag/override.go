@@ -0,0 +1,54 @@
+package featureflagmodel
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OverrideValueError points at the override serving a value the flag can't
+// hold, Err is why
+type OverrideValueError struct {
+	Key string
+	Err error
+}
+
+func (ove *OverrideValueError) Error() string {
+	return fmt.Sprintf("override %s: %s", ove.Key, ove.Err)
+}
+
+func (ove *OverrideValueError) Unwrap() error {
+	return ove.Err
+}
+
+// ValidateOverrides checks every override serves a value the flag can hold:
+// one of its type, within its bounds and following its schema. Keys are
+// checked in order so the same overrides always report the same one.
+func (ffr *FeatureFlagRecord) ValidateOverrides(overrides map[string]string) error {
+	schema, err := CompileSchema(ffr.Type, ffr.Schema)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := overrides[key]
+		if _, err := ParseValue(ffr.Type, value); err != nil {
+			return &OverrideValueError{Key: key, Err: err}
+		}
+
+		if err := ffr.NumberBounds.Check(value); err != nil {
+			return &OverrideValueError{Key: key, Err: err}
+		}
+
+		if err := CheckSchema(schema, value); err != nil {
+			return &OverrideValueError{Key: key, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package featureflagmodel_test
+
+import (
+	"errors"
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOverrides(t *testing.T) {
+	max := 10.0
+
+	testCases := []struct {
+		name      string
+		flag      featureflagmodel.FeatureFlagRecord
+		overrides map[string]string
+		key       string
+		err       error
+	}{
+		{
+			name:      "values of the flag type",
+			flag:      featureflagmodel.FeatureFlagRecord{Type: featureflagmodel.Boolean},
+			overrides: map[string]string{"alice": "true", "bob": "false"},
+		},
+		{
+			name:      "no overrides",
+			flag:      featureflagmodel.FeatureFlagRecord{Type: featureflagmodel.Boolean},
+			overrides: nil,
+		},
+		{
+			name:      "value of another type",
+			flag:      featureflagmodel.FeatureFlagRecord{Type: featureflagmodel.Boolean},
+			overrides: map[string]string{"alice": "true", "bob": "yes"},
+			key:       "bob",
+			err:       featureflagmodel.ErrInvalidValue,
+		},
+		{
+			name: "value out of bounds",
+			flag: featureflagmodel.FeatureFlagRecord{
+				Type:         featureflagmodel.Number,
+				NumberBounds: featureflagmodel.NumberBounds{Max: &max},
+			},
+			overrides: map[string]string{"alice": "5", "bob": "11"},
+			key:       "bob",
+		},
+		{
+			name: "value violating the schema",
+			flag: featureflagmodel.FeatureFlagRecord{
+				Type:   featureflagmodel.JSON,
+				Schema: `{"type": "object", "required": ["color"]}`,
+			},
+			overrides: map[string]string{"alice": `{"color": "red"}`, "bob": `{}`},
+			key:       "bob",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.flag.ValidateOverrides(testCase.overrides)
+			if testCase.key == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var overrideValueError *featureflagmodel.OverrideValueError
+			assert.True(t, errors.As(err, &overrideValueError))
+			assert.Equal(t, testCase.key, overrideValueError.Key)
+			if testCase.err != nil {
+				assert.ErrorIs(t, err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package featureflagmodel
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPrerequisiteCycle    = errors.New("prerequisites would form a cycle")
+	ErrPrerequisiteNotFound = errors.New("prerequisite feature flag not found")
+)
+
+// Prerequisite makes a flag serve its default value unless another flag,
+// evaluated in the same environment and context, serves Value
+type Prerequisite struct {
+	FeatureFlagID primitive.ObjectID `json:"feature_flag_id" bson:"feature_flag_id" validate:"required"`
+	Value         string             `json:"value" bson:"value" validate:"required"`
+}
+
+// ValidatePrerequisites checks the prerequisites proposed for the flag
+// against the other flags of its organization: every prerequisite has to
+// exist, be able to serve the required value, and not depend back on the
+// flag, directly or through other prerequisites.
+func ValidatePrerequisites(
+	featureFlagID primitive.ObjectID,
+	prerequisites []Prerequisite,
+	flags []FeatureFlagRecord,
+) error {
+	flagsByID := make(map[primitive.ObjectID]*FeatureFlagRecord, len(flags))
+	for index, flag := range flags {
+		flagsByID[flag.ID] = &flags[index]
+	}
+
+	for _, prerequisite := range prerequisites {
+		flag, ok := flagsByID[prerequisite.FeatureFlagID]
+		if !ok && prerequisite.FeatureFlagID != featureFlagID {
+			return ErrPrerequisiteNotFound
+		}
+
+		if ok {
+			if _, err := flag.TypedValue(prerequisite.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The proposed prerequisites replace the stored ones, then a cycle is any
+	// path from one of them leading back to the flag
+	dependencies := func(id primitive.ObjectID) []Prerequisite {
+		if id == featureFlagID {
+			return prerequisites
+		}
+		if flag, ok := flagsByID[id]; ok {
+			return flag.Prerequisites
+		}
+
+		return nil
+	}
+
+	visited := make(map[primitive.ObjectID]bool)
+	stack := []primitive.ObjectID{featureFlagID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, prerequisite := range dependencies(id) {
+			if prerequisite.FeatureFlagID == featureFlagID {
+				return ErrPrerequisiteCycle
+			}
+
+			if !visited[prerequisite.FeatureFlagID] {
+				visited[prerequisite.FeatureFlagID] = true
+				stack = append(stack, prerequisite.FeatureFlagID)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PrerequisiteTestSuite struct {
+	suite.Suite
+}
+
+func prerequisiteFlag(prerequisites ...featureflagmodel.Prerequisite) featureflagmodel.FeatureFlagRecord {
+	return featureflagmodel.FeatureFlagRecord{
+		ID:            primitive.NewObjectID(),
+		Type:          featureflagmodel.Boolean,
+		Prerequisites: prerequisites,
+	}
+}
+
+func (suite *PrerequisiteTestSuite) TestValidPrerequisites() {
+	t := suite.T()
+
+	a := prerequisiteFlag()
+	b := prerequisiteFlag(featureflagmodel.Prerequisite{FeatureFlagID: a.ID, Value: "true"})
+	c := prerequisiteFlag()
+
+	// c depending on b, which depends on a, is a chain and not a cycle
+	err := featureflagmodel.ValidatePrerequisites(c.ID, []featureflagmodel.Prerequisite{
+		{FeatureFlagID: b.ID, Value: "true"},
+		{FeatureFlagID: a.ID, Value: "false"},
+	}, []featureflagmodel.FeatureFlagRecord{a, b, c})
+	assert.NoError(t, err)
+}
+
+func (suite *PrerequisiteTestSuite) TestCycleIsRejected() {
+	t := suite.T()
+
+	a := prerequisiteFlag()
+	b := prerequisiteFlag(featureflagmodel.Prerequisite{FeatureFlagID: a.ID, Value: "true"})
+	c := prerequisiteFlag(featureflagmodel.Prerequisite{FeatureFlagID: b.ID, Value: "true"})
+	flags := []featureflagmodel.FeatureFlagRecord{a, b, c}
+
+	err := featureflagmodel.ValidatePrerequisites(a.ID, []featureflagmodel.Prerequisite{
+		{FeatureFlagID: c.ID, Value: "true"},
+	}, flags)
+	assert.ErrorIs(t, err, featureflagmodel.ErrPrerequisiteCycle)
+
+	err = featureflagmodel.ValidatePrerequisites(a.ID, []featureflagmodel.Prerequisite{
+		{FeatureFlagID: a.ID, Value: "true"},
+	}, flags)
+	assert.ErrorIs(t, err, featureflagmodel.ErrPrerequisiteCycle)
+}
+
+func (suite *PrerequisiteTestSuite) TestInvalidPrerequisites() {
+	t := suite.T()
+
+	a := prerequisiteFlag()
+	b := prerequisiteFlag()
+	flags := []featureflagmodel.FeatureFlagRecord{a, b}
+
+	err := featureflagmodel.ValidatePrerequisites(b.ID, []featureflagmodel.Prerequisite{
+		{FeatureFlagID: primitive.NewObjectID(), Value: "true"},
+	}, flags)
+	assert.ErrorIs(t, err, featureflagmodel.ErrPrerequisiteNotFound)
+
+	err = featureflagmodel.ValidatePrerequisites(b.ID, []featureflagmodel.Prerequisite{
+		{FeatureFlagID: a.ID, Value: "yes"},
+	}, flags)
+	assert.ErrorIs(t, err, featureflagmodel.ErrInvalidValue)
+}
+
+func TestPrerequisiteTestSuite(t *testing.T) {
+	suite.Run(t, new(PrerequisiteTestSuite))
+}
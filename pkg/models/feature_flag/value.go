@@ -0,0 +1,127 @@
+package featureflagmodel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidValue = errors.New("value does not match the flag type")
+
+// ParseValue reads a value stored as a string into the Go value of the flag
+// type: a bool, a float64, any decoded JSON value, or the string itself
+func ParseValue(flagType FlagType, value string) (interface{}, error) {
+	switch flagType {
+	case Boolean:
+		switch value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	case Number:
+		number, err := strconv.ParseFloat(value, 64)
+		// JSON has no representation for NaN and infinities
+		if err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+			return number, nil
+		}
+	case JSON:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+			return decoded, nil
+		}
+	case String:
+		return value, nil
+	}
+
+	return nil, ErrInvalidValue
+}
+
+// TypedValue parses a value served by the flag, its default value or the
+// value of one of its rules, according to the flag type
+func (ffr *FeatureFlagRecord) TypedValue(value string) (interface{}, error) {
+	return ParseValue(ffr.Type, value)
+}
+
+// RuleValueError points at the first rule serving a value the flag can't
+// hold, Err is why and defaults to ErrInvalidValue
+type RuleValueError struct {
+	Index int
+	Err   error
+}
+
+func (rve *RuleValueError) Error() string {
+	return fmt.Sprintf("rule %d: %s", rve.Index, rve.Unwrap())
+}
+
+func (rve *RuleValueError) Unwrap() error {
+	if rve.Err == nil {
+		return ErrInvalidValue
+	}
+
+	return rve.Err
+}
+
+// ValidateRuleValues checks every rule serves a value of the flag type
+func ValidateRuleValues(flagType FlagType, rules []Rule) error {
+	for index, rule := range rules {
+		if _, err := ParseValue(flagType, rule.Value); err != nil {
+			return &RuleValueError{Index: index}
+		}
+	}
+
+	return nil
+}
+
+// servesValueExpr is the aggregation expression matching flags whose
+// live_revision serves the value. Values are stored as strings, so the value
+// is compared the way each flag type reads it: numbers by what they parse
+// to, so 1 matches 1.0, and only the flag types that can hold the value are
+// considered at all, so true matches boolean and string flags but no number.
+func servesValueExpr(value string) bson.M {
+	types := bson.A{}
+	for _, flagType := range FlagTypes {
+		parsed, err := ParseValue(flagType, value)
+		if err != nil {
+			continue
+		}
+
+		equals := func(stored interface{}) bson.M {
+			return bson.M{"$eq": bson.A{stored, value}}
+		}
+		if flagType == Number {
+			equals = func(stored interface{}) bson.M {
+				return bson.M{"$eq": bson.A{
+					bson.M{"$convert": bson.M{
+						"input":   stored,
+						"to":      "double",
+						"onError": nil,
+						"onNull":  nil,
+					}},
+					parsed,
+				}}
+			}
+		}
+
+		types = append(types, bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{"$type", flagType}},
+			bson.M{"$or": bson.A{
+				equals("$live_revision.default_value"),
+				bson.M{"$anyElementTrue": bson.A{bson.M{"$map": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$live_revision.rules", bson.A{}}},
+					"as":    "rule",
+					"in": bson.M{"$and": bson.A{
+						"$$rule.is_enabled",
+						equals("$$rule.value"),
+					}},
+				}}}},
+			}},
+		}})
+	}
+
+	return bson.M{"$or": types}
+}
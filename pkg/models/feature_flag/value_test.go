@@ -0,0 +1,111 @@
+package featureflagmodel_test
+
+import (
+	"testing"
+
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValueTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ValueTestSuite) TestParseValidValues() {
+	t := suite.T()
+
+	testCases := []struct {
+		flagType featureflagmodel.FlagType
+		value    string
+		expected interface{}
+	}{
+		{featureflagmodel.Boolean, "true", true},
+		{featureflagmodel.Boolean, "false", false},
+		{featureflagmodel.Number, "42", float64(42)},
+		{featureflagmodel.Number, "-0.5", -0.5},
+		{featureflagmodel.JSON, `{"color":"blue","sizes":[1,2]}`, map[string]interface{}{
+			"color": "blue",
+			"sizes": []interface{}{float64(1), float64(2)},
+		}},
+		{featureflagmodel.JSON, `"plain"`, "plain"},
+		{featureflagmodel.String, "true", "true"},
+		{featureflagmodel.String, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		value, err := featureflagmodel.ParseValue(testCase.flagType, testCase.value)
+		assert.NoError(t, err, testCase.value)
+		assert.Equal(t, testCase.expected, value, testCase.value)
+	}
+}
+
+func (suite *ValueTestSuite) TestParseInvalidValues() {
+	t := suite.T()
+
+	testCases := []struct {
+		flagType featureflagmodel.FlagType
+		value    string
+	}{
+		{featureflagmodel.Boolean, "yes"},
+		{featureflagmodel.Boolean, "1"},
+		{featureflagmodel.Boolean, "True"},
+		{featureflagmodel.Number, "abc"},
+		{featureflagmodel.Number, "NaN"},
+		{featureflagmodel.Number, "Inf"},
+		{featureflagmodel.Number, ""},
+		{featureflagmodel.JSON, "{color: blue}"},
+		{featureflagmodel.JSON, ""},
+		{"date", "2024-01-01"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := featureflagmodel.ParseValue(testCase.flagType, testCase.value)
+		assert.ErrorIs(t, err, featureflagmodel.ErrInvalidValue, testCase.value)
+	}
+}
+
+func (suite *ValueTestSuite) TestTypedValueUsesFlagType() {
+	t := suite.T()
+
+	record := featureflagmodel.FeatureFlagRecord{Type: featureflagmodel.Number}
+	value, err := record.TypedValue("3.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, value)
+}
+
+func (suite *ValueTestSuite) TestValidateRuleValuesReportsIndex() {
+	t := suite.T()
+
+	rules := []featureflagmodel.Rule{
+		rule("plan: pro", "true"),
+		rule("plan: free", "false"),
+		rule("country: br", "hello"),
+	}
+
+	err := featureflagmodel.ValidateRuleValues(featureflagmodel.Boolean, rules)
+	assert.ErrorIs(t, err, featureflagmodel.ErrInvalidValue)
+
+	var ruleValueError *featureflagmodel.RuleValueError
+	assert.ErrorAs(t, err, &ruleValueError)
+	assert.Equal(t, 2, ruleValueError.Index)
+
+	assert.NoError(t, featureflagmodel.ValidateRuleValues(featureflagmodel.String, rules))
+	assert.NoError(t, featureflagmodel.ValidateRuleValues(featureflagmodel.Number, nil))
+}
+
+func (suite *ValueTestSuite) TestFlagTypeIsValid() {
+	t := suite.T()
+
+	for _, flagType := range featureflagmodel.FlagTypes {
+		assert.True(t, flagType.IsValid(), flagType)
+	}
+
+	for _, flagType := range []featureflagmodel.FlagType{"", "date", "Boolean", "bool"} {
+		assert.False(t, flagType.IsValid(), flagType)
+	}
+}
+
+func TestValueTestSuite(t *testing.T) {
+	suite.Run(t, new(ValueTestSuite))
+}
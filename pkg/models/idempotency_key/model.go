@@ -0,0 +1,90 @@
+package idempotencykeymodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const IdempotencyKeyCollectionName = "idempotency_key"
+
+type IdempotencyKeyModel struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func New(db *mongo.Database) *IdempotencyKeyModel {
+	return &IdempotencyKeyModel{
+		db:         db,
+		collection: db.Collection(IdempotencyKeyCollectionName),
+	}
+}
+
+// IdempotencyKeyRecord remembers the feature flag a request carrying Key
+// created, so a retry of the request is answered with it instead of
+// creating another. RequestHash tells a retry apart from a different
+// request reusing the key. Records are purged by a TTL index on ExpiresAt.
+type IdempotencyKeyRecord struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	Key            string             `json:"key" bson:"key"`
+	RequestHash    string             `json:"request_hash" bson:"request_hash"`
+	FeatureFlagID  primitive.ObjectID `json:"feature_flag_id" bson:"feature_flag_id"`
+	ExpiresAt      primitive.DateTime `json:"expires_at" bson:"expires_at"`
+}
+
+func NewIdempotencyKeyRecord(
+	organizationID primitive.ObjectID,
+	key,
+	requestHash string,
+	featureFlagID primitive.ObjectID,
+	expiresAt time.Time,
+) *IdempotencyKeyRecord {
+	return &IdempotencyKeyRecord{
+		OrganizationID: organizationID,
+		Key:            key,
+		RequestHash:    requestHash,
+		FeatureFlagID:  featureFlagID,
+		ExpiresAt:      primitive.NewDateTimeFromTime(expiresAt),
+	}
+}
+
+func (ikm *IdempotencyKeyModel) InsertOne(ctx context.Context, record *IdempotencyKeyRecord) (primitive.ObjectID, error) {
+	record.ID = primitive.NewObjectID()
+	result, err := ikm.collection.InsertOne(ctx, record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	objectID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("unable to assert type of objectID")
+	}
+
+	return objectID, nil
+}
+
+// FindByKey returns the unexpired record of key within the organization.
+// The TTL index only purges periodically, so expired records are filtered
+// out here too.
+func (ikm *IdempotencyKeyModel) FindByKey(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	key string,
+) (*IdempotencyKeyRecord, error) {
+	record := new(IdempotencyKeyRecord)
+	err := ikm.collection.FindOne(ctx, bson.D{
+		{Key: "organization_id", Value: organizationID},
+		{Key: "key", Value: key},
+		{Key: "expires_at", Value: bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now().UTC())}},
+	}).Decode(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -2,6 +2,9 @@ package organizationmodel
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -10,10 +13,14 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const OrganizationCollectionName = "organization"
 
+// DefaultEnvironmentName is the environment new organizations start with
+const DefaultEnvironmentName = "production"
+
 type OrganizationModel struct {
 	db         *mongo.Database
 	collection *mongo.Collection
@@ -79,6 +86,185 @@ func (om *OrganizationModel) FindByMember(
 		{Key: "members.user._id", Value: memberID}})
 }
 
+// FindActiveByMember pages through the organizations the user is a member
+// of, sorted by name and leaving out the soft deleted ones, along with how
+// many of them there are
+func (om *OrganizationModel) FindActiveByMember(
+	ctx context.Context,
+	memberID primitive.ObjectID,
+	page,
+	limit int,
+) ([]OrganizationRecord, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "members.user._id", Value: memberID},
+			{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+	}
+
+	return models.Paginate[OrganizationRecord](ctx, om.collection, pipeline, page, limit)
+}
+
+// NameInUseByCreator reports whether the user already created a non-deleted
+// organization other than ignoreID with the name
+func (om *OrganizationModel) NameInUseByCreator(
+	ctx context.Context,
+	creatorID primitive.ObjectID,
+	name string,
+	ignoreID primitive.ObjectID,
+) (bool, error) {
+	count, err := om.collection.CountDocuments(ctx, bson.D{
+		{Key: "_id", Value: bson.M{"$ne": ignoreID}},
+		{Key: "created_by_id", Value: creatorID},
+		{Key: "name", Value: name},
+		{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (om *OrganizationModel) FindByAPIKeyHash(ctx context.Context, hash string) (*OrganizationRecord, error) {
+	record := new(OrganizationRecord)
+	if err := om.collection.FindOne(ctx, bson.D{{Key: "api_keys.hash", Value: hash}}).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// TransferOwnership makes the member the owner and the previous owner an
+// admin in a single update, so there's never more or less than one owner.
+// A nil previous owner is for organizations created before there were
+// owners. It returns mongo.ErrNoDocuments when the member or the previous
+// owner changed in the meantime.
+func (om *OrganizationModel) TransferOwnership(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	previousOwnerID *primitive.ObjectID,
+	memberID primitive.ObjectID,
+) error {
+	filter := bson.D{
+		{Key: "_id", Value: organizationID},
+		{Key: "members.user._id", Value: memberID},
+	}
+	set := bson.D{{Key: "members.$[owner].permission_level", Value: Owner}}
+	arrayFilters := []interface{}{bson.M{"owner.user._id": memberID}}
+	if previousOwnerID == nil {
+		filter = append(filter, bson.E{Key: "members.permission_level", Value: bson.M{"$ne": Owner}})
+	} else {
+		filter = append(filter, bson.E{Key: "members", Value: bson.M{"$elemMatch": bson.M{
+			"user._id":         *previousOwnerID,
+			"permission_level": Owner,
+		}}})
+		set = append(set, bson.E{Key: "members.$[previous].permission_level", Value: Admin})
+		arrayFilters = append(arrayFilters, bson.M{"previous.user._id": *previousOwnerID})
+	}
+
+	result, err := om.collection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{{Key: "$set", Value: set}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: arrayFilters}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// keepsAdminFilter matches organizations where a member other than the
+// given one administers the organization, so it is never left without an
+// admin whatever happens to that member
+func keepsAdminFilter(memberID primitive.ObjectID) bson.M {
+	return bson.M{"$elemMatch": bson.M{
+		"user._id":         bson.M{"$ne": memberID},
+		"permission_level": bson.M{"$in": []PermissionLevelEnum{Owner, Admin}},
+	}}
+}
+
+// SetMemberRole changes the permission level of a member other than the
+// owner. Members are only demoted below admin while another member
+// administers the organization, the check and the update happen in a
+// single operation so concurrent demotions can't both get through. It
+// returns mongo.ErrNoDocuments when nothing was updated.
+func (om *OrganizationModel) SetMemberRole(
+	ctx context.Context,
+	organizationID,
+	memberID primitive.ObjectID,
+	permissionLevel PermissionLevelEnum,
+) error {
+	members := []interface{}{bson.M{"$elemMatch": bson.M{
+		"user._id":         memberID,
+		"permission_level": bson.M{"$ne": Owner},
+	}}}
+	if !HasPermission(permissionLevel, Admin) {
+		members = append(members, keepsAdminFilter(memberID))
+	}
+
+	result, err := om.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: organizationID},
+			{Key: "members", Value: bson.M{"$all": members}},
+		},
+		bson.D{{Key: "$set", Value: bson.M{"members.$[member].permission_level": permissionLevel}}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"member.user._id": memberID}},
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// RemoveMember removes a member other than the owner, as long as another
+// member administers the organization. It returns mongo.ErrNoDocuments
+// when nothing was removed.
+func (om *OrganizationModel) RemoveMember(
+	ctx context.Context,
+	organizationID,
+	memberID primitive.ObjectID,
+) error {
+	result, err := om.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: organizationID},
+			{Key: "members", Value: bson.M{"$all": []interface{}{
+				bson.M{"$elemMatch": bson.M{
+					"user._id":         memberID,
+					"permission_level": bson.M{"$ne": Owner},
+				}},
+				keepsAdminFilter(memberID),
+			}}},
+		},
+		bson.D{{Key: "$pull", Value: bson.M{"members": bson.M{"user._id": memberID}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
 func (om *OrganizationModel) UpdateOne(
 	ctx context.Context,
 	filter,
@@ -92,11 +278,45 @@ func (om *OrganizationModel) UpdateOne(
 type PermissionLevelEnum = string
 
 const (
+	// Owner is held by exactly one member, it's an Admin who can't be
+	// demoted or removed and only changes hands through a transfer
+	Owner        PermissionLevelEnum = "OWNER"
 	Admin        PermissionLevelEnum = "ADMIN"
 	Collaborator PermissionLevelEnum = "COLLABORATOR"
 	ReadOnly     PermissionLevelEnum = "READ_ONLY"
 )
 
+// permissionRanks orders the levels, each one grants everything the lower
+// ones do
+var permissionRanks = map[PermissionLevelEnum]int{
+	ReadOnly:     1,
+	Collaborator: 2,
+	Admin:        3,
+	Owner:        4,
+}
+
+// IsValidPermissionLevel reports whether members can be given the level
+// through an invite or a role change, Owner is only ever transferred
+func IsValidPermissionLevel(permissionLevel string) bool {
+	switch permissionLevel {
+	case Admin, Collaborator, ReadOnly:
+		return true
+	}
+
+	return false
+}
+
+// HasPermission reports whether a member with the level is allowed what
+// the required level is
+func HasPermission(level, required PermissionLevelEnum) bool {
+	rank, ok := permissionRanks[level]
+	if !ok {
+		return false
+	}
+
+	return rank >= permissionRanks[required]
+}
+
 type OrganizationMember struct {
 	User            usermodel.UserRecord `json:"user" bson:"user"`
 	PermissionLevel PermissionLevelEnum  `json:"permission_level" bson:"permission_level"`
@@ -112,8 +332,9 @@ const (
 )
 
 type OrganizationInvite struct {
-	Email  string
-	Status OrganizationInviteStatus
+	Email           string                   `json:"email" bson:"email"`
+	Status          OrganizationInviteStatus `json:"status" bson:"status"`
+	PermissionLevel PermissionLevelEnum      `json:"permission_level" bson:"permission_level"`
 }
 
 type OrganizationRecord struct {
@@ -124,14 +345,199 @@ type OrganizationRecord struct {
 	Environments []Environment        `json:"environments,omitempty" bson:"environments,omitempty"`
 	Projects     []Project            `json:"projects" bson:"projects"`
 	Tags         []string             `json:"tags" bson:"tags"`
+	// APIKeys are only ever exposed through the API key endpoints
+	APIKeys []APIKey `json:"-" bson:"api_keys,omitempty"`
+	// RequiredApprovals is how many distinct users must approve a revision
+	// before it goes live, unset means a single approval is enough
+	RequiredApprovals int `json:"required_approvals,omitempty" bson:"required_approvals,omitempty"`
+	// AllowSelfApproval lets the author of a revision approve it
+	AllowSelfApproval bool `json:"allow_self_approval" bson:"allow_self_approval"`
+	// DefaultEnvironment is used by the calls that leave the environment out
+	DefaultEnvironment string `json:"default_environment,omitempty" bson:"default_environment,omitempty"`
+	// MaxFlags caps how many non-deleted flags the organization can have,
+	// unset means there's no limit
+	MaxFlags int `json:"max_flags,omitempty" bson:"max_flags,omitempty"`
+	// CreatedByID is the user who created the organization, organization
+	// names are unique per creator
+	CreatedByID *primitive.ObjectID `json:"created_by_id,omitempty" bson:"created_by_id,omitempty"`
+	// DeletePermissionLevel is required to delete flags, unset means Admin
+	DeletePermissionLevel PermissionLevelEnum `json:"delete_permission_level,omitempty" bson:"delete_permission_level,omitempty"`
+	// MaintenanceMode makes every flag of the organization serve its default
+	// value, whatever its rules, until it's turned off
+	MaintenanceMode bool `json:"maintenance_mode" bson:"maintenance_mode"`
 	models.Timestamps
 }
 
+// FlagQuotaAllows reports whether the organization may have that many flags
+func (or *OrganizationRecord) FlagQuotaAllows(flags int) bool {
+	return or.MaxFlags <= 0 || flags <= or.MaxFlags
+}
+
+// HasMember reports whether a member has the email, compared normalized as
+// members and invites stored before emails were normalized may not be
+func (or *OrganizationRecord) HasMember(email string) bool {
+	email = usermodel.NormalizeEmail(email)
+	for _, member := range or.Members {
+		if usermodel.NormalizeEmail(member.User.Email) == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (or *OrganizationRecord) FindMember(userID primitive.ObjectID) *OrganizationMember {
+	for index, member := range or.Members {
+		if member.User.ID == userID {
+			return &or.Members[index]
+		}
+	}
+
+	return nil
+}
+
+// Owner is the member owning the organization, nil for organizations
+// created before there were owners
+func (or *OrganizationRecord) Owner() *OrganizationMember {
+	for index, member := range or.Members {
+		if member.PermissionLevel == Owner {
+			return &or.Members[index]
+		}
+	}
+
+	return nil
+}
+
+// AdminCount counts the members administering the organization, the owner
+// included
+func (or *OrganizationRecord) AdminCount() int {
+	count := 0
+	for _, member := range or.Members {
+		if HasPermission(member.PermissionLevel, Admin) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// HasPendingInvite reports whether the email was invited and has yet to
+// answer
+func (or *OrganizationRecord) HasPendingInvite(email string) bool {
+	email = usermodel.NormalizeEmail(email)
+	for _, invite := range or.Invites {
+		if usermodel.NormalizeEmail(invite.Email) == email && invite.Status == Pending {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FlagDeletionPermission is the permission level needed to delete a flag,
+// deleting one affects every environment so it defaults to Admin
+func (or *OrganizationRecord) FlagDeletionPermission() PermissionLevelEnum {
+	if or.DeletePermissionLevel == "" {
+		return Admin
+	}
+
+	return or.DeletePermissionLevel
+}
+
+func (or *OrganizationRecord) ApprovalThreshold() int {
+	if or.RequiredApprovals < 1 {
+		return 1
+	}
+
+	return or.RequiredApprovals
+}
+
 type Environment struct {
-	Name        string `json:"name" bson:"name"`
+	Name        string `json:"name" bson:"name" validate:"required"`
 	Description string `json:"description" bson:"description"`
 }
 
+func (or *OrganizationRecord) HasEnvironment(name string) bool {
+	for _, environment := range or.Environments {
+		if environment.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveEnvironment falls back to the default environment when no name is
+// given, an empty result means neither is set
+func (or *OrganizationRecord) ResolveEnvironment(name string) string {
+	if name != "" {
+		return name
+	}
+
+	return or.DefaultEnvironment
+}
+
+func (or *OrganizationRecord) EnvironmentNames() []string {
+	names := make([]string, 0, len(or.Environments))
+	for _, environment := range or.Environments {
+		names = append(names, environment.Name)
+	}
+
+	return names
+}
+
+const APIKeyPrefix = "tl_"
+
+// apiKeyVisibleLength is how much of a key is kept in clear so users can
+// tell their keys apart
+const apiKeyVisibleLength = len(APIKeyPrefix) + 8
+
+// APIKey grants SDKs read access to an organization. Only the hash of the
+// key is stored, the plain key is shown once when it's generated.
+type APIKey struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Prefix    string             `json:"prefix" bson:"prefix"`
+	Hash      string             `json:"-" bson:"hash"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	CreatedAt primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+// NewAPIKey generates a random key for the organization, returning the
+// record to store along with the plain key
+func NewAPIKey(userID primitive.ObjectID) (*APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	key := APIKeyPrefix + hex.EncodeToString(secret)
+
+	return &APIKey{
+		ID:        primitive.NewObjectID(),
+		Prefix:    key[:apiKeyVisibleLength],
+		Hash:      HashAPIKey(key),
+		UserID:    userID,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now().UTC()),
+	}, key, nil
+}
+
+// HashAPIKey digests a plain key. Keys are random enough that a plain
+// sha256 is safe and, unlike bcrypt, lets keys be looked up by hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (or *OrganizationRecord) FindAPIKey(id primitive.ObjectID) *APIKey {
+	for index, apiKey := range or.APIKeys {
+		if apiKey.ID == id {
+			return &or.APIKeys[index]
+		}
+	}
+
+	return nil
+}
+
 type Project struct {
 	ID          primitive.ObjectID `json:"_id" bson:"_id"`
 	Name        string             `json:"name" bson:"name"`
@@ -142,6 +548,7 @@ func NewOrganizationRecord(name string, members []OrganizationMember) *Organizat
 	return &OrganizationRecord{
 		Name:     name,
 		Members:  members,
+		Invites:  []OrganizationInvite{},
 		Projects: []Project{},
 		Tags:     []string{},
 		Timestamps: models.Timestamps{
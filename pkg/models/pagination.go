@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Page is what the pagination facet yields, the documents of the requested
+// page along with how many documents there are across all pages
+type Page[T any] struct {
+	Data  []T `bson:"data"`
+	Total []struct {
+		Count int `bson:"count"`
+	} `bson:"total"`
+}
+
+// Count is the number of documents across all pages, the facet leaves the
+// total empty when nothing matches
+func (p Page[T]) Count() int {
+	if len(p.Total) == 0 {
+		return 0
+	}
+
+	return p.Total[0].Count
+}
+
+// PaginationStage splits the documents reaching it into the requested page
+// and their total count, so both come back in one round trip
+func PaginationStage(page, limit int) bson.D {
+	return bson.D{{Key: "$facet", Value: bson.M{
+		"data": bson.A{
+			bson.M{"$skip": int64((page - 1) * limit)},
+			bson.M{"$limit": int64(limit)},
+		},
+		"total": bson.A{
+			bson.M{"$count": "count"},
+		},
+	}}}
+}
+
+// Paginate runs the pipeline, which matches and sorts the documents, and
+// returns the requested page along with the number of matching documents
+func Paginate[T any](
+	ctx context.Context,
+	collection *mongo.Collection,
+	pipeline mongo.Pipeline,
+	page,
+	limit int,
+) ([]T, int, error) {
+	stages := make(mongo.Pipeline, 0, len(pipeline)+1)
+	stages = append(stages, pipeline...)
+	stages = append(stages, PaginationStage(page, limit))
+
+	cursor, err := collection.Aggregate(ctx, stages)
+	if err != nil {
+		return make([]T, 0), 0, err
+	}
+	defer cursor.Close(ctx)
+
+	pages := make([]Page[T], 0, 1)
+	if err := cursor.All(ctx, &pages); err != nil {
+		return make([]T, 0), 0, err
+	}
+
+	items := make([]T, 0)
+	if len(pages) == 0 {
+		return items, 0, nil
+	}
+
+	return append(items, pages[0].Data...), pages[0].Count(), nil
+}
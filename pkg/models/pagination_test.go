@@ -0,0 +1,70 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type item struct {
+	Name string `bson:"name"`
+}
+
+func TestPaginationStage(t *testing.T) {
+	stage := models.PaginationStage(3, 20)
+
+	assert.Equal(t, "$facet", stage[0].Key)
+	facet := stage[0].Value.(bson.M)
+	assert.Equal(t, bson.A{
+		bson.M{"$skip": int64(40)},
+		bson.M{"$limit": int64(20)},
+	}, facet["data"])
+	assert.Equal(t, bson.A{bson.M{"$count": "count"}}, facet["total"])
+}
+
+func TestPageCount(t *testing.T) {
+	testCases := []struct {
+		name  string
+		facet bson.M
+		count int
+	}{
+		{
+			name: "counts every matching document",
+			facet: bson.M{
+				"data":  bson.A{bson.M{"name": "third"}},
+				"total": bson.A{bson.M{"count": 3}},
+			},
+			count: 3,
+		},
+		{
+			name: "past the last page",
+			facet: bson.M{
+				"data":  bson.A{},
+				"total": bson.A{bson.M{"count": 3}},
+			},
+			count: 3,
+		},
+		{
+			name: "nothing matches",
+			facet: bson.M{
+				"data":  bson.A{},
+				"total": bson.A{},
+			},
+			count: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			raw, err := bson.Marshal(testCase.facet)
+			assert.NoError(t, err)
+
+			var page models.Page[item]
+			assert.NoError(t, bson.Unmarshal(raw, &page))
+			assert.Equal(t, testCase.count, page.Count())
+			assert.Len(t, page.Data, len(testCase.facet["data"].(bson.A)))
+		})
+	}
+}
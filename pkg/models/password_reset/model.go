@@ -0,0 +1,32 @@
+package passwordresetmodel
+
+import (
+	"time"
+
+	singleusetokenmodel "github.com/Roll-Play/togglelabs/pkg/models/single_use_token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const PasswordResetCollectionName = "password_reset"
+
+type PasswordResetModel = singleusetokenmodel.SingleUseTokenModel[singleusetokenmodel.NoPayload]
+
+// PasswordResetRecord holds the hash of a password reset token, the token
+// itself is only ever sent to the user. UsedAt is set once the password is
+// reset or the token revoked.
+type PasswordResetRecord = singleusetokenmodel.SingleUseTokenRecord[singleusetokenmodel.NoPayload]
+
+func New(db *mongo.Database) *PasswordResetModel {
+	return singleusetokenmodel.New[singleusetokenmodel.NoPayload](db, PasswordResetCollectionName)
+}
+
+// NewPasswordResetRecord generates a token for the user valid for the given
+// duration, returning the record to store along with the plain token
+func NewPasswordResetRecord(userID primitive.ObjectID, expireIn time.Duration) (*PasswordResetRecord, string, error) {
+	return singleusetokenmodel.NewRecord(userID, singleusetokenmodel.NoPayload{}, expireIn)
+}
+
+func HashToken(token string) string {
+	return singleusetokenmodel.HashToken(token)
+}
@@ -0,0 +1,32 @@
+package refreshtokenmodel
+
+import (
+	"time"
+
+	singleusetokenmodel "github.com/Roll-Play/togglelabs/pkg/models/single_use_token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const RefreshTokenCollectionName = "refresh_token"
+
+type RefreshTokenModel = singleusetokenmodel.SingleUseTokenModel[singleusetokenmodel.NoPayload]
+
+// RefreshTokenRecord holds the hash of a refresh token, the token itself is
+// only ever known by the client. UsedAt is set once the token is exchanged
+// or revoked.
+type RefreshTokenRecord = singleusetokenmodel.SingleUseTokenRecord[singleusetokenmodel.NoPayload]
+
+func New(db *mongo.Database) *RefreshTokenModel {
+	return singleusetokenmodel.New[singleusetokenmodel.NoPayload](db, RefreshTokenCollectionName)
+}
+
+// NewRefreshTokenRecord generates a token for the user valid for the given
+// duration, returning the record to store along with the plain token
+func NewRefreshTokenRecord(userID primitive.ObjectID, expireIn time.Duration) (*RefreshTokenRecord, string, error) {
+	return singleusetokenmodel.NewRecord(userID, singleusetokenmodel.NoPayload{}, expireIn)
+}
+
+func HashToken(token string) string {
+	return singleusetokenmodel.HashToken(token)
+}
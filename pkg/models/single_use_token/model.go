@@ -0,0 +1,151 @@
+package singleusetokenmodel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SingleUseTokenModel stores the tokens of one collection, P is the payload
+// each of its tokens carries along with the user
+type SingleUseTokenModel[P any] struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func New[P any](db *mongo.Database, collectionName string) *SingleUseTokenModel[P] {
+	return &SingleUseTokenModel[P]{
+		db:         db,
+		collection: db.Collection(collectionName),
+	}
+}
+
+// NoPayload is the payload of tokens that only identify the user
+type NoPayload struct{}
+
+// SingleUseTokenRecord holds the hash of a token, the token itself is only
+// ever known by whoever it was handed to. Tokens are single use, UsedAt is set
+// once the token is exchanged or revoked. The payload is stored inline so
+// its fields sit next to the common ones.
+type SingleUseTokenRecord[P any] struct {
+	ID        primitive.ObjectID  `json:"_id" bson:"_id"`
+	UserID    primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	Payload   P                   `json:"payload" bson:",inline"`
+	Hash      string              `json:"-" bson:"hash"`
+	ExpiresAt primitive.DateTime  `json:"expires_at" bson:"expires_at"`
+	UsedAt    *primitive.DateTime `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	CreatedAt primitive.DateTime  `json:"created_at" bson:"created_at"`
+}
+
+// HashIndex backs the lookups by token hash
+var HashIndex = mongo.IndexModel{
+	Keys: bson.D{{Key: "hash", Value: 1}},
+}
+
+// ExpiryIndex drops tokens once they expire, used or not
+var ExpiryIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "expires_at", Value: 1}},
+	Options: options.Index().SetExpireAfterSeconds(0),
+}
+
+// NewRecord generates a token for the user valid for the given duration,
+// returning the record to store along with the plain token
+func NewRecord[P any](
+	userID primitive.ObjectID,
+	payload P,
+	expireIn time.Duration,
+) (*SingleUseTokenRecord[P], string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	token := hex.EncodeToString(secret)
+	now := time.Now().UTC()
+
+	return &SingleUseTokenRecord[P]{
+		UserID:    userID,
+		Payload:   payload,
+		Hash:      HashToken(token),
+		ExpiresAt: primitive.NewDateTimeFromTime(now.Add(expireIn)),
+		CreatedAt: primitive.NewDateTimeFromTime(now),
+	}, token, nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (sutr *SingleUseTokenRecord[P]) IsExpired(now time.Time) bool {
+	return !now.Before(sutr.ExpiresAt.Time())
+}
+
+func (sutm *SingleUseTokenModel[P]) InsertOne(
+	ctx context.Context,
+	record *SingleUseTokenRecord[P],
+) (primitive.ObjectID, error) {
+	record.ID = primitive.NewObjectID()
+	result, err := sutm.collection.InsertOne(ctx, record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	objectID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("unable to assert type of objectID")
+	}
+
+	return objectID, nil
+}
+
+func (sutm *SingleUseTokenModel[P]) FindByHash(ctx context.Context, hash string) (*SingleUseTokenRecord[P], error) {
+	record := new(SingleUseTokenRecord[P])
+	if err := sutm.collection.FindOne(ctx, bson.D{{Key: "hash", Value: hash}}).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Consume marks the unused token with the given hash as used and returns it.
+// The check and the update happen in a single operation so a token can't
+// be used twice when requests race.
+func (sutm *SingleUseTokenModel[P]) Consume(ctx context.Context, hash string) (*SingleUseTokenRecord[P], error) {
+	record := new(SingleUseTokenRecord[P])
+	err := sutm.collection.FindOneAndUpdate(
+		ctx,
+		bson.D{
+			{Key: "hash", Value: hash},
+			{Key: "used_at", Value: bson.M{"$exists": false}},
+		},
+		bson.D{{Key: "$set", Value: bson.M{"used_at": primitive.NewDateTimeFromTime(time.Now().UTC())}}},
+	).Decode(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// RevokeAllForUser marks every outstanding token of the user as used
+func (sutm *SingleUseTokenModel[P]) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := sutm.collection.UpdateMany(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "used_at", Value: bson.M{"$exists": false}},
+		},
+		bson.D{{Key: "$set", Value: bson.M{"used_at": primitive.NewDateTimeFromTime(time.Now().UTC())}}},
+	)
+
+	return err
+}
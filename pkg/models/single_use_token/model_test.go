@@ -0,0 +1,83 @@
+package singleusetokenmodel_test
+
+import (
+	"testing"
+	"time"
+
+	singleusetokenmodel "github.com/Roll-Play/togglelabs/pkg/models/single_use_token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SingleUseTokenTestSuite struct {
+	suite.Suite
+}
+
+type emailPayload struct {
+	Email string `bson:"email"`
+}
+
+func (suite *SingleUseTokenTestSuite) TestNewRecordStoresOnlyTheHash() {
+	t := suite.T()
+
+	userID := primitive.NewObjectID()
+	record, token, err := singleusetokenmodel.NewRecord(userID, emailPayload{Email: "new@mail.com"}, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, token, 64)
+	assert.NotEqual(t, token, record.Hash)
+	assert.Equal(t, singleusetokenmodel.HashToken(token), record.Hash)
+	assert.Equal(t, userID, record.UserID)
+	assert.Equal(t, "new@mail.com", record.Payload.Email)
+	assert.Nil(t, record.UsedAt)
+
+	_, other, err := singleusetokenmodel.NewRecord(userID, emailPayload{}, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func (suite *SingleUseTokenTestSuite) TestIsExpired() {
+	t := suite.T()
+
+	record, _, err := singleusetokenmodel.NewRecord(
+		primitive.NewObjectID(),
+		singleusetokenmodel.NoPayload{},
+		time.Hour,
+	)
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	assert.False(t, record.IsExpired(now))
+	assert.True(t, record.IsExpired(now.Add(2*time.Hour)))
+	assert.True(t, record.IsExpired(record.ExpiresAt.Time()))
+}
+
+func (suite *SingleUseTokenTestSuite) TestPayloadIsStoredInline() {
+	t := suite.T()
+
+	record, _, err := singleusetokenmodel.NewRecord(
+		primitive.NewObjectID(),
+		emailPayload{Email: "new@mail.com"},
+		time.Hour,
+	)
+	assert.NoError(t, err)
+
+	raw, err := bson.Marshal(record)
+	assert.NoError(t, err)
+
+	var document bson.M
+	assert.NoError(t, bson.Unmarshal(raw, &document))
+	assert.Equal(t, "new@mail.com", document["email"])
+	assert.Equal(t, record.Hash, document["hash"])
+	assert.NotContains(t, document, "payload")
+	assert.NotContains(t, document, "used_at")
+
+	decoded := new(singleusetokenmodel.SingleUseTokenRecord[emailPayload])
+	assert.NoError(t, bson.Unmarshal(raw, decoded))
+	assert.Equal(t, "new@mail.com", decoded.Payload.Email)
+}
+
+func TestSingleUseTokenTestSuite(t *testing.T) {
+	suite.Run(t, new(SingleUseTokenTestSuite))
+}
@@ -3,24 +3,97 @@ package timelinemodel
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/Roll-Play/togglelabs/pkg/models"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const TimelineCollectionName = "timeline"
 
 const (
-	Created             = "FeatureFlag created"
-	RevisionCreated     = "Revision created"
-	RevisionApproved    = "Revision approved"
-	FeatureFlagRollback = "FeatureFlag rollback"
-	FeatureFlagDeleted  = "FeatureFlag deleted"
-	FeatureFlagToggle   = "FeatureFlag environment %s toggle"
+	ChangeSetIDMetadataKey     = "change_set_id"
+	ReleaseNoteMetadataKey     = "release_note"
+	RevisionIDMetadataKey      = "revision_id"
+	RevisionStatusMetadataKey  = "revision_status"
+	OldDefaultValueMetadataKey = "old_default_value"
+	NewDefaultValueMetadataKey = "new_default_value"
+	OldRulesMetadataKey        = "old_rules"
+	NewRulesMetadataKey        = "new_rules"
+	EnvironmentMetadataKey     = "environment"
+	IsEnabledMetadataKey       = "is_enabled"
+	OldNameMetadataKey         = "old_name"
+	NewNameMetadataKey         = "new_name"
 )
 
+const (
+	Created               = "FeatureFlag created"
+	RevisionCreated       = "Revision created"
+	RevisionApproved      = "Revision approved"
+	RevisionApprovalAdded = "Revision approval added"
+	RevisionScheduled     = "Revision scheduled to go live at %s"
+	RevisionRejected      = "Revision rejected"
+	FeatureFlagRollback   = "FeatureFlag rollback"
+	FeatureFlagDeleted    = "FeatureFlag deleted"
+	FeatureFlagToggle     = "FeatureFlag environment %s toggle"
+	EnvironmentAdded      = "FeatureFlag environment %s added"
+	EnvironmentRemoved    = "FeatureFlag environment %s removed"
+	FeatureFlagRenamed    = "FeatureFlag renamed from %s to %s"
+	FeatureFlagRestored   = "FeatureFlag restored"
+	PrerequisitesChanged  = "FeatureFlag prerequisites changed"
+	OverridesChanged      = "FeatureFlag overrides changed"
+	DescriptionChanged    = "FeatureFlag description changed"
+	FeatureFlagArchived   = "FeatureFlag archived"
+	FeatureFlagUnarchived = "FeatureFlag unarchived"
+)
+
+// actionFilters groups the entry actions under the names accepted by the
+// timeline action filter
+var actionFilters = map[string][]string{
+	"created":      {Created},
+	"revision":     {RevisionCreated},
+	"approval":     {RevisionApproved, RevisionApprovalAdded, RevisionScheduled},
+	"rejection":    {RevisionRejected},
+	"rollback":     {FeatureFlagRollback},
+	"delete":       {FeatureFlagDeleted},
+	"toggle":       {FeatureFlagToggle},
+	"environment":  {EnvironmentAdded, EnvironmentRemoved},
+	"rename":       {FeatureFlagRenamed},
+	"restore":      {FeatureFlagRestored},
+	"prerequisite": {PrerequisitesChanged},
+	"override":     {OverridesChanged},
+	"description":  {DescriptionChanged},
+	"archive":      {FeatureFlagArchived, FeatureFlagUnarchived},
+}
+
+func IsValidActionFilter(filter string) bool {
+	_, ok := actionFilters[filter]
+	return ok
+}
+
+// actionPattern turns an action format into a pattern matching the actions
+// it produces, with every %s standing for any text
+func actionPattern(format string) string {
+	parts := strings.Split(format, "%s")
+	for index, part := range parts {
+		parts[index] = regexp.QuoteMeta(part)
+	}
+
+	return "^" + strings.Join(parts, ".*") + "$"
+}
+
+// matchesAction reports whether action was produced by the given action
+// format
+func matchesAction(action, format string) bool {
+	return regexp.MustCompile(actionPattern(format)).MatchString(action)
+}
+
 type TimelineModel struct {
 	db         *mongo.Database
 	collection *mongo.Collection
@@ -34,9 +107,10 @@ func New(db *mongo.Database) *TimelineModel {
 }
 
 type TimelineEntry struct {
-	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
-	Action    string             `json:"action" bson:"action"`
-	Timestamp primitive.DateTime `json:"timestamp" bson:"timestamp"`
+	UserID    primitive.ObjectID     `json:"user_id" bson:"user_id"`
+	Action    string                 `json:"action" bson:"action"`
+	Timestamp primitive.DateTime     `json:"timestamp" bson:"timestamp"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
 }
 
 type TimelineRecord struct {
@@ -45,14 +119,40 @@ type TimelineRecord struct {
 	Entries       []TimelineEntry    `json:"entries" bson:"entries"`
 }
 
-func NewTimelineEntry(userID primitive.ObjectID, action string) *TimelineEntry {
+// NewTimelineEntry builds an entry for the action taken by the user.
+// Metadata is optional and may be nil when there's no context to record.
+func NewTimelineEntry(userID primitive.ObjectID, action string, metadata map[string]interface{}) *TimelineEntry {
 	return &TimelineEntry{
 		UserID:    userID,
 		Action:    action,
 		Timestamp: primitive.NewDateTimeFromTime(time.Now().UTC()),
+		Metadata:  metadata,
 	}
 }
 
+// FilterEntries returns the entries newest first, keeping only the ones
+// matching the given action filter when one is set
+func (tr *TimelineRecord) FilterEntries(filter string) []TimelineEntry {
+	entries := make([]TimelineEntry, 0, len(tr.Entries))
+	for index := len(tr.Entries) - 1; index >= 0; index-- {
+		if filter == "" || entryMatches(tr.Entries[index], filter) {
+			entries = append(entries, tr.Entries[index])
+		}
+	}
+
+	return entries
+}
+
+func entryMatches(entry TimelineEntry, filter string) bool {
+	for _, format := range actionFilters[filter] {
+		if matchesAction(entry.Action, format) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (tm *TimelineModel) InsertOne(ctx context.Context, record *TimelineRecord) (primitive.ObjectID, error) {
 	record.ID = primitive.NewObjectID()
 	result, err := tm.collection.InsertOne(ctx, record)
@@ -68,6 +168,18 @@ func (tm *TimelineModel) InsertOne(ctx context.Context, record *TimelineRecord)
 	return objectID, nil
 }
 
+// InsertMany inserts the records, giving each an ID
+func (tm *TimelineModel) InsertMany(ctx context.Context, records []*TimelineRecord) error {
+	documents := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		record.ID = primitive.NewObjectID()
+		documents = append(documents, record)
+	}
+
+	_, err := tm.collection.InsertMany(ctx, documents)
+	return err
+}
+
 func (tm *TimelineModel) UpdateOne(
 	ctx context.Context,
 	featureFlagID primitive.ObjectID,
@@ -85,6 +197,17 @@ func (tm *TimelineModel) UpdateOne(
 	return nil
 }
 
+// DeleteByFeatureFlagID deletes the timeline of a flag, reporting how many
+// records were deleted
+func (tm *TimelineModel) DeleteByFeatureFlagID(ctx context.Context, featureFlagID primitive.ObjectID) (int, error) {
+	result, err := tm.collection.DeleteMany(ctx, bson.D{{Key: "feature_flag_id", Value: featureFlagID}})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.DeletedCount), nil
+}
+
 func (ffm *TimelineModel) FindByID(ctx context.Context, id primitive.ObjectID) (*TimelineRecord, error) {
 	record := new(TimelineRecord)
 	if err := ffm.collection.FindOne(ctx, bson.D{{Key: "feature_flag_id", Value: id}}).Decode(record); err != nil {
@@ -92,3 +215,116 @@ func (ffm *TimelineModel) FindByID(ctx context.Context, id primitive.ObjectID) (
 	}
 	return record, nil
 }
+
+func (tm *TimelineModel) FindFeatureFlagIDsByChangeSet(
+	ctx context.Context,
+	changeSetID string,
+) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"feature_flag_id": 1})
+	cursor, err := tm.collection.Find(ctx, bson.D{
+		{Key: "entries.metadata." + ChangeSetIDMetadataKey, Value: changeSetID},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := make([]TimelineRecord, 0)
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	featureFlagIDs := make([]primitive.ObjectID, 0, len(records))
+	for _, record := range records {
+		featureFlagIDs = append(featureFlagIDs, record.FeatureFlagID)
+	}
+
+	return featureFlagIDs, nil
+}
+
+// ActivityEntry is a timeline entry along with the flag it was recorded on
+type ActivityEntry struct {
+	FeatureFlagID   primitive.ObjectID `json:"feature_flag_id" bson:"feature_flag_id"`
+	FeatureFlagName string             `json:"feature_flag_name" bson:"feature_flag_name"`
+	TimelineEntry   `bson:",inline"`
+}
+
+// ActivityFilter narrows the organization activity, zero fields don't filter
+type ActivityFilter struct {
+	UserID primitive.ObjectID
+	Action string
+	From   time.Time
+	To     time.Time
+}
+
+func (af *ActivityFilter) entryMatch() bson.D {
+	match := bson.D{}
+	if !af.UserID.IsZero() {
+		match = append(match, bson.E{Key: "entries.user_id", Value: af.UserID})
+	}
+
+	if af.Action != "" {
+		patterns := bson.A{}
+		for _, format := range actionFilters[af.Action] {
+			patterns = append(patterns, primitive.Regex{Pattern: actionPattern(format)})
+		}
+		match = append(match, bson.E{Key: "entries.action", Value: bson.M{"$in": patterns}})
+	}
+
+	timestamp := bson.M{}
+	if !af.From.IsZero() {
+		timestamp["$gte"] = primitive.NewDateTimeFromTime(af.From)
+	}
+	if !af.To.IsZero() {
+		timestamp["$lte"] = primitive.NewDateTimeFromTime(af.To)
+	}
+	if len(timestamp) > 0 {
+		match = append(match, bson.E{Key: "entries.timestamp", Value: timestamp})
+	}
+
+	return match
+}
+
+// FindOrganizationActivity merges the timelines of every flag of the
+// organization, deleted ones included, newest entry first. It returns the
+// requested page along with the number of matching entries.
+func (tm *TimelineModel) FindOrganizationActivity(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	filter ActivityFilter,
+	page,
+	limit int,
+) ([]ActivityEntry, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "organization_id", Value: organizationID}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         TimelineCollectionName,
+			"localField":   "_id",
+			"foreignField": "feature_flag_id",
+			"as":           "timeline",
+		}}},
+		{{Key: "$unwind", Value: "$timeline"}},
+		{{Key: "$unwind", Value: "$timeline.entries"}},
+		{{Key: "$project", Value: bson.M{
+			"_id":               0,
+			"feature_flag_id":   "$_id",
+			"feature_flag_name": "$name",
+			"entries":           "$timeline.entries",
+		}}},
+		{{Key: "$match", Value: filter.entryMatch()}},
+		{{Key: "$sort", Value: bson.D{{Key: "entries.timestamp", Value: -1}}}},
+		{{Key: "$project", Value: bson.M{
+			"feature_flag_id":   1,
+			"feature_flag_name": 1,
+			"user_id":           "$entries.user_id",
+			"action":            "$entries.action",
+			"timestamp":         "$entries.timestamp",
+			"metadata":          "$entries.metadata",
+		}}},
+	}
+
+	// Timelines carry no organization, the flags they belong to do
+	collection := tm.db.Collection(featureflagmodel.FeatureFlagCollectionName)
+
+	return models.Paginate[ActivityEntry](ctx, collection, pipeline, page, limit)
+}
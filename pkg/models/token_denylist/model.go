@@ -0,0 +1,65 @@
+package tokendenylistmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const TokenDenylistCollectionName = "token_denylist"
+
+type TokenDenylistModel struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func New(db *mongo.Database) *TokenDenylistModel {
+	return &TokenDenylistModel{
+		db:         db,
+		collection: db.Collection(TokenDenylistCollectionName),
+	}
+}
+
+// TokenDenylistRecord marks the JWT with the given jti as revoked. Records
+// are purged by a TTL index on ExpiresAt once the token would have expired
+// anyway.
+type TokenDenylistRecord struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	TokenID   string             `json:"token_id" bson:"token_id"`
+	ExpiresAt primitive.DateTime `json:"expires_at" bson:"expires_at"`
+}
+
+func NewTokenDenylistRecord(tokenID string, expiresAt time.Time) *TokenDenylistRecord {
+	return &TokenDenylistRecord{
+		TokenID:   tokenID,
+		ExpiresAt: primitive.NewDateTimeFromTime(expiresAt),
+	}
+}
+
+func (tdm *TokenDenylistModel) InsertOne(ctx context.Context, record *TokenDenylistRecord) (primitive.ObjectID, error) {
+	record.ID = primitive.NewObjectID()
+	result, err := tdm.collection.InsertOne(ctx, record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	objectID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("unable to assert type of objectID")
+	}
+
+	return objectID, nil
+}
+
+func (tdm *TokenDenylistModel) IsDenied(ctx context.Context, tokenID string) (bool, error) {
+	count, err := tdm.collection.CountDocuments(ctx, bson.D{{Key: "token_id", Value: tokenID}})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WithTransaction runs fn in a transaction, every model call made with the
+// session context handed to fn commits or rolls back along with the others.
+// The driver runs fn again when the transaction hits a transient error, so
+// fn shouldn't have side effects besides its writes. Transactions need the
+// database to be a replica set member.
+func WithTransaction(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) error) error {
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext)
+	})
+
+	return err
+}
@@ -3,7 +3,10 @@ package usermodel
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Roll-Play/togglelabs/pkg/config"
 	"github.com/Roll-Play/togglelabs/pkg/models"
@@ -41,9 +44,15 @@ type UserWithOrganization struct {
 	Organizations []UserOrganization `json:"organizations" bson:"organizations"`
 }
 
+// FindUserOrganization finds a user, soft deleted ones aside, along with the
+// organizations they belong to. It returns mongo.ErrNoDocuments when there's
+// no such user.
 func (um *UserModel) FindUserOrganization(ctx context.Context, id primitive.ObjectID) (*UserWithOrganization, error) {
 	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"_id": id}}},
+		{{Key: "$match", Value: bson.M{
+			"_id":                   id,
+			"timestamps.deleted_at": bson.M{"$exists": false},
+		}}},
 		{{Key: "$lookup", Value: bson.M{
 			"from":         "organization",
 			"localField":   "_id",
@@ -70,7 +79,12 @@ func (um *UserModel) FindUserOrganization(ctx context.Context, id primitive.Obje
 	}
 	defer cursor.Close(ctx)
 
-	cursor.Next(ctx)
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return nil, mongo.ErrNoDocuments
+	}
 	if err := cursor.Decode(user); err != nil {
 		return nil, err
 	}
@@ -78,9 +92,15 @@ func (um *UserModel) FindUserOrganization(ctx context.Context, id primitive.Obje
 	return user, nil
 }
 
+// FindByID and FindByEmail leave out soft deleted users, their records are
+// kept around but they can't sign in or be looked up anymore
 func (um *UserModel) FindByID(ctx context.Context, id primitive.ObjectID) (*UserRecord, error) {
 	record := new(UserRecord)
-	if err := um.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(record); err != nil {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+	}
+	if err := um.collection.FindOne(ctx, filter).Decode(record); err != nil {
 		return nil, err
 	}
 
@@ -89,15 +109,42 @@ func (um *UserModel) FindByID(ctx context.Context, id primitive.ObjectID) (*User
 
 func (um *UserModel) FindByEmail(ctx context.Context, email string) (*UserRecord, error) {
 	record := new(UserRecord)
-	if err := um.collection.FindOne(ctx, bson.D{{Key: "email", Value: email}}).Decode(record); err != nil {
+	filter := bson.D{
+		{Key: "email", Value: NormalizeEmail(email)},
+		{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+	}
+	if err := um.collection.FindOne(ctx, filter).Decode(record); err != nil {
 		return nil, err
 	}
 
 	return record, nil
 }
 
+// FindActiveByIDs returns the users with the given ids, leaving out the
+// soft deleted ones
+func (um *UserModel) FindActiveByIDs(ctx context.Context, ids []primitive.ObjectID) ([]UserRecord, error) {
+	records := make([]UserRecord, 0)
+	cursor, err := um.collection.Find(ctx, bson.D{
+		{Key: "_id", Value: bson.M{"$in": ids}},
+		{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+	})
+	if err != nil {
+		return records, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &records); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
+
 func (um *UserModel) InsertOne(ctx context.Context, record *UserRecord) (primitive.ObjectID, error) {
 	record.ID = primitive.NewObjectID()
+	// Records built outside of NewUserRecord, like the OAuth ones, are
+	// normalized here
+	record.Email = NormalizeEmail(record.Email)
 	result, err := um.collection.InsertOne(ctx, record)
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -128,16 +175,97 @@ func (um *UserModel) UpdateOne(
 	return err
 }
 
+// UpdatePassword stores the bcrypt hash of the given password
+func (um *UserModel) UpdatePassword(ctx context.Context, id primitive.ObjectID, password string) error {
+	encryptedPassword, err := encryptPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return um.UpdateOne(ctx, id, bson.D{{Key: "password", Value: encryptedPassword}})
+}
+
+// EmailInUse reports whether any user, soft deleted ones included, has the
+// given email
+func (um *UserModel) EmailInUse(ctx context.Context, email string) (bool, error) {
+	count, err := um.collection.CountDocuments(ctx, bson.D{{Key: "email", Value: NormalizeEmail(email)}})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ConfirmEmail replaces the email of the user with its pending email, as
+// long as the pending email is still the given one
+func (um *UserModel) ConfirmEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+	result, err := um.collection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "pending_email", Value: email},
+			{Key: "timestamps.deleted_at", Value: bson.M{"$exists": false}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "email", Value: email},
+				{Key: "timestamps.updated_at", Value: primitive.NewDateTimeFromTime(time.Now().UTC())},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "pending_email", Value: ""}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// SoftDelete flags the user as deleted. The email stays taken so the account
+// can still be restored.
+func (um *UserModel) SoftDelete(ctx context.Context, id primitive.ObjectID) error {
+	return um.UpdateOne(ctx, id, bson.D{{
+		Key:   "timestamps.deleted_at",
+		Value: primitive.NewDateTimeFromTime(time.Now().UTC()),
+	}})
+}
+
+// IsDeleted reports whether the user was soft deleted, users that were never
+// stored aren't deleted
+func (um *UserModel) IsDeleted(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := um.collection.CountDocuments(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "timestamps.deleted_at", Value: bson.M{"$exists": true}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 type UserRecord struct {
 	ID        primitive.ObjectID `json:"_id" bson:"_id"`
 	Email     string             `json:"email" bson:"email"`
 	OAuthID   string             `json:"oauth_id,omitempty" bson:"oauth_id,omitempty"`
-	Password  string             `json:"password,omitempty" bson:"password,omitempty"`
+	Password  string             `json:"-" bson:"password,omitempty"`
 	FirstName string             `json:"first_name,omitempty" bson:"first_name,omitempty"`
 	LastName  string             `json:"last_name,omitempty" bson:"last_name,omitempty"`
+	// PendingEmail replaces Email once the user confirms they own it
+	PendingEmail string `json:"pending_email,omitempty" bson:"pending_email,omitempty"`
 	models.Timestamps
 }
 
+// NormalizeEmail trims and lowercases an email, emails are only ever stored
+// and looked up normalized so their case can't tell two users apart
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func NewUserRecord(email, password, firstName, lastName string) (*UserRecord, error) {
 	ep, err := encryptPassword(password)
 	if err != nil {
@@ -145,7 +273,7 @@ func NewUserRecord(email, password, firstName, lastName string) (*UserRecord, er
 	}
 
 	return &UserRecord{
-		Email:     email,
+		Email:     NormalizeEmail(email),
 		Password:  ep,
 		FirstName: firstName,
 		LastName:  lastName,
@@ -155,6 +283,53 @@ func NewUserRecord(email, password, firstName, lastName string) (*UserRecord, er
 		}}, nil
 }
 
+var (
+	ErrPasswordTooShort      = errors.New("password is too short")
+	ErrPasswordTooFewClasses = errors.New("password mixes too few character classes")
+)
+
+// CheckPassword reports the rule of the password policy the password
+// breaks, if any. Passwords need config.PasswordMinLength characters out of
+// config.PasswordMinCharacterClasses classes: lowercase letters, uppercase
+// letters, digits and symbols.
+func CheckPassword(password string) error {
+	if utf8.RuneCountInString(password) < config.PasswordMinLength {
+		return ErrPasswordTooShort
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			upper = true
+		case unicode.IsLetter(char):
+			lower = true
+		case unicode.IsDigit(char):
+			digit = true
+		case !unicode.IsSpace(char):
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+
+	if classes < config.PasswordMinCharacterClasses {
+		return ErrPasswordTooFewClasses
+	}
+
+	return nil
+}
+
+// IsStrongPassword reports whether the password follows the password policy
+func IsStrongPassword(password string) bool {
+	return CheckPassword(password) == nil
+}
+
 func encryptPassword(password string) (string, error) {
 	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(password), config.BCryptCost)
 	if err != nil {
@@ -0,0 +1,126 @@
+package webhookmodel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const WebhookCollectionName = "webhook"
+
+type WebhookModel struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+func New(db *mongo.Database) *WebhookModel {
+	return &WebhookModel{
+		db:         db,
+		collection: db.Collection(WebhookCollectionName),
+	}
+}
+
+type EventType = string
+
+const (
+	FeatureFlagToggle  EventType = "feature_flag.toggle"
+	RevisionApproved   EventType = "revision.approved"
+	FeatureFlagDeleted EventType = "feature_flag.deleted"
+)
+
+func IsValidEventType(event string) bool {
+	switch event {
+	case FeatureFlagToggle, RevisionApproved, FeatureFlagDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// WebhookRecord subscribes a URL to events of an organization. Deliveries
+// are signed with Secret, which is only shown once when the webhook is
+// registered.
+type WebhookRecord struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	UserID         primitive.ObjectID `json:"user_id" bson:"user_id"`
+	URL            string             `json:"url" bson:"url"`
+	Events         []EventType        `json:"events" bson:"events"`
+	Secret         string             `json:"-" bson:"secret"`
+	CreatedAt      primitive.DateTime `json:"created_at" bson:"created_at"`
+}
+
+func NewWebhookRecord(
+	organizationID,
+	userID primitive.ObjectID,
+	url string,
+	events []EventType,
+) (*WebhookRecord, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &WebhookRecord{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		URL:            url,
+		Events:         events,
+		Secret:         hex.EncodeToString(secret),
+		CreatedAt:      primitive.NewDateTimeFromTime(time.Now().UTC()),
+	}, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload keyed with the
+// webhook secret
+func (wr *WebhookRecord) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(wr.Secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wm *WebhookModel) InsertOne(ctx context.Context, record *WebhookRecord) (primitive.ObjectID, error) {
+	record.ID = primitive.NewObjectID()
+	result, err := wm.collection.InsertOne(ctx, record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	objectID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, errors.New("unable to assert type of objectID")
+	}
+
+	return objectID, nil
+}
+
+// FindByEvent returns the organization's webhooks subscribed to the event
+func (wm *WebhookModel) FindByEvent(
+	ctx context.Context,
+	organizationID primitive.ObjectID,
+	event EventType,
+) ([]WebhookRecord, error) {
+	records := make([]WebhookRecord, 0)
+	cursor, err := wm.collection.Find(ctx, bson.D{
+		{Key: "organization_id", Value: organizationID},
+		{Key: "events", Value: event},
+	})
+	if err != nil {
+		return records, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &records); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
@@ -5,6 +5,12 @@ import (
 	"os"
 	"sync"
 
+	emailchangemodel "github.com/Roll-Play/togglelabs/pkg/models/email_change"
+	featureflagmodel "github.com/Roll-Play/togglelabs/pkg/models/feature_flag"
+	idempotencykeymodel "github.com/Roll-Play/togglelabs/pkg/models/idempotency_key"
+	passwordresetmodel "github.com/Roll-Play/togglelabs/pkg/models/password_reset"
+	refreshtokenmodel "github.com/Roll-Play/togglelabs/pkg/models/refresh_token"
+	singleusetokenmodel "github.com/Roll-Play/togglelabs/pkg/models/single_use_token"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -15,6 +21,11 @@ type MongoStorage struct {
 	init bool
 }
 
+type collectionIndex struct {
+	collection string
+	opts       mongo.IndexModel
+}
+
 var lock = &sync.Mutex{}
 var store *MongoStorage
 
@@ -40,10 +51,7 @@ func (ms *MongoStorage) Init() error {
 	}
 
 	ms.init = true
-	indexes := []struct {
-		collection string
-		opts       mongo.IndexModel
-	}{
+	indexes := []collectionIndex{
 		{
 			collection: "user",
 			opts: mongo.IndexModel{
@@ -51,12 +59,96 @@ func (ms *MongoStorage) Init() error {
 				Options: options.Index().SetUnique(true),
 			},
 		},
+		{
+			// Emails are stored normalized, this index also keeps the ones
+			// stored before that from colliding by case alone
+			collection: "user",
+			opts: mongo.IndexModel{
+				Keys: bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().
+					SetName("email_normalized").
+					SetUnique(true).
+					SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+			},
+		},
 		{
 			collection: "organization",
 			opts: mongo.IndexModel{
 				Keys: bson.D{{Key: "members.user._id", Value: 1}},
 			},
 		},
+		{
+			// deleted_at is part of the key so soft deleted flags, which
+			// each carry a distinct deletion time, don't hold on to their name
+			collection: "feature_flag",
+			opts: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "organization_id", Value: 1},
+					{Key: "name", Value: 1},
+					{Key: "environments.name", Value: 1},
+					{Key: "deleted_at", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			collection: featureflagmodel.FeatureFlagCollectionName,
+			opts:       featureflagmodel.SearchIndex,
+		},
+		{
+			collection: "webhook",
+			opts: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "organization_id", Value: 1},
+					{Key: "events", Value: 1},
+				},
+			},
+		},
+		{
+			collection: "token_denylist",
+			opts: mongo.IndexModel{
+				Keys: bson.D{{Key: "token_id", Value: 1}},
+			},
+		},
+		{
+			// Denylisted tokens are dropped once they would have expired
+			collection: "token_denylist",
+			opts: mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		{
+			// A key is only ever used once per organization, so concurrent
+			// retries can't both create a flag
+			collection: idempotencykeymodel.IdempotencyKeyCollectionName,
+			opts: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "organization_id", Value: 1},
+					{Key: "key", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			collection: idempotencykeymodel.IdempotencyKeyCollectionName,
+			opts: mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+	}
+
+	for _, collection := range []string{
+		passwordresetmodel.PasswordResetCollectionName,
+		emailchangemodel.EmailChangeCollectionName,
+		refreshtokenmodel.RefreshTokenCollectionName,
+	} {
+		indexes = append(
+			indexes,
+			collectionIndex{collection: collection, opts: singleusetokenmodel.HashIndex},
+			collectionIndex{collection: collection, opts: singleusetokenmodel.ExpiryIndex},
+		)
 	}
 
 	for _, index := range indexes {
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	apierrors "github.com/Roll-Play/togglelabs/pkg/api/error"
+	"github.com/Roll-Play/togglelabs/pkg/config"
 	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -39,20 +42,24 @@ func (c *OAuthClientProduction) Exchange(ctx context.Context, code string) (*oau
 	return c.config.Exchange(ctx, code)
 }
 
+// GetPaginationParams parses the page and page_size query params. Missing,
+// malformed or non-positive values fall back to the defaults and page sizes
+// above config.MaxPageSize are clamped to it, so the page size to report
+// back is always the returned one.
 func GetPaginationParams(page, limit string) (int, int) {
-	if page == "" {
-		page = "1"
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil || pageNumber < 1 {
+		pageNumber = 1
 	}
 
-	if limit == "" {
-		limit = "10"
+	limitNumber, err := strconv.Atoi(limit)
+	if err != nil || limitNumber < 1 {
+		limitNumber = config.DefaultPageSize
 	}
 
-	// Atoi can return an error if the string size is < 1
-	// but because we check it before converting it'll probably
-	// never happen
-	pageNumber, _ := strconv.Atoi(page)
-	limitNumber, _ := strconv.Atoi(limit)
+	if limitNumber > config.MaxPageSize {
+		limitNumber = config.MaxPageSize
+	}
 
 	return pageNumber, limitNumber
 }
@@ -81,6 +88,48 @@ func GetUserFromContext(c echo.Context) (primitive.ObjectID, error) {
 	return userID, nil
 }
 
+// APIKeyContextKey flags requests authenticated with an organization API key
+// rather than a user token
+const APIKeyContextKey = "api_key"
+
+func IsAPIKeyRequest(c echo.Context) bool {
+	isAPIKey, _ := c.Get(APIKeyContextKey).(bool)
+	return isAPIKey
+}
+
+const (
+	// RequestIDContextKey holds the X-Request-ID of the request, which log
+	// lines carry under RequestIDLogField
+	RequestIDContextKey = "request_id"
+	RequestIDLogField   = "request_id"
+)
+
+func GetRequestIDFromContext(c echo.Context) string {
+	requestID, _ := c.Get(RequestIDContextKey).(string)
+	return requestID
+}
+
+const (
+	TokenIDContextKey        = "token_id"
+	TokenExpiresAtContextKey = "token_expires_at"
+)
+
+// GetTokenFromContext returns the jti and expiration of the JWT the request
+// was authenticated with
+func GetTokenFromContext(c echo.Context) (string, time.Time, error) {
+	tokenID, ok := c.Get(TokenIDContextKey).(string)
+	if !ok || tokenID == "" {
+		return "", time.Time{}, ErrNotAuthenticated
+	}
+
+	expiresAt, ok := c.Get(TokenExpiresAtContextKey).(time.Time)
+	if !ok {
+		return "", time.Time{}, ErrNotAuthenticated
+	}
+
+	return tokenID, expiresAt, nil
+}
+
 func GetOrganizationFromContext(c echo.Context) (primitive.ObjectID, error) {
 	ctxOrganization := c.Get("organization")
 	if ctxOrganization == nil {
@@ -120,25 +169,26 @@ func HandlerLogMessage(resource string, id primitive.ObjectID, c echo.Context) s
 	)
 }
 
+// PermissionDenied responds to a user who is authenticated but whose
+// permission level falls short. 401 is reserved for requests missing valid
+// credentials.
+func PermissionDenied(c echo.Context) error {
+	return apierrors.CustomError(
+		c,
+		http.StatusForbidden,
+		apierrors.ForbiddenError,
+	)
+}
+
 func UserHasPermission(
 	userID primitive.ObjectID,
 	organization *organizationmodel.OrganizationRecord,
 	permission organizationmodel.PermissionLevelEnum,
 ) bool {
-	for _, member := range organization.Members {
-		if member.User.ID == userID {
-			switch permission {
-			case organizationmodel.Admin:
-				return member.PermissionLevel == permission
-			case organizationmodel.Collaborator:
-				return member.PermissionLevel == permission || member.PermissionLevel == organizationmodel.Admin
-			case organizationmodel.ReadOnly:
-				return member.PermissionLevel == permission ||
-					member.PermissionLevel == organizationmodel.Collaborator ||
-					member.PermissionLevel == organizationmodel.Admin
-			}
-		}
+	member := organization.FindMember(userID)
+	if member == nil {
+		return false
 	}
 
-	return false
+	return organizationmodel.HasPermission(member.PermissionLevel, permission)
 }
@@ -0,0 +1,68 @@
+package apiutils_test
+
+import (
+	"testing"
+
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	organizationmodel "github.com/Roll-Play/togglelabs/pkg/models/organization"
+	usermodel "github.com/Roll-Play/togglelabs/pkg/models/user"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetPaginationParams(t *testing.T) {
+	testCases := []struct {
+		name     string
+		page     string
+		limit    string
+		expected [2]int
+	}{
+		{"defaults", "", "", [2]int{1, config.DefaultPageSize}},
+		{"valid", "3", "25", [2]int{3, 25}},
+		{"oversized", "1", "1000000", [2]int{1, config.MaxPageSize}},
+		{"max", "1", "100", [2]int{1, config.MaxPageSize}},
+		{"zero", "0", "0", [2]int{1, config.DefaultPageSize}},
+		{"negative", "-2", "-10", [2]int{1, config.DefaultPageSize}},
+		{"non numeric", "first", "lots", [2]int{1, config.DefaultPageSize}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, limit := apiutils.GetPaginationParams(tc.page, tc.limit)
+			assert.Equal(t, tc.expected, [2]int{page, limit})
+		})
+	}
+}
+
+func TestUserHasPermission(t *testing.T) {
+	levels := []organizationmodel.PermissionLevelEnum{
+		organizationmodel.ReadOnly,
+		organizationmodel.Collaborator,
+		organizationmodel.Admin,
+		organizationmodel.Owner,
+	}
+
+	for held, level := range levels {
+		userID := primitive.NewObjectID()
+		organization := &organizationmodel.OrganizationRecord{
+			Members: []organizationmodel.OrganizationMember{{
+				User:            usermodel.UserRecord{ID: userID},
+				PermissionLevel: level,
+			}},
+		}
+
+		for required, requiredLevel := range levels {
+			assert.Equal(
+				t,
+				held >= required,
+				apiutils.UserHasPermission(userID, organization, requiredLevel),
+				"%s checked against %s",
+				level,
+				requiredLevel,
+			)
+		}
+
+		assert.False(t, apiutils.UserHasPermission(primitive.NewObjectID(), organization, organizationmodel.ReadOnly))
+	}
+}
@@ -0,0 +1,60 @@
+package apiutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// ETag derives a strong entity tag from the body served, so it changes
+// along with anything the response carries
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// VersionedETag leads the entity tag with the version of the resource, so
+// it can be sent back in If-Match by writes conditioned on the version
+func VersionedETag(version int, body []byte) string {
+	return fmt.Sprintf(`"%d-%s`, version, strings.TrimPrefix(ETag(body), `"`))
+}
+
+// ETagMatches reports whether the If-None-Match header of the request lists
+// etag. Weak tags match their strong counterpart, as comparing them for a
+// GET is meant to be weak.
+func ETagMatches(c echo.Context, etag string) bool {
+	header := c.Request().Header.Get(HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BlobWithETag serves body along with etag, or only a 304 when the client
+// already holds it
+func BlobWithETag(c echo.Context, etag string, code int, contentType string, body []byte) error {
+	c.Response().Header().Set(HeaderETag, etag)
+
+	if ETagMatches(c, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(code, contentType, body)
+}
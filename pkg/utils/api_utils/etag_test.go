@@ -0,0 +1,50 @@
+package apiutils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := apiutils.ETag([]byte(`{"name":"cool feature"}`))
+
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		expected    bool
+	}{
+		{"no header", "", false},
+		{"same tag", etag, true},
+		{"weak tag", "W/" + etag, true},
+		{"listed tag", `"stale", ` + etag, true},
+		{"any tag", "*", true},
+		{"other tag", apiutils.ETag([]byte(`{"name":"other feature"}`)), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.ifNoneMatch != "" {
+				request.Header.Set(apiutils.HeaderIfNoneMatch, tc.ifNoneMatch)
+			}
+			c := echo.New().NewContext(request, httptest.NewRecorder())
+
+			assert.Equal(t, tc.expected, apiutils.ETagMatches(c, etag))
+		})
+	}
+}
+
+func TestVersionedETag(t *testing.T) {
+	body := []byte(`{"name":"cool feature"}`)
+
+	etag := apiutils.VersionedETag(3, body)
+	assert.True(t, strings.HasPrefix(etag, `"3-`))
+	assert.NotEqual(t, etag, apiutils.VersionedETag(4, body))
+	assert.NotEqual(t, etag, apiutils.VersionedETag(3, []byte(`{"name":"other feature"}`)))
+}
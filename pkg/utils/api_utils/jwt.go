@@ -1,25 +1,30 @@
 package apiutils
 
 import (
-	"os"
+	"errors"
 	"time"
 
+	"github.com/Roll-Play/togglelabs/pkg/config"
 	"github.com/golang-jwt/jwt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var ErrInvalidSignMethod = errors.New("invalid signing method")
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// CreateJWT signs the token with the current signing key, named by the kid
+// header so it can still be verified once the key is retired
 func CreateJWT(id primitive.ObjectID, expireAt time.Duration) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss": "togglelabs",
 		"sub": id.Hex(),
+		"jti": primitive.NewObjectID().Hex(),
 		"exp": time.Now().Add(expireAt * time.Millisecond).Unix(),
 	})
 
-	key := os.Getenv("JWT_SECRET")
-	if key == "" {
-		key = "your-secret-key"
-	}
-	signedToken, err := token.SignedString([]byte(key))
+	key := config.JWTSigningKeys[0]
+	token.Header["kid"] = key.ID
+	signedToken, err := token.SignedString([]byte(key.Secret))
 
 	if err != nil {
 		return "", err
@@ -27,3 +32,27 @@ func CreateJWT(id primitive.ObjectID, expireAt time.Duration) (string, error) {
 
 	return signedToken, nil
 }
+
+// ParseJWT verifies the token with the signing key its kid header names.
+// Tokens without a kid predate key rotation and are checked against the
+// current key.
+func ParseJWT(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidSignMethod
+		}
+
+		kid, ok := token.Header["kid"]
+		if !ok {
+			return []byte(config.JWTSigningKeys[0].Secret), nil
+		}
+
+		for _, key := range config.JWTSigningKeys {
+			if key.ID == kid {
+				return []byte(key.Secret), nil
+			}
+		}
+
+		return nil, ErrUnknownSigningKey
+	})
+}
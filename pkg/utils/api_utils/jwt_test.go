@@ -0,0 +1,63 @@
+package apiutils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Roll-Play/togglelabs/pkg/config"
+	apiutils "github.com/Roll-Play/togglelabs/pkg/utils/api_utils"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func withSigningKeys(t *testing.T, keys ...config.JWTSigningKey) {
+	previous := config.JWTSigningKeys
+	config.JWTSigningKeys = keys
+	t.Cleanup(func() {
+		config.JWTSigningKeys = previous
+	})
+}
+
+func TestParseJWTSignedByRetiredKey(t *testing.T) {
+	oldKey := config.JWTSigningKey{ID: "old", Secret: "old-secret"}
+	newKey := config.JWTSigningKey{ID: "new", Secret: "new-secret"}
+
+	withSigningKeys(t, oldKey)
+	token, err := apiutils.CreateJWT(primitive.NewObjectID(), time.Second*120)
+	assert.NoError(t, err)
+
+	withSigningKeys(t, newKey, oldKey)
+	parsedToken, err := apiutils.ParseJWT(token)
+	assert.NoError(t, err)
+	assert.True(t, parsedToken.Valid)
+	assert.Equal(t, "old", parsedToken.Header["kid"])
+
+	token, err = apiutils.CreateJWT(primitive.NewObjectID(), time.Second*120)
+	assert.NoError(t, err)
+	parsedToken, err = apiutils.ParseJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", parsedToken.Header["kid"])
+}
+
+func TestParseJWTSignedByUnknownKey(t *testing.T) {
+	withSigningKeys(t, config.JWTSigningKey{ID: "unknown", Secret: "unknown-secret"})
+	token, err := apiutils.CreateJWT(primitive.NewObjectID(), time.Second*120)
+	assert.NoError(t, err)
+
+	withSigningKeys(t, config.JWTSigningKey{ID: "current", Secret: "current-secret"})
+	_, err = apiutils.ParseJWT(token)
+	var validationError *jwt.ValidationError
+	assert.ErrorAs(t, err, &validationError)
+	assert.Equal(t, apiutils.ErrUnknownSigningKey, validationError.Inner)
+}
+
+func TestParseJWTRejectsReusedKeyID(t *testing.T) {
+	withSigningKeys(t, config.JWTSigningKey{ID: "current", Secret: "forged-secret"})
+	token, err := apiutils.CreateJWT(primitive.NewObjectID(), time.Second*120)
+	assert.NoError(t, err)
+
+	withSigningKeys(t, config.JWTSigningKey{ID: "current", Secret: "current-secret"})
+	_, err = apiutils.ParseJWT(token)
+	assert.Error(t, err)
+}